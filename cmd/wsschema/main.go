@@ -0,0 +1,32 @@
+// Command wsschema writes the JSON Schema for the WebSocket protocol.
+//
+//	go run ./cmd/wsschema -o docs/websocket-protocol.schema.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"dnd-simulator/internal/models"
+)
+
+func main() {
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	schema, err := json.MarshalIndent(models.ProtocolSchema(), "", "  ")
+	if err != nil {
+		log.Fatal("Failed to encode schema:", err)
+	}
+	schema = append(schema, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*out, schema, 0644); err != nil {
+		log.Fatal("Failed to write schema:", err)
+	}
+}
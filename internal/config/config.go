@@ -1,7 +1,11 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -10,6 +14,46 @@ type Config struct {
 	DatabaseName string
 	JWTSecret   string
 	GeminiAPIKey string
+	GeminiModel string
+	OpenAIBaseURL string // OpenAI-compatible chat completions API, e.g. http://localhost:11434/v1 for Ollama
+	OpenAIAPIKey string
+	OpenAIModel string
+	AIMockEnabled bool   // Offer the scripted mock provider, for development
+	AIProvider  string   // Provider for campaigns that don't pick one
+	AIModel     string   // Model for the default provider; empty for its own default
+	HubBroker   string // "memory" for a single instance, "mongo" to share the hub across replicas
+	WSLimits    WSLimits // Flood protection for each WebSocket connection
+	ShutdownTimeout time.Duration // How long to drain connections and requests before exiting
+}
+
+// RateLimit is a token bucket: Rate messages a second on average, up to Burst at once
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// WSLimits bounds what a single WebSocket connection may send. A message over a rate limit
+// is rejected and counts as a violation; MuteAfter violations close together mute the
+// connection for MuteDuration, and the MaxMutes-th time it would be muted it is closed.
+type WSLimits struct {
+	MaxMessageBytes int64
+	Messages        RateLimit            // Every message from the connection
+	PerType         map[string]RateLimit // Stricter limits by message type; chat variants share "chat"
+	MuteAfter       int
+	MuteDuration    time.Duration
+	MaxMutes        int
+}
+
+// defaultTypeRateLimits are the per-type limits unless WS_TYPE_RATE_LIMITS overrides them
+var defaultTypeRateLimits = map[string]RateLimit{
+	"chat":             {Rate: 1, Burst: 5},
+	"dice_roll":        {Rate: 1, Burst: 5},
+	"character_update": {Rate: 2, Burst: 10},
+	"typing":           {Rate: 1, Burst: 4},
+	"presence":         {Rate: 0.5, Burst: 4},
+	"ready_check":      {Rate: 0.1, Burst: 2},
+	"ready_response":   {Rate: 0.5, Burst: 3},
+	"resume":           {Rate: 0.2, Burst: 3},
 }
 
 func Load() *Config {
@@ -19,6 +63,23 @@ func Load() *Config {
 		DatabaseName: getEnv("DATABASE_NAME", "dnd_simulator"),
 		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 		GeminiAPIKey: getEnv("GEMINI_API_KEY", ""),
+		GeminiModel: getEnv("GEMINI_MODEL", "gemini-2.5-pro-preview-05-06"),
+		OpenAIBaseURL: getEnv("OPENAI_BASE_URL", ""),
+		OpenAIAPIKey: getEnv("OPENAI_API_KEY", ""),
+		OpenAIModel: getEnv("OPENAI_MODEL", "gpt-4o-mini"),
+		AIMockEnabled: getEnv("AI_MOCK_ENABLED", "false") == "true",
+		AIProvider:  getEnv("AI_PROVIDER", "gemini"),
+		AIModel:     getEnv("AI_MODEL", ""),
+		HubBroker:   getEnv("HUB_BROKER", "memory"),
+		WSLimits: WSLimits{
+			MaxMessageBytes: int64(getEnvInt("WS_MAX_MESSAGE_BYTES", 16384)),
+			Messages:        getEnvRateLimit("WS_RATE_LIMIT", RateLimit{Rate: 10, Burst: 20}),
+			PerType:         getEnvTypeRateLimits("WS_TYPE_RATE_LIMITS"),
+			MuteAfter:       getEnvInt("WS_MUTE_AFTER", 5),
+			MuteDuration:    time.Duration(getEnvInt("WS_MUTE_SECONDS", 60)) * time.Second,
+			MaxMutes:        getEnvInt("WS_MAX_MUTES", 3),
+		},
+		ShutdownTimeout: time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second,
 	}
 }
 
@@ -27,4 +88,74 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getEnvInt reads a positive integer, falling back to the default if it is unset or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Ignoring invalid %s %q", key, value)
+		return defaultValue
+	}
+	return n
+}
+
+// parseRateLimit parses "rate:burst", e.g. "0.5:3"
+func parseRateLimit(value string) (RateLimit, bool) {
+	rate, burst, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		return RateLimit{}, false
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r <= 0 {
+		return RateLimit{}, false
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b <= 0 {
+		return RateLimit{}, false
+	}
+	return RateLimit{Rate: r, Burst: b}, true
+}
+
+// getEnvRateLimit reads a "rate:burst" limit, falling back to the default if it is unset
+// or invalid
+func getEnvRateLimit(key string, defaultValue RateLimit) RateLimit {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	limit, ok := parseRateLimit(value)
+	if !ok {
+		log.Printf("Ignoring invalid %s %q", key, value)
+		return defaultValue
+	}
+	return limit
+}
+
+// getEnvTypeRateLimits reads per-type limits as "type=rate:burst,...", overriding the
+// defaults for the types it lists
+func getEnvTypeRateLimits(key string) map[string]RateLimit {
+	limits := make(map[string]RateLimit, len(defaultTypeRateLimits))
+	for messageType, limit := range defaultTypeRateLimits {
+		limits[messageType] = limit
+	}
+
+	value := os.Getenv(key)
+	if value == "" {
+		return limits
+	}
+	for _, entry := range strings.Split(value, ",") {
+		messageType, rate, found := strings.Cut(entry, "=")
+		limit, ok := parseRateLimit(rate)
+		if !found || !ok {
+			log.Printf("Ignoring invalid %s entry %q", key, entry)
+			continue
+		}
+		limits[strings.TrimSpace(messageType)] = limit
+	}
+	return limits
+}
@@ -0,0 +1,44 @@
+package data
+
+import "dnd-simulator/internal/models"
+
+// DMG per-character XP thresholds by character level
+var XPThresholds = map[int]models.XPThreshold{
+	1:  {Easy: 25, Medium: 50, Hard: 75, Deadly: 100},
+	2:  {Easy: 50, Medium: 100, Hard: 150, Deadly: 200},
+	3:  {Easy: 75, Medium: 150, Hard: 225, Deadly: 400},
+	4:  {Easy: 125, Medium: 250, Hard: 375, Deadly: 500},
+	5:  {Easy: 250, Medium: 500, Hard: 750, Deadly: 1100},
+	6:  {Easy: 300, Medium: 600, Hard: 900, Deadly: 1400},
+	7:  {Easy: 350, Medium: 750, Hard: 1100, Deadly: 1700},
+	8:  {Easy: 450, Medium: 900, Hard: 1400, Deadly: 2100},
+	9:  {Easy: 550, Medium: 1100, Hard: 1600, Deadly: 2400},
+	10: {Easy: 600, Medium: 1200, Hard: 1900, Deadly: 2800},
+	11: {Easy: 800, Medium: 1600, Hard: 2400, Deadly: 3600},
+	12: {Easy: 1000, Medium: 2000, Hard: 3000, Deadly: 4500},
+	13: {Easy: 1100, Medium: 2200, Hard: 3400, Deadly: 5100},
+	14: {Easy: 1250, Medium: 2500, Hard: 3800, Deadly: 5700},
+	15: {Easy: 1400, Medium: 2800, Hard: 4300, Deadly: 6400},
+	16: {Easy: 1600, Medium: 3200, Hard: 4800, Deadly: 7200},
+	17: {Easy: 2000, Medium: 3900, Hard: 5900, Deadly: 8800},
+	18: {Easy: 2100, Medium: 4200, Hard: 6300, Deadly: 9500},
+	19: {Easy: 2400, Medium: 4900, Hard: 7300, Deadly: 10900},
+	20: {Easy: 2800, Medium: 5700, Hard: 8500, Deadly: 12700},
+}
+
+// DMG encounter multipliers, ordered from smallest to largest group.
+// The first and last entries are only reached through the party-size
+// adjustment (large parties step down, small parties step up).
+var EncounterMultipliers = []struct {
+	MinMonsters int
+	Multiplier  float64
+}{
+	{MinMonsters: 0, Multiplier: 0.5},
+	{MinMonsters: 1, Multiplier: 1},
+	{MinMonsters: 2, Multiplier: 1.5},
+	{MinMonsters: 3, Multiplier: 2},
+	{MinMonsters: 7, Multiplier: 2.5},
+	{MinMonsters: 11, Multiplier: 3},
+	{MinMonsters: 15, Multiplier: 4},
+	{MinMonsters: -1, Multiplier: 5},
+}
@@ -0,0 +1,629 @@
+package data
+
+import "dnd-simulator/internal/models"
+
+// D&D 5e SRD monster data, keyed by slug
+var Monsters = map[string]models.Monster{
+	"giant-rat": {
+		Slug:              "giant-rat",
+		Name:              "Giant Rat",
+		Size:              "Small",
+		Type:              "beast",
+		Alignment:         "unaligned",
+		ArmorClass:        12,
+		HitPoints:         7,
+		HitDice:           "2d6",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 7, Dexterity: 15, Constitution: 11, Intelligence: 2, Wisdom: 10, Charisma: 4},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 10,
+		Languages:         []string{},
+		ChallengeRating:   0.125,
+		XP:                25,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Keen Smell", Description: "The rat has advantage on Wisdom (Perception) checks that rely on smell."},
+			{Name: "Pack Tactics", Description: "The rat has advantage on an attack roll against a creature if at least one of the rat's allies is within 5 feet of the creature and the ally isn't incapacitated."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Bite", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one target.", AttackBonus: 4, Reach: 5, DamageDice: "1d4+2", DamageType: "piercing"},
+		},
+	},
+	"kobold": {
+		Slug:              "kobold",
+		Name:              "Kobold",
+		Size:              "Small",
+		Type:              "humanoid (kobold)",
+		Alignment:         "lawful evil",
+		ArmorClass:        12,
+		HitPoints:         5,
+		HitDice:           "2d6-2",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 7, Dexterity: 15, Constitution: 9, Intelligence: 8, Wisdom: 7, Charisma: 8},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 8,
+		Languages:         []string{"Common", "Draconic"},
+		ChallengeRating:   0.125,
+		XP:                25,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Sunlight Sensitivity", Description: "While in sunlight, the kobold has disadvantage on attack rolls, as well as on Wisdom (Perception) checks that rely on sight."},
+			{Name: "Pack Tactics", Description: "The kobold has advantage on an attack roll against a creature if at least one of the kobold's allies is within 5 feet of the creature and the ally isn't incapacitated."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Dagger", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one target.", AttackBonus: 4, Reach: 5, DamageDice: "1d4+2", DamageType: "piercing"},
+			{Name: "Sling", Description: "Ranged Weapon Attack: +4 to hit, range 30/120 ft., one target.", AttackBonus: 4, Range: "30/120", DamageDice: "1d4+2", DamageType: "bludgeoning"},
+		},
+	},
+	"bandit": {
+		Slug:              "bandit",
+		Name:              "Bandit",
+		Size:              "Medium",
+		Type:              "humanoid (any race)",
+		Alignment:         "any non-lawful alignment",
+		ArmorClass:        12,
+		ArmorDesc:         "leather armor",
+		HitPoints:         11,
+		HitDice:           "2d8+2",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 11, Dexterity: 12, Constitution: 12, Intelligence: 10, Wisdom: 10, Charisma: 10},
+		PassivePerception: 10,
+		Languages:         []string{"Any one language (usually Common)"},
+		ChallengeRating:   0.125,
+		XP:                25,
+		Actions: []models.MonsterAction{
+			{Name: "Scimitar", Description: "Melee Weapon Attack: +3 to hit, reach 5 ft., one target.", AttackBonus: 3, Reach: 5, DamageDice: "1d6+1", DamageType: "slashing"},
+			{Name: "Light Crossbow", Description: "Ranged Weapon Attack: +3 to hit, range 80/320 ft., one target.", AttackBonus: 3, Range: "80/320", DamageDice: "1d8+1", DamageType: "piercing"},
+		},
+	},
+	"cultist": {
+		Slug:              "cultist",
+		Name:              "Cultist",
+		Size:              "Medium",
+		Type:              "humanoid (any race)",
+		Alignment:         "any non-good alignment",
+		ArmorClass:        12,
+		ArmorDesc:         "leather armor",
+		HitPoints:         9,
+		HitDice:           "2d8",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 11, Dexterity: 12, Constitution: 10, Intelligence: 10, Wisdom: 11, Charisma: 10},
+		Skills:            map[string]int{"Deception": 2, "Religion": 2},
+		PassivePerception: 10,
+		Languages:         []string{"Any one language (usually Common)"},
+		ChallengeRating:   0.125,
+		XP:                25,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Dark Devotion", Description: "The cultist has advantage on saving throws against being charmed or frightened."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Scimitar", Description: "Melee Weapon Attack: +3 to hit, reach 5 ft., one creature.", AttackBonus: 3, Reach: 5, DamageDice: "1d6+1", DamageType: "slashing"},
+		},
+	},
+	"guard": {
+		Slug:              "guard",
+		Name:              "Guard",
+		Size:              "Medium",
+		Type:              "humanoid (any race)",
+		Alignment:         "any alignment",
+		ArmorClass:        16,
+		ArmorDesc:         "chain shirt, shield",
+		HitPoints:         11,
+		HitDice:           "2d8+2",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 13, Dexterity: 12, Constitution: 12, Intelligence: 10, Wisdom: 11, Charisma: 10},
+		Skills:            map[string]int{"Perception": 2},
+		PassivePerception: 12,
+		Languages:         []string{"Any one language (usually Common)"},
+		ChallengeRating:   0.125,
+		XP:                25,
+		Actions: []models.MonsterAction{
+			{Name: "Spear", Description: "Melee or Ranged Weapon Attack: +3 to hit, reach 5 ft. or range 20/60 ft., one target.", AttackBonus: 3, Reach: 5, Range: "20/60", DamageDice: "1d6+1", DamageType: "piercing"},
+		},
+	},
+	"goblin": {
+		Slug:              "goblin",
+		Name:              "Goblin",
+		Size:              "Small",
+		Type:              "humanoid (goblinoid)",
+		Alignment:         "neutral evil",
+		ArmorClass:        15,
+		ArmorDesc:         "leather armor, shield",
+		HitPoints:         7,
+		HitDice:           "2d6",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 8, Dexterity: 14, Constitution: 10, Intelligence: 10, Wisdom: 8, Charisma: 8},
+		Skills:            map[string]int{"Stealth": 6},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 9,
+		Languages:         []string{"Common", "Goblin"},
+		ChallengeRating:   0.25,
+		XP:                50,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Nimble Escape", Description: "The goblin can take the Disengage or Hide action as a bonus action on each of its turns."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Scimitar", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one target.", AttackBonus: 4, Reach: 5, DamageDice: "1d6+2", DamageType: "slashing"},
+			{Name: "Shortbow", Description: "Ranged Weapon Attack: +4 to hit, range 80/320 ft., one target.", AttackBonus: 4, Range: "80/320", DamageDice: "1d6+2", DamageType: "piercing"},
+		},
+	},
+	"skeleton": {
+		Slug:                  "skeleton",
+		Name:                  "Skeleton",
+		Size:                  "Medium",
+		Type:                  "undead",
+		Alignment:             "lawful evil",
+		ArmorClass:            13,
+		ArmorDesc:             "armor scraps",
+		HitPoints:             13,
+		HitDice:               "2d8+4",
+		Speed:                 map[string]int{"walk": 30},
+		Abilities:             models.AbilityScores{Strength: 10, Dexterity: 14, Constitution: 15, Intelligence: 6, Wisdom: 8, Charisma: 5},
+		Senses:                map[string]int{"darkvision": 60},
+		PassivePerception:     9,
+		Languages:             []string{"Understands all languages it knew in life but can't speak"},
+		ChallengeRating:       0.25,
+		XP:                    50,
+		DamageVulnerabilities: []string{"bludgeoning"},
+		DamageImmunities:      []string{"poison"},
+		ConditionImmunities:   []string{"exhaustion", "poisoned"},
+		Actions: []models.MonsterAction{
+			{Name: "Shortsword", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one target.", AttackBonus: 4, Reach: 5, DamageDice: "1d6+2", DamageType: "piercing"},
+			{Name: "Shortbow", Description: "Ranged Weapon Attack: +4 to hit, range 80/320 ft., one target.", AttackBonus: 4, Range: "80/320", DamageDice: "1d6+2", DamageType: "piercing"},
+		},
+	},
+	"zombie": {
+		Slug:                "zombie",
+		Name:                "Zombie",
+		Size:                "Medium",
+		Type:                "undead",
+		Alignment:           "neutral evil",
+		ArmorClass:          8,
+		HitPoints:           22,
+		HitDice:             "3d8+9",
+		Speed:               map[string]int{"walk": 20},
+		Abilities:           models.AbilityScores{Strength: 13, Dexterity: 6, Constitution: 16, Intelligence: 3, Wisdom: 6, Charisma: 5},
+		SavingThrows:        map[string]int{"wisdom": 0},
+		Senses:              map[string]int{"darkvision": 60},
+		PassivePerception:   8,
+		Languages:           []string{"Understands the languages it knew in life but can't speak"},
+		ChallengeRating:     0.25,
+		XP:                  50,
+		DamageImmunities:    []string{"poison"},
+		ConditionImmunities: []string{"poisoned"},
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Undead Fortitude", Description: "If damage reduces the zombie to 0 hit points, it must make a Constitution saving throw with a DC of 5 + the damage taken, unless the damage is radiant or from a critical hit. On a success, the zombie drops to 1 hit point instead."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Slam", Description: "Melee Weapon Attack: +3 to hit, reach 5 ft., one target.", AttackBonus: 3, Reach: 5, DamageDice: "1d6+1", DamageType: "bludgeoning"},
+		},
+	},
+	"wolf": {
+		Slug:              "wolf",
+		Name:              "Wolf",
+		Size:              "Medium",
+		Type:              "beast",
+		Alignment:         "unaligned",
+		ArmorClass:        13,
+		ArmorDesc:         "natural armor",
+		HitPoints:         11,
+		HitDice:           "2d8+2",
+		Speed:             map[string]int{"walk": 40},
+		Abilities:         models.AbilityScores{Strength: 12, Dexterity: 15, Constitution: 12, Intelligence: 3, Wisdom: 12, Charisma: 6},
+		Skills:            map[string]int{"Perception": 3, "Stealth": 4},
+		PassivePerception: 13,
+		Languages:         []string{},
+		ChallengeRating:   0.25,
+		XP:                50,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Keen Hearing and Smell", Description: "The wolf has advantage on Wisdom (Perception) checks that rely on hearing or smell."},
+			{Name: "Pack Tactics", Description: "The wolf has advantage on an attack roll against a creature if at least one of the wolf's allies is within 5 feet of the creature and the ally isn't incapacitated."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Bite", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one target. If the target is a creature, it must succeed on a DC 11 Strength saving throw or be knocked prone.", AttackBonus: 4, Reach: 5, DamageDice: "2d4+2", DamageType: "piercing", SaveDC: 11, SaveAbility: "strength"},
+		},
+	},
+	"orc": {
+		Slug:              "orc",
+		Name:              "Orc",
+		Size:              "Medium",
+		Type:              "humanoid (orc)",
+		Alignment:         "chaotic evil",
+		ArmorClass:        13,
+		ArmorDesc:         "hide armor",
+		HitPoints:         15,
+		HitDice:           "2d8+6",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 16, Dexterity: 12, Constitution: 16, Intelligence: 7, Wisdom: 11, Charisma: 10},
+		Skills:            map[string]int{"Intimidation": 2},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 10,
+		Languages:         []string{"Common", "Orc"},
+		ChallengeRating:   0.5,
+		XP:                100,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Aggressive", Description: "As a bonus action, the orc can move up to its speed toward a hostile creature that it can see."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Greataxe", Description: "Melee Weapon Attack: +5 to hit, reach 5 ft., one target.", AttackBonus: 5, Reach: 5, DamageDice: "1d12+3", DamageType: "slashing"},
+			{Name: "Javelin", Description: "Melee or Ranged Weapon Attack: +5 to hit, reach 5 ft. or range 30/120 ft., one target.", AttackBonus: 5, Reach: 5, Range: "30/120", DamageDice: "1d6+3", DamageType: "piercing"},
+		},
+	},
+	"hobgoblin": {
+		Slug:              "hobgoblin",
+		Name:              "Hobgoblin",
+		Size:              "Medium",
+		Type:              "humanoid (goblinoid)",
+		Alignment:         "lawful evil",
+		ArmorClass:        18,
+		ArmorDesc:         "chain mail, shield",
+		HitPoints:         11,
+		HitDice:           "2d8+2",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 13, Dexterity: 12, Constitution: 12, Intelligence: 10, Wisdom: 10, Charisma: 9},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 10,
+		Languages:         []string{"Common", "Goblin"},
+		ChallengeRating:   0.5,
+		XP:                100,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Martial Advantage", Description: "Once per turn, the hobgoblin can deal an extra 7 (2d6) damage to a creature it hits with a weapon attack if that creature is within 5 feet of an ally of the hobgoblin that isn't incapacitated."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Longsword", Description: "Melee Weapon Attack: +3 to hit, reach 5 ft., one target.", AttackBonus: 3, Reach: 5, DamageDice: "1d8+1", DamageType: "slashing"},
+			{Name: "Longbow", Description: "Ranged Weapon Attack: +3 to hit, range 150/600 ft., one target.", AttackBonus: 3, Range: "150/600", DamageDice: "1d8+1", DamageType: "piercing"},
+		},
+	},
+	"gnoll": {
+		Slug:              "gnoll",
+		Name:              "Gnoll",
+		Size:              "Medium",
+		Type:              "humanoid (gnoll)",
+		Alignment:         "chaotic evil",
+		ArmorClass:        15,
+		ArmorDesc:         "hide armor, shield",
+		HitPoints:         22,
+		HitDice:           "5d8",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 14, Dexterity: 12, Constitution: 11, Intelligence: 6, Wisdom: 10, Charisma: 7},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 10,
+		Languages:         []string{"Gnoll"},
+		ChallengeRating:   0.5,
+		XP:                100,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Rampage", Description: "When the gnoll reduces a creature to 0 hit points with a melee attack on its turn, the gnoll can take a bonus action to move up to half its speed and make a bite attack."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Bite", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one creature.", AttackBonus: 4, Reach: 5, DamageDice: "1d4+2", DamageType: "piercing"},
+			{Name: "Spear", Description: "Melee or Ranged Weapon Attack: +4 to hit, reach 5 ft. or range 20/60 ft., one target.", AttackBonus: 4, Reach: 5, Range: "20/60", DamageDice: "1d6+2", DamageType: "piercing"},
+			{Name: "Longbow", Description: "Ranged Weapon Attack: +3 to hit, range 150/600 ft., one target.", AttackBonus: 3, Range: "150/600", DamageDice: "1d8+1", DamageType: "piercing"},
+		},
+	},
+	"bugbear": {
+		Slug:              "bugbear",
+		Name:              "Bugbear",
+		Size:              "Medium",
+		Type:              "humanoid (goblinoid)",
+		Alignment:         "chaotic evil",
+		ArmorClass:        16,
+		ArmorDesc:         "hide armor, shield",
+		HitPoints:         27,
+		HitDice:           "5d8+5",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 15, Dexterity: 14, Constitution: 13, Intelligence: 8, Wisdom: 11, Charisma: 9},
+		Skills:            map[string]int{"Stealth": 6, "Survival": 2},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 10,
+		Languages:         []string{"Common", "Goblin"},
+		ChallengeRating:   1,
+		XP:                200,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Brute", Description: "A melee weapon deals one extra die of its damage when the bugbear hits with it (included in the attack)."},
+			{Name: "Surprise Attack", Description: "If the bugbear surprises a creature and hits it with an attack during the first round of combat, the target takes an extra 7 (2d6) damage from the attack."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Morningstar", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one target.", AttackBonus: 4, Reach: 5, DamageDice: "2d8+2", DamageType: "piercing"},
+			{Name: "Javelin", Description: "Melee or Ranged Weapon Attack: +4 to hit, reach 5 ft. or range 30/120 ft., one target.", AttackBonus: 4, Reach: 5, Range: "30/120", DamageDice: "2d6+2", DamageType: "piercing"},
+		},
+	},
+	"dire-wolf": {
+		Slug:              "dire-wolf",
+		Name:              "Dire Wolf",
+		Size:              "Large",
+		Type:              "beast",
+		Alignment:         "unaligned",
+		ArmorClass:        14,
+		ArmorDesc:         "natural armor",
+		HitPoints:         37,
+		HitDice:           "5d10+10",
+		Speed:             map[string]int{"walk": 50},
+		Abilities:         models.AbilityScores{Strength: 17, Dexterity: 15, Constitution: 15, Intelligence: 3, Wisdom: 12, Charisma: 7},
+		Skills:            map[string]int{"Perception": 3, "Stealth": 4},
+		PassivePerception: 13,
+		Languages:         []string{},
+		ChallengeRating:   1,
+		XP:                200,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Keen Hearing and Smell", Description: "The wolf has advantage on Wisdom (Perception) checks that rely on hearing or smell."},
+			{Name: "Pack Tactics", Description: "The wolf has advantage on an attack roll against a creature if at least one of the wolf's allies is within 5 feet of the creature and the ally isn't incapacitated."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Bite", Description: "Melee Weapon Attack: +5 to hit, reach 5 ft., one target. If the target is a creature, it must succeed on a DC 13 Strength saving throw or be knocked prone.", AttackBonus: 5, Reach: 5, DamageDice: "2d6+3", DamageType: "piercing", SaveDC: 13, SaveAbility: "strength"},
+		},
+	},
+	"ghoul": {
+		Slug:                "ghoul",
+		Name:                "Ghoul",
+		Size:                "Medium",
+		Type:                "undead",
+		Alignment:           "chaotic evil",
+		ArmorClass:          12,
+		HitPoints:           22,
+		HitDice:             "5d8",
+		Speed:               map[string]int{"walk": 30},
+		Abilities:           models.AbilityScores{Strength: 13, Dexterity: 15, Constitution: 10, Intelligence: 7, Wisdom: 10, Charisma: 6},
+		Senses:              map[string]int{"darkvision": 60},
+		PassivePerception:   10,
+		Languages:           []string{"Common"},
+		ChallengeRating:     1,
+		XP:                  200,
+		DamageImmunities:    []string{"poison"},
+		ConditionImmunities: []string{"charmed", "exhaustion", "poisoned"},
+		Actions: []models.MonsterAction{
+			{Name: "Bite", Description: "Melee Weapon Attack: +2 to hit, reach 5 ft., one creature.", AttackBonus: 2, Reach: 5, DamageDice: "2d6+2", DamageType: "piercing"},
+			{Name: "Claws", Description: "Melee Weapon Attack: +4 to hit, reach 5 ft., one target. If the target is a creature other than an elf or undead, it must succeed on a DC 10 Constitution saving throw or be paralyzed for 1 minute.", AttackBonus: 4, Reach: 5, DamageDice: "2d4+2", DamageType: "slashing", SaveDC: 10, SaveAbility: "constitution"},
+		},
+	},
+	"giant-spider": {
+		Slug:              "giant-spider",
+		Name:              "Giant Spider",
+		Size:              "Large",
+		Type:              "beast",
+		Alignment:         "unaligned",
+		ArmorClass:        14,
+		ArmorDesc:         "natural armor",
+		HitPoints:         26,
+		HitDice:           "4d10+4",
+		Speed:             map[string]int{"walk": 30, "climb": 30},
+		Abilities:         models.AbilityScores{Strength: 14, Dexterity: 16, Constitution: 12, Intelligence: 2, Wisdom: 11, Charisma: 4},
+		Skills:            map[string]int{"Stealth": 7},
+		Senses:            map[string]int{"blindsight": 10, "darkvision": 60},
+		PassivePerception: 10,
+		Languages:         []string{},
+		ChallengeRating:   1,
+		XP:                200,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Spider Climb", Description: "The spider can climb difficult surfaces, including upside down on ceilings, without needing to make an ability check."},
+			{Name: "Web Sense", Description: "While in contact with a web, the spider knows the exact location of any other creature in contact with the same web."},
+			{Name: "Web Walker", Description: "The spider ignores movement restrictions caused by webbing."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Bite", Description: "Melee Weapon Attack: +5 to hit, reach 5 ft., one creature. The target must make a DC 11 Constitution saving throw, taking 9 (2d8) poison damage on a failed save, or half as much damage on a successful one.", AttackBonus: 5, Reach: 5, DamageDice: "1d8+3", DamageType: "piercing", ExtraDamageDice: "2d8", ExtraDamageType: "poison", SaveDC: 11, SaveAbility: "constitution"},
+			{Name: "Web (Recharge 5-6)", Description: "Ranged Weapon Attack: +5 to hit, range 30/60 ft., one creature. Hit: The target is restrained by webbing.", AttackBonus: 5, Range: "30/60"},
+		},
+	},
+	"bandit-captain": {
+		Slug:              "bandit-captain",
+		Name:              "Bandit Captain",
+		Size:              "Medium",
+		Type:              "humanoid (any race)",
+		Alignment:         "any non-lawful alignment",
+		ArmorClass:        15,
+		ArmorDesc:         "studded leather",
+		HitPoints:         65,
+		HitDice:           "10d8+20",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 15, Dexterity: 16, Constitution: 14, Intelligence: 14, Wisdom: 11, Charisma: 14},
+		SavingThrows:      map[string]int{"strength": 4, "dexterity": 5, "wisdom": 2},
+		Skills:            map[string]int{"Athletics": 4, "Deception": 4},
+		PassivePerception: 10,
+		Languages:         []string{"Any two languages"},
+		ChallengeRating:   2,
+		XP:                450,
+		Actions: []models.MonsterAction{
+			{Name: "Multiattack", Description: "The captain makes three melee attacks: two with its scimitar and one with its dagger. Or the captain makes two ranged attacks with its daggers."},
+			{Name: "Scimitar", Description: "Melee Weapon Attack: +5 to hit, reach 5 ft., one target.", AttackBonus: 5, Reach: 5, DamageDice: "1d6+3", DamageType: "slashing"},
+			{Name: "Dagger", Description: "Melee or Ranged Weapon Attack: +5 to hit, reach 5 ft. or range 20/60 ft., one target.", AttackBonus: 5, Reach: 5, Range: "20/60", DamageDice: "1d4+3", DamageType: "piercing"},
+		},
+		Reactions: []models.MonsterAction{
+			{Name: "Parry", Description: "The captain adds 2 to its AC against one melee attack that would hit it. To do so, the captain must see the attacker and be wielding a melee weapon."},
+		},
+	},
+	"ogre": {
+		Slug:              "ogre",
+		Name:              "Ogre",
+		Size:              "Large",
+		Type:              "giant",
+		Alignment:         "chaotic evil",
+		ArmorClass:        11,
+		ArmorDesc:         "hide armor",
+		HitPoints:         59,
+		HitDice:           "7d10+21",
+		Speed:             map[string]int{"walk": 40},
+		Abilities:         models.AbilityScores{Strength: 19, Dexterity: 8, Constitution: 16, Intelligence: 5, Wisdom: 7, Charisma: 7},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 8,
+		Languages:         []string{"Common", "Giant"},
+		ChallengeRating:   2,
+		XP:                450,
+		Actions: []models.MonsterAction{
+			{Name: "Greatclub", Description: "Melee Weapon Attack: +6 to hit, reach 5 ft., one target.", AttackBonus: 6, Reach: 5, DamageDice: "2d8+4", DamageType: "bludgeoning"},
+			{Name: "Javelin", Description: "Melee or Ranged Weapon Attack: +6 to hit, reach 5 ft. or range 30/120 ft., one target.", AttackBonus: 6, Reach: 5, Range: "30/120", DamageDice: "2d6+4", DamageType: "piercing"},
+		},
+	},
+	"owlbear": {
+		Slug:              "owlbear",
+		Name:              "Owlbear",
+		Size:              "Large",
+		Type:              "monstrosity",
+		Alignment:         "unaligned",
+		ArmorClass:        13,
+		ArmorDesc:         "natural armor",
+		HitPoints:         59,
+		HitDice:           "7d10+21",
+		Speed:             map[string]int{"walk": 40},
+		Abilities:         models.AbilityScores{Strength: 20, Dexterity: 12, Constitution: 17, Intelligence: 3, Wisdom: 12, Charisma: 7},
+		Skills:            map[string]int{"Perception": 3},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 13,
+		Languages:         []string{},
+		ChallengeRating:   3,
+		XP:                700,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Keen Sight and Smell", Description: "The owlbear has advantage on Wisdom (Perception) checks that rely on sight or smell."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Multiattack", Description: "The owlbear makes two attacks: one with its beak and one with its claws."},
+			{Name: "Beak", Description: "Melee Weapon Attack: +7 to hit, reach 5 ft., one creature.", AttackBonus: 7, Reach: 5, DamageDice: "1d10+5", DamageType: "piercing"},
+			{Name: "Claws", Description: "Melee Weapon Attack: +7 to hit, reach 5 ft., one target.", AttackBonus: 7, Reach: 5, DamageDice: "2d8+5", DamageType: "slashing"},
+		},
+	},
+	"veteran": {
+		Slug:              "veteran",
+		Name:              "Veteran",
+		Size:              "Medium",
+		Type:              "humanoid (any race)",
+		Alignment:         "any alignment",
+		ArmorClass:        17,
+		ArmorDesc:         "splint",
+		HitPoints:         58,
+		HitDice:           "9d8+18",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 16, Dexterity: 13, Constitution: 14, Intelligence: 10, Wisdom: 11, Charisma: 10},
+		Skills:            map[string]int{"Athletics": 5, "Perception": 2},
+		PassivePerception: 12,
+		Languages:         []string{"Any one language (usually Common)"},
+		ChallengeRating:   3,
+		XP:                700,
+		Actions: []models.MonsterAction{
+			{Name: "Multiattack", Description: "The veteran makes two longsword attacks. If it has a shortsword drawn, it can also make a shortsword attack."},
+			{Name: "Longsword", Description: "Melee Weapon Attack: +5 to hit, reach 5 ft., one target.", AttackBonus: 5, Reach: 5, DamageDice: "1d8+3", DamageType: "slashing"},
+			{Name: "Shortsword", Description: "Melee Weapon Attack: +5 to hit, reach 5 ft., one target.", AttackBonus: 5, Reach: 5, DamageDice: "1d6+3", DamageType: "piercing"},
+			{Name: "Heavy Crossbow", Description: "Ranged Weapon Attack: +3 to hit, range 100/400 ft., one target.", AttackBonus: 3, Range: "100/400", DamageDice: "1d10+1", DamageType: "piercing"},
+		},
+	},
+	"minotaur": {
+		Slug:              "minotaur",
+		Name:              "Minotaur",
+		Size:              "Large",
+		Type:              "monstrosity",
+		Alignment:         "chaotic evil",
+		ArmorClass:        14,
+		ArmorDesc:         "natural armor",
+		HitPoints:         76,
+		HitDice:           "9d10+27",
+		Speed:             map[string]int{"walk": 40},
+		Abilities:         models.AbilityScores{Strength: 18, Dexterity: 11, Constitution: 16, Intelligence: 6, Wisdom: 16, Charisma: 9},
+		Skills:            map[string]int{"Perception": 7},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 17,
+		Languages:         []string{"Abyssal"},
+		ChallengeRating:   3,
+		XP:                700,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Charge", Description: "If the minotaur moves at least 10 feet straight toward a target and then hits it with a gore attack on the same turn, the target takes an extra 9 (2d8) piercing damage. If the target is a creature, it must succeed on a DC 14 Strength saving throw or be pushed up to 10 feet away and knocked prone."},
+			{Name: "Labyrinthine Recall", Description: "The minotaur can perfectly recall any path it has traveled."},
+			{Name: "Reckless", Description: "At the start of its turn, the minotaur can gain advantage on all melee weapon attack rolls it makes during that turn, but attack rolls against it have advantage until the start of its next turn."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Greataxe", Description: "Melee Weapon Attack: +6 to hit, reach 5 ft., one target.", AttackBonus: 6, Reach: 5, DamageDice: "2d12+4", DamageType: "slashing"},
+			{Name: "Gore", Description: "Melee Weapon Attack: +6 to hit, reach 5 ft., one target.", AttackBonus: 6, Reach: 5, DamageDice: "2d8+4", DamageType: "piercing"},
+		},
+	},
+	"troll": {
+		Slug:              "troll",
+		Name:              "Troll",
+		Size:              "Large",
+		Type:              "giant",
+		Alignment:         "chaotic evil",
+		ArmorClass:        15,
+		ArmorDesc:         "natural armor",
+		HitPoints:         84,
+		HitDice:           "8d10+40",
+		Speed:             map[string]int{"walk": 30},
+		Abilities:         models.AbilityScores{Strength: 18, Dexterity: 13, Constitution: 20, Intelligence: 7, Wisdom: 9, Charisma: 7},
+		Skills:            map[string]int{"Perception": 2},
+		Senses:            map[string]int{"darkvision": 60},
+		PassivePerception: 12,
+		Languages:         []string{"Giant"},
+		ChallengeRating:   5,
+		XP:                1800,
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Keen Smell", Description: "The troll has advantage on Wisdom (Perception) checks that rely on smell."},
+			{Name: "Regeneration", Description: "The troll regains 10 hit points at the start of its turn. If the troll takes acid or fire damage, this trait doesn't function at the start of the troll's next turn. The troll dies only if it starts its turn with 0 hit points and doesn't regenerate."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Multiattack", Description: "The troll makes three attacks: one with its bite and two with its claws."},
+			{Name: "Bite", Description: "Melee Weapon Attack: +7 to hit, reach 5 ft., one target.", AttackBonus: 7, Reach: 5, DamageDice: "1d6+4", DamageType: "piercing"},
+			{Name: "Claw", Description: "Melee Weapon Attack: +7 to hit, reach 5 ft., one target.", AttackBonus: 7, Reach: 5, DamageDice: "2d6+4", DamageType: "slashing"},
+		},
+	},
+	"young-green-dragon": {
+		Slug:                "young-green-dragon",
+		Name:                "Young Green Dragon",
+		Size:                "Large",
+		Type:                "dragon",
+		Alignment:           "lawful evil",
+		ArmorClass:          18,
+		ArmorDesc:           "natural armor",
+		HitPoints:           136,
+		HitDice:             "16d10+48",
+		Speed:               map[string]int{"walk": 40, "fly": 80, "swim": 40},
+		Abilities:           models.AbilityScores{Strength: 19, Dexterity: 12, Constitution: 17, Intelligence: 16, Wisdom: 13, Charisma: 15},
+		SavingThrows:        map[string]int{"dexterity": 4, "constitution": 6, "wisdom": 4, "charisma": 5},
+		Skills:              map[string]int{"Deception": 5, "Perception": 7, "Stealth": 4},
+		Senses:              map[string]int{"blindsight": 30, "darkvision": 120},
+		PassivePerception:   17,
+		Languages:           []string{"Common", "Draconic"},
+		ChallengeRating:     8,
+		XP:                  3900,
+		DamageImmunities:    []string{"poison"},
+		ConditionImmunities: []string{"poisoned"},
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Amphibious", Description: "The dragon can breathe air and water."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Multiattack", Description: "The dragon makes three attacks: one with its bite and two with its claws."},
+			{Name: "Bite", Description: "Melee Weapon Attack: +7 to hit, reach 10 ft., one target.", AttackBonus: 7, Reach: 10, DamageDice: "2d10+4", DamageType: "piercing", ExtraDamageDice: "2d6", ExtraDamageType: "poison"},
+			{Name: "Claw", Description: "Melee Weapon Attack: +7 to hit, reach 5 ft., one target.", AttackBonus: 7, Reach: 5, DamageDice: "2d6+4", DamageType: "slashing"},
+			{Name: "Poison Breath (Recharge 5-6)", Description: "The dragon exhales poisonous gas in a 30-foot cone. Each creature in that area must make a DC 14 Constitution saving throw, taking 42 (12d6) poison damage on a failed save, or half as much damage on a successful one.", DamageDice: "12d6", DamageType: "poison", SaveDC: 14, SaveAbility: "constitution"},
+		},
+	},
+	"adult-red-dragon": {
+		Slug:                "adult-red-dragon",
+		Name:                "Adult Red Dragon",
+		Size:                "Huge",
+		Type:                "dragon",
+		Alignment:           "chaotic evil",
+		ArmorClass:          19,
+		ArmorDesc:           "natural armor",
+		HitPoints:           256,
+		HitDice:             "19d12+133",
+		Speed:               map[string]int{"walk": 40, "climb": 40, "fly": 80},
+		Abilities:           models.AbilityScores{Strength: 27, Dexterity: 10, Constitution: 25, Intelligence: 16, Wisdom: 13, Charisma: 21},
+		SavingThrows:        map[string]int{"dexterity": 6, "constitution": 13, "wisdom": 7, "charisma": 11},
+		Skills:              map[string]int{"Perception": 13, "Stealth": 6},
+		Senses:              map[string]int{"blindsight": 60, "darkvision": 120},
+		PassivePerception:   23,
+		Languages:           []string{"Common", "Draconic"},
+		ChallengeRating:     17,
+		XP:                  18000,
+		DamageImmunities:    []string{"fire"},
+		SpecialAbilities: []models.MonsterTrait{
+			{Name: "Legendary Resistance (3/Day)", Description: "If the dragon fails a saving throw, it can choose to succeed instead."},
+		},
+		Actions: []models.MonsterAction{
+			{Name: "Multiattack", Description: "The dragon can use its Frightful Presence. It then makes three attacks: one with its bite and two with its claws."},
+			{Name: "Bite", Description: "Melee Weapon Attack: +14 to hit, reach 10 ft., one target.", AttackBonus: 14, Reach: 10, DamageDice: "2d10+8", DamageType: "piercing", ExtraDamageDice: "2d6", ExtraDamageType: "fire"},
+			{Name: "Claw", Description: "Melee Weapon Attack: +14 to hit, reach 5 ft., one target.", AttackBonus: 14, Reach: 5, DamageDice: "2d6+8", DamageType: "slashing"},
+			{Name: "Tail", Description: "Melee Weapon Attack: +14 to hit, reach 15 ft., one target.", AttackBonus: 14, Reach: 15, DamageDice: "2d8+8", DamageType: "bludgeoning"},
+			{Name: "Frightful Presence", Description: "Each creature of the dragon's choice that is within 120 feet of the dragon and aware of it must succeed on a DC 19 Wisdom saving throw or become frightened for 1 minute.", SaveDC: 19, SaveAbility: "wisdom"},
+			{Name: "Fire Breath (Recharge 5-6)", Description: "The dragon exhales fire in a 60-foot cone. Each creature in that area must make a DC 21 Dexterity saving throw, taking 63 (18d6) fire damage on a failed save, or half as much damage on a successful one.", DamageDice: "18d6", DamageType: "fire", SaveDC: 21, SaveAbility: "dexterity"},
+		},
+		LegendaryActionsPerRound: 3,
+		LegendaryActions: []models.MonsterAction{
+			{Name: "Detect", Description: "The dragon makes a Wisdom (Perception) check.", Cost: 1},
+			{Name: "Tail Attack", Description: "The dragon makes a tail attack.", Cost: 1},
+			{Name: "Wing Attack", Description: "The dragon beats its wings. Each creature within 10 feet of the dragon must succeed on a DC 22 Dexterity saving throw or take 15 (2d6 + 8) bludgeoning damage and be knocked prone. The dragon can then fly up to half its flying speed.", DamageDice: "2d6+8", DamageType: "bludgeoning", SaveDC: 22, SaveAbility: "dexterity", Cost: 2},
+		},
+	},
+}
@@ -88,4 +88,11 @@ var Races = map[string]models.Race{
 		Languages:       []string{"Common", "Infernal"},
 		Proficiencies:   []string{},
 	},
-}
\ No newline at end of file
+}
+
+// Damage resistances granted by racial traits. Dragonborn resistance depends on the
+// draconic ancestry, which characters don't record, so it isn't listed.
+var TraitResistances = map[string][]string{
+	"Dwarven Resilience": {"poison"},
+	"Hellish Resistance": {"fire"},
+}
@@ -38,9 +38,8 @@ func Connect(cfg *config.Config) (*DB, error) {
 	}, nil
 }
 
-func (db *DB) Close() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// Close disconnects from MongoDB, giving up when ctx expires
+func (db *DB) Close(ctx context.Context) error {
 	return db.Client.Disconnect(ctx)
 }
 
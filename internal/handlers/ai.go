@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -9,35 +12,85 @@ import (
 
 	"dnd-simulator/internal/models"
 	"dnd-simulator/internal/services"
+	"dnd-simulator/internal/websocket"
 )
 
 type AIHandler struct {
 	aiService       *services.AIService
+	llms            *services.LLMRegistry
 	sessionService  *services.SessionService
 	characterService *services.CharacterService
 	campaignService *services.CampaignService
 	eventService    *services.EventService
+	mechanicsService *services.MechanicsService
+	hub             *websocket.Hub
 }
 
 func NewAIHandler(
 	aiService *services.AIService,
+	llms *services.LLMRegistry,
 	sessionService *services.SessionService,
 	characterService *services.CharacterService,
 	campaignService *services.CampaignService,
 	eventService *services.EventService,
+	mechanicsService *services.MechanicsService,
+	hub *websocket.Hub,
 ) *AIHandler {
 	return &AIHandler{
 		aiService:       aiService,
+		llms:            llms,
 		sessionService:  sessionService,
 		characterService: characterService,
 		campaignService: campaignService,
 		eventService:    eventService,
+		mechanicsService: mechanicsService,
+		hub:             hub,
 	}
 }
 
-// ProcessPlayerAction handles a player action and generates an AI DM response
+// ProcessPlayerAction handles a player action and generates an AI DM response. The
+// narration streams to the session's WebSocket as it is written.
 // POST /api/sessions/:id/action
 func (h *AIHandler) ProcessPlayerAction(c *gin.Context) {
+	h.processPlayerAction(c, false)
+}
+
+// StreamPlayerAction is ProcessPlayerAction answering with server-sent events: a "delta"
+// event per piece of narration, then "response" with the same body ProcessPlayerAction
+// returns, or "error"
+// POST /api/sessions/:id/action/stream
+func (h *AIHandler) StreamPlayerAction(c *gin.Context) {
+	h.processPlayerAction(c, true)
+}
+
+// InterruptResponse stops the AI DM's response in progress (DM only)
+// POST /api/sessions/:id/action/interrupt
+func (h *AIHandler) InterruptResponse(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	value, _ := c.Get("session")
+	if session, ok := value.(*models.GameSession); !ok || session.DMUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can interrupt the AI DM"})
+		return
+	}
+
+	responseID, ok, err := h.aiService.Interrupt(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to interrupt the AI DM"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The AI DM is not responding right now"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "AI response interrupted", "response_id": responseID})
+}
+
+func (h *AIHandler) processPlayerAction(c *gin.Context, stream bool) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -117,7 +170,8 @@ func (h *AIHandler) ProcessPlayerAction(c *gin.Context) {
 	}
 
 	// Build AI context
-	aiContext := &services.AIContext{
+	aiContext := &models.AIContext{
+		SessionID:    sessionID,
 		Campaign:     campaign,
 		CurrentScene: session.Scene,
 		Characters:   characters,
@@ -135,14 +189,79 @@ func (h *AIHandler) ProcessPlayerAction(c *gin.Context) {
 		CurrentTurn: session.CurrentTurn,
 	}
 
-	// Generate AI response
-	aiResponse, err := h.aiService.GenerateResponse(c.Request.Context(), aiContext)
+	// One response at a time per session, so the DM has one thing to interrupt
+	responseID := primitive.NewObjectID()
+	ctx, slot, err := h.aiService.BeginResponse(c.Request.Context(), sessionID, responseID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate AI response"})
+		if errors.Is(err, services.ErrAIResponseInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the AI response"})
 		return
 	}
+	defer slot.Release()
+
+	// Once written, the response is kept even if the client goes away
+	persistCtx := context.WithoutCancel(c.Request.Context())
 
-	// Set session ID
+	if stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+	}
+
+	// Generate AI response, streaming the narration as it is written
+	index := 0
+	aiResponse, err := h.aiService.StreamResponse(ctx, aiContext, func(delta string, restart bool) {
+		data := models.AIResponseDeltaData{
+			ResponseID: responseID,
+			Index:      index,
+			Delta:      delta,
+			Restart:    restart,
+		}
+		index++
+		h.hub.BroadcastTransient(sessionID, models.WSMessage{
+			Type:      models.MessageTypeAIResponseDelta,
+			Timestamp: time.Now(),
+			SessionID: sessionID,
+			Data:      data,
+		})
+		if stream {
+			c.SSEvent("delta", data)
+			c.Writer.Flush()
+		}
+	})
+	if err == nil {
+		// From here on the DM's interrupt has nothing to stop, unless it got in first
+		err = slot.Finish(persistCtx)
+	}
+	if err != nil {
+		status, message, reason := http.StatusInternalServerError, "Failed to generate AI response", "failed"
+		if errors.Is(err, services.ErrAIResponseInterrupted) || errors.Is(context.Cause(ctx), services.ErrAIResponseInterrupted) {
+			status, message, reason = http.StatusConflict, services.ErrAIResponseInterrupted.Error(), "interrupted"
+		}
+		h.hub.BroadcastToSession(sessionID, models.WSMessage{
+			Type:      models.MessageTypeAIResponseCancelled,
+			Timestamp: time.Now(),
+			SessionID: sessionID,
+			Data: models.AIResponseCancelledData{
+				ResponseID: responseID,
+				Reason:     reason,
+			},
+		})
+		if stream {
+			c.SSEvent("error", gin.H{"error": message})
+			return
+		}
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	// Match the ID the deltas carried
+	aiResponse.ID = responseID
 	aiResponse.SessionID = sessionID
 
 	// Store the player action event
@@ -156,7 +275,7 @@ func (h *AIHandler) ProcessPlayerAction(c *gin.Context) {
 		Timestamp:     time.Now(),
 	}
 	
-	gameEvent, err := h.eventService.StorePlayerAction(c.Request.Context(), sessionID, &playerAction)
+	gameEvent, err := h.eventService.StorePlayerAction(persistCtx, sessionID, &playerAction)
 	if err != nil {
 		// Log error but continue - we still want to return the AI response
 		gameEvent = &models.GameEvent{
@@ -170,7 +289,7 @@ func (h *AIHandler) ProcessPlayerAction(c *gin.Context) {
 	}
 
 	// Store AI response event
-	aiEvent, err := h.eventService.StoreAIResponse(c.Request.Context(), sessionID, aiResponse)
+	aiEvent, err := h.eventService.StoreAIResponse(persistCtx, sessionID, aiResponse)
 	if err != nil {
 		// Log error but continue
 		aiEvent = &models.GameEvent{
@@ -182,30 +301,42 @@ func (h *AIHandler) ProcessPlayerAction(c *gin.Context) {
 		}
 	}
 
+	// Queue the changes the response calls for, for the DM to review (or apply them
+	// straight away if the campaign auto-applies)
+	changes, err := h.mechanicsService.Propose(persistCtx, session, campaign, aiResponse)
+	if err != nil {
+		log.Printf("Failed to queue AI mechanics for session %s: %v", sessionID.Hex(), err)
+	}
+	publishMechanicChanges(h.hub, session, changes)
+
 	// Broadcast to WebSocket clients
 	wsMessage := models.WSMessage{
 		Type:      models.MessageTypeAIResponse,
 		Timestamp: time.Now(),
 		UserID:    userID.(primitive.ObjectID),
 		SessionID: sessionID,
-		Data: map[string]interface{}{
-			"character_name": character.Name,
-			"action":         req.Action,
-			"ai_response":    aiResponse,
-			"game_event":     gameEvent,
-			"ai_event":       aiEvent,
+		Data: models.AIResponseData{
+			CharacterName: character.Name,
+			Action:        req.Action,
+			AIResponse:    aiResponse,
+			GameEvent:     gameEvent,
+			AIEvent:       aiEvent,
 		},
 	}
 
-	// Send through WebSocket hub (would need to inject hub)
-	// h.hub.BroadcastToSession(sessionID, wsMessage)
+	h.hub.BroadcastToSession(sessionID, wsMessage)
 
-	c.JSON(http.StatusOK, gin.H{
+	body := gin.H{
 		"response":    aiResponse,
 		"game_event":  gameEvent,
 		"ai_event":    aiEvent,
 		"ws_message":  wsMessage,
-	})
+	}
+	if stream {
+		c.SSEvent("response", body)
+		return
+	}
+	c.JSON(http.StatusOK, body)
 }
 
 // GetNarrativeHistory returns the narrative history for a session
@@ -256,7 +387,7 @@ func (h *AIHandler) GetEventsByType(c *gin.Context) {
 	}
 
 	eventType := c.Param("type")
-	validTypes := []string{"player_action", "ai_response", "dice_roll", "combat", "narrative"}
+	validTypes := []string{"player_action", "ai_response", "dice_roll", "combat", "narrative", "xp_award"}
 	isValid := false
 	for _, vt := range validTypes {
 		if eventType == vt {
@@ -281,4 +412,10 @@ func (h *AIHandler) GetEventsByType(c *gin.Context) {
 		"events": events,
 		"count": len(events),
 	})
-}
\ No newline at end of file
+}
+
+// GetProviders lists the LLM providers campaigns can pick in settings.ai
+// GET /api/ai/providers
+func (h *AIHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.llms.Providers()})
+}
@@ -11,11 +11,13 @@ import (
 
 type CampaignHandler struct {
 	campaignService *services.CampaignService
+	llms            *services.LLMRegistry
 }
 
-func NewCampaignHandler(campaignService *services.CampaignService) *CampaignHandler {
+func NewCampaignHandler(campaignService *services.CampaignService, llms *services.LLMRegistry) *CampaignHandler {
 	return &CampaignHandler{
 		campaignService: campaignService,
+		llms:            llms,
 	}
 }
 
@@ -46,6 +48,11 @@ func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
 		return
 	}
 
+	if err := h.llms.Validate(req.Settings.AI); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Set default settings if not provided
 	if req.Settings.MaxPlayers == 0 {
 		req.Settings.MaxPlayers = 6
@@ -134,6 +141,10 @@ func (h *CampaignHandler) UpdateCampaign(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := h.llms.Validate(req.Settings.AI); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	userID, exists := c.Get("user_id")
 	if !exists {
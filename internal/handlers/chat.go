@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+	"dnd-simulator/internal/websocket"
+)
+
+type ChatHandler struct {
+	chatService *services.ChatService
+	hub         *websocket.Hub
+}
+
+func NewChatHandler(chatService *services.ChatService, hub *websocket.Hub) *ChatHandler {
+	return &ChatHandler{
+		chatService: chatService,
+		hub:         hub,
+	}
+}
+
+// GetMessages returns a page of chat history, newest first
+// GET /api/sessions/:id/chat?before=<message id>&limit=<n>
+func (h *ChatHandler) GetMessages(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var before primitive.ObjectID
+	if value := c.Query("before"); value != "" {
+		var err error
+		before, err = primitive.ObjectIDFromHex(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before cursor"})
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.chatService.GetMessages(c.Request.Context(), sessionID, userID, before, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SearchMessages runs a full-text search over the session's chat
+// GET /api/sessions/:id/chat/search?q=<text>&limit=<n>
+func (h *ChatHandler) SearchMessages(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	messages, err := h.chatService.SearchMessages(c.Request.Context(), sessionID, userID, c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// EditMessage changes a chat message's text (author or DM only)
+// PUT /api/sessions/:id/chat/:messageId
+func (h *ChatHandler) EditMessage(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	messageID, err := primitive.ObjectIDFromHex(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	var req models.EditChatMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	msg, audience, err := h.chatService.EditMessage(c.Request.Context(), sessionID, messageID, userID, req.Message)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.hub.SendToAudience(sessionID, websocket.Audience{UserIDs: audience}, models.WSMessage{
+		Type:      models.MessageTypeChatEdited,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.ChatEditedData{
+			Message: msg,
+		},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": msg})
+}
+
+// DeleteMessage removes a chat message (author or DM only)
+// DELETE /api/sessions/:id/chat/:messageId
+func (h *ChatHandler) DeleteMessage(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	messageID, err := primitive.ObjectIDFromHex(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	audience, err := h.chatService.DeleteMessage(c.Request.Context(), sessionID, messageID, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.hub.SendToAudience(sessionID, websocket.Audience{UserIDs: audience}, models.WSMessage{
+		Type:      models.MessageTypeChatDeleted,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.ChatDeletedData{
+			MessageID: messageID,
+		},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Chat message deleted"})
+}
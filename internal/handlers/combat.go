@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+	"dnd-simulator/internal/websocket"
+)
+
+type CombatHandler struct {
+	combatService *services.CombatService
+	hub           *websocket.Hub
+}
+
+func NewCombatHandler(combatService *services.CombatService, hub *websocket.Hub) *CombatHandler {
+	return &CombatHandler{
+		combatService: combatService,
+		hub:           hub,
+	}
+}
+
+// Attack spends an action (or bonus action) on a weapon attack
+// POST /api/sessions/:id/combat/attack
+func (h *CombatHandler) Attack(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.AttackActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.Attack(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "attack", map[string]interface{}{
+		"weapon":       req.Weapon,
+		"bonus_action": req.BonusAction,
+	})
+	c.JSON(http.StatusOK, gin.H{"economy": turn.Entry.Economy})
+}
+
+// ResolveAttack rolls an attack against a target and applies the damage
+// POST /api/sessions/:id/combat/attack/resolve
+func (h *CombatHandler) ResolveAttack(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.ResolveAttackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, turn, err := h.combatService.ResolveAttack(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+	broadcastFinishedInitiative(h.hub, turn)
+
+	h.hub.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeAttackResult,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.AttackResultData{
+			Result: result,
+		},
+	})
+	h.sendEconomy(sessionID, turn)
+	c.JSON(http.StatusOK, gin.H{
+		"result":  result,
+		"economy": turn.Entry.Economy,
+	})
+}
+
+// CastSpell spends the action, bonus action or reaction matching the spell's casting time
+// POST /api/sessions/:id/combat/cast
+func (h *CombatHandler) CastSpell(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.CastSpellRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.CastSpell(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "cast", map[string]interface{}{
+		"spell_name":   req.SpellName,
+		"casting_time": req.CastingTime,
+	})
+	c.JSON(http.StatusOK, gin.H{"economy": turn.Entry.Economy})
+}
+
+// Dash spends an action (or bonus action) to double movement this turn
+// POST /api/sessions/:id/combat/dash
+func (h *CombatHandler) Dash(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.DashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.Dash(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "dash", map[string]interface{}{
+		"bonus_action": req.BonusAction,
+	})
+	c.JSON(http.StatusOK, gin.H{"economy": turn.Entry.Economy})
+}
+
+// Move spends feet of movement in a session without a battle map
+// POST /api/sessions/:id/combat/move
+func (h *CombatHandler) Move(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.Move(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "move", map[string]interface{}{
+		"feet": req.Feet,
+	})
+	c.JSON(http.StatusOK, gin.H{"economy": turn.Entry.Economy})
+}
+
+// Interact spends the free object interaction for this turn
+// POST /api/sessions/:id/combat/interact
+func (h *CombatHandler) Interact(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.InteractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.Interact(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "interact", map[string]interface{}{
+		"object": req.Object,
+	})
+	c.JSON(http.StatusOK, gin.H{"economy": turn.Entry.Economy})
+}
+
+// Ready spends the action to hold another action until a trigger occurs
+// POST /api/sessions/:id/combat/ready
+func (h *CombatHandler) Ready(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.ReadyActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.Ready(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "ready", map[string]interface{}{
+		"trigger": req.Trigger,
+		"action":  req.Action,
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"readied": turn.Entry.Readied,
+		"economy": turn.Entry.Economy,
+	})
+}
+
+// TriggerReadied spends the reaction to take a readied action once its trigger occurs
+// POST /api/sessions/:id/combat/ready/trigger
+func (h *CombatHandler) TriggerReadied(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var actor models.CombatActor
+	c.ShouldBindJSON(&actor) // Optional body
+
+	turn, readied, err := h.combatService.TriggerReadied(c.Request.Context(), sessionID, userID, actor)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "readied_trigger", map[string]interface{}{
+		"trigger": readied.Trigger,
+		"action":  readied.Action,
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"readied": readied,
+		"economy": turn.Entry.Economy,
+	})
+}
+
+// Reaction spends any combatant's reaction as an interrupt (DM only)
+// POST /api/sessions/:id/combat/reaction
+func (h *CombatHandler) Reaction(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.Reaction(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "reaction", map[string]interface{}{
+		"reaction": req.Reaction,
+	})
+	c.JSON(http.StatusOK, gin.H{"economy": turn.Entry.Economy})
+}
+
+// DelayTurn moves the current combatant later in the turn order
+// POST /api/sessions/:id/turn/delay
+func (h *CombatHandler) DelayTurn(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.DelayTurnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.DelayTurn(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "delay", map[string]interface{}{
+		"initiative": req.Initiative,
+	})
+	broadcastTurnOrder(h.hub, turn.Session)
+
+	// The combatant now in the current slot starts their turn
+	current := &services.CombatTurn{
+		Session: turn.Session,
+		Index:   turn.Session.CurrentTurn,
+		Entry:   &turn.Session.TurnOrder[turn.Session.CurrentTurn],
+	}
+	h.sendEconomy(sessionID, current)
+
+	c.JSON(http.StatusOK, gin.H{
+		"turn_order":   turn.Session.TurnOrder,
+		"current_turn": turn.Session.CurrentTurn,
+	})
+}
+
+// LairAction takes the lair action on the lair's turn (DM only)
+// POST /api/sessions/:id/combat/lair
+func (h *CombatHandler) LairAction(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.LairActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.LairAction(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.broadcastAction(sessionID, turn, "lair_action", map[string]interface{}{
+		"action": req.Action,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Lair action taken"})
+}
+
+// LegendaryAction spends a monster's legendary actions between turns (DM only)
+// POST /api/sessions/:id/combat/legendary
+func (h *CombatHandler) LegendaryAction(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.LegendaryActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	turn, err := h.combatService.LegendaryAction(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	remaining := turn.Entry.LegendaryActions - turn.Entry.Economy.LegendaryActionsUsed
+	h.broadcastAction(sessionID, turn, "legendary_action", map[string]interface{}{
+		"action":    req.Action,
+		"remaining": remaining,
+	})
+	c.JSON(http.StatusOK, gin.H{"legendary_actions_remaining": remaining})
+}
+
+// PreviewArea returns the cells and tokens an area template covers
+// POST /api/sessions/:id/combat/area/preview
+func (h *CombatHandler) PreviewArea(c *gin.Context) {
+	_, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.PreviewAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cells, tokens, err := h.combatService.PreviewArea(c.Request.Context(), sessionID, req.Template)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cells":  cells,
+		"tokens": tokens,
+	})
+}
+
+// ResolveArea rolls saves and damage for everyone in an area effect
+// POST /api/sessions/:id/combat/area/resolve
+func (h *CombatHandler) ResolveArea(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.ResolveAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	effect, caster, err := h.combatService.ResolveArea(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	h.hub.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeAreaEffect,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.AreaEffectData{
+			Effect: effect,
+		},
+	})
+	if caster != nil {
+		broadcastFinishedInitiative(h.hub, caster)
+		h.sendEconomy(sessionID, caster)
+	}
+	c.JSON(http.StatusOK, gin.H{"effect": effect})
+}
+
+// GetEconomy returns the remaining action economy of a combatant (the current one by default)
+// GET /api/sessions/:id/combat/economy?character_id=&combatant_id=
+func (h *CombatHandler) GetEconomy(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var actor models.CombatActor
+	if characterID := c.Query("character_id"); characterID != "" {
+		id, err := primitive.ObjectIDFromHex(characterID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid character ID"})
+			return
+		}
+		actor.CharacterID = id
+	}
+	if combatantID := c.Query("combatant_id"); combatantID != "" {
+		id, err := primitive.ObjectIDFromHex(combatantID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid combatant ID"})
+			return
+		}
+		actor.CombatantID = id
+	}
+
+	turn, err := h.combatService.GetTurn(c.Request.Context(), sessionID, userID, actor)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+	broadcastFinishedInitiative(h.hub, turn)
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":               turn.Entry.Name,
+		"is_current_turn":    turn.Index == turn.Session.CurrentTurn,
+		"economy":            turn.Entry.Economy,
+		"movement_remaining": turn.Entry.Economy.MovementRemaining(),
+	})
+}
+
+// broadcastAction announces a combat action to the session and sends the actor's
+// remaining economy privately to whoever controls them
+func (h *CombatHandler) broadcastAction(sessionID primitive.ObjectID, turn *services.CombatTurn, action string, details map[string]interface{}) {
+	broadcastFinishedInitiative(h.hub, turn)
+	h.hub.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeCombatAction,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.CombatActionData{
+			Action:  action,
+			Name:    turn.Entry.Name,
+			Details: details,
+		},
+	})
+
+	h.sendEconomy(sessionID, turn)
+}
+
+// broadcastFinishedInitiative announces the turn order when resolving the turn closed an
+// expired initiative roll window, as the timer that would have announced it never ran
+func broadcastFinishedInitiative(hub *websocket.Hub, turn *services.CombatTurn) {
+	if turn != nil && turn.InitiativeFinished {
+		broadcastTurnOrder(hub, turn.Session)
+	}
+}
+
+// sendEconomy sends a combatant's remaining economy to whoever controls them
+func (h *CombatHandler) sendEconomy(sessionID primitive.ObjectID, turn *services.CombatTurn) {
+	h.hub.SendToUser(sessionID, turn.Recipient(), models.WSMessage{
+		Type:      models.MessageTypeActionEconomy,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.ActionEconomyData{
+			Name:              turn.Entry.Name,
+			Economy:           turn.Entry.Economy,
+			MovementRemaining: turn.Entry.Economy.MovementRemaining(),
+		},
+	})
+}
+
+// sessionRequestIDs extracts the authenticated user and session ID, writing an error response on failure
+func sessionRequestIDs(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	return userID.(primitive.ObjectID), sessionID, true
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+)
+
+type EncounterHandler struct {
+	encounterService *services.EncounterService
+}
+
+func NewEncounterHandler(encounterService *services.EncounterService) *EncounterHandler {
+	return &EncounterHandler{
+		encounterService: encounterService,
+	}
+}
+
+// CreateEncounter creates an encounter in a session (DM only)
+// POST /api/sessions/:id/encounters
+func (h *EncounterHandler) CreateEncounter(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var req models.CreateEncounterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	encounter, err := h.encounterService.CreateEncounter(c.Request.Context(), sessionID, userID.(primitive.ObjectID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"encounter": encounter})
+}
+
+// GetEncounters lists the encounters of a session
+// GET /api/sessions/:id/encounters
+func (h *EncounterHandler) GetEncounters(c *gin.Context) {
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	encounters, err := h.encounterService.GetSessionEncounters(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"encounters": encounters})
+}
+
+// GetEncounter returns a single encounter with its combatants
+// GET /api/sessions/:id/encounters/:encounterId
+func (h *EncounterHandler) GetEncounter(c *gin.Context) {
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	encounterID, err := primitive.ObjectIDFromHex(c.Param("encounterId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return
+	}
+
+	encounter, err := h.encounterService.GetEncounter(c.Request.Context(), sessionID, encounterID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"encounter": encounter})
+}
+
+// AddMonsters adds monster combatants to an encounter (DM only)
+// POST /api/sessions/:id/encounters/:encounterId/monsters
+func (h *EncounterHandler) AddMonsters(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	encounterID, err := primitive.ObjectIDFromHex(c.Param("encounterId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return
+	}
+
+	var req models.AddMonstersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	encounter, err := h.encounterService.AddMonsters(c.Request.Context(), sessionID, encounterID, userID.(primitive.ObjectID), req.Monsters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"encounter": encounter})
+}
+
+// PreviewEncounter rates a prospective set of monsters against the session's party
+// POST /api/sessions/:id/encounters/preview
+func (h *EncounterHandler) PreviewEncounter(c *gin.Context) {
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var req models.PreviewEncounterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	difficulty, err := h.encounterService.PreviewDifficulty(c.Request.Context(), sessionID, req.Monsters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"difficulty": difficulty})
+}
+
+// GetEncounterDifficulty rates an existing encounter's remaining monsters
+// GET /api/sessions/:id/encounters/:encounterId/difficulty
+func (h *EncounterHandler) GetEncounterDifficulty(c *gin.Context) {
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	encounterID, err := primitive.ObjectIDFromHex(c.Param("encounterId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return
+	}
+
+	difficulty, err := h.encounterService.GetEncounterDifficulty(c.Request.Context(), sessionID, encounterID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"difficulty": difficulty})
+}
+
+// EndEncounter completes an encounter and awards XP on victory (DM only)
+// POST /api/sessions/:id/encounters/:encounterId/end
+func (h *EncounterHandler) EndEncounter(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	encounterID, err := primitive.ObjectIDFromHex(c.Param("encounterId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return
+	}
+
+	var req models.EndEncounterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	encounter, award, err := h.encounterService.EndEncounter(c.Request.Context(), sessionID, encounterID, userID.(primitive.ObjectID), req.Outcome)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"encounter": encounter,
+		"xp_award":  award,
+	})
+}
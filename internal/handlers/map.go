@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+	"dnd-simulator/internal/websocket"
+)
+
+type MapHandler struct {
+	mapService *services.MapService
+	hub        *websocket.Hub
+}
+
+func NewMapHandler(mapService *services.MapService, hub *websocket.Hub) *MapHandler {
+	return &MapHandler{
+		mapService: mapService,
+		hub:        hub,
+	}
+}
+
+// GetMap returns the part of the session's battle map the user can see
+// GET /api/sessions/:id/map
+func (h *MapHandler) GetMap(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	view, err := h.mapService.GetMapView(c.Request.Context(), sessionID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"map": view})
+}
+
+// SetMap creates or resizes the battle map and replaces its terrain (DM only)
+// PUT /api/sessions/:id/map
+func (h *MapHandler) SetMap(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetMapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	battleMap, err := h.mapService.SetMap(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMap(c.Request.Context(), sessionID)
+	c.JSON(http.StatusOK, gin.H{"map": battleMap})
+}
+
+// PlaceToken puts a token for a character or combatant on the map (DM only)
+// POST /api/sessions/:id/map/tokens
+func (h *MapHandler) PlaceToken(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.PlaceTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.mapService.PlaceToken(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMap(c.Request.Context(), sessionID)
+	c.JSON(http.StatusCreated, gin.H{"token": token})
+}
+
+// RemoveToken takes a token off the map (DM only)
+// DELETE /api/sessions/:id/map/tokens/:tokenId
+func (h *MapHandler) RemoveToken(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	tokenID, err := primitive.ObjectIDFromHex(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := h.mapService.RemoveToken(c.Request.Context(), sessionID, userID, tokenID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMap(c.Request.Context(), sessionID)
+	c.JSON(http.StatusOK, gin.H{"message": "Token removed"})
+}
+
+// MoveToken moves a token along a path, spending movement during combat
+// POST /api/sessions/:id/map/tokens/:tokenId/move
+func (h *MapHandler) MoveToken(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	tokenID, err := primitive.ObjectIDFromHex(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	var req models.MoveTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	move, turn, err := h.mapService.MoveToken(c.Request.Context(), sessionID, userID, tokenID, &req)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	broadcastFinishedInitiative(h.hub, turn)
+	h.broadcastMove(c.Request.Context(), sessionID, move)
+
+	response := gin.H{"move": move}
+	if turn != nil {
+		h.hub.SendToUser(sessionID, turn.Recipient(), models.WSMessage{
+			Type:      models.MessageTypeActionEconomy,
+			Timestamp: time.Now(),
+			SessionID: sessionID,
+			Data: models.ActionEconomyData{
+				Name:              turn.Entry.Name,
+				Economy:           turn.Entry.Economy,
+				MovementRemaining: turn.Entry.Economy.MovementRemaining(),
+			},
+		})
+		response["economy"] = turn.Entry.Economy
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AddLight places a light source on the map (DM only)
+// POST /api/sessions/:id/map/lights
+func (h *MapHandler) AddLight(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.AddLightRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	light, err := h.mapService.AddLight(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMap(c.Request.Context(), sessionID)
+	c.JSON(http.StatusCreated, gin.H{"light": light})
+}
+
+// RemoveLight removes a light source from the map (DM only)
+// DELETE /api/sessions/:id/map/lights/:lightId
+func (h *MapHandler) RemoveLight(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	lightID, err := primitive.ObjectIDFromHex(c.Param("lightId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid light ID"})
+		return
+	}
+
+	if err := h.mapService.RemoveLight(c.Request.Context(), sessionID, userID, lightID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMap(c.Request.Context(), sessionID)
+	c.JSON(http.StatusOK, gin.H{"message": "Light removed"})
+}
+
+// RevealRegion reveals a rectangle of the map to every player (DM only)
+// POST /api/sessions/:id/map/reveal
+func (h *MapHandler) RevealRegion(c *gin.Context) {
+	h.updateRegion(c, true)
+}
+
+// HideRegion hides a rectangle of the map from every player (DM only)
+// POST /api/sessions/:id/map/hide
+func (h *MapHandler) HideRegion(c *gin.Context) {
+	h.updateRegion(c, false)
+}
+
+func (h *MapHandler) updateRegion(c *gin.Context, reveal bool) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.MapRegionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var battleMap *models.BattleMap
+	var err error
+	if reveal {
+		battleMap, err = h.mapService.RevealRegion(c.Request.Context(), sessionID, userID, &req)
+	} else {
+		battleMap, err = h.mapService.HideRegion(c.Request.Context(), sessionID, userID, &req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMap(c.Request.Context(), sessionID)
+	c.JSON(http.StatusOK, gin.H{
+		"revealed": battleMap.Revealed,
+		"hidden":   battleMap.Hidden,
+	})
+}
+
+// broadcastMap sends every user in the session the part of the battle map they can see
+func (h *MapHandler) broadcastMap(ctx context.Context, sessionID primitive.ObjectID) {
+	views, err := h.mapService.GetMapViews(ctx, sessionID)
+	if err != nil {
+		log.Printf("Failed to compute map views for session %s: %v", sessionID.Hex(), err)
+		return
+	}
+
+	h.hub.BroadcastPerUser(sessionID, func(userID primitive.ObjectID) (models.WSMessage, bool) {
+		return mapUpdateMessage(sessionID, views.For(userID)), true
+	})
+}
+
+// broadcastMove tells each user about a token move as far as they can see it. Users who
+// saw the token leave but can't see where it went are only told it disappeared, and the
+// token's owner gets a fresh view since their sight moved with it.
+func (h *MapHandler) broadcastMove(ctx context.Context, sessionID primitive.ObjectID, move *models.TokenMove) {
+	views, err := h.mapService.GetMapViews(ctx, sessionID)
+	if err != nil {
+		log.Printf("Failed to compute map views for session %s: %v", sessionID.Hex(), err)
+		return
+	}
+
+	owner := views.Owner(move.Token)
+	h.hub.BroadcastPerUser(sessionID, func(userID primitive.ObjectID) (models.WSMessage, bool) {
+		if !owner.IsZero() && userID == owner {
+			return mapUpdateMessage(sessionID, views.For(userID)), true
+		}
+
+		message := models.WSMessage{
+			Type:      models.MessageTypeTokenMoved,
+			Timestamp: time.Now(),
+			SessionID: sessionID,
+		}
+		switch {
+		case views.TokenVisible(userID, move.Token.ID):
+			// Only the parts of the move the viewer can see, so fog of war hides where
+			// the token came from and went through
+			to := move.Token.Position()
+			data := models.TokenMovedData{
+				TokenID: move.Token.ID,
+				Visible: true,
+				Name:    move.Token.Name,
+				To:      &to,
+				Path:    views.VisiblePath(userID, move.Path),
+			}
+			fromVisible := views.CellVisible(userID, move.From)
+			if fromVisible {
+				from := move.From
+				data.From = &from
+			}
+			if fromVisible && len(data.Path) == len(move.Path) {
+				data.Cost = move.Cost
+			}
+			message.Data = data
+		case views.CellVisible(userID, move.From):
+			message.Data = models.TokenMovedData{
+				TokenID: move.Token.ID,
+				Visible: false,
+			}
+		default:
+			return message, false
+		}
+		return message, true
+	})
+}
+
+func mapUpdateMessage(sessionID primitive.ObjectID, view *models.MapView) models.WSMessage {
+	return models.WSMessage{
+		Type:      models.MessageTypeMapUpdate,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.MapUpdateData{
+			Map: view,
+		},
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+	"dnd-simulator/internal/websocket"
+)
+
+type MechanicsHandler struct {
+	mechanicsService *services.MechanicsService
+	hub              *websocket.Hub
+}
+
+func NewMechanicsHandler(mechanicsService *services.MechanicsService, hub *websocket.Hub) *MechanicsHandler {
+	return &MechanicsHandler{
+		mechanicsService: mechanicsService,
+		hub:              hub,
+	}
+}
+
+// ListChanges returns the AI-proposed changes in the session's review queue (DM only)
+// GET /api/sessions/:id/mechanics?status=pending
+func (h *MechanicsHandler) ListChanges(c *gin.Context) {
+	session, _, ok := h.dmSession(c)
+	if !ok {
+		return
+	}
+
+	status := models.MechanicChangeStatus(c.Query("status"))
+	changes, err := h.mechanicsService.List(c.Request.Context(), session.ID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list mechanic changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
+
+// EditChange changes an AI-proposed change before it is approved (DM only)
+// PUT /api/sessions/:id/mechanics/:changeId
+func (h *MechanicsHandler) EditChange(c *gin.Context) {
+	session, userID, ok := h.dmSession(c)
+	if !ok {
+		return
+	}
+
+	changeID, err := primitive.ObjectIDFromHex(c.Param("changeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change ID"})
+		return
+	}
+
+	var req models.EditMechanicChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	change, err := h.mechanicsService.Edit(c.Request.Context(), session, changeID, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	publishMechanicChanges(h.hub, session, []models.MechanicChange{*change})
+	c.JSON(http.StatusOK, gin.H{"change": change})
+}
+
+// ApproveChange applies an AI-proposed change to the game (DM only)
+// POST /api/sessions/:id/mechanics/:changeId/approve
+func (h *MechanicsHandler) ApproveChange(c *gin.Context) {
+	session, userID, ok := h.dmSession(c)
+	if !ok {
+		return
+	}
+
+	changeID, err := primitive.ObjectIDFromHex(c.Param("changeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change ID"})
+		return
+	}
+
+	change, err := h.mechanicsService.Approve(c.Request.Context(), session, changeID, userID)
+	if change != nil {
+		publishMechanicChanges(h.hub, session, []models.MechanicChange{*change})
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "change": change})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"change": change})
+}
+
+// RejectChange discards an AI-proposed change, keeping the reason for prompt tuning (DM only)
+// POST /api/sessions/:id/mechanics/:changeId/reject
+func (h *MechanicsHandler) RejectChange(c *gin.Context) {
+	session, userID, ok := h.dmSession(c)
+	if !ok {
+		return
+	}
+
+	changeID, err := primitive.ObjectIDFromHex(c.Param("changeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change ID"})
+		return
+	}
+
+	var req models.RejectMechanicChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	change, err := h.mechanicsService.Reject(c.Request.Context(), session, changeID, userID, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	publishMechanicChanges(h.hub, session, []models.MechanicChange{*change})
+	c.JSON(http.StatusOK, gin.H{"change": change})
+}
+
+// dmSession returns the session from SessionMemberMiddleware, answering 403 unless the
+// user is its DM
+func (h *MechanicsHandler) dmSession(c *gin.Context) (*models.GameSession, primitive.ObjectID, bool) {
+	userID, _, ok := sessionRequestIDs(c)
+	if !ok {
+		return nil, primitive.NilObjectID, false
+	}
+
+	value, _ := c.Get("session")
+	session, ok := value.(*models.GameSession)
+	if !ok || session.DMUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can review AI mechanics"})
+		return nil, primitive.NilObjectID, false
+	}
+	return session, userID, true
+}
+
+// publishMechanicChanges tells the DM about new or reviewed changes, and the table about
+// the ones that were applied. Roll requests go to whoever rolls, with the DC kept from
+// players, and a changed turn order goes to everyone.
+func publishMechanicChanges(hub *websocket.Hub, session *models.GameSession, changes []models.MechanicChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	hub.SendToAudience(session.ID, websocket.Audience{Roles: []websocket.ClientRole{websocket.RoleDM}}, models.WSMessage{
+		Type:      models.MessageTypeMechanicUpdate,
+		Timestamp: time.Now(),
+		SessionID: session.ID,
+		Data:      models.MechanicUpdateData{Changes: changes},
+	})
+
+	turnOrderChanged := false
+	for i := range changes {
+		change := &changes[i]
+		if change.Status != models.MechanicApplied {
+			continue
+		}
+
+		// A roll request only goes to whoever rolls, since the change carries the DC
+		if change.Kind == models.MechanicRollRequest {
+			spec := change.Spec()
+			userID := spec.Target.UserID
+			if userID.IsZero() {
+				userID = session.DMUserID
+			}
+			roll := spec.Roll
+			if roll != nil && userID != session.DMUserID {
+				hidden := *roll
+				hidden.DC = 0
+				roll = &hidden
+			}
+			hub.SendToUser(session.ID, userID, models.WSMessage{
+				Type:      models.MessageTypeRollRequest,
+				Timestamp: time.Now(),
+				SessionID: session.ID,
+				Data: models.RollRequestData{
+					ChangeID:    change.ID,
+					Description: change.Description,
+					Target:      spec.Target,
+					Roll:        roll,
+				},
+			})
+			continue
+		}
+
+		hub.BroadcastToSession(session.ID, models.WSMessage{
+			Type:      models.MessageTypeMechanicApplied,
+			Timestamp: time.Now(),
+			SessionID: session.ID,
+			Data:      models.MechanicAppliedData{Change: change},
+		})
+		if change.Kind == models.MechanicNPCInitiative {
+			turnOrderChanged = true
+		}
+		// An NPC's attack is announced like any other resolved attack
+		if result, ok := change.Result["attack"].(*models.AttackResult); ok && change.Kind == models.MechanicNPCAttack {
+			hub.BroadcastToSession(session.ID, models.WSMessage{
+				Type:      models.MessageTypeAttackResult,
+				Timestamp: time.Now(),
+				SessionID: session.ID,
+				Data:      models.AttackResultData{Result: result},
+			})
+		}
+	}
+
+	if turnOrderChanged {
+		broadcastTurnOrder(hub, session)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"dnd-simulator/internal/services"
+)
+
+type MonsterHandler struct {
+	monsterService *services.MonsterService
+}
+
+func NewMonsterHandler(monsterService *services.MonsterService) *MonsterHandler {
+	return &MonsterHandler{
+		monsterService: monsterService,
+	}
+}
+
+// GetMonsters lists SRD monsters with optional search and filters
+// GET /api/dnd/monsters?q=&type=&size=&cr=&min_cr=&max_cr=
+func (h *MonsterHandler) GetMonsters(c *gin.Context) {
+	filter := services.MonsterFilter{
+		Query: c.Query("q"),
+		Type:  c.Query("type"),
+		Size:  c.Query("size"),
+	}
+
+	// An exact CR sets both bounds
+	if cr := c.Query("cr"); cr != "" {
+		value, err := services.ParseChallengeRating(cr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.MinCR = &value
+		filter.MaxCR = &value
+	}
+
+	if minCR := c.Query("min_cr"); minCR != "" {
+		value, err := services.ParseChallengeRating(minCR)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.MinCR = &value
+	}
+
+	if maxCR := c.Query("max_cr"); maxCR != "" {
+		value, err := services.ParseChallengeRating(maxCR)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.MaxCR = &value
+	}
+
+	monsters := h.monsterService.SearchMonsters(filter)
+	c.JSON(http.StatusOK, gin.H{
+		"monsters": monsters,
+		"count":    len(monsters),
+	})
+}
+
+// GetMonster returns a single monster stat block
+// GET /api/dnd/monsters/:slug
+func (h *MonsterHandler) GetMonster(c *gin.Context) {
+	monster, err := h.monsterService.GetMonster(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"monster": monster})
+}
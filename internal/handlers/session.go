@@ -1,24 +1,31 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"dnd-simulator/internal/models"
 	"dnd-simulator/internal/services"
+	"dnd-simulator/internal/websocket"
 )
 
 type SessionHandler struct {
 	sessionService  *services.SessionService
 	campaignService *services.CampaignService
+	hub             *websocket.Hub
 }
 
-func NewSessionHandler(sessionService *services.SessionService, campaignService *services.CampaignService) *SessionHandler {
+func NewSessionHandler(sessionService *services.SessionService, campaignService *services.CampaignService, hub *websocket.Hub) *SessionHandler {
 	return &SessionHandler{
 		sessionService:  sessionService,
 		campaignService: campaignService,
+		hub:             hub,
 	}
 }
 
@@ -44,10 +51,11 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, session)
+	c.JSON(http.StatusCreated, session.WithoutMap())
 }
 
-// GetSession retrieves a specific session
+// GetSession retrieves a specific session, without its battle map, which the map endpoint
+// returns as the user can see it
 // GET /api/sessions/:id
 func (h *SessionHandler) GetSession(c *gin.Context) {
 	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
@@ -62,10 +70,11 @@ func (h *SessionHandler) GetSession(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, session)
+	c.JSON(http.StatusOK, session.WithoutMap())
 }
 
-// GetCampaignSessions retrieves all sessions for a campaign
+// GetCampaignSessions retrieves all sessions for a campaign. CampaignMemberMiddleware keeps
+// it to the campaign's DM and players.
 // GET /api/campaigns/:id/sessions
 func (h *SessionHandler) GetCampaignSessions(c *gin.Context) {
 	campaignID, err := primitive.ObjectIDFromHex(c.Param("id"))
@@ -80,6 +89,9 @@ func (h *SessionHandler) GetCampaignSessions(c *gin.Context) {
 		return
 	}
 
+	for i, session := range sessions {
+		sessions[i] = session.WithoutMap()
+	}
 	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
 }
 
@@ -181,13 +193,203 @@ func (h *SessionHandler) SetInitiative(c *gin.Context) {
 
 	err = h.sessionService.SetInitiative(c.Request.Context(), sessionID, req.CharacterID, req.Initiative)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondSessionError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Initiative set successfully"})
 }
 
+// RollInitiative rolls initiative for the whole table (DM only)
+// POST /api/sessions/:id/initiative/roll
+func (h *SessionHandler) RollInitiative(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var req models.RollInitiativeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.sessionService.RollInitiative(c.Request.Context(), sessionID, userID.(primitive.ObjectID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if session.PendingInitiative == nil {
+		broadcastTurnOrder(h.hub, session)
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Initiative rolled",
+			"turn_order": session.TurnOrder,
+		})
+		return
+	}
+
+	// Players have until the deadline to roll their own; the server rolls for the rest
+	h.hub.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeInitiativeRequest,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.InitiativeRequestData{
+			Deadline: session.PendingInitiative.Deadline,
+			Awaiting: session.PendingInitiative.Awaiting,
+		},
+	})
+	time.AfterFunc(time.Until(session.PendingInitiative.Deadline), func() {
+		h.finalizeInitiative(sessionID)
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":            "Waiting for players to roll initiative",
+		"pending_initiative": session.PendingInitiative,
+	})
+}
+
+// RollOwnInitiative lets a player roll for their own character during the roll window
+// POST /api/sessions/:id/initiative/self
+func (h *SessionHandler) RollOwnInitiative(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	username, _ := c.Get("username")
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var req models.RollOwnInitiativeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roll, session, err := h.sessionService.RollOwnInitiative(c.Request.Context(), sessionID, userID.(primitive.ObjectID), &req)
+	if err != nil {
+		if errors.Is(err, services.ErrInitiativeWindowClosed) && session != nil {
+			// This roll was what noticed the deadline had passed
+			broadcastTurnOrder(h.hub, session)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	usernameStr, _ := username.(string)
+	h.hub.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeDiceResult,
+		Timestamp: time.Now(),
+		UserID:    userID.(primitive.ObjectID),
+		Username:  usernameStr,
+		SessionID: sessionID,
+		Data: models.DiceResultData{
+			Dice:        roll.Dice,
+			Result:      roll.Result,
+			Total:       roll.Total,
+			Modifier:    roll.Modifier,
+			Purpose:     roll.Purpose,
+			CharacterID: roll.CharacterID,
+		},
+	})
+
+	if session.PendingInitiative == nil {
+		broadcastTurnOrder(h.hub, session)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Initiative rolled",
+		"result":  roll,
+	})
+}
+
+// finalizeInitiative closes the roll window once its deadline passes
+func (h *SessionHandler) finalizeInitiative(sessionID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := h.sessionService.FinalizeInitiative(ctx, sessionID)
+	if err != nil {
+		log.Printf("Failed to finalize initiative for session %s: %v", sessionID.Hex(), err)
+		return
+	}
+	if session != nil {
+		broadcastTurnOrder(h.hub, session)
+	}
+}
+
+// respondSessionError replies 409 with the current session state when err is a version
+// conflict, and 400 otherwise
+func respondSessionError(c *gin.Context, err error) {
+	var conflict *services.ConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"session": conflict.Session.WithoutMap(),
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// broadcastTurnOrder sends the current turn order to everyone in the session
+func broadcastTurnOrder(hub *websocket.Hub, session *models.GameSession) {
+	hub.BroadcastToSession(session.ID, models.WSMessage{
+		Type:      models.MessageTypeTurnOrder,
+		Timestamp: time.Now(),
+		SessionID: session.ID,
+		Data: models.TurnOrderData{
+			TurnOrder:   session.TurnOrder,
+			CurrentTurn: session.CurrentTurn,
+			Round:       session.Round,
+		},
+	})
+}
+
+// AddLairActions adds a lair action turn at initiative 20 (DM only)
+// POST /api/sessions/:id/turn/lair
+func (h *SessionHandler) AddLairActions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var req models.AddLairActionsRequest
+	c.ShouldBindJSON(&req) // Optional body
+
+	session, err := h.sessionService.AddLairActions(c.Request.Context(), sessionID, userID.(primitive.ObjectID), req.Name)
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	broadcastTurnOrder(h.hub, session)
+	c.JSON(http.StatusOK, gin.H{
+		"turn_order":   session.TurnOrder,
+		"current_turn": session.CurrentTurn,
+	})
+}
+
 // AdvanceTurn moves to the next turn in the order
 // POST /api/sessions/:id/turn/advance
 func (h *SessionHandler) AdvanceTurn(c *gin.Context) {
@@ -207,13 +409,45 @@ func (h *SessionHandler) AdvanceTurn(c *gin.Context) {
 	c.ShouldBindJSON(&req) // Optional body
 
 	userObjID := userID.(primitive.ObjectID)
-	err = h.sessionService.AdvanceTurn(c.Request.Context(), sessionID, userObjID, req.Force)
+	session, err := h.sessionService.AdvanceTurn(c.Request.Context(), sessionID, userObjID, req.Force, req.Version)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Turn advanced successfully"})
+		respondSessionError(c, err)
+		return
+	}
+
+	current := session.TurnOrder[session.CurrentTurn]
+	h.hub.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeTurnAdvance,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.TurnAdvanceData{
+			CurrentTurn: session.CurrentTurn,
+			Round:       session.Round,
+			Current:     current,
+			Version:     session.Version,
+		},
+	})
+
+	// Let the acting player (or the DM, for NPCs) know what they have to spend
+	actingUserID := current.UserID
+	if actingUserID.IsZero() {
+		actingUserID = session.DMUserID
+	}
+	h.hub.SendToUser(sessionID, actingUserID, models.WSMessage{
+		Type:      models.MessageTypeActionEconomy,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.ActionEconomyData{
+			Name:              current.Name,
+			Economy:           current.Economy,
+			MovementRemaining: current.Economy.MovementRemaining(),
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Turn advanced successfully",
+		"version": session.Version,
+	})
 }
 
 // GetSessionStatus returns current session state for WebSocket clients
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+)
+
+// secretMapSession is a session whose map has a token and a cell players mustn't see
+func secretMapSession() *models.GameSession {
+	now := time.Now()
+	return &models.GameSession{
+		ID:       primitive.NewObjectID(),
+		Name:     "Fog of War",
+		Status:   models.SessionStatusActive,
+		DMUserID: primitive.NewObjectID(),
+		Players:  []models.SessionPlayer{},
+		Map: &models.BattleMap{
+			Width:    10,
+			Height:   10,
+			CellFeet: 5,
+			Tokens:   []models.MapToken{{ID: primitive.NewObjectID(), Name: "Lurking Assassin", X: 9, Y: 9}},
+			Hidden:   []models.GridPoint{{X: 8, Y: 8}},
+		},
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// assertNoMap fails if a response body leaks anything from the session's battle map
+func assertNoMap(t *testing.T, body string) {
+	t.Helper()
+	for _, secret := range []string{"Lurking Assassin", `"map"`, `"hidden"`} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response contains %s: %s", secret, body)
+		}
+	}
+}
+
+func TestConflictResponseHasNoMap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	respondSessionError(c, &services.ConflictError{Session: secretMapSession()})
+
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want 409", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "Fog of War") {
+		t.Errorf("conflict doesn't include the current session: %s", recorder.Body.String())
+	}
+	assertNoMap(t, recorder.Body.String())
+}
+
+func TestGetSessionHasNoMap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t)
+	session := secretMapSession()
+	if _, err := db.GetCollection("sessions").InsertOne(context.Background(), session); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+
+	handler := NewSessionHandler(services.NewSessionService(db, services.NewDiceService()), services.NewCampaignService(db), nil)
+	router := gin.New()
+	router.GET("/sessions/:id", func(c *gin.Context) {
+		c.Set("user_id", primitive.NewObjectID()) // A player, not the DM
+		handler.GetSession(c)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/sessions/"+session.ID.Hex(), nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	assertNoMap(t, recorder.Body.String())
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+	"dnd-simulator/internal/websocket"
+)
+
+// overlayRollLimit is how many recent rolls the overlay shows
+const overlayRollLimit = 10
+
+type SpectatorHandler struct {
+	spectatorService *services.SpectatorService
+	hub              *websocket.Hub
+}
+
+func NewSpectatorHandler(spectatorService *services.SpectatorService, hub *websocket.Hub) *SpectatorHandler {
+	return &SpectatorHandler{
+		spectatorService: spectatorService,
+		hub:              hub,
+	}
+}
+
+// CreateLink issues an expiring spectator link for the session (DM only). The token is
+// only shown in this response.
+// POST /api/sessions/:id/spectators
+func (h *SpectatorHandler) CreateLink(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateSpectatorLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, token, err := h.spectatorService.CreateLink(c.Request.Context(), sessionID, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	base := "/api/spectate/" + sessionID.Hex()
+	query := "?token=" + url.QueryEscape(token)
+	c.JSON(http.StatusCreated, gin.H{
+		"link":        link,
+		"token":       token,
+		"ws_url":      base + "/ws" + query,
+		"overlay_url": base + "/overlay" + query,
+		"map_url":     base + "/map" + query,
+	})
+}
+
+// ListLinks returns the session's active spectator links (DM only)
+// GET /api/sessions/:id/spectators
+func (h *SpectatorHandler) ListLinks(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	value, _ := c.Get("session")
+	if session, ok := value.(*models.GameSession); !ok || session.DMUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the DM can list spectator links"})
+		return
+	}
+
+	links, err := h.spectatorService.ListLinks(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"links": links})
+}
+
+// RevokeLink stops a spectator link from working and disconnects anyone using it (DM only)
+// DELETE /api/sessions/:id/spectators/:linkId
+func (h *SpectatorHandler) RevokeLink(c *gin.Context) {
+	userID, sessionID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	linkID, err := primitive.ObjectIDFromHex(c.Param("linkId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		return
+	}
+
+	if err := h.spectatorService.RevokeLink(c.Request.Context(), sessionID, userID, linkID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.hub.DisconnectSpectators(sessionID, linkID)
+	c.JSON(http.StatusOK, gin.H{"message": "Spectator link revoked"})
+}
+
+// HandleWebSocket connects a spectator to the session's filtered, delayed feed
+// GET /api/spectate/:id/ws?token=
+func (h *SpectatorHandler) HandleWebSocket(c *gin.Context) {
+	h.hub.HandleSpectatorWebSocket(c)
+}
+
+// GetOverlay returns the session's current turn, party HP and recent public rolls, for
+// OBS browser sources. Like the feed, it runs the link's delay behind the game.
+// GET /api/spectate/:id/overlay?token=
+func (h *SpectatorHandler) GetOverlay(c *gin.Context) {
+	link, ok := spectatorLink(c)
+	if !ok {
+		return
+	}
+
+	overlay, err := h.spectatorService.GetOverlay(c.Request.Context(), link, overlayRollLimit)
+	if err != nil {
+		respondSpectatorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, overlay)
+}
+
+// GetMap returns the parts of the battle map the DM had revealed, the link's delay ago
+// GET /api/spectate/:id/map?token=
+func (h *SpectatorHandler) GetMap(c *gin.Context) {
+	link, ok := spectatorLink(c)
+	if !ok {
+		return
+	}
+
+	view, err := h.spectatorService.GetMap(c.Request.Context(), link)
+	if err != nil {
+		respondSpectatorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"map": view})
+}
+
+// respondSpectatorError tells the client to try again later while the delayed view hasn't
+// caught up yet
+func respondSpectatorError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrSpectatorViewNotReady) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+}
+
+// spectatorLink returns the link verified by the spectator middleware
+func spectatorLink(c *gin.Context) (*models.SpectatorLink, bool) {
+	value, exists := c.Get("spectator_link")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Spectator link not verified"})
+		return nil, false
+	}
+	return value.(*models.SpectatorLink), true
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/database"
+)
+
+// newTestDB connects to the MongoDB in TEST_MONGODB_URI and returns a fresh database that
+// is dropped when the test ends. Tests needing it are skipped when the variable is unset.
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	db := &database.DB{
+		Client:   client,
+		Database: client.Database("dnd_test_" + primitive.NewObjectID().Hex()),
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = db.Database.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return db
+}
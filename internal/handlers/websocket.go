@@ -14,15 +14,39 @@ import (
 type WebSocketHandler struct {
 	hub         *websocket.Hub
 	diceService *services.DiceService
+	chatService *services.ChatService
 }
 
-func NewWebSocketHandler(hub *websocket.Hub, diceService *services.DiceService) *WebSocketHandler {
+func NewWebSocketHandler(hub *websocket.Hub, diceService *services.DiceService, chatService *services.ChatService) *WebSocketHandler {
 	return &WebSocketHandler{
 		hub:         hub,
 		diceService: diceService,
+		chatService: chatService,
 	}
 }
 
+// ownsSessionCharacter checks that a character in a request is the caller's character in
+// the session loaded by the membership middleware. The DM may act for any character.
+func ownsSessionCharacter(c *gin.Context, userID, characterID primitive.ObjectID) bool {
+	if characterID.IsZero() {
+		return true
+	}
+	value, exists := c.Get("session")
+	if !exists {
+		return false
+	}
+	session := value.(*models.GameSession)
+	if session.DMUserID == userID {
+		return true
+	}
+	for _, player := range session.Players {
+		if player.UserID == userID && player.CharacterID == characterID {
+			return true
+		}
+	}
+	return false
+}
+
 // HandleWebSocket upgrades HTTP connection to WebSocket
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	h.hub.HandleWebSocket(c)
@@ -49,37 +73,59 @@ func (h *WebSocketHandler) SendChatMessage(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Content     string             `json:"content" binding:"required"`
-		CharacterID primitive.ObjectID `json:"character_id,omitempty"`
-		IsIC        bool               `json:"is_ic"`
-	}
-
+	var req models.SendChatMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	messageType := models.MessageTypeChatOOC
+	if !ownsSessionCharacter(c, userID.(primitive.ObjectID), req.CharacterID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Character is not yours in this session"})
+		return
+	}
+
+	messageType, chatType := models.MessageTypeChatOOC, "ooc"
 	if req.IsIC {
-		messageType = models.MessageTypeChatIC
+		messageType, chatType = models.MessageTypeChatIC, "ic"
+	}
+	if req.Visibility == models.ChatVisibilityWhisper {
+		messageType = models.MessageTypeWhisper
+	}
+
+	chatMsg := &models.SessionChatMessage{
+		SessionID:   sessionID,
+		UserID:      userID.(primitive.ObjectID),
+		Username:    username.(string),
+		CharacterID: req.CharacterID,
+		Message:     req.Content,
+		Type:        chatType,
+		Visibility:  req.Visibility,
+		Recipients:  req.Recipients,
+	}
+	audience, err := h.chatService.SendMessage(c.Request.Context(), chatMsg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	message := models.WSMessage{
 		Type:      messageType,
-		Timestamp: time.Now(),
-		UserID:    userID.(primitive.ObjectID),
-		Username:  username.(string),
+		Timestamp: chatMsg.Timestamp,
+		UserID:    chatMsg.UserID,
+		Username:  chatMsg.Username,
 		SessionID: sessionID,
-		Data: map[string]interface{}{
-			"content":      req.Content,
-			"character_id": req.CharacterID,
-			"is_ic":        req.IsIC,
+		Data: models.ChatMessageData{
+			ID:          chatMsg.ID,
+			Content:     req.Content,
+			CharacterID: req.CharacterID,
+			IsIC:        req.IsIC,
+			Visibility:  chatMsg.Visibility,
+			Recipients:  chatMsg.Recipients,
 		},
 	}
 
-	h.hub.BroadcastToSession(sessionID, message)
-	c.JSON(http.StatusOK, gin.H{"message": "Chat message sent"})
+	h.hub.SendToAudience(sessionID, websocket.Audience{UserIDs: audience}, message)
+	c.JSON(http.StatusOK, gin.H{"message": "Chat message sent", "id": chatMsg.ID})
 }
 
 // RollDice rolls dice and broadcasts the result to session
@@ -107,6 +153,7 @@ func (h *WebSocketHandler) RollDice(c *gin.Context) {
 		Dice        string             `json:"dice" binding:"required"`
 		Purpose     string             `json:"purpose"`
 		CharacterID primitive.ObjectID `json:"character_id,omitempty"`
+		Visibility  string             `json:"visibility,omitempty" binding:"omitempty,oneof=public dm self"` // dm: hidden roll seen by the DM
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -114,6 +161,11 @@ func (h *WebSocketHandler) RollDice(c *gin.Context) {
 		return
 	}
 
+	if !ownsSessionCharacter(c, userID.(primitive.ObjectID), req.CharacterID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Character is not yours in this session"})
+		return
+	}
+
 	// Roll the dice
 	diceResult, err := h.diceService.ParseAndRoll(req.Dice, req.Purpose)
 	if err != nil {
@@ -139,18 +191,30 @@ func (h *WebSocketHandler) RollDice(c *gin.Context) {
 		UserID:    userID.(primitive.ObjectID),
 		Username:  username.(string),
 		SessionID: sessionID,
-		Data: map[string]interface{}{
-			"dice":            diceResult.Dice,
-			"result":          diceResult.Result,
-			"total":           diceResult.Total,
-			"modifier":        diceResult.Modifier,
-			"purpose":         diceResult.Purpose,
-			"character_id":    diceResult.CharacterID,
-			"special_message": specialMessage,
+		Data: models.DiceResultData{
+			Dice:           diceResult.Dice,
+			Result:         diceResult.Result,
+			Total:          diceResult.Total,
+			Modifier:       diceResult.Modifier,
+			Purpose:        diceResult.Purpose,
+			CharacterID:    diceResult.CharacterID,
+			SpecialMessage: specialMessage,
+			Visibility:     req.Visibility,
 		},
 	}
 
-	h.hub.BroadcastToSession(sessionID, message)
+	// Hidden rolls go to the roller and the DM, self rolls to the roller alone
+	switch req.Visibility {
+	case models.ChatVisibilityDM:
+		h.hub.SendToAudience(sessionID, websocket.Audience{
+			UserIDs: []primitive.ObjectID{userID.(primitive.ObjectID)},
+			Roles:   []websocket.ClientRole{websocket.RoleDM},
+		}, message)
+	case models.RollVisibilitySelf:
+		h.hub.SendToUser(sessionID, userID.(primitive.ObjectID), message)
+	default:
+		h.hub.BroadcastToSession(sessionID, message)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Dice rolled",
 		"result":  diceResult,
@@ -190,17 +254,22 @@ func (h *WebSocketHandler) UpdateCharacter(c *gin.Context) {
 		return
 	}
 
+	if !ownsSessionCharacter(c, userID.(primitive.ObjectID), req.CharacterID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Character is not yours in this session"})
+		return
+	}
+
 	message := models.WSMessage{
 		Type:      models.MessageTypeCharacterUpdate,
 		Timestamp: time.Now(),
 		UserID:    userID.(primitive.ObjectID),
 		Username:  username.(string),
 		SessionID: sessionID,
-		Data: map[string]interface{}{
-			"character_id": req.CharacterID,
-			"field":        req.Field,
-			"value":        req.Value,
-			"old_value":    req.OldValue,
+		Data: models.CharacterUpdate{
+			CharacterID: req.CharacterID,
+			Field:       req.Field,
+			Value:       req.Value,
+			OldValue:    req.OldValue,
 		},
 	}
 
@@ -208,7 +277,8 @@ func (h *WebSocketHandler) UpdateCharacter(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Character update broadcasted"})
 }
 
-// GetSessionStatus returns information about active connections in a session
+// GetSessionStatus returns active connections in a session, each connected user's
+// presence and the latest ready check
 func (h *WebSocketHandler) GetSessionStatus(c *gin.Context) {
 	sessionIDStr := c.Param("id")
 	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
@@ -219,10 +289,25 @@ func (h *WebSocketHandler) GetSessionStatus(c *gin.Context) {
 
 	clientCount := h.hub.GetSessionClients(sessionID)
 	
+	presence, err := h.hub.SessionPresence(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	
+	// The membership middleware already loaded the session
+	var readyCheck *models.ReadyCheck
+	if value, exists := c.Get("session"); exists {
+		readyCheck = value.(*models.GameSession).ReadyCheck
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
-		"session_id":     sessionID,
-		"active_clients": clientCount,
-		"status":         "active",
+		"session_id":      sessionID,
+		"active_clients":  clientCount,
+		"connected_users": len(presence),
+		"presence":        presence,
+		"ready_check":     readyCheck,
+		"status":          "active",
 	})
 }
 
@@ -266,12 +351,12 @@ func (h *WebSocketHandler) RollQuickDice(c *gin.Context) {
 		UserID:    userID.(primitive.ObjectID),
 		Username:  username.(string),
 		SessionID: sessionID,
-		Data: map[string]interface{}{
-			"dice":     diceResult.Dice,
-			"result":   diceResult.Result,
-			"total":    diceResult.Total,
-			"modifier": diceResult.Modifier,
-			"purpose":  diceResult.Purpose,
+		Data: models.DiceResultData{
+			Dice:     diceResult.Dice,
+			Result:   diceResult.Result,
+			Total:    diceResult.Total,
+			Modifier: diceResult.Modifier,
+			Purpose:  diceResult.Purpose,
 		},
 	}
 
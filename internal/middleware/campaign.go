@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+)
+
+// CampaignMemberMiddleware only lets the campaign's DM and its players through to
+// /campaigns/:id routes. The loaded campaign is stored as "campaign".
+func CampaignMemberMiddleware(campaignService *services.CampaignService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get campaign ID from URL parameter
+		campaignIDStr := c.Param("id")
+		campaignID, err := primitive.ObjectIDFromHex(campaignIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+			c.Abort()
+			return
+		}
+
+		// Get user ID from auth middleware
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		campaign, err := campaignService.GetCampaignByID(campaignID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+			c.Abort()
+			return
+		}
+
+		if !isCampaignMember(campaign, userID.(primitive.ObjectID)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this campaign"})
+			c.Abort()
+			return
+		}
+
+		// Store campaign in context for handler use
+		c.Set("campaign", campaign)
+		c.Next()
+	}
+}
+
+// isCampaignMember reports whether the user is the campaign's DM or one of its players
+func isCampaignMember(campaign *models.Campaign, userID primitive.ObjectID) bool {
+	if campaign.DMID == userID {
+		return true
+	}
+	for _, playerID := range campaign.PlayerIDs {
+		if playerID == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+func TestIsCampaignMember(t *testing.T) {
+	dmID := primitive.NewObjectID()
+	playerID := primitive.NewObjectID()
+	campaign := &models.Campaign{DMID: dmID, PlayerIDs: []primitive.ObjectID{playerID}}
+
+	tests := []struct {
+		name   string
+		userID primitive.ObjectID
+		want   bool
+	}{
+		{name: "dm", userID: dmID, want: true},
+		{name: "player", userID: playerID, want: true},
+		{name: "stranger", userID: primitive.NewObjectID()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCampaignMember(campaign, tt.userID); got != tt.want {
+				t.Errorf("isCampaignMember = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
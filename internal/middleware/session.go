@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"dnd-simulator/internal/models"
+	"dnd-simulator/internal/services"
+)
+
+// SessionMemberMiddleware only lets the session's DM, its joined players and members of its
+// campaign through to /sessions/:id routes. The loaded session is stored as "session".
+func SessionMemberMiddleware(sessionService *services.SessionService, campaignService *services.CampaignService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get session ID from URL parameter
+		sessionIDStr := c.Param("id")
+		sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+			c.Abort()
+			return
+		}
+
+		// Get user ID from auth middleware
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		session, err := sessionService.GetSession(c.Request.Context(), sessionID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			c.Abort()
+			return
+		}
+
+		if !isSessionMember(session, userID.(primitive.ObjectID), campaignService) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this session"})
+			c.Abort()
+			return
+		}
+
+		// Store session in context for handler use
+		c.Set("session", session)
+		c.Next()
+	}
+}
+
+// isSessionMember checks the session's DM and players first, then falls back to the campaign
+func isSessionMember(session *models.GameSession, userID primitive.ObjectID, campaignService *services.CampaignService) bool {
+	if session.DMUserID == userID {
+		return true
+	}
+	for _, player := range session.Players {
+		if player.UserID == userID {
+			return true
+		}
+	}
+
+	campaign, err := campaignService.GetCampaignByID(session.CampaignID)
+	if err != nil {
+		return false
+	}
+	return isCampaignMember(campaign, userID)
+}
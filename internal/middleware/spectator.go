@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"dnd-simulator/internal/services"
+)
+
+// SpectatorAuthMiddleware admits holders of a valid spectator link for the session in
+// the URL. The token comes from the X-Spectator-Token header or the token query parameter
+// (for WebSockets and OBS browser sources). The link is stored as "spectator_link".
+func SpectatorAuthMiddleware(spectatorService *services.SpectatorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+			c.Abort()
+			return
+		}
+
+		token := c.GetHeader("X-Spectator-Token")
+		if token == "" {
+			token = c.Query("token")
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Spectator token required"})
+			c.Abort()
+			return
+		}
+
+		link, err := spectatorService.Authenticate(c.Request.Context(), sessionID, token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("spectator_link", link)
+		c.Next()
+	}
+}
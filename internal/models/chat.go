@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Chat message visibility
+const (
+	ChatVisibilityPublic  = "public"  // Everyone in the session
+	ChatVisibilityWhisper = "whisper" // The sender and the recipients
+	ChatVisibilityDM      = "dm"      // The sender and the DM, e.g. hidden rolls
+	RollVisibilitySelf    = "self"    // Dice rolls only: the roller alone
+)
+
+// SessionChatMessage is a session chat message, stored in its own collection
+type SessionChatMessage struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	SessionID   primitive.ObjectID   `bson:"session_id" json:"session_id"`
+	UserID      primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	Username    string               `bson:"username" json:"username"`
+	CharacterID primitive.ObjectID   `bson:"character_id,omitempty" json:"character_id,omitempty"`
+	Message     string               `bson:"message" json:"message"`
+	Type        string               `bson:"type" json:"type"`                                 // "ic", "ooc", "roll", "system"
+	Visibility  string               `bson:"visibility,omitempty" json:"visibility,omitempty"` // Defaults to public
+	Recipients  []primitive.ObjectID `bson:"recipients,omitempty" json:"recipients,omitempty"` // Users a whisper is addressed to
+	Timestamp   time.Time            `bson:"timestamp" json:"timestamp"`
+	EditedAt    *time.Time           `bson:"edited_at,omitempty" json:"edited_at,omitempty"`
+}
+
+// Audience returns the users allowed to see a message, or nil if everyone in the session may
+func (m *SessionChatMessage) Audience(dmUserID primitive.ObjectID) []primitive.ObjectID {
+	switch m.Visibility {
+	case ChatVisibilityWhisper:
+		return append([]primitive.ObjectID{m.UserID}, m.Recipients...)
+	case ChatVisibilityDM:
+		return []primitive.ObjectID{m.UserID, dmUserID}
+	}
+	return nil
+}
+
+// ChatPage is one page of a session's chat history, newest first
+type ChatPage struct {
+	Messages []SessionChatMessage `json:"messages"`
+	Before   *primitive.ObjectID  `json:"before,omitempty"` // Pass as ?before= to get the next (older) page
+}
+
+// Request DTOs for chat operations
+type SendChatMessageRequest struct {
+	Content     string               `json:"content" binding:"required,max=2000"`
+	CharacterID primitive.ObjectID   `json:"character_id,omitempty"`
+	IsIC        bool                 `json:"is_ic"`
+	Visibility  string               `json:"visibility,omitempty" binding:"omitempty,oneof=public whisper dm"`
+	Recipients  []primitive.ObjectID `json:"recipients,omitempty"` // Required for whispers
+}
+
+type EditChatMessageRequest struct {
+	Message string `json:"message" binding:"required,max=2000"`
+}
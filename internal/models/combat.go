@@ -0,0 +1,97 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AreaShape string
+
+const (
+	AreaSphere AreaShape = "sphere" // Size is the radius around Origin
+	AreaCone   AreaShape = "cone"   // Size is the length from Origin toward Target; as wide as it is long
+	AreaLine   AreaShape = "line"   // Size is the length from Origin toward Target
+	AreaCube   AreaShape = "cube"   // Size is the side; Origin is a corner and the cube extends toward Target
+)
+
+// AreaTemplate is an area-of-effect shape placed on the battle map
+type AreaTemplate struct {
+	Shape  AreaShape `json:"shape" bson:"shape" binding:"required,oneof=sphere cone line cube"`
+	Origin GridPoint `json:"origin" bson:"origin"`
+	Target GridPoint `json:"target,omitempty" bson:"target,omitempty"`                                 // Direction for cones, lines and cubes
+	Size   int       `json:"size" bson:"size" binding:"required,min=5,max=500"`                        // Feet
+	Width  int       `json:"width,omitempty" bson:"width,omitempty" binding:"omitempty,min=5,max=100"` // Line width in feet, defaults to 5
+}
+
+// AreaTarget is a token caught in an area of effect and how the effect resolved for it
+type AreaTarget struct {
+	TokenID     primitive.ObjectID `json:"token_id"`
+	Name        string             `json:"name"`
+	CharacterID primitive.ObjectID `json:"character_id,omitempty"`
+	CombatantID primitive.ObjectID `json:"combatant_id,omitempty"`
+	Save        *DiceRoll          `json:"save,omitempty"`
+	Saved       bool               `json:"saved"`
+	Damage      int                `json:"damage"`
+	DamageNote  string             `json:"damage_note,omitempty"` // "resistant", "immune" or "vulnerable"
+	HPBefore    int                `json:"hp_before"`
+	HPAfter     int                `json:"hp_after"`
+}
+
+// AreaEffect is the compound result of resolving an area of effect against every target in it
+type AreaEffect struct {
+	Name        string       `json:"name"`
+	Template    AreaTemplate `json:"template"`
+	Cells       []GridPoint  `json:"cells"`
+	SaveAbility string       `json:"save_ability"`
+	SaveDC      int          `json:"save_dc"`
+	DamageRoll  *DiceRoll    `json:"damage_roll"`
+	DamageType  string       `json:"damage_type,omitempty"`
+	OnSave      string       `json:"on_save"` // "half" or "none"
+	Targets     []AreaTarget `json:"targets"`
+}
+
+// Request DTOs for combat resolution
+type PreviewAreaRequest struct {
+	Template AreaTemplate `json:"template" binding:"required"`
+}
+
+type ResolveAreaRequest struct {
+	Caster      CombatActor  `json:"caster,omitempty"`                // Spends the caster's action; the DM may resolve without a caster
+	Name        string       `json:"name" binding:"required,max=100"` // e.g., "Fireball"
+	Template    AreaTemplate `json:"template" binding:"required"`
+	SaveAbility string       `json:"save_ability" binding:"required,oneof=strength dexterity constitution intelligence wisdom charisma"`
+	SaveDC      int          `json:"save_dc" binding:"required,min=1,max=30"`
+	DamageDice  string       `json:"damage_dice" binding:"required"` // e.g., "8d6"
+	DamageType  string       `json:"damage_type,omitempty"`
+	OnSave      string       `json:"on_save,omitempty" binding:"omitempty,oneof=half none"` // Defaults to half
+}
+
+// AttackResult is the full resolution of a single attack roll against a target
+type AttackResult struct {
+	AttackerName string             `json:"attacker_name"`
+	AttackerID   primitive.ObjectID `json:"attacker_id"` // Character or combatant ID
+	TargetName   string             `json:"target_name"`
+	TargetID     primitive.ObjectID `json:"target_id"`
+	Attack       string             `json:"attack"` // Weapon or monster action name
+	Advantage    string             `json:"advantage,omitempty"`
+	AttackRoll   *DiceRoll          `json:"attack_roll"`
+	Natural      int                `json:"natural"` // The d20 that counted
+	TargetAC     int                `json:"target_ac"`
+	Hit          bool               `json:"hit"`
+	Critical     bool               `json:"critical"`
+	DamageRolls  []*DiceRoll        `json:"damage_rolls,omitempty"`
+	DamageType   string             `json:"damage_type,omitempty"`
+	Damage       int                `json:"damage"` // Total after resistances
+	DamageNote   string             `json:"damage_note,omitempty"`
+	HPBefore     int                `json:"hp_before"`
+	HPAfter      int                `json:"hp_after"`
+	Narrative    string             `json:"narrative"` // One-line description for chat and the AI DM
+}
+
+type ResolveAttackRequest struct {
+	Attacker    CombatActor `json:"attacker,omitempty"` // Defaults to whoever's turn it is
+	Target      CombatActor `json:"target"`
+	Weapon      string      `json:"weapon,omitempty"` // Character weapon, defaults to the first one carried
+	Action      string      `json:"action,omitempty"` // Monster action, defaults to its first attack
+	Advantage   string      `json:"advantage,omitempty" binding:"omitempty,oneof=advantage disadvantage"`
+	BonusAction bool        `json:"bonus_action,omitempty"` // e.g., off-hand attack
+}
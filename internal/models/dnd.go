@@ -52,6 +52,7 @@ type Character struct {
 	Background        string               `bson:"background" json:"background" binding:"required"`
 	Level             int                  `bson:"level" json:"level"`
 	ExperiencePoints  int                  `bson:"experience_points" json:"experience_points"`
+	XPEncounters      []primitive.ObjectID `bson:"xp_encounters,omitempty" json:"-"` // Encounters whose XP was credited, so a retried award can't count twice
 	
 	// Core Abilities
 	Abilities         AbilityScores        `bson:"abilities" json:"abilities"`
@@ -61,6 +62,7 @@ type Character struct {
 	MaxHP             int                  `bson:"max_hp" json:"max_hp"`
 	ArmorClass        int                  `bson:"armor_class" json:"armor_class"`
 	Initiative        int                  `bson:"initiative" json:"initiative"`
+	Conditions        []string             `bson:"conditions,omitempty" json:"conditions,omitempty"` // e.g., ["prone", "poisoned"]
 	Speed             int                  `bson:"speed" json:"speed"`
 	
 	// Proficiencies
@@ -169,12 +171,67 @@ type AIResponse struct {
 	GameMechanics []GameMechanic     `bson:"game_mechanics,omitempty" json:"game_mechanics,omitempty"`
 	Timestamp     time.Time          `bson:"timestamp" json:"timestamp"`
 	TokensUsed    int                `bson:"tokens_used" json:"tokens_used"`
+	Provider      string             `bson:"provider,omitempty" json:"provider,omitempty"` // LLM provider and model that wrote it
+	Model         string             `bson:"model,omitempty" json:"model,omitempty"`
+	NPCActions    []NPCAction        `bson:"npc_actions,omitempty" json:"npc_actions,omitempty"`
+	SceneChanges  *SceneChange       `bson:"scene_changes,omitempty" json:"scene_changes,omitempty"`
+	Structured    bool               `bson:"structured" json:"structured"` // Mechanics came from validated structured output, not guessed from the text
 }
 
 type GameMechanic struct {
-	Type        string                 `json:"type"`        // "dice_roll", "status_effect", "damage", etc.
-	Description string                 `json:"description"`
-	Target      string                 `json:"target,omitempty"`
-	Value       interface{}            `json:"value,omitempty"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Type         string                 `json:"type"`        // "skill_check", "attack_roll", "saving_throw", "damage", "condition", "initiative"
+	Description  string                 `json:"description"`
+	Target       string                 `json:"target,omitempty"`
+	Value        interface{}            `json:"value,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Requirements *RollRequirements      `bson:"requirements,omitempty" json:"requirements,omitempty"`
+	Effects      *MechanicEffects       `bson:"effects,omitempty" json:"effects,omitempty"`
+}
+
+// RollRequirements is the roll a mechanic calls for
+type RollRequirements struct {
+	RollType     string `bson:"roll_type" json:"roll_type"`                               // "d20", "damage", etc.
+	DC           int    `bson:"dc,omitempty" json:"dc,omitempty"`                         // Difficulty Class for checks
+	Skill        string `bson:"skill,omitempty" json:"skill,omitempty"`                   // For skill checks
+	SaveType     string `bson:"save_type,omitempty" json:"save_type,omitempty"`           // For saving throws
+	AttackBonus  int    `bson:"attack_bonus,omitempty" json:"attack_bonus,omitempty"`
+	DamageDice   string `bson:"damage_dice,omitempty" json:"damage_dice,omitempty"`       // e.g., "2d6+3"
+	DamageType   string `bson:"damage_type,omitempty" json:"damage_type,omitempty"`       // e.g., "fire", "slashing"
+	Advantage    bool   `bson:"advantage,omitempty" json:"advantage,omitempty"`
+	Disadvantage bool   `bson:"disadvantage,omitempty" json:"disadvantage,omitempty"`
+}
+
+// MechanicEffects is what follows from a mechanic's roll
+type MechanicEffects struct {
+	OnSuccess  string   `bson:"on_success,omitempty" json:"on_success,omitempty"`
+	OnFailure  string   `bson:"on_failure,omitempty" json:"on_failure,omitempty"`
+	Conditions []string `bson:"conditions,omitempty" json:"conditions,omitempty"` // e.g., ["prone", "frightened"]
+	Duration   string   `bson:"duration,omitempty" json:"duration,omitempty"`     // e.g., "1 minute", "until end of turn"
+}
+
+type NPCAction struct {
+	NPCName    string `bson:"npc_name" json:"npc_name"`
+	Action     string `bson:"action" json:"action"`
+	Target     string `bson:"target,omitempty" json:"target,omitempty"`
+	Initiative int    `bson:"initiative,omitempty" json:"initiative,omitempty"`
+}
+
+type SceneChange struct {
+	NewLocation string   `bson:"new_location,omitempty" json:"new_location,omitempty"`
+	TimeChange  string   `bson:"time_change,omitempty" json:"time_change,omitempty"`
+	Weather     string   `bson:"weather,omitempty" json:"weather,omitempty"`
+	Visibility  string   `bson:"visibility,omitempty" json:"visibility,omitempty"`
+	Hazards     []string `bson:"hazards,omitempty" json:"hazards,omitempty"`
+}
+
+// AIContext contains all the context needed for AI to generate appropriate responses
+type AIContext struct {
+	SessionID    primitive.ObjectID `json:"session_id"`
+	Campaign     *Campaign          `json:"campaign"`
+	CurrentScene string             `json:"current_scene"`
+	Characters   []Character        `json:"characters"`
+	RecentEvents []GameEvent        `json:"recent_events"`
+	PlayerAction PlayerAction       `json:"player_action"`
+	TurnOrder    []TurnEntry        `json:"turn_order"`
+	CurrentTurn  int                `json:"current_turn"`
 }
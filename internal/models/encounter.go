@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Encounter groups the combatants for a fight within a session
+type Encounter struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SessionID  primitive.ObjectID `bson:"session_id" json:"session_id"`
+	Name       string             `bson:"name" json:"name"`
+	Status     EncounterStatus    `bson:"status" json:"status"`
+	Combatants []Combatant        `bson:"combatants" json:"combatants"`
+	Outcome    EncounterOutcome   `bson:"outcome,omitempty" json:"outcome,omitempty"`
+	EndedAt    *time.Time         `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
+	XPAward    *XPAward           `bson:"xp_award,omitempty" json:"xp_award,omitempty"`     // Fixed when a victory is recorded
+	XPAwarded  bool               `bson:"xp_awarded,omitempty" json:"xp_awarded,omitempty"` // Set once the award is credited and logged
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type EncounterStatus string
+
+const (
+	EncounterStatusPlanned   EncounterStatus = "planned"   // Built but not yet started
+	EncounterStatusActive    EncounterStatus = "active"    // Combat in progress
+	EncounterStatusCompleted EncounterStatus = "completed" // Combat resolved
+)
+
+type EncounterOutcome string
+
+const (
+	EncounterOutcomeVictory EncounterOutcome = "victory" // Party defeated the monsters
+	EncounterOutcomeDefeat  EncounterOutcome = "defeat"  // Party was defeated
+	EncounterOutcomeFled    EncounterOutcome = "fled"    // One side escaped
+)
+
+// Combatant is a monster instance taking part in an encounter
+type Combatant struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	MonsterSlug  string             `bson:"monster_slug" json:"monster_slug"`
+	Name         string             `bson:"name" json:"name"`
+	ArmorClass   int                `bson:"armor_class" json:"armor_class"`
+	MaxHP        int                `bson:"max_hp" json:"max_hp"`
+	CurrentHP    int                `bson:"current_hp" json:"current_hp"`
+	HitPointRoll *DiceRoll          `bson:"hit_point_roll,omitempty" json:"hit_point_roll,omitempty"`
+	Abilities    AbilityScores      `bson:"abilities" json:"abilities"`
+	Speed        int                `bson:"speed" json:"speed"`
+	XP           int                `bson:"xp" json:"xp"`
+	Conditions   []string           `bson:"conditions,omitempty" json:"conditions,omitempty"`
+}
+
+// Request DTOs for encounter operations
+type MonsterGroup struct {
+	Slug  string `json:"slug" binding:"required"`
+	Count int    `json:"count" binding:"omitempty,min=1,max=20"`
+	Name  string `json:"name,omitempty"` // Optional display name override
+}
+
+type CreateEncounterRequest struct {
+	Name     string         `json:"name" binding:"required,min=2,max=100"`
+	Monsters []MonsterGroup `json:"monsters,omitempty" binding:"dive"`
+}
+
+type AddMonstersRequest struct {
+	Monsters []MonsterGroup `json:"monsters" binding:"required,min=1,dive"`
+}
+
+type PreviewEncounterRequest struct {
+	Monsters []MonsterGroup `json:"monsters" binding:"required,min=1,dive"`
+}
+
+type EndEncounterRequest struct {
+	Outcome EncounterOutcome `json:"outcome" binding:"required,oneof=victory defeat fled"`
+}
+
+// XPThreshold holds the DMG per-character XP thresholds for a level
+type XPThreshold struct {
+	Easy   int `json:"easy"`
+	Medium int `json:"medium"`
+	Hard   int `json:"hard"`
+	Deadly int `json:"deadly"`
+}
+
+// EncounterDifficulty is the result of the DMG encounter-building calculation
+type EncounterDifficulty struct {
+	PartyLevels     []int       `json:"party_levels"`
+	PartyThresholds XPThreshold `json:"party_thresholds"`
+	MonsterCount    int         `json:"monster_count"`
+	TotalXP         int         `json:"total_xp"`    // Sum of monster XP (what the party earns)
+	Multiplier      float64     `json:"multiplier"`  // Group-size multiplier
+	AdjustedXP      int         `json:"adjusted_xp"` // TotalXP x Multiplier, compared against thresholds
+	Difficulty      string      `json:"difficulty"`  // "trivial", "easy", "medium", "hard", "deadly"
+	XPPerCharacter  int         `json:"xp_per_character"`
+}
+
+// XPAward records how encounter XP was split after a victory
+type XPAward struct {
+	EncounterID    primitive.ObjectID   `json:"encounter_id" bson:"encounter_id"`
+	TotalXP        int                  `json:"total_xp" bson:"total_xp"`
+	XPPerCharacter int                  `json:"xp_per_character" bson:"xp_per_character"`
+	CharacterIDs   []primitive.ObjectID `json:"character_ids" bson:"character_ids"`
+	CharacterNames []string             `json:"character_names" bson:"character_names"`
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BattleMap is a session's tactical grid. Only non-open terrain cells are stored.
+type BattleMap struct {
+	Width     int           `bson:"width" json:"width"`         // Cells
+	Height    int           `bson:"height" json:"height"`       // Cells
+	CellFeet  int           `bson:"cell_feet" json:"cell_feet"` // Feet per cell side
+	Terrain   []TerrainCell `bson:"terrain" json:"terrain"`
+	Tokens    []MapToken    `bson:"tokens" json:"tokens"`
+	Lighting  LightLevel    `bson:"lighting,omitempty" json:"lighting,omitempty"` // Ambient light; bright when empty
+	Lights    []LightSource `bson:"lights,omitempty" json:"lights,omitempty"`
+	Revealed  []GridPoint   `bson:"revealed,omitempty" json:"revealed,omitempty"` // Cells the DM has revealed to every player
+	Hidden    []GridPoint   `bson:"hidden,omitempty" json:"hidden,omitempty"`     // Cells the DM hides even from players in sight of them
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+type LightLevel string
+
+const (
+	LightBright LightLevel = "bright"
+	LightDim    LightLevel = "dim"
+	LightDark   LightLevel = "dark"
+)
+
+// LightSource lights cells within its radii that it has line of sight to
+type LightSource struct {
+	ID     primitive.ObjectID `bson:"_id" json:"id"`
+	Name   string             `bson:"name,omitempty" json:"name,omitempty"` // e.g., "torch", "brazier"
+	X      int                `bson:"x" json:"x"`
+	Y      int                `bson:"y" json:"y"`
+	Bright int                `bson:"bright" json:"bright"` // Feet of bright light
+	Dim    int                `bson:"dim" json:"dim"`       // Additional feet of dim light beyond the bright radius
+}
+
+type TerrainType string
+
+const (
+	TerrainDifficult TerrainType = "difficult" // Costs double movement
+	TerrainWall      TerrainType = "wall"      // Impassable
+	TerrainWater     TerrainType = "water"     // Swimming; costs double movement
+)
+
+type TerrainCell struct {
+	X    int         `bson:"x" json:"x"`
+	Y    int         `bson:"y" json:"y"`
+	Type TerrainType `bson:"type" json:"type" binding:"required,oneof=difficult wall water"`
+}
+
+// GridPoint is a cell coordinate on the battle map, with 0,0 at the top left
+type GridPoint struct {
+	X int `bson:"x" json:"x"`
+	Y int `bson:"y" json:"y"`
+}
+
+// MapToken is a piece on the battle map, usually bound to a combatant
+type MapToken struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	CharacterID primitive.ObjectID `bson:"character_id,omitempty" json:"character_id,omitempty"`
+	CombatantID primitive.ObjectID `bson:"combatant_id,omitempty" json:"combatant_id,omitempty"` // Encounter combatant for NPCs
+	X           int                `bson:"x" json:"x"`
+	Y           int                `bson:"y" json:"y"`
+}
+
+// Position returns the token's cell
+func (t MapToken) Position() GridPoint {
+	return GridPoint{X: t.X, Y: t.Y}
+}
+
+// MapView is the part of a battle map one viewer can see. Players only get the
+// terrain, lights and tokens in cells they can currently see or the DM revealed.
+type MapView struct {
+	Width      int           `json:"width"`
+	Height     int           `json:"height"`
+	CellFeet   int           `json:"cell_feet"`
+	Lighting   LightLevel    `json:"lighting,omitempty"`
+	Terrain    []TerrainCell `json:"terrain"`
+	Tokens     []MapToken    `json:"tokens"`
+	Lights     []LightSource `json:"lights"`
+	Visible    []GridPoint   `json:"visible,omitempty"` // Omitted for full vision
+	FullVision bool          `json:"full_vision"`       // The DM sees everything
+	Revealed   []GridPoint   `json:"revealed,omitempty"`
+	Hidden     []GridPoint   `json:"hidden,omitempty"`
+}
+
+// Request DTOs for battle map operations
+type SetMapRequest struct {
+	Width    int           `json:"width" binding:"required,min=1,max=200"`
+	Height   int           `json:"height" binding:"required,min=1,max=200"`
+	CellFeet int           `json:"cell_feet,omitempty" binding:"omitempty,min=1,max=100"` // Defaults to 5
+	Terrain  []TerrainCell `json:"terrain,omitempty" binding:"dive"`
+	Lighting LightLevel    `json:"lighting,omitempty" binding:"omitempty,oneof=bright dim dark"`
+}
+
+type AddLightRequest struct {
+	Name   string `json:"name,omitempty"`
+	X      int    `json:"x" binding:"min=0"`
+	Y      int    `json:"y" binding:"min=0"`
+	Bright int    `json:"bright" binding:"min=0,max=300"`
+	Dim    int    `json:"dim" binding:"min=0,max=300"`
+}
+
+// MapRegionRequest selects a rectangle of cells for the DM to reveal or hide
+type MapRegionRequest struct {
+	X      int `json:"x" binding:"min=0"`
+	Y      int `json:"y" binding:"min=0"`
+	Width  int `json:"width" binding:"required,min=1"`
+	Height int `json:"height" binding:"required,min=1"`
+}
+
+type PlaceTokenRequest struct {
+	Name        string             `json:"name,omitempty"` // Defaults to the character or combatant name
+	CharacterID primitive.ObjectID `json:"character_id,omitempty"`
+	CombatantID primitive.ObjectID `json:"combatant_id,omitempty"`
+	X           int                `json:"x" binding:"min=0"`
+	Y           int                `json:"y" binding:"min=0"`
+}
+
+type MoveTokenRequest struct {
+	Path []GridPoint `json:"path" binding:"required,min=1,max=100"` // Cells stepped through, ending at the destination
+	Free bool        `json:"free,omitempty"`                        // DM only: move without spending movement (shoves, teleports)
+}
+
+// TokenMove is the outcome of a validated token move
+type TokenMove struct {
+	Token MapToken    `json:"token"`
+	From  GridPoint   `json:"from"`
+	Path  []GridPoint `json:"path"`
+	Cost  int         `json:"cost"` // Feet of movement spent
+}
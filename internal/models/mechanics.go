@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MechanicChangeKind is what a proposed change does to the game
+type MechanicChangeKind string
+
+const (
+	MechanicRollRequest   MechanicChangeKind = "roll_request"   // Ask a player (or the DM, for NPCs) to roll
+	MechanicDamage        MechanicChangeKind = "damage"         // Take hit points from a character or combatant
+	MechanicCondition     MechanicChangeKind = "condition"      // Add conditions such as prone
+	MechanicNPCInitiative MechanicChangeKind = "npc_initiative" // Add an NPC to the turn order
+	MechanicScene         MechanicChangeKind = "scene"          // Change the scene
+	MechanicNPCAttack     MechanicChangeKind = "npc_attack"     // The NPC whose turn it is attacks, rolled against the target's AC
+)
+
+// MechanicChangeStatus is where a proposed change is in the DM's review
+type MechanicChangeStatus string
+
+const (
+	MechanicPending  MechanicChangeStatus = "pending"
+	MechanicApplying MechanicChangeStatus = "applying" // Approved and being applied, so it can't be approved twice; reviewable again if it stays stuck
+	MechanicApplied  MechanicChangeStatus = "applied"
+	MechanicRejected MechanicChangeStatus = "rejected" // Kept, with the DM's reason, for prompt tuning
+	MechanicFailed   MechanicChangeStatus = "failed"   // Approved but couldn't be applied; can be edited and approved again
+)
+
+// MechanicChange is a change to game state the AI DM proposed, turned from its structured
+// mechanics. It waits for the DM to approve, edit or reject it, unless the campaign applies
+// AI mechanics automatically.
+type MechanicChange struct {
+	ID           primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	SessionID    primitive.ObjectID     `bson:"session_id" json:"session_id"`
+	AIResponseID primitive.ObjectID     `bson:"ai_response_id" json:"ai_response_id"`
+	Kind         MechanicChangeKind     `bson:"kind" json:"kind"`
+	Status       MechanicChangeStatus   `bson:"status" json:"status"`
+	Description  string                 `bson:"description" json:"description"`
+	Proposed     MechanicChangeSpec     `bson:"proposed" json:"proposed"`                 // As the AI proposed it
+	Edited       *MechanicChangeSpec    `bson:"edited,omitempty" json:"edited,omitempty"` // The DM's version, if they edited it
+	Result       map[string]interface{} `bson:"result,omitempty" json:"result,omitempty"` // What applying it did, e.g. damage dealt
+	Error        string                 `bson:"error,omitempty" json:"error,omitempty"`   // Why applying it failed
+	Reason       string                 `bson:"reason,omitempty" json:"reason,omitempty"` // Why the DM rejected it
+	AutoApplied  bool                   `bson:"auto_applied,omitempty" json:"auto_applied,omitempty"`
+	ReviewedBy   primitive.ObjectID     `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time             `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	ApplyingAt   *time.Time             `bson:"applying_at,omitempty" json:"applying_at,omitempty"` // When it was last claimed for applying
+	CreatedAt    time.Time              `bson:"created_at" json:"created_at"`
+}
+
+// Spec returns the version of the change to apply: the DM's edit, else the proposal
+func (c *MechanicChange) Spec() MechanicChangeSpec {
+	if c.Edited != nil {
+		return *c.Edited
+	}
+	return c.Proposed
+}
+
+// MechanicChangeSpec is what a change does. Which fields are set depends on its kind.
+type MechanicChangeSpec struct {
+	Target     MechanicTarget    `bson:"target" json:"target"`
+	Roll       *RollRequirements `bson:"roll,omitempty" json:"roll,omitempty"`             // roll_request; npc_attack: advantage or disadvantage
+	Effects    *MechanicEffects  `bson:"effects,omitempty" json:"effects,omitempty"`       // roll_request: what follows the roll
+	Damage     *DamageChange     `bson:"damage,omitempty" json:"damage,omitempty"`         // damage
+	Conditions []string          `bson:"conditions,omitempty" json:"conditions,omitempty"` // condition
+	Duration   string            `bson:"duration,omitempty" json:"duration,omitempty"`     // condition
+	Initiative *int              `bson:"initiative,omitempty" json:"initiative,omitempty"` // npc_initiative
+	Scene      *SceneChange      `bson:"scene,omitempty" json:"scene,omitempty"`           // scene
+	Attacker   *MechanicTarget   `bson:"attacker,omitempty" json:"attacker,omitempty"`     // npc_attack
+	Attack     string            `bson:"attack,omitempty" json:"attack,omitempty"`         // npc_attack: stat block action, the first attack when empty
+}
+
+// MechanicTarget is who a change affects: a character, an encounter combatant, or only a
+// name when the AI named someone the game doesn't know
+type MechanicTarget struct {
+	Name        string             `bson:"name,omitempty" json:"name,omitempty"`
+	CharacterID primitive.ObjectID `bson:"character_id,omitempty" json:"character_id,omitempty"`
+	CombatantID primitive.ObjectID `bson:"combatant_id,omitempty" json:"combatant_id,omitempty"`
+	UserID      primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"` // Who rolls for them
+}
+
+// DamageChange is damage to deal. Amount is rolled from Dice when the change is proposed,
+// so the DM reviews an actual number.
+type DamageChange struct {
+	Dice   string `bson:"dice,omitempty" json:"dice,omitempty"`
+	Amount int    `bson:"amount" json:"amount" binding:"min=0,max=1000"`
+	Type   string `bson:"type,omitempty" json:"type,omitempty"`
+}
+
+// Request DTOs for reviewing mechanics
+type EditMechanicChangeRequest struct {
+	Target     *MechanicTarget   `json:"target,omitempty"`
+	Roll       *RollRequirements `json:"roll,omitempty"`
+	Effects    *MechanicEffects  `json:"effects,omitempty"`
+	Damage     *DamageChange     `json:"damage,omitempty"` // Merged field by field; new dice without an amount are rolled
+	Conditions []string          `json:"conditions,omitempty" binding:"max=10,dive,max=50"`
+	Duration   *string           `json:"duration,omitempty" binding:"omitempty,max=100"`
+	Initiative *int              `json:"initiative,omitempty" binding:"omitempty,min=-10,max=50"`
+	Scene      *SceneChange      `json:"scene,omitempty"`
+	Attack     *string           `json:"attack,omitempty" binding:"omitempty,max=100"`
+}
+
+type RejectMechanicChangeRequest struct {
+	Reason string `json:"reason,omitempty" binding:"max=500"`
+}
@@ -0,0 +1,64 @@
+package models
+
+// D&D 5e SRD monster stat block
+type Monster struct {
+	Slug      string `json:"slug" bson:"slug"`
+	Name      string `json:"name" bson:"name"`
+	Size      string `json:"size" bson:"size"`
+	Type      string `json:"type" bson:"type"`
+	Alignment string `json:"alignment" bson:"alignment"`
+
+	// Defense
+	ArmorClass int            `json:"armor_class" bson:"armor_class"`
+	ArmorDesc  string         `json:"armor_desc,omitempty" bson:"armor_desc,omitempty"`
+	HitPoints  int            `json:"hit_points" bson:"hit_points"` // Average HP
+	HitDice    string         `json:"hit_dice" bson:"hit_dice"`     // e.g., "2d6", "5d10+10"
+	Speed      map[string]int `json:"speed" bson:"speed"`           // walk, fly, swim, climb, burrow (feet)
+
+	// Abilities
+	Abilities    AbilityScores  `json:"abilities" bson:"abilities"`
+	SavingThrows map[string]int `json:"saving_throws,omitempty" bson:"saving_throws,omitempty"`
+	Skills       map[string]int `json:"skills,omitempty" bson:"skills,omitempty"`
+
+	// Senses & languages
+	Senses            map[string]int `json:"senses,omitempty" bson:"senses,omitempty"` // darkvision, blindsight, tremorsense, truesight (feet)
+	PassivePerception int            `json:"passive_perception" bson:"passive_perception"`
+	Languages         []string       `json:"languages" bson:"languages"`
+
+	// Challenge
+	ChallengeRating float64 `json:"challenge_rating" bson:"challenge_rating"`
+	XP              int     `json:"xp" bson:"xp"`
+
+	// Resistances
+	DamageVulnerabilities []string `json:"damage_vulnerabilities,omitempty" bson:"damage_vulnerabilities,omitempty"`
+	DamageResistances     []string `json:"damage_resistances,omitempty" bson:"damage_resistances,omitempty"`
+	DamageImmunities      []string `json:"damage_immunities,omitempty" bson:"damage_immunities,omitempty"`
+	ConditionImmunities   []string `json:"condition_immunities,omitempty" bson:"condition_immunities,omitempty"`
+
+	// Features & actions
+	SpecialAbilities         []MonsterTrait  `json:"special_abilities,omitempty" bson:"special_abilities,omitempty"`
+	Actions                  []MonsterAction `json:"actions" bson:"actions"`
+	Reactions                []MonsterAction `json:"reactions,omitempty" bson:"reactions,omitempty"`
+	LegendaryActionsPerRound int             `json:"legendary_actions_per_round,omitempty" bson:"legendary_actions_per_round,omitempty"`
+	LegendaryActions         []MonsterAction `json:"legendary_actions,omitempty" bson:"legendary_actions,omitempty"`
+}
+
+type MonsterTrait struct {
+	Name        string `json:"name" bson:"name"`
+	Description string `json:"description" bson:"description"`
+}
+
+type MonsterAction struct {
+	Name            string `json:"name" bson:"name"`
+	Description     string `json:"description" bson:"description"`
+	AttackBonus     int    `json:"attack_bonus,omitempty" bson:"attack_bonus,omitempty"`
+	Reach           int    `json:"reach,omitempty" bson:"reach,omitempty"`             // Melee reach in feet
+	Range           string `json:"range,omitempty" bson:"range,omitempty"`             // e.g., "80/320"
+	DamageDice      string `json:"damage_dice,omitempty" bson:"damage_dice,omitempty"` // e.g., "1d6+2"
+	DamageType      string `json:"damage_type,omitempty" bson:"damage_type,omitempty"`
+	ExtraDamageDice string `json:"extra_damage_dice,omitempty" bson:"extra_damage_dice,omitempty"` // Rider damage, e.g., "2d6" fire
+	ExtraDamageType string `json:"extra_damage_type,omitempty" bson:"extra_damage_type,omitempty"`
+	SaveDC          int    `json:"save_dc,omitempty" bson:"save_dc,omitempty"`
+	SaveAbility     string `json:"save_ability,omitempty" bson:"save_ability,omitempty"`
+	Cost            int    `json:"cost,omitempty" bson:"cost,omitempty"` // Legendary action cost
+}
@@ -21,21 +21,32 @@ type GameSession struct {
 	TurnOrder   []TurnEntry        `bson:"turn_order" json:"turn_order"`
 	CurrentTurn int                `bson:"current_turn" json:"current_turn"`
 	Round       int                `bson:"round" json:"round"`
+	PendingInitiative *PendingInitiative `bson:"pending_initiative,omitempty" json:"pending_initiative,omitempty"`
+	ReadyCheck  *ReadyCheck        `bson:"ready_check,omitempty" json:"ready_check,omitempty"` // Latest ready check, open or finished
+	
+	// Battle Map
+	Map         *BattleMap         `bson:"map,omitempty" json:"map,omitempty"`
 	
 	// Participants
 	Players     []SessionPlayer    `bson:"players" json:"players"`
 	DMUserID    primitive.ObjectID `bson:"dm_user_id" json:"dm_user_id"`
 	
-	// Session Data
-	ChatHistory []SessionChatMsg   `bson:"chat_history,omitempty" json:"chat_history,omitempty"`
-	
 	// Metadata
+	Version     int64              `bson:"version" json:"version"` // Incremented by every game state write, for compare-and-swap updates
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
 	StartedAt   *time.Time         `bson:"started_at,omitempty" json:"started_at,omitempty"`
 	EndedAt     *time.Time         `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
 }
 
+// WithoutMap returns a copy of the session without its battle map. Each viewer sees the map
+// differently, so it is only sent through the map endpoint, which filters it for them.
+func (s *GameSession) WithoutMap() *GameSession {
+	stripped := *s
+	stripped.Map = nil
+	return &stripped
+}
+
 type SessionStatus string
 
 const (
@@ -56,12 +67,81 @@ type SessionPlayer struct {
 }
 
 type TurnEntry struct {
-	Type         TurnType           `bson:"type" json:"type"`
-	UserID       primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
-	CharacterID  primitive.ObjectID `bson:"character_id,omitempty" json:"character_id,omitempty"`
-	Initiative   int                `bson:"initiative" json:"initiative"`
-	Name         string             `bson:"name" json:"name"`
-	HasActed     bool               `bson:"has_acted" json:"has_acted"`
+	Type             TurnType           `bson:"type" json:"type"`
+	UserID           primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	CharacterID      primitive.ObjectID `bson:"character_id,omitempty" json:"character_id,omitempty"`
+	CombatantID      primitive.ObjectID `bson:"combatant_id,omitempty" json:"combatant_id,omitempty"` // Encounter combatant for NPCs
+	Initiative       int                `bson:"initiative" json:"initiative"`
+	Dexterity        int                `bson:"dexterity,omitempty" json:"dexterity,omitempty"`     // First initiative tie-breaker
+	TieBreaker       int                `bson:"tie_breaker,omitempty" json:"tie_breaker,omitempty"` // Roll-off when initiative and Dex are tied
+	Name             string             `bson:"name" json:"name"`
+	Speed            int                `bson:"speed,omitempty" json:"speed,omitempty"` // Walking speed in feet
+	HasActed         bool               `bson:"has_acted" json:"has_acted"`
+	Economy          ActionEconomy      `bson:"economy" json:"economy"`
+	Readied          *ReadiedAction     `bson:"readied,omitempty" json:"readied,omitempty"`
+	Delayed          bool               `bson:"delayed,omitempty" json:"delayed,omitempty"`                     // Delayed this round; the reaction stays spent
+	LegendaryActions int                `bson:"legendary_actions,omitempty" json:"legendary_actions,omitempty"` // Legendary actions per round
+}
+
+// ReadiedAction is an action held until a trigger occurs before the combatant's next turn
+type ReadiedAction struct {
+	Trigger   string    `bson:"trigger" json:"trigger"`
+	Action    string    `bson:"action" json:"action"`
+	Round     int       `bson:"round" json:"round"`
+	ReadiedAt time.Time `bson:"readied_at" json:"readied_at"`
+}
+
+// ActionEconomy tracks what a combatant has spent this turn. Everything refreshes
+// at the start of the combatant's own turn, including the reaction.
+type ActionEconomy struct {
+	ActionUsed            bool `bson:"action_used" json:"action_used"`
+	BonusActionUsed       bool `bson:"bonus_action_used" json:"bonus_action_used"`
+	ReactionUsed          bool `bson:"reaction_used" json:"reaction_used"`
+	ObjectInteractionUsed bool `bson:"object_interaction_used" json:"object_interaction_used"`
+	MovementMax           int  `bson:"movement_max" json:"movement_max"`   // Feet available this turn (Speed, more after Dash)
+	MovementUsed          int  `bson:"movement_used" json:"movement_used"` // Feet spent this turn
+	LegendaryActionsUsed  int  `bson:"legendary_actions_used" json:"legendary_actions_used"`
+}
+
+// MovementRemaining returns the feet of movement left this turn
+func (e ActionEconomy) MovementRemaining() int {
+	if e.MovementUsed >= e.MovementMax {
+		return 0
+	}
+	return e.MovementMax - e.MovementUsed
+}
+
+// NewActionEconomy returns a fresh economy for a combatant with the given speed
+func NewActionEconomy(speed int) ActionEconomy {
+	return ActionEconomy{MovementMax: speed}
+}
+
+// PendingInitiative tracks an initiative roll where players may roll their own
+// before the window closes and the server rolls for anyone left
+type PendingInitiative struct {
+	StartedAt   time.Time            `bson:"started_at" json:"started_at"`
+	Deadline    time.Time            `bson:"deadline" json:"deadline"`
+	EncounterID primitive.ObjectID   `bson:"encounter_id,omitempty" json:"encounter_id,omitempty"`
+	Advantage   []primitive.ObjectID `bson:"advantage" json:"advantage"` // Characters rolling with advantage
+	Rolled      []TurnEntry          `bson:"rolled" json:"rolled"`
+	Awaiting    []primitive.ObjectID `bson:"awaiting" json:"awaiting"` // Characters that haven't rolled yet
+}
+
+// ReadyCheck collects ready or not-ready from every player in the session until they have
+// all answered or the deadline passes
+type ReadyCheck struct {
+	StartedAt   time.Time            `bson:"started_at" json:"started_at"`
+	Deadline    time.Time            `bson:"deadline" json:"deadline"`
+	Prompt      string               `bson:"prompt,omitempty" json:"prompt,omitempty"`
+	Ready       []primitive.ObjectID `bson:"ready" json:"ready"`         // Users who answered ready
+	NotReady    []primitive.ObjectID `bson:"not_ready" json:"not_ready"` // Users who answered not ready
+	Awaiting    []primitive.ObjectID `bson:"awaiting" json:"awaiting"`   // Users who haven't answered
+	CompletedAt *time.Time           `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// IsOpen reports whether the check is still collecting answers
+func (r *ReadyCheck) IsOpen() bool {
+	return r.CompletedAt == nil
 }
 
 type TurnType string
@@ -70,18 +150,9 @@ const (
 	TurnTypePlayer TurnType = "player"
 	TurnTypeNPC    TurnType = "npc"
 	TurnTypeEvent  TurnType = "event"
+	TurnTypeLair   TurnType = "lair" // Lair actions at initiative 20, losing ties
 )
 
-// Session-specific chat message (for persistence)
-type SessionChatMsg struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Username  string             `bson:"username" json:"username"`
-	Message   string             `bson:"message" json:"message"`
-	Type      string             `bson:"type" json:"type"` // "ic", "ooc", "roll", "system"
-	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
-}
-
 // Request/Response DTOs for session operations
 type CreateSessionRequest struct {
 	CampaignID  primitive.ObjectID `json:"campaign_id" binding:"required"`
@@ -98,8 +169,83 @@ type SetInitiativeRequest struct {
 	Initiative  int                `json:"initiative" binding:"required,min=1,max=30"`
 }
 
+type RollInitiativeRequest struct {
+	EncounterID      primitive.ObjectID   `json:"encounter_id,omitempty"`                         // Include this encounter's monsters as NPCs
+	Advantage        []primitive.ObjectID `json:"advantage,omitempty"`                            // Character or combatant IDs rolling with advantage
+	PlayerRollWindow int                  `json:"player_roll_window,omitempty" binding:"min=0,max=300"` // Seconds players have to roll their own
+}
+
+type RollOwnInitiativeRequest struct {
+	CharacterID primitive.ObjectID `json:"character_id" binding:"required"`
+	Advantage   bool               `json:"advantage,omitempty"`
+}
+
+// CombatActor identifies who is acting; when empty, the combatant whose turn it is acts
+type CombatActor struct {
+	CharacterID primitive.ObjectID `json:"character_id,omitempty"`
+	CombatantID primitive.ObjectID `json:"combatant_id,omitempty"`
+}
+
+type AttackActionRequest struct {
+	CombatActor
+	Weapon      string `json:"weapon,omitempty"`
+	BonusAction bool   `json:"bonus_action,omitempty"` // e.g., off-hand attack
+}
+
+type CastSpellRequest struct {
+	CombatActor
+	SpellName   string `json:"spell_name" binding:"required"`
+	CastingTime string `json:"casting_time" binding:"required,oneof=action bonus_action reaction"`
+}
+
+type DashRequest struct {
+	CombatActor
+	BonusAction bool `json:"bonus_action,omitempty"` // e.g., Cunning Action
+}
+
+type MoveRequest struct {
+	CombatActor
+	Feet int `json:"feet" binding:"required,min=1"`
+}
+
+type InteractRequest struct {
+	CombatActor
+	Object string `json:"object" binding:"required"` // e.g., "draw sword", "open door"
+}
+
+type ReadyActionRequest struct {
+	CombatActor
+	Trigger string `json:"trigger" binding:"required,max=500"` // e.g., "when the door opens"
+	Action  string `json:"action" binding:"required,max=500"`  // e.g., "attack whoever comes through"
+}
+
+type ReactionRequest struct {
+	CombatActor
+	Reaction string `json:"reaction" binding:"required,max=500"` // e.g., "opportunity attack against the fleeing goblin"
+}
+
+type DelayTurnRequest struct {
+	CombatActor
+	Initiative int `json:"initiative"` // New, lower initiative count to act on
+}
+
+type AddLairActionsRequest struct {
+	Name string `json:"name,omitempty"` // Defaults to "Lair Actions"
+}
+
+type LairActionRequest struct {
+	Action string `json:"action" binding:"required,max=500"`
+}
+
+type LegendaryActionRequest struct {
+	CombatantID primitive.ObjectID `json:"combatant_id" binding:"required"`
+	Action      string             `json:"action" binding:"required"`
+	Cost        int                `json:"cost,omitempty" binding:"omitempty,min=1,max=3"` // Defaults to 1
+}
+
 type AdvanceTurnRequest struct {
-	Force bool `json:"force,omitempty"` // Force advance even if player hasn't acted
+	Force   bool  `json:"force,omitempty"`   // Force advance even if player hasn't acted
+	Version int64 `json:"version,omitempty"` // Session version the client saw; rejected with 409 if it has changed
 }
 
 type SessionResponse struct {
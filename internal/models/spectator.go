@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SpectatorLink lets someone without an account watch a session read-only, e.g. for a
+// stream, until it expires or the DM revokes it. Only a hash of its token is stored.
+type SpectatorLink struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SessionID    primitive.ObjectID `bson:"session_id" json:"session_id"`
+	TokenHash    string             `bson:"token_hash" json:"-"`
+	Label        string             `bson:"label,omitempty" json:"label,omitempty"`
+	DelaySeconds int                `bson:"delay_seconds" json:"delay_seconds"` // How far the spectator feed runs behind the game
+	CreatedBy    primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// Delay returns how far the spectator feed runs behind the game
+func (l *SpectatorLink) Delay() time.Duration {
+	return time.Duration(l.DelaySeconds) * time.Second
+}
+
+// SessionOverlay is a session's state for stream overlays: whose turn it is, the party's
+// health and recent public rolls, as they were at least the link's delay ago
+type SessionOverlay struct {
+	SessionID    primitive.ObjectID `bson:"session_id" json:"session_id"`
+	Name         string             `bson:"name" json:"name"`
+	Status       SessionStatus      `bson:"status" json:"status"`
+	Scene        string             `bson:"scene,omitempty" json:"scene,omitempty"`
+	Round        int                `bson:"round" json:"round"`
+	CurrentTurn  *OverlayTurn       `bson:"current_turn,omitempty" json:"current_turn,omitempty"`
+	TurnOrder    []OverlayTurn      `bson:"turn_order" json:"turn_order"`
+	Party        []OverlayCharacter `bson:"party" json:"party"`
+	RecentRolls  []OverlayRoll      `bson:"recent_rolls" json:"recent_rolls"`
+	DelaySeconds int                `bson:"delay_seconds" json:"delay_seconds"` // Everything shown is at least this old
+	GeneratedAt  time.Time          `bson:"generated_at" json:"generated_at"`
+}
+
+type OverlayTurn struct {
+	Name       string   `bson:"name" json:"name"`
+	Type       TurnType `bson:"type" json:"type"`
+	Initiative int      `bson:"initiative" json:"initiative"`
+	Current    bool     `bson:"current" json:"current"`
+}
+
+type OverlayCharacter struct {
+	CharacterID primitive.ObjectID `bson:"character_id" json:"character_id"`
+	Name        string             `bson:"name" json:"name"`
+	PlayerName  string             `bson:"player_name" json:"player_name"`
+	Race        string             `bson:"race" json:"race"`
+	Class       string             `bson:"class" json:"class"`
+	Level       int                `bson:"level" json:"level"`
+	CurrentHP   int                `bson:"current_hp" json:"current_hp"`
+	MaxHP       int                `bson:"max_hp" json:"max_hp"`
+	ArmorClass  int                `bson:"armor_class" json:"armor_class"`
+	Connected   bool               `bson:"connected" json:"connected"`
+}
+
+type OverlayRoll struct {
+	Username    string             `bson:"username" json:"username"`
+	CharacterID primitive.ObjectID `bson:"character_id,omitempty" json:"character_id,omitempty"`
+	Dice        string             `bson:"dice" json:"dice"`
+	Result      []int              `bson:"result" json:"result"`
+	Total       int                `bson:"total" json:"total"`
+	Modifier    int                `bson:"modifier" json:"modifier"`
+	Purpose     string             `bson:"purpose,omitempty" json:"purpose,omitempty"`
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
+}
+
+// Request DTOs for spectator links
+type CreateSpectatorLinkRequest struct {
+	Label        string `json:"label,omitempty" binding:"max=100"`
+	ExpiresIn    int    `json:"expires_in" binding:"required,min=60,max=604800"` // Seconds, up to a week
+	DelaySeconds int    `json:"delay_seconds,omitempty" binding:"min=0,max=600"`
+}
@@ -31,5 +31,21 @@ type CampaignSettings struct {
 	IsPublic     bool `bson:"is_public" json:"is_public"`
 	MaxPlayers   int  `bson:"max_players" json:"max_players"`
 	AllowGuests  bool `bson:"allow_guests" json:"allow_guests"`
+	AI           AISettings `bson:"ai" json:"ai"`
+}
+
+// AISettings picks the LLM provider and model a campaign's AI DM uses. Empty values fall
+// back to the server's defaults.
+type AISettings struct {
+	Provider  string `bson:"provider,omitempty" json:"provider,omitempty" binding:"omitempty,max=50"` // e.g. "gemini", "openai", "mock"
+	Model     string `bson:"model,omitempty" json:"model,omitempty" binding:"omitempty,max=100"`
+	AutoApply bool   `bson:"auto_apply,omitempty" json:"auto_apply,omitempty"` // Apply AI-proposed mechanics without the DM's review
+}
+
+// AIProviderInfo describes an LLM provider the server is configured for
+type AIProviderInfo struct {
+	Name         string `json:"name"`
+	DefaultModel string `json:"default_model"`
+	Default      bool   `json:"default"` // Used by campaigns that don't pick one
 }
 
@@ -12,11 +12,23 @@ const (
 	MessageTypeLeaveSession   = "leave_session"
 	MessageTypePlayerJoined   = "player_joined"
 	MessageTypePlayerLeft     = "player_left"
+	MessageTypeResume         = "resume"  // Client: replay everything after data.last_seq
+	MessageTypeResumed        = "resumed" // Server: replay finished, or a snapshot was sent instead
+	
+	// Presence
+	MessageTypePresence       = "presence"       // Client: report online/away; server: users' presence changed
+	MessageTypeTyping         = "typing"         // Typing indicator for IC/OOC chat
+	MessageTypeReadyCheck     = "ready_check"    // DM: start a ready check; server: its current state
+	MessageTypeReadyResponse  = "ready_response" // Player: answer the open ready check
 	
 	// Chat messages
 	MessageTypeChat           = "chat"
 	MessageTypeChatIC         = "chat_ic"  // In-character
 	MessageTypeChatOOC        = "chat_ooc" // Out-of-character
+	MessageTypeChatEdited     = "chat_edited"
+	MessageTypeChatDeleted    = "chat_deleted"
+	MessageTypeChatHistory    = "chat_history"
+	MessageTypeWhisper        = "whisper"
 	
 	// Dice rolling
 	MessageTypeDiceRoll       = "dice_roll"
@@ -30,32 +42,51 @@ const (
 	MessageTypeGameState      = "game_state"
 	MessageTypeTurnOrder      = "turn_order"
 	MessageTypeTurnAdvance    = "turn_advance"
+	MessageTypeInitiativeRequest = "initiative_request"
+	MessageTypeActionEconomy  = "action_economy"
+	MessageTypeCombatAction   = "combat_action"
+	MessageTypeMapUpdate      = "map_update"
+	MessageTypeTokenMoved     = "token_moved"
+	MessageTypeAreaEffect     = "area_effect"
+	MessageTypeAttackResult   = "attack_result"
 	
 	// AI DM
 	MessageTypeAIResponse     = "ai_response"
+	MessageTypeAIResponseDelta = "ai_response_delta"     // The next piece of the AI DM's narration, as it is written
+	MessageTypeAIResponseCancelled = "ai_response_cancelled" // The AI DM's response was interrupted or failed; drop its narration
 	MessageTypePlayerAction   = "player_action"
+	MessageTypeMechanicUpdate = "mechanic_update"  // DM: AI-proposed changes were added or reviewed
+	MessageTypeMechanicApplied = "mechanic_applied" // An AI-proposed change was applied
+	MessageTypeRollRequest    = "roll_request"     // A player is asked to roll
 	
 	// System messages
 	MessageTypeError          = "error"
 	MessageTypeSuccess        = "success"
 	MessageTypeNotification   = "notification"
+	MessageTypeUserMuted      = "user_muted" // A connection was muted or disconnected for flooding
+	MessageTypeServerRestarting = "server_restarting" // The server is shutting down; reconnect shortly
 )
 
 // WebSocket message structure
 type WSMessage struct {
-	Type      string                 `json:"type"`
-	Data      map[string]interface{} `json:"data"`
-	Timestamp time.Time              `json:"timestamp"`
-	UserID    primitive.ObjectID     `json:"user_id,omitempty"`
-	Username  string                 `json:"username,omitempty"`
-	SessionID primitive.ObjectID     `json:"session_id,omitempty"`
+	Type      string             `json:"type"`
+	Version   int                `json:"v"`
+	Data      interface{}        `json:"data"` // The type's payload struct, see Protocol
+	Timestamp time.Time          `json:"timestamp"`
+	UserID    primitive.ObjectID `json:"user_id,omitempty"`
+	Username  string             `json:"username,omitempty"`
+	SessionID primitive.ObjectID `json:"session_id,omitempty"`
+	Seq       uint64             `json:"seq,omitempty"`        // Increases with each broadcast in a session but is sparse, so gaps don't mean a missed message; direct replies have none
+	RequestID string             `json:"request_id,omitempty"` // Echoes the client message a reply is for
 }
 
 // Specific message data structures
 type ChatMessage struct {
-	Content     string             `json:"content"`
-	CharacterID primitive.ObjectID `json:"character_id,omitempty"`
-	IsIC        bool               `json:"is_ic"` // In-character vs out-of-character
+	Content     string               `json:"content" binding:"required,max=2000"`
+	CharacterID primitive.ObjectID   `json:"character_id,omitempty"`
+	IsIC        bool                 `json:"is_ic"` // In-character vs out-of-character
+	Visibility  string               `json:"visibility,omitempty" binding:"omitempty,oneof=public whisper dm"`
+	Recipients  []primitive.ObjectID `json:"recipients,omitempty"` // Required for whispers
 }
 
 type DiceRoll struct {
@@ -68,9 +99,9 @@ type DiceRoll struct {
 }
 
 type CharacterUpdate struct {
-	CharacterID primitive.ObjectID     `json:"character_id"`
-	Field       string                 `json:"field"`
-	Value       interface{}            `json:"value"`
+	CharacterID primitive.ObjectID     `json:"character_id" binding:"required"`
+	Field       string                 `json:"field" binding:"required,max=100"`
+	Value       interface{}            `json:"value" binding:"required"`
 	OldValue    interface{}            `json:"old_value,omitempty"`
 }
 
@@ -94,6 +125,26 @@ type PlayerLeftData struct {
 	Username string             `json:"username"`
 }
 
+// PresenceStatus is how present a connected user is
+type PresenceStatus string
+
+const (
+	PresenceOnline PresenceStatus = "online" // Active recently
+	PresenceIdle   PresenceStatus = "idle"   // Connected but inactive for a while
+	PresenceAway   PresenceStatus = "away"   // Inactive for long, or reported away by the client
+)
+
+// UserPresence is a connected user's presence in a session, across all their connections
+type UserPresence struct {
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Username    string             `bson:"username" json:"username"`
+	Role        string             `bson:"role" json:"role"`
+	Status      PresenceStatus     `bson:"status" json:"status"`
+	LastActive  time.Time          `bson:"last_active" json:"last_active"`
+	LatencyMS   int64              `bson:"latency_ms" json:"latency_ms"` // Last ping round trip, 0 until measured
+	Connections int                `bson:"connections" json:"connections"`
+}
+
 // Connection represents an active WebSocket connection
 type Connection struct {
 	UserID      primitive.ObjectID `json:"user_id"`
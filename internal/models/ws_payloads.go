@@ -0,0 +1,323 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProtocolVersion is the WebSocket protocol version. Messages carry it as "v"; clients
+// may leave it out to mean the current version.
+const ProtocolVersion = 1
+
+// WebSocket error codes, sent in ErrorData.Code
+const (
+	ErrorCodeInvalidMessage     = "invalid_message"     // Not JSON, or the payload failed validation
+	ErrorCodeUnsupportedType    = "unsupported_type"    // Unknown type, or one only the server sends
+	ErrorCodeUnsupportedVersion = "unsupported_version" // Newer protocol version than the server speaks
+	ErrorCodeForbidden          = "forbidden"           // Not allowed for this user
+	ErrorCodeFailed             = "failed"              // Valid request the server couldn't carry out
+	ErrorCodeRateLimited        = "rate_limited"        // Sent too many messages; slow down
+	ErrorCodeMuted              = "muted"               // Temporarily muted for flooding
+)
+
+// ClientMessage is a message as received from a client, before its payload is decoded
+type ClientMessage struct {
+	Type      string          `json:"type"`
+	Version   int             `json:"v,omitempty"`
+	RequestID string          `json:"request_id,omitempty"` // Echoed in error replies, at most 64 characters
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// MessageSpec describes a message type's payloads. Client is what a client may send, nil if
+// only the server sends it; Server is what the server sends, nil if it never does.
+type MessageSpec struct {
+	Description string
+	Client      interface{}
+	Server      interface{}
+}
+
+// Protocol lists every WebSocket message type with its payloads
+var Protocol = map[string]MessageSpec{
+	MessageTypeJoinSession:       {Description: "Switch sessions; not supported, reconnect to the other session instead", Client: JoinSessionData{}},
+	MessageTypeLeaveSession:      {Description: "Leave the session and close the connection", Client: LeaveSessionData{}},
+	MessageTypePlayerJoined:      {Description: "A user connected to the session", Server: PlayerJoinedData{}},
+	MessageTypePlayerLeft:        {Description: "A user disconnected from the session", Server: PlayerLeftData{}},
+	MessageTypeResume:            {Description: "Replay everything after the last sequence number seen", Client: ResumeData{}},
+	MessageTypeResumed:           {Description: "Replay finished, or a snapshot was sent instead", Server: ResumedData{}},
+	MessageTypePresence:          {Description: "Report the client online or away; the server sends users whose presence changed", Client: PresenceData{}, Server: PresenceUpdateData{}},
+	MessageTypeTyping:            {Description: "Started or stopped typing a chat message; not sequenced or replayed", Client: TypingData{}, Server: TypingIndicatorData{}},
+	MessageTypeReadyCheck:        {Description: "The DM starts a ready check; the server sends its state as answers come in", Client: ReadyCheckStartData{}, Server: ReadyCheckData{}},
+	MessageTypeReadyResponse:     {Description: "Answer the open ready check", Client: ReadyResponseData{}},
+	MessageTypeChat:              {Description: "Chat message, in or out of character", Client: ChatMessage{}, Server: ChatMessageData{}},
+	MessageTypeChatIC:            {Description: "In-character chat message", Client: ChatMessage{}, Server: ChatMessageData{}},
+	MessageTypeChatOOC:           {Description: "Out-of-character chat message", Client: ChatMessage{}, Server: ChatMessageData{}},
+	MessageTypeChatEdited:        {Description: "A chat message was edited", Server: ChatEditedData{}},
+	MessageTypeChatDeleted:       {Description: "A chat message was deleted", Server: ChatDeletedData{}},
+	MessageTypeChatHistory:       {Description: "Recent chat, sent on connect", Server: ChatHistoryData{}},
+	MessageTypeWhisper:           {Description: "Private chat message to some users", Client: ChatMessage{}, Server: ChatMessageData{}},
+	MessageTypeDiceRoll:          {Description: "Dice rolled by a client", Client: DiceRollData{}, Server: DiceRollData{}},
+	MessageTypeDiceResult:        {Description: "Dice rolled by the server", Server: DiceResultData{}},
+	MessageTypeCharacterUpdate:   {Description: "A character's field changed", Client: CharacterUpdate{}, Server: CharacterUpdate{}},
+	MessageTypeCharacterSync:     {Description: "A character's full sheet", Server: CharacterSyncData{}},
+	MessageTypeGameState:         {Description: "Session state snapshot, sent on connect", Server: GameStateData{}},
+	MessageTypeTurnOrder:         {Description: "The initiative order changed", Server: TurnOrderData{}},
+	MessageTypeTurnAdvance:       {Description: "The turn moved on", Server: TurnAdvanceData{}},
+	MessageTypeInitiativeRequest: {Description: "Players should roll initiative before the deadline", Server: InitiativeRequestData{}},
+	MessageTypeActionEconomy:     {Description: "What a combatant has left to spend this turn", Server: ActionEconomyData{}},
+	MessageTypeCombatAction:      {Description: "A combatant took an action", Server: CombatActionData{}},
+	MessageTypeMapUpdate:         {Description: "The user's view of the battle map", Server: MapUpdateData{}},
+	MessageTypeTokenMoved:        {Description: "A token moved, as far as the user can see", Server: TokenMovedData{}},
+	MessageTypeAreaEffect:        {Description: "An area effect was resolved", Server: AreaEffectData{}},
+	MessageTypeAttackResult:      {Description: "An attack was resolved", Server: AttackResultData{}},
+	MessageTypeAIResponse:        {Description: "The AI DM responded to a player action", Server: AIResponseData{}},
+	MessageTypeAIResponseDelta:   {Description: "The next piece of the AI DM's narration; live only, never replayed. The final ai_response has the same response ID.", Server: AIResponseDeltaData{}},
+	MessageTypeAIResponseCancelled: {Description: "The AI DM's response stopped before it finished; drop its narration", Server: AIResponseCancelledData{}},
+	MessageTypePlayerAction:      {Description: "A player declared an action", Server: PlayerAction{}},
+	MessageTypeMechanicUpdate:    {Description: "AI-proposed changes were added to the DM's review queue or changed state; DM only", Server: MechanicUpdateData{}},
+	MessageTypeMechanicApplied:   {Description: "An AI-proposed change was applied to the game", Server: MechanicAppliedData{}},
+	MessageTypeRollRequest:       {Description: "The DM asks the user to roll", Server: RollRequestData{}},
+	MessageTypeError:             {Description: "A client message was rejected", Server: ErrorData{}},
+	MessageTypeSuccess:           {Description: "A client request succeeded", Server: SuccessData{}},
+	MessageTypeNotification:      {Description: "Informational message for the user", Server: NotificationData{}},
+	MessageTypeServerRestarting:  {Description: "The server is shutting down and will close the connection; reconnect after reconnect_in seconds with last_seq to resume", Server: ServerRestartingData{}},
+	MessageTypeUserMuted:         {Description: "A connection was muted or disconnected for flooding; sent to the DM and the user", Server: UserMutedData{}},
+}
+
+// Client message payloads
+
+type JoinSessionData struct {
+	SessionID   primitive.ObjectID `json:"session_id" binding:"required"`
+	CharacterID primitive.ObjectID `json:"character_id,omitempty"`
+}
+
+type LeaveSessionData struct{}
+
+type ResumeData struct {
+	LastSeq *uint64 `json:"last_seq" binding:"required"`
+}
+
+type DiceRollData struct {
+	Dice        string             `json:"dice" binding:"required,max=50"`
+	Result      []int              `json:"result" binding:"max=100"`
+	Total       int                `json:"total"`
+	Modifier    int                `json:"modifier"`
+	Purpose     string             `json:"purpose,omitempty" binding:"max=200"`
+	CharacterID primitive.ObjectID `json:"character_id,omitempty"`
+	Visibility  string             `json:"visibility,omitempty" binding:"omitempty,oneof=public dm self"`
+}
+
+type PresenceData struct {
+	Status PresenceStatus `json:"status" binding:"required,oneof=online away"`
+}
+
+type TypingData struct {
+	IsIC   bool `json:"is_ic"`
+	Typing bool `json:"typing"`
+}
+
+type ReadyCheckStartData struct {
+	Prompt  string `json:"prompt,omitempty" binding:"max=200"`
+	Timeout int    `json:"timeout,omitempty" binding:"omitempty,min=5,max=300"` // Seconds, 30 if unset
+}
+
+type ReadyResponseData struct {
+	Ready *bool `json:"ready" binding:"required"`
+}
+
+// Server message payloads
+
+type ResumedData struct {
+	LastSeq  uint64 `json:"last_seq"`
+	Seq      uint64 `json:"seq"`
+	Replayed int    `json:"replayed"`
+	Snapshot bool   `json:"snapshot"`
+}
+
+// PresenceUpdateData lists users whose presence changed, or everyone's in a snapshot. Users
+// who disconnect are announced with player_left instead.
+type PresenceUpdateData struct {
+	Users    []UserPresence `json:"users"`
+	Snapshot bool           `json:"snapshot"`
+}
+
+// TypingIndicatorData says a user is typing; clients should clear it after ExpiresIn
+// seconds unless it is repeated
+type TypingIndicatorData struct {
+	UserID      primitive.ObjectID `json:"user_id"`
+	Username    string             `json:"username"`
+	CharacterID primitive.ObjectID `json:"character_id,omitempty"`
+	IsIC        bool               `json:"is_ic"`
+	Typing      bool               `json:"typing"`
+	ExpiresIn   int                `json:"expires_in"`
+}
+
+type ReadyCheckData struct {
+	Check *ReadyCheck `json:"check"`
+}
+
+type ChatMessageData struct {
+	ID          primitive.ObjectID   `json:"id"`
+	Content     string               `json:"content"`
+	CharacterID primitive.ObjectID   `json:"character_id,omitempty"`
+	IsIC        bool                 `json:"is_ic"`
+	Visibility  string               `json:"visibility"`
+	Recipients  []primitive.ObjectID `json:"recipients,omitempty"`
+}
+
+type ChatEditedData struct {
+	Message *SessionChatMessage `json:"message"`
+}
+
+type ChatDeletedData struct {
+	MessageID primitive.ObjectID `json:"message_id"`
+}
+
+type ChatHistoryData struct {
+	Messages []SessionChatMessage `json:"messages"`
+	Seq      uint64               `json:"seq"`
+}
+
+type DiceResultData struct {
+	Dice           string             `json:"dice"`
+	Result         []int              `json:"result"`
+	Total          int                `json:"total"`
+	Modifier       int                `json:"modifier"`
+	Purpose        string             `json:"purpose"`
+	CharacterID    primitive.ObjectID `json:"character_id,omitempty"`
+	SpecialMessage string             `json:"special_message,omitempty"`
+	Visibility     string             `json:"visibility,omitempty"`
+}
+
+type CharacterSyncData struct {
+	Character *Character `json:"character"`
+}
+
+type GameStateData struct {
+	Session GameSession `json:"session"`
+	Seq     uint64      `json:"seq"`
+}
+
+type TurnOrderData struct {
+	TurnOrder   []TurnEntry `json:"turn_order"`
+	CurrentTurn int         `json:"current_turn"`
+	Round       int         `json:"round"`
+}
+
+type TurnAdvanceData struct {
+	CurrentTurn int       `json:"current_turn"`
+	Round       int       `json:"round"`
+	Current     TurnEntry `json:"current"`
+	Version     int64     `json:"version"`
+}
+
+type InitiativeRequestData struct {
+	Deadline time.Time            `json:"deadline"`
+	Awaiting []primitive.ObjectID `json:"awaiting"` // Characters that haven't rolled yet
+}
+
+type ActionEconomyData struct {
+	Name              string        `json:"name"`
+	Economy           ActionEconomy `json:"economy"`
+	MovementRemaining int           `json:"movement_remaining"`
+}
+
+type CombatActionData struct {
+	Action  string                 `json:"action"`
+	Name    string                 `json:"name"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type MapUpdateData struct {
+	Map *MapView `json:"map"`
+}
+
+// TokenMovedData is the whole move if the user can see the token, otherwise only that it
+// left a cell they can see
+type TokenMovedData struct {
+	TokenID primitive.ObjectID `json:"token_id"`
+	Visible bool               `json:"visible"`
+	Name    string             `json:"name,omitempty"`
+	From    *GridPoint         `json:"from,omitempty"` // Left out unless the recipient can see it
+	To      *GridPoint         `json:"to,omitempty"`
+	Path    []GridPoint        `json:"path,omitempty"` // Only the cells the recipient can see
+	Cost    int                `json:"cost,omitempty"` // Only when the whole move is visible
+}
+
+type AreaEffectData struct {
+	Effect *AreaEffect `json:"effect"`
+}
+
+type AttackResultData struct {
+	Result *AttackResult `json:"result"`
+}
+
+type AIResponseData struct {
+	CharacterName string      `json:"character_name"`
+	Action        string      `json:"action"`
+	AIResponse    *AIResponse `json:"ai_response"`
+	GameEvent     *GameEvent  `json:"game_event"`
+	AIEvent       *GameEvent  `json:"ai_event"`
+}
+
+// AIResponseDeltaData is a piece of narration. Restart means the model's earlier attempt
+// was rejected and its narration should be dropped before appending this.
+type AIResponseDeltaData struct {
+	ResponseID primitive.ObjectID `json:"response_id"`
+	Index      int                `json:"index"` // Counts up from 0 within a response
+	Delta      string             `json:"delta"`
+	Restart    bool               `json:"restart,omitempty"`
+}
+
+type AIResponseCancelledData struct {
+	ResponseID primitive.ObjectID `json:"response_id"`
+	Reason     string             `json:"reason"` // "interrupted" or "failed"
+}
+
+type MechanicUpdateData struct {
+	Changes []MechanicChange `json:"changes"`
+}
+
+type MechanicAppliedData struct {
+	Change *MechanicChange `json:"change"`
+}
+
+// RollRequestData asks for a roll. The DC is left out for players, so it stays secret.
+type RollRequestData struct {
+	ChangeID    primitive.ObjectID `json:"change_id"`
+	Description string             `json:"description"`
+	Target      MechanicTarget     `json:"target"`
+	Roll        *RollRequirements  `json:"roll,omitempty"`
+}
+
+type ErrorData struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+type SuccessData struct {
+	Message   string             `json:"message"`
+	SessionID primitive.ObjectID `json:"session_id,omitempty"`
+}
+
+type NotificationData struct {
+	Message string `json:"message"`
+}
+
+// UserMutedData says a user's connection was muted until a time, or disconnected, for
+// going over its rate limits
+type UserMutedData struct {
+	UserID       primitive.ObjectID `json:"user_id"`
+	Username     string             `json:"username"`
+	Until        *time.Time         `json:"until,omitempty"` // End of the mute; unset when disconnected
+	Mutes        int                `json:"mutes"`           // Times the connection has been muted
+	Disconnected bool               `json:"disconnected"`
+}
+
+// ServerRestartingData tells a client to reconnect after ReconnectIn seconds, which is
+// spread out so clients don't all return at once
+type ServerRestartingData struct {
+	Message     string `json:"message"`
+	ReconnectIn int    `json:"reconnect_in"`
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//go:generate go run ../../cmd/wsschema -o ../../docs/websocket-protocol.schema.json
+
+// ProtocolSchema builds a JSON Schema (draft 2020-12) for every WebSocket message in
+// Protocol. Client payloads are strict: unknown fields are rejected and binding rules
+// become required/enum/length constraints, as the hub enforces them.
+func ProtocolSchema() map[string]interface{} {
+	gen := &schemaGenerator{defs: make(map[string]interface{})}
+
+	types := make([]string, 0, len(Protocol))
+	for messageType := range Protocol {
+		types = append(types, messageType)
+	}
+	sort.Strings(types)
+
+	var clientMessages, serverMessages []interface{}
+	for _, messageType := range types {
+		spec := Protocol[messageType]
+		if spec.Client != nil {
+			clientMessages = append(clientMessages, gen.message(messageType, spec.Description, spec.Client, true))
+		}
+		if spec.Server != nil {
+			serverMessages = append(serverMessages, gen.message(messageType, spec.Description, spec.Server, false))
+		}
+	}
+	gen.defs["ClientMessage"] = map[string]interface{}{"oneOf": clientMessages}
+	gen.defs["ServerMessage"] = map[string]interface{}{"oneOf": serverMessages}
+
+	return map[string]interface{}{
+		"$schema":  "https://json-schema.org/draft/2020-12/schema",
+		"$id":      "https://dnd-simulator/schemas/websocket-protocol.json",
+		"title":    "D&D Simulator WebSocket protocol",
+		"version":  ProtocolVersion,
+		"oneOf":    []interface{}{ref("ClientMessage"), ref("ServerMessage")},
+		"$defs":    gen.defs,
+		"$comment": "Generated from internal/models by cmd/wsschema; do not edit by hand.",
+	}
+}
+
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
+
+// message is the envelope schema for one message type in one direction
+func (g *schemaGenerator) message(messageType, description string, payload interface{}, client bool) map[string]interface{} {
+	properties := map[string]interface{}{
+		"type":       map[string]interface{}{"const": messageType},
+		"v":          map[string]interface{}{"type": "integer", "minimum": 0, "maximum": ProtocolVersion},
+		"request_id": map[string]interface{}{"type": "string", "maxLength": 64},
+		"data":       g.schema(reflect.TypeOf(payload), client),
+	}
+	required := []string{"type"}
+	if !client {
+		properties["timestamp"] = map[string]interface{}{"type": "string", "format": "date-time"}
+		properties["user_id"] = objectIDSchema()
+		properties["username"] = map[string]interface{}{"type": "string"}
+		properties["session_id"] = objectIDSchema()
+		properties["seq"] = map[string]interface{}{
+			"type":    "integer",
+			"minimum": 0,
+			"description": "Increases with each broadcast in a session. Numbers are shared across sessions and servers, " +
+				"so they are sparse: a gap is normal and doesn't show a missed message. Missed messages are only " +
+				"found by sending resume with the last seq seen after reconnecting.",
+		}
+		required = append(required, "v", "data", "timestamp")
+	}
+
+	return map[string]interface{}{
+		"title":                messageType,
+		"description":          description,
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": !client,
+	}
+}
+
+func objectIDSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema describes how a Go type encodes as JSON. Named structs go into $defs, with a
+// separate "Request" variant when they are decoded from clients.
+func (g *schemaGenerator) schema(t reflect.Type, client bool) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return objectIDSchema()
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return map[string]interface{}{"anyOf": []interface{}{g.schema(t.Elem(), client), map[string]interface{}{"type": "null"}}}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": g.schema(t.Elem(), client)}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": g.schema(t.Elem(), client)}
+	case reflect.Struct:
+		name := t.Name()
+		if client {
+			name += "Request"
+		}
+		if name == "" || name == "Request" {
+			return g.object(t, client)
+		}
+		if _, done := g.defs[name]; !done {
+			g.defs[name] = nil // Placeholder so recursive types terminate
+			g.defs[name] = g.object(t, client)
+		}
+		return ref(name)
+	}
+	return map[string]interface{}{} // interface{}: any JSON value
+}
+
+// object describes a struct's JSON fields, flattening embedded structs
+func (g *schemaGenerator) object(t reflect.Type, client bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	g.fields(t, client, properties, &required)
+
+	object := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	if client {
+		object["additionalProperties"] = false
+	}
+	return object
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, client bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.fields(field.Type, client, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := g.schema(field.Type, client)
+		rules := field.Tag.Get("binding")
+		if client {
+			applyBindingRules(schema, field.Type, rules)
+		}
+		properties[name] = schema
+
+		// Clients must send what binding requires; the server always sends non-omitempty fields
+		if (client && hasRule(rules, "required")) || (!client && !strings.Contains(options, "omitempty")) {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func hasRule(rules, rule string) bool {
+	for _, r := range strings.Split(rules, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// applyBindingRules turns max, min and oneof binding rules into schema constraints
+func applyBindingRules(schema map[string]interface{}, t reflect.Type, rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "oneof":
+			schema["enum"] = strings.Fields(value)
+		case "max", "min":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			schema[lengthKeyword(t, key)] = n
+		}
+	}
+}
+
+// lengthKeyword picks the schema keyword a max or min rule means for a type
+func lengthKeyword(t reflect.Type, rule string) string {
+	keyword := map[bool]string{true: "max", false: "min"}[rule == "max"]
+	switch t.Kind() {
+	case reflect.String:
+		return keyword + "Length"
+	case reflect.Slice, reflect.Array:
+		return keyword + "Items"
+	}
+	if rule == "max" {
+		return "maximum"
+	}
+	return "minimum"
+}
@@ -1,165 +1,128 @@
 package services
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
 	"strings"
 	"time"
 
-	"dnd-simulator/internal/config"
+	"dnd-simulator/internal/database"
 	"dnd-simulator/internal/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // AIService handles AI-powered DM responses
 type AIService struct {
-	apiKey       string
-	apiEndpoint  string
-	model        string
-	httpClient   *http.Client
-	temperature  float64
+	providers   *LLMRegistry
+	structured  *EnhancedAIService
+	temperature float64
+	claims      *responseClaims
 }
 
 // NewAIService creates a new AI service instance
-func NewAIService(cfg *config.Config) *AIService {
+func NewAIService(db *database.DB, providers *LLMRegistry) *AIService {
 	return &AIService{
-		apiKey:      cfg.GeminiAPIKey,
-		apiEndpoint: "https://generativelanguage.googleapis.com/v1beta/models",
-		model:       "gemini-2.5-pro-preview-05-06",
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		providers:   providers,
+		structured:  NewEnhancedAIService(providers),
 		temperature: 0.8,
+		claims:      newResponseClaims(db),
 	}
 }
 
-// AIContext contains all the context needed for AI to generate appropriate responses
-type AIContext struct {
-	Campaign      *models.Campaign     `json:"campaign"`
-	CurrentScene  string               `json:"current_scene"`
-	Characters    []models.Character   `json:"characters"`
-	RecentEvents  []models.GameEvent   `json:"recent_events"`
-	PlayerAction  models.PlayerAction  `json:"player_action"`
-	TurnOrder     []models.TurnEntry   `json:"turn_order"`
-	CurrentTurn   int                  `json:"current_turn"`
+// GenerateResponse generates an AI DM response based on the game context and player action.
+// Structured output is the primary path; only if the model can't produce a valid structured
+// reply is the narrative generated as plain text, with mechanics guessed from its wording.
+func (s *AIService) GenerateResponse(ctx context.Context, aiContext *models.AIContext) (*models.AIResponse, error) {
+	return s.respond(ctx, aiContext, nil)
 }
 
-// GenerateResponse generates an AI DM response based on the game context and player action
-func (s *AIService) GenerateResponse(ctx context.Context, aiContext *AIContext) (*models.AIResponse, error) {
-	// Build the combined prompt
-	prompt := s.buildCombinedPrompt(aiContext)
-	
-	// Prepare the API request for Gemini
-	requestBody := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"role": "user",
-				"parts": []map[string]interface{}{
-					{
-						"text": prompt,
-					},
-				},
-			},
-		},
-		"generationConfig": map[string]interface{}{
-			"temperature": s.temperature,
-			"maxOutputTokens": 8192,
-			"responseMimeType": "text/plain",
-			"topK": 40,
-			"topP": 0.95,
-			"stopSequences": []string{"[END_SCENE]", "[AWAIT_PLAYER_ACTION]"},
-		},
+// StreamResponse is GenerateResponse, calling onDelta with each piece of the narrative as
+// the model writes it. restart marks the first piece of a new attempt after a rejected
+// one, whose narration should be discarded. The complete response, with its mechanics,
+// is returned at the end.
+func (s *AIService) StreamResponse(ctx context.Context, aiContext *models.AIContext, onDelta func(delta string, restart bool)) (*models.AIResponse, error) {
+	return s.respond(ctx, aiContext, &narrator{onDelta: onDelta})
+}
+
+// EnsureIndexes creates the index that expires abandoned AI response claims
+func (s *AIService) EnsureIndexes(ctx context.Context) error {
+	return s.claims.ensureIndexes(ctx)
+}
+
+// BeginResponse claims a session for one AI response at a time, across all servers. The
+// returned context is cancelled with ErrAIResponseInterrupted if the DM interrupts; call
+// Finish once the response is written and Release when done with it.
+func (s *AIService) BeginResponse(ctx context.Context, sessionID, responseID primitive.ObjectID) (context.Context, *AIResponseSlot, error) {
+	return s.claims.begin(ctx, sessionID, responseID)
+}
+
+// Interrupt stops the AI response being written in a session, on whichever server is
+// writing it, and returns its ID
+func (s *AIService) Interrupt(ctx context.Context, sessionID primitive.ObjectID) (primitive.ObjectID, bool, error) {
+	return s.claims.interrupt(ctx, sessionID)
+}
+
+func (s *AIService) respond(ctx context.Context, aiContext *models.AIContext, narration *narrator) (*models.AIResponse, error) {
+	response, err := s.structured.generateDMResponse(ctx, aiContext, narration)
+	if err == nil {
+		return response, nil
 	}
-	
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	if !errors.Is(err, errInvalidStructuredReply) {
+		return nil, err
 	}
 	
-	// Create HTTP request - using streamGenerateContent endpoint
-	url := fmt.Sprintf("%s/%s:streamGenerateContent?key=%s&alt=sse", s.apiEndpoint, s.model, s.apiKey)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	log.Printf("Falling back to a plain narrative: %v", err)
+	return s.generateNarrative(ctx, aiContext, narration)
+}
+
+// generateNarrative generates a plain-text response and guesses its mechanics
+func (s *AIService) generateNarrative(ctx context.Context, aiContext *models.AIContext, narration *narrator) (*models.AIResponse, error) {
+	provider, model, err := s.providers.ForCampaign(aiContext.Campaign)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 	
-	req.Header.Set("Content-Type", "application/json")
+	// Build the combined prompt
+	prompt := s.buildCombinedPrompt(aiContext)
 	
-	// Send request
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	req := &LLMRequest{
+		Model:       model,
+		Prompt:      prompt,
+		Temperature: s.temperature,
+		MaxTokens:   8192,
+		Stop:        []string{"[END_SCENE]", "[AWAIT_PLAYER_ACTION]"},
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		var errorBody map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&errorBody)
-		return nil, fmt.Errorf("API returned status code %d: %v", resp.StatusCode, errorBody)
+	var reply *LLMResponse
+	if narration != nil {
+		narration.begin()
+		reply, err = provider.Stream(ctx, req, narration.send)
+	} else {
+		reply, err = provider.Generate(ctx, req)
 	}
-	
-	// Read the entire response body
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-	
-	// Parse SSE data
-	var fullText strings.Builder
-	var totalTokens int
-	
-	// Split by "data: " to get individual SSE messages
-	lines := strings.Split(string(body), "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "data: ") {
-			jsonData := strings.TrimPrefix(line, "data: ")
-			jsonData = strings.TrimSpace(jsonData)
-			if jsonData == "" || jsonData == "[DONE]" {
-				continue
-			}
-			
-			var chunk GeminiStreamResponse
-			if err := json.Unmarshal([]byte(jsonData), &chunk); err != nil {
-				continue
-			}
-			
-			// Extract text from candidates
-			if len(chunk.Candidates) > 0 && len(chunk.Candidates[0].Content.Parts) > 0 {
-				text := chunk.Candidates[0].Content.Parts[0].Text
-				fullText.WriteString(text)
-			}
-			
-			// Update token count
-			if chunk.UsageMetadata.TotalTokenCount > 0 {
-				totalTokens = chunk.UsageMetadata.TotalTokenCount
-			}
-		}
-	}
-	
-	narrative := strings.TrimSpace(fullText.String())
-	if narrative == "" {
-		return nil, errors.New("empty response from AI")
+		return nil, err
 	}
+	narrative := reply.Text
 	
 	// Extract game mechanics from the narrative
 	mechanics := s.extractGameMechanics(narrative)
 	
 	return &models.AIResponse{
 		ID:            primitive.NewObjectID(),
+		SessionID:     aiContext.SessionID,
 		Narrative:     narrative,
 		GameMechanics: mechanics,
 		Timestamp:     time.Now(),
-		TokensUsed:    totalTokens,
+		TokensUsed:    reply.Usage.TotalTokens,
+		Provider:      provider.Name(),
+		Model:         reply.Model,
 	}, nil
 }
 
-// buildCombinedPrompt creates a single prompt with all context for Gemini
-func (s *AIService) buildCombinedPrompt(ctx *AIContext) string {
+// buildCombinedPrompt creates a single prompt with all context for the model
+func (s *AIService) buildCombinedPrompt(ctx *models.AIContext) string {
 	var sb strings.Builder
 	
 	// System context
@@ -274,22 +237,3 @@ func (s *AIService) extractGameMechanics(narrative string) []models.GameMechanic
 	
 	return mechanics
 }
-
-// GeminiStreamResponse represents a single chunk in the streaming response
-type GeminiStreamResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-			Role string `json:"role"`
-		} `json:"content"`
-		FinishReason string `json:"finishReason,omitempty"`
-		Index        int    `json:"index"`
-	} `json:"candidates"`
-	UsageMetadata struct {
-		PromptTokenCount int `json:"promptTokenCount"`
-		CandidatesTokenCount int `json:"candidatesTokenCount"`
-		TotalTokenCount int `json:"totalTokenCount"`
-	} `json:"usageMetadata"`
-}
\ No newline at end of file
@@ -1,307 +1,374 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"log"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"dnd-simulator/internal/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	// structuredRetries is how many times the model is asked again after a reply that
+	// can't be repaired
+	structuredRetries = 1
+
+	// rejectedReplyLimit is how much of a rejected reply is quoted back to the model
+	rejectedReplyLimit = 4000
+)
+
+// errInvalidStructuredReply means the model never produced a reply matching the schema
+var errInvalidStructuredReply = errors.New("AI did not return valid structured output")
+
 // EnhancedAIService with structured output support
 type EnhancedAIService struct {
-	apiKey      string
-	model       string
+	providers   *LLMRegistry
 	temperature float64
-	topK        int
-	topP        float64
+	maxTokens   int
 }
 
 // StructuredAIResponse defines the JSON schema for AI responses
 type StructuredAIResponse struct {
-	Narrative     string                    `json:"narrative"`
+	Narrative     string                   `json:"narrative"`
 	GameMechanics []StructuredGameMechanic `json:"game_mechanics"`
-	NPCActions    []NPCAction              `json:"npc_actions,omitempty"`
-	SceneChanges  *SceneChange             `json:"scene_changes,omitempty"`
+	NPCActions    []models.NPCAction       `json:"npc_actions,omitempty"`
+	SceneChanges  *models.SceneChange      `json:"scene_changes,omitempty"`
 }
 
 type StructuredGameMechanic struct {
-	Type         string            `json:"type"`         // "skill_check", "attack_roll", "saving_throw", "damage", "condition"
-	Description  string            `json:"description"`
-	Target       string            `json:"target,omitempty"`
-	Requirements *RollRequirements `json:"requirements,omitempty"`
-	Effects      *MechanicEffects  `json:"effects,omitempty"`
-}
-
-type RollRequirements struct {
-	RollType      string   `json:"roll_type"`      // "d20", "damage", etc.
-	DC            int      `json:"dc,omitempty"`   // Difficulty Class for checks
-	Skill         string   `json:"skill,omitempty"` // For skill checks
-	SaveType      string   `json:"save_type,omitempty"` // For saving throws
-	AttackBonus   int      `json:"attack_bonus,omitempty"`
-	DamageDice    string   `json:"damage_dice,omitempty"` // e.g., "2d6+3"
-	DamageType    string   `json:"damage_type,omitempty"` // e.g., "fire", "slashing"
-	Advantage     bool     `json:"advantage,omitempty"`
-	Disadvantage  bool     `json:"disadvantage,omitempty"`
-}
-
-type MechanicEffects struct {
-	OnSuccess     string   `json:"on_success,omitempty"`
-	OnFailure     string   `json:"on_failure,omitempty"`
-	Conditions    []string `json:"conditions,omitempty"` // e.g., ["prone", "frightened"]
-	Duration      string   `json:"duration,omitempty"`   // e.g., "1 minute", "until end of turn"
-}
-
-type NPCAction struct {
-	NPCName      string `json:"npc_name"`
-	Action       string `json:"action"`
-	Target       string `json:"target,omitempty"`
-	Initiative   int    `json:"initiative,omitempty"`
-}
-
-type SceneChange struct {
-	NewLocation  string   `json:"new_location,omitempty"`
-	TimeChange   string   `json:"time_change,omitempty"`
-	Weather      string   `json:"weather,omitempty"`
-	Visibility   string   `json:"visibility,omitempty"`
-	Hazards      []string `json:"hazards,omitempty"`
+	Type         string                   `json:"type"` // One of mechanicTypes
+	Description  string                   `json:"description"`
+	Target       string                   `json:"target,omitempty"`
+	Requirements *models.RollRequirements `json:"requirements,omitempty"`
+	Effects      *models.MechanicEffects  `json:"effects,omitempty"`
 }
 
-func NewEnhancedAIService(apiKey, model string) *EnhancedAIService {
-	return &EnhancedAIService{
-		apiKey:      apiKey,
-		model:       model,
-		temperature: 0.8,  // Balanced creativity
-		topK:        40,   // Good variety
-		topP:        0.95, // High quality sampling
-	}
-}
+// mechanicTypes are the kinds of game mechanic the model may ask for
+var mechanicTypes = []string{"skill_check", "attack_roll", "saving_throw", "damage", "condition", "initiative"}
 
-func (s *EnhancedAIService) GenerateDMResponse(ctx models.AIContext) (*models.AIResponse, error) {
-	prompt := s.buildStructuredPrompt(ctx)
-	
-	// Define the response schema for structured output
-	responseSchema := map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"narrative": map[string]interface{}{
-				"type": "string",
-				"description": "The narrative description of what happens in the scene",
-			},
-			"game_mechanics": map[string]interface{}{
-				"type": "array",
-				"items": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"type": map[string]interface{}{
-							"type": "string",
-							"enum": []string{"skill_check", "attack_roll", "saving_throw", "damage", "condition", "initiative"},
-						},
-						"description": map[string]interface{}{
-							"type": "string",
-						},
-						"target": map[string]interface{}{
-							"type": "string",
-						},
-						"requirements": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"roll_type": map[string]interface{}{"type": "string"},
-								"dc": map[string]interface{}{"type": "integer"},
-								"skill": map[string]interface{}{"type": "string"},
-								"save_type": map[string]interface{}{"type": "string"},
-								"attack_bonus": map[string]interface{}{"type": "integer"},
-								"damage_dice": map[string]interface{}{"type": "string"},
-								"damage_type": map[string]interface{}{"type": "string"},
-								"advantage": map[string]interface{}{"type": "boolean"},
-								"disadvantage": map[string]interface{}{"type": "boolean"},
-							},
+// structuredResponseSchema is the JSON schema structured replies must match
+var structuredResponseSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"narrative": map[string]interface{}{
+			"type":        "string",
+			"description": "The narrative description of what happens in the scene",
+		},
+		"game_mechanics": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{
+						"type": "string",
+						"enum": mechanicTypes,
+					},
+					"description": map[string]interface{}{"type": "string"},
+					"target":      map[string]interface{}{"type": "string"},
+					"requirements": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"roll_type":    map[string]interface{}{"type": "string"},
+							"dc":           map[string]interface{}{"type": "integer"},
+							"skill":        map[string]interface{}{"type": "string"},
+							"save_type":    map[string]interface{}{"type": "string"},
+							"attack_bonus": map[string]interface{}{"type": "integer"},
+							"damage_dice":  map[string]interface{}{"type": "string"},
+							"damage_type":  map[string]interface{}{"type": "string"},
+							"advantage":    map[string]interface{}{"type": "boolean"},
+							"disadvantage": map[string]interface{}{"type": "boolean"},
 						},
-						"effects": map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"on_success": map[string]interface{}{"type": "string"},
-								"on_failure": map[string]interface{}{"type": "string"},
-								"conditions": map[string]interface{}{
-									"type": "array",
-									"items": map[string]interface{}{"type": "string"},
-								},
-								"duration": map[string]interface{}{"type": "string"},
+					},
+					"effects": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"on_success": map[string]interface{}{"type": "string"},
+							"on_failure": map[string]interface{}{"type": "string"},
+							"conditions": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"type": "string"},
 							},
+							"duration": map[string]interface{}{"type": "string"},
 						},
 					},
-					"required": []string{"type", "description"},
-				},
-			},
-			"npc_actions": map[string]interface{}{
-				"type": "array",
-				"items": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"npc_name": map[string]interface{}{"type": "string"},
-						"action": map[string]interface{}{"type": "string"},
-						"target": map[string]interface{}{"type": "string"},
-						"initiative": map[string]interface{}{"type": "integer"},
-					},
-					"required": []string{"npc_name", "action"},
 				},
+				"required": []string{"type", "description"},
 			},
-			"scene_changes": map[string]interface{}{
+		},
+		"npc_actions": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"new_location": map[string]interface{}{"type": "string"},
-					"time_change": map[string]interface{}{"type": "string"},
-					"weather": map[string]interface{}{"type": "string"},
-					"visibility": map[string]interface{}{"type": "string"},
-					"hazards": map[string]interface{}{
-						"type": "array",
-						"items": map[string]interface{}{"type": "string"},
-					},
+					"npc_name":   map[string]interface{}{"type": "string"},
+					"action":     map[string]interface{}{"type": "string"},
+					"target":     map[string]interface{}{"type": "string"},
+					"initiative": map[string]interface{}{"type": "integer"},
 				},
+				"required": []string{"npc_name", "action"},
 			},
 		},
-		"required": []string{"narrative", "game_mechanics"},
-	}
-
-	requestBody := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"role": "user",
-				"parts": []map[string]interface{}{
-					{
-						"text": prompt,
-					},
+		"scene_changes": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"new_location": map[string]interface{}{"type": "string"},
+				"time_change":  map[string]interface{}{"type": "string"},
+				"weather":      map[string]interface{}{"type": "string"},
+				"visibility":   map[string]interface{}{"type": "string"},
+				"hazards": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "string"},
 				},
 			},
 		},
-		"generationConfig": map[string]interface{}{
-			"temperature":       s.temperature,
-			"maxOutputTokens":   8192,
-			"topK":             s.topK,
-			"topP":             s.topP,
-			"responseMimeType": "application/json",
-			"responseSchema":   responseSchema,
-			"stopSequences":    []string{"[END_SCENE]", "[PLAYER_INPUT_REQUIRED]"},
-		},
-	}
+	},
+	"required": []string{"narrative", "game_mechanics"},
+}
 
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+func NewEnhancedAIService(providers *LLMRegistry) *EnhancedAIService {
+	return &EnhancedAIService{
+		providers:   providers,
+		temperature: 0.8, // Balanced creativity
+		maxTokens:   8192,
 	}
+}
 
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?key=%s",
-		s.model, s.apiKey)
+// GenerateDMResponse asks the campaign's model for a structured response. A reply that
+// doesn't match the schema is repaired if possible; otherwise the model is told what was
+// wrong and asked again. errInvalidStructuredReply means it never got it right.
+func (s *EnhancedAIService) GenerateDMResponse(ctx context.Context, aiContext *models.AIContext) (*models.AIResponse, error) {
+	return s.generateDMResponse(ctx, aiContext, nil)
+}
 
-	resp, err := http.Post(url, "application/json", bytes.NewReader(jsonBody))
+// generateDMResponse is GenerateDMResponse, streaming each attempt's narrative to the
+// narrator if there is one
+func (s *EnhancedAIService) generateDMResponse(ctx context.Context, aiContext *models.AIContext, narration *narrator) (*models.AIResponse, error) {
+	provider, model, err := s.providers.ForCampaign(aiContext.Campaign)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+	prompt := s.buildStructuredPrompt(aiContext)
+	req := &LLMRequest{
+		Model:       model,
+		Prompt:      prompt,
+		Temperature: s.temperature,
+		MaxTokens:   s.maxTokens,
+		JSONSchema:  structuredResponseSchema,
 	}
 
-	// Parse streaming response
-	structuredResp, err := s.parseStructuredStreamResponse(resp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+	tokens := 0
+	var problems []string
+	for attempt := 0; attempt <= structuredRetries; attempt++ {
+		var reply *LLMResponse
+		if narration != nil {
+			narration.begin()
+			reply, err = provider.Stream(ctx, req, newNarrativeExtractor(narration.send).write)
+		} else {
+			reply, err = provider.Generate(ctx, req)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens += reply.Usage.TotalTokens
 
-	// Convert to models.AIResponse
-	return s.convertToAIResponse(structuredResp, ctx.SessionID), nil
+		var structured *StructuredAIResponse
+		structured, problems = parseStructuredResponse(reply.Text)
+		if len(problems) == 0 {
+			response := s.convertToAIResponse(structured, aiContext.SessionID)
+			response.TokensUsed = tokens
+			response.Provider = provider.Name()
+			response.Model = reply.Model
+			return response, nil
+		}
+
+		log.Printf("Rejected structured AI reply (attempt %d): %s", attempt+1, strings.Join(problems, "; "))
+		req.Prompt = retryPrompt(prompt, reply.Text, problems)
+	}
+	return nil, fmt.Errorf("%w: %s", errInvalidStructuredReply, strings.Join(problems, "; "))
 }
 
-func (s *EnhancedAIService) buildStructuredPrompt(ctx models.AIContext) string {
+func (s *EnhancedAIService) buildStructuredPrompt(aiContext *models.AIContext) string {
 	var sb strings.Builder
 
 	sb.WriteString("You are an expert Dungeon Master for D&D 5e. Generate a JSON response for the following game situation.\n\n")
-	
+
 	// Campaign context
-	sb.WriteString(fmt.Sprintf("Campaign: %s\n", ctx.Campaign.Name))
-	sb.WriteString(fmt.Sprintf("Setting: %s\n", ctx.Campaign.Description))
-	sb.WriteString(fmt.Sprintf("Current Scene: %s\n\n", ctx.CurrentScene))
-	
+	sb.WriteString(fmt.Sprintf("Campaign: %s\n", aiContext.Campaign.Name))
+	sb.WriteString(fmt.Sprintf("Setting: %s\n", aiContext.Campaign.Description))
+	if aiContext.Campaign.WorldInfo != "" {
+		sb.WriteString(fmt.Sprintf("World Info: %s\n", aiContext.Campaign.WorldInfo))
+	}
+	sb.WriteString(fmt.Sprintf("Current Scene: %s\n\n", aiContext.CurrentScene))
+
 	// Character information
 	sb.WriteString("Party Members:\n")
-	for _, char := range ctx.Characters {
+	for _, char := range aiContext.Characters {
 		sb.WriteString(fmt.Sprintf("- %s: Level %d %s %s, HP: %d/%d, AC: %d\n",
 			char.Name, char.Level, char.Race, char.Class,
 			char.CurrentHP, char.MaxHP, char.ArmorClass))
 	}
-	
+
 	// Recent events
-	if len(ctx.RecentEvents) > 0 {
+	if len(aiContext.RecentEvents) > 0 {
 		sb.WriteString("\nRecent Events:\n")
-		for _, event := range ctx.RecentEvents {
+		for _, event := range aiContext.RecentEvents {
 			sb.WriteString(fmt.Sprintf("- %s\n", event.Description))
 		}
 	}
-	
+
 	sb.WriteString("\nJSON Response Requirements:\n")
 	sb.WriteString("1. 'narrative': Engaging description of what happens\n")
 	sb.WriteString("2. 'game_mechanics': Array of required game mechanics with specific details\n")
 	sb.WriteString("3. 'npc_actions': Any NPC actions in combat\n")
 	sb.WriteString("4. 'scene_changes': Environmental changes if any\n")
-	sb.WriteString("\nFor each game mechanic, specify exact DCs, damage dice, and effects.\n")
-	
+	sb.WriteString("\nFor each game mechanic, specify exact DCs, damage dice (like 2d6+3), and effects.\n")
+	sb.WriteString(fmt.Sprintf("Mechanic types: %s.\n", strings.Join(mechanicTypes, ", ")))
+
 	// Current action
 	sb.WriteString("\n--- CURRENT ACTION ---\n")
-	
+
 	// Turn information
-	if len(ctx.TurnOrder) > 0 && ctx.CurrentTurn < len(ctx.TurnOrder) {
-		currentChar := ctx.TurnOrder[ctx.CurrentTurn]
+	if len(aiContext.TurnOrder) > 0 && aiContext.CurrentTurn < len(aiContext.TurnOrder) {
+		currentChar := aiContext.TurnOrder[aiContext.CurrentTurn]
 		sb.WriteString(fmt.Sprintf("It is %s's turn.\n\n", currentChar.Name))
 	}
-	
+
 	// Player action
-	sb.WriteString(fmt.Sprintf("Player: %s\n", ctx.PlayerAction.CharacterName))
-	sb.WriteString(fmt.Sprintf("Action: %s\n", ctx.PlayerAction.Action))
-	
-	if ctx.PlayerAction.Target != "" {
-		sb.WriteString(fmt.Sprintf("Target: %s\n", ctx.PlayerAction.Target))
+	sb.WriteString(fmt.Sprintf("Player: %s\n", aiContext.PlayerAction.CharacterName))
+	sb.WriteString(fmt.Sprintf("Action: %s\n", aiContext.PlayerAction.Action))
+
+	if aiContext.PlayerAction.Target != "" {
+		sb.WriteString(fmt.Sprintf("Target: %s\n", aiContext.PlayerAction.Target))
 	}
-	
-	if ctx.PlayerAction.DiceRoll != nil {
-		sb.WriteString(fmt.Sprintf("Dice Roll: %s = %d\n", 
-			ctx.PlayerAction.DiceRoll.Dice, ctx.PlayerAction.DiceRoll.Total))
+
+	if aiContext.PlayerAction.DiceRoll != nil {
+		sb.WriteString(fmt.Sprintf("Dice Roll: %s = %d\n",
+			aiContext.PlayerAction.DiceRoll.Dice, aiContext.PlayerAction.DiceRoll.Total))
 	}
-	
+
 	sb.WriteString("\nGenerate a response that advances the story and provides clear game mechanics.")
-	
+
 	return sb.String()
 }
 
-func (s *EnhancedAIService) parseStructuredStreamResponse(resp *http.Response) (*StructuredAIResponse, error) {
-	// Implementation would parse the SSE stream and extract the JSON response
-	// This is simplified for demonstration
-	
-	// In reality, you'd parse the stream chunks and combine them
-	// For now, assuming we get a complete JSON response
-	
-	var result StructuredAIResponse
-	// Parse streaming response logic here...
-	
-	return &result, nil
+// retryPrompt asks the model again, telling it why its last reply was rejected
+func retryPrompt(prompt, rejected string, problems []string) string {
+	if len(rejected) > rejectedReplyLimit {
+		cut := rejectedReplyLimit
+		for cut > 0 && !utf8.RuneStart(rejected[cut]) {
+			cut-- // Don't split a multi-byte character
+		}
+		rejected = rejected[:cut]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\n--- YOUR PREVIOUS REPLY WAS REJECTED ---\n")
+	for _, problem := range problems {
+		sb.WriteString(fmt.Sprintf("- %s\n", problem))
+	}
+	sb.WriteString("\nPrevious reply:\n")
+	sb.WriteString(rejected)
+	sb.WriteString("\n\nReply again with only a JSON object matching the schema.")
+	return sb.String()
+}
+
+var (
+	codeFencePattern     = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*(.*?)\\s*```$")
+	trailingCommaPattern = regexp.MustCompile(`,\s*([}\]])`)
+)
+
+// repairJSON fixes the usual ways models break JSON: code fences, text around the object
+// and trailing commas
+func repairJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if matches := codeFencePattern.FindStringSubmatch(text); matches != nil {
+		text = matches[1]
+	}
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+		text = text[start : end+1]
+	}
+	return trailingCommaPattern.ReplaceAllString(text, "$1")
+}
+
+// parseStructuredResponse decodes and validates a structured reply, repairing it if it
+// isn't valid as it is. It returns the problems with the reply if neither works.
+func parseStructuredResponse(text string) (*StructuredAIResponse, []string) {
+	candidates := []string{text}
+	if repaired := repairJSON(text); repaired != text {
+		candidates = append(candidates, repaired)
+	}
+
+	var problems []string
+	for _, candidate := range candidates {
+		var raw interface{}
+		decoder := json.NewDecoder(strings.NewReader(candidate))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			problems = []string{"not valid JSON: " + err.Error()}
+			continue
+		}
+		if problems = validateJSON(raw, structuredResponseSchema, "$"); len(problems) > 0 {
+			continue
+		}
+
+		var structured StructuredAIResponse
+		if err := json.Unmarshal([]byte(candidate), &structured); err != nil {
+			problems = []string{"not valid JSON: " + err.Error()}
+			continue
+		}
+		if problems = structured.validate(); len(problems) == 0 {
+			return &structured, nil
+		}
+	}
+	return nil, problems
+}
+
+// validate checks what the schema can't: that there is a narrative and that DCs and
+// damage dice make sense
+func (r *StructuredAIResponse) validate() []string {
+	var problems []string
+	if strings.TrimSpace(r.Narrative) == "" {
+		problems = append(problems, "$.narrative: must not be empty")
+	}
+	for i, mechanic := range r.GameMechanics {
+		req := mechanic.Requirements
+		if req == nil {
+			continue
+		}
+		path := fmt.Sprintf("$.game_mechanics[%d].requirements", i)
+		if req.DC != 0 && (req.DC < 1 || req.DC > 30) {
+			problems = append(problems, fmt.Sprintf("%s.dc: %d is not between 1 and 30", path, req.DC))
+		}
+		if req.DamageDice != "" && !damageDicePattern.MatchString(strings.ToLower(strings.ReplaceAll(req.DamageDice, " ", ""))) {
+			problems = append(problems, fmt.Sprintf("%s.damage_dice: %q is not dice notation like 2d6+3", path, req.DamageDice))
+		}
+	}
+	return problems
 }
 
 func (s *EnhancedAIService) convertToAIResponse(structured *StructuredAIResponse, sessionID primitive.ObjectID) *models.AIResponse {
 	mechanics := make([]models.GameMechanic, len(structured.GameMechanics))
-	
+
 	for i, sm := range structured.GameMechanics {
 		mechanic := models.GameMechanic{
-			Type:        sm.Type,
-			Description: sm.Description,
-			Target:      sm.Target,
-			Metadata:    make(map[string]interface{}),
+			Type:         sm.Type,
+			Description:  sm.Description,
+			Target:       sm.Target,
+			Metadata:     make(map[string]interface{}),
+			Requirements: sm.Requirements,
+			Effects:      sm.Effects,
 		}
-		
-		// Add requirements to metadata
+
+		// Add requirements to metadata, for clients that predate the typed fields
 		if sm.Requirements != nil {
 			if sm.Requirements.DC > 0 {
 				mechanic.Metadata["dc"] = sm.Requirements.DC
@@ -320,7 +387,7 @@ func (s *EnhancedAIService) convertToAIResponse(structured *StructuredAIResponse
 				mechanic.Metadata["disadvantage"] = true
 			}
 		}
-		
+
 		// Add effects to metadata
 		if sm.Effects != nil {
 			if sm.Effects.OnSuccess != "" {
@@ -336,14 +403,18 @@ func (s *EnhancedAIService) convertToAIResponse(structured *StructuredAIResponse
 				mechanic.Metadata["duration"] = sm.Effects.Duration
 			}
 		}
-		
+
 		mechanics[i] = mechanic
 	}
-	
+
 	return &models.AIResponse{
+		ID:            primitive.NewObjectID(),
 		SessionID:     sessionID,
-		Narrative:     structured.Narrative,
+		Narrative:     strings.TrimSpace(structured.Narrative),
 		GameMechanics: mechanics,
+		NPCActions:    structured.NPCActions,
+		SceneChanges:  structured.SceneChanges,
+		Structured:    true,
 		Timestamp:     time.Now(),
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/config"
+	"dnd-simulator/internal/models"
+)
+
+const validStructuredReply = `{"narrative": "The goblin snarls.", "game_mechanics": [{"type": "attack_roll", "description": "The goblin attacks", "requirements": {"attack_bonus": 4, "damage_dice": "1d6+2"}}]}`
+
+// newScriptedAIService returns an AI service whose only provider is a mock with the replies
+func newScriptedAIService(replies ...string) (*EnhancedAIService, *MockProvider) {
+	mock := NewMockProvider(replies...)
+	registry := NewLLMRegistry(&config.Config{AIProvider: "mock"})
+	registry.Register(mock)
+	return NewEnhancedAIService(registry), mock
+}
+
+// testAIContext is a player's action in a minimal campaign
+func testAIContext(sessionID primitive.ObjectID) *models.AIContext {
+	return &models.AIContext{
+		SessionID:    sessionID,
+		Campaign:     &models.Campaign{Name: "Test Campaign"},
+		PlayerAction: models.PlayerAction{CharacterName: "Hero", Action: "I attack the goblin"},
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "code fence", text: "```json\n{\"a\": 1}\n```", want: `{"a": 1}`},
+		{name: "text around the object", text: `Here you go: {"a": 1} Enjoy!`, want: `{"a": 1}`},
+		{name: "trailing commas", text: `{"a": [1, 2,], "b": 3,}`, want: `{"a": [1, 2], "b": 3}`},
+		{name: "already valid", text: `{"a": 1}`, want: `{"a": 1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repairJSON(tt.text); got != tt.want {
+				t.Errorf("repairJSON(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStructuredResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		problem string // Expected in the problems; empty for a valid reply
+	}{
+		{name: "valid", text: validStructuredReply},
+		{name: "repaired", text: "```json\n" + `{"narrative": "Quiet.", "game_mechanics": [],}` + "\n```"},
+		{name: "not JSON", text: "The goblin snarls.", problem: "not valid JSON"},
+		{name: "missing mechanics", text: `{"narrative": "Quiet."}`, problem: "$.game_mechanics: is required"},
+		{name: "unknown mechanic", text: `{"narrative": "Quiet.", "game_mechanics": [{"type": "dance", "description": "x"}]}`, problem: `$.game_mechanics[0].type: "dance" is not one of`},
+		{name: "empty narrative", text: `{"narrative": " ", "game_mechanics": []}`, problem: "$.narrative: must not be empty"},
+		{name: "DC out of range", text: `{"narrative": "Climb.", "game_mechanics": [{"type": "skill_check", "description": "x", "requirements": {"dc": 45}}]}`, problem: "dc: 45 is not between 1 and 30"},
+		{name: "bad damage dice", text: `{"narrative": "Ouch.", "game_mechanics": [{"type": "damage", "description": "x", "requirements": {"damage_dice": "lots"}}]}`, problem: "is not dice notation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structured, problems := parseStructuredResponse(tt.text)
+			if tt.problem == "" {
+				if len(problems) > 0 || structured == nil {
+					t.Fatalf("rejected a valid reply: %q", problems)
+				}
+				return
+			}
+			if structured != nil {
+				t.Fatalf("accepted an invalid reply")
+			}
+			if !strings.Contains(strings.Join(problems, "\n"), tt.problem) {
+				t.Errorf("got problems %q, want one containing %q", problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestGenerateDMResponseValid(t *testing.T) {
+	service, mock := newScriptedAIService(validStructuredReply)
+	sessionID := primitive.NewObjectID()
+
+	response, err := service.GenerateDMResponse(context.Background(), testAIContext(sessionID))
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if response.Narrative != "The goblin snarls." || len(response.GameMechanics) != 1 {
+		t.Errorf("got %+v", response)
+	}
+	if response.SessionID != sessionID || response.Provider != "mock" || response.Model != "mock" {
+		t.Errorf("got session %s from %s/%s", response.SessionID.Hex(), response.Provider, response.Model)
+	}
+	if requests := mock.Requests(); len(requests) != 1 || requests[0].JSONSchema == nil {
+		t.Errorf("sent %d requests, want one asking for structured output", len(requests))
+	}
+}
+
+// A reply that only needs repairing is used without asking the model again
+func TestGenerateDMResponseRepairs(t *testing.T) {
+	service, mock := newScriptedAIService("Sure! ```json\n" + validStructuredReply + "\n```")
+
+	if _, err := service.GenerateDMResponse(context.Background(), testAIContext(primitive.NilObjectID)); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if got := len(mock.Requests()); got != 1 {
+		t.Errorf("sent %d requests, want 1", got)
+	}
+}
+
+// An invalid reply is sent back with its problems, and the retry's answer is used
+func TestGenerateDMResponseRetries(t *testing.T) {
+	service, mock := newScriptedAIService(`{"narrative": "Hm.", "game_mechanics": [{"type": "dance"}]}`, validStructuredReply)
+
+	response, err := service.GenerateDMResponse(context.Background(), testAIContext(primitive.NilObjectID))
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if response.Narrative != "The goblin snarls." {
+		t.Errorf("used narrative %q, want the retry's", response.Narrative)
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(requests))
+	}
+	retry := requests[1].Prompt
+	for _, want := range []string{"REJECTED", `"dance" is not one of`, "$.game_mechanics[0].description: is required"} {
+		if !strings.Contains(retry, want) {
+			t.Errorf("retry prompt doesn't mention %q", want)
+		}
+	}
+	if !strings.HasPrefix(retry, requests[0].Prompt) {
+		t.Error("retry prompt doesn't repeat the original")
+	}
+
+	// Both attempts count toward the tokens used
+	var first, second *LLMResponse
+	first, _ = NewMockProvider(`{"narrative": "Hm.", "game_mechanics": [{"type": "dance"}]}`).Generate(context.Background(), &requests[0])
+	second, _ = NewMockProvider(validStructuredReply).Generate(context.Background(), &requests[1])
+	if want := first.Usage.TotalTokens + second.Usage.TotalTokens; response.TokensUsed != want {
+		t.Errorf("used %d tokens, want %d", response.TokensUsed, want)
+	}
+}
+
+func TestGenerateDMResponseGivesUp(t *testing.T) {
+	service, mock := newScriptedAIService("I'd rather not.")
+
+	_, err := service.GenerateDMResponse(context.Background(), testAIContext(primitive.NilObjectID))
+	if !errors.Is(err, errInvalidStructuredReply) {
+		t.Fatalf("got %v, want errInvalidStructuredReply", err)
+	}
+	if got := len(mock.Requests()); got != structuredRetries+1 {
+		t.Errorf("sent %d requests, want %d", got, structuredRetries+1)
+	}
+}
+
+// A long rejected reply is cut to fit the retry prompt without splitting a character
+func TestRetryPromptTruncatesAtRune(t *testing.T) {
+	rejected := strings.Repeat("a", rejectedReplyLimit-1) + "é" + strings.Repeat("b", 10)
+
+	prompt := retryPrompt("Prompt", rejected, []string{"bad"})
+	if !utf8.ValidString(prompt) {
+		t.Fatal("the retry prompt is not valid UTF-8")
+	}
+	if !strings.Contains(prompt, strings.Repeat("a", rejectedReplyLimit-1)+"\n") {
+		t.Error("the reply wasn't cut just before the split character")
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+// areaCells returns the map cells covered by an area template. The effect spreads from
+// its origin, so cells the origin has no line of sight to (behind walls) are excluded.
+func areaCells(battleMap *models.BattleMap, template models.AreaTemplate) ([]models.GridPoint, error) {
+	if !inBounds(battleMap, template.Origin) {
+		return nil, errors.New("template origin is off the map")
+	}
+
+	size := float64(template.Size) / float64(battleMap.CellFeet)
+	dirX := float64(template.Target.X - template.Origin.X)
+	dirY := float64(template.Target.Y - template.Origin.Y)
+	length := math.Hypot(dirX, dirY)
+	if template.Shape != models.AreaSphere && length == 0 {
+		return nil, fmt.Errorf("a %s needs a target cell to point toward", template.Shape)
+	}
+
+	halfWidth := 0.5
+	if template.Width > 0 {
+		halfWidth = float64(template.Width) / float64(battleMap.CellFeet) / 2
+	}
+
+	// Cubes extend from their corner toward the target's quadrant
+	stepX, stepY := 1, 1
+	if dirX < 0 {
+		stepX = -1
+	}
+	if dirY < 0 {
+		stepY = -1
+	}
+	side := int(math.Round(size))
+
+	walls := wallSet(battleMap)
+	cells := []models.GridPoint{}
+	for x := 0; x < battleMap.Width; x++ {
+		for y := 0; y < battleMap.Height; y++ {
+			cell := models.GridPoint{X: x, Y: y}
+			offsetX := float64(x - template.Origin.X)
+			offsetY := float64(y - template.Origin.Y)
+
+			inside := false
+			switch template.Shape {
+			case models.AreaSphere:
+				inside = math.Hypot(offsetX, offsetY) <= size
+			case models.AreaCone:
+				// A 5e cone is as wide as it is long: half-angle atan(1/2)
+				distance := math.Hypot(offsetX, offsetY)
+				if distance > 0 && distance <= size {
+					cos := (offsetX*dirX + offsetY*dirY) / (distance * length)
+					inside = cos >= math.Cos(math.Atan(0.5))
+				}
+			case models.AreaLine:
+				along := (offsetX*dirX + offsetY*dirY) / length
+				across := math.Abs(offsetX*dirY-offsetY*dirX) / length
+				inside = along > 0 && along <= size && across <= halfWidth
+			case models.AreaCube:
+				dx := (x - template.Origin.X) * stepX
+				dy := (y - template.Origin.Y) * stepY
+				inside = dx >= 0 && dy >= 0 && dx < side && dy < side
+			}
+
+			if inside && !walls[cell] && lineOfSight(walls, template.Origin, cell) {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells, nil
+}
+
+// PreviewArea returns the cells and tokens an area template would cover
+func (s *CombatService) PreviewArea(ctx context.Context, sessionID primitive.ObjectID, template models.AreaTemplate) ([]models.GridPoint, []models.MapToken, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if session.Map == nil {
+		return nil, nil, errors.New("session has no battle map")
+	}
+
+	cells, err := areaCells(session.Map, template)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cells, tokensInCells(session.Map, cells), nil
+}
+
+// ResolveArea applies an area effect to every token it covers: each target rolls its
+// own saving throw, damage is rolled once, and each target takes full damage on a
+// failed save and half (or none) on a success, after resistances. With a caster, the
+// caster's action is spent and whoever controls the caster may resolve it; otherwise
+// only the DM can. The caster's turn is returned when one was given. If damaging a target
+// fails partway, the targets already damaged are logged before the error is returned.
+func (s *CombatService) ResolveArea(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.ResolveAreaRequest) (*models.AreaEffect, *CombatTurn, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if session.Map == nil {
+		return nil, nil, errors.New("session has no battle map")
+	}
+
+	cells, err := areaCells(session.Map, req.Template)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var caster *CombatTurn
+	if !req.Caster.CharacterID.IsZero() || !req.Caster.CombatantID.IsZero() {
+		caster, err = s.GetTurn(ctx, sessionID, userID, req.Caster)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if session.DMUserID != userID {
+		return nil, nil, errors.New("only the DM can resolve an area effect without a caster")
+	}
+
+	// Every target is loaded before anything is spent or damaged, so a missing one
+	// fails the whole effect instead of part of it
+	type areaVictim struct {
+		token  models.MapToken
+		target *combatTarget
+	}
+	var victims []areaVictim
+	for _, token := range tokensInCells(session.Map, cells) {
+		if token.CharacterID.IsZero() && token.CombatantID.IsZero() {
+			continue // Unbound tokens (objects, markers) have no stats to resolve
+		}
+		target, err := s.loadTarget(ctx, session, models.CombatActor{CharacterID: token.CharacterID, CombatantID: token.CombatantID})
+		if err != nil {
+			return nil, nil, err
+		}
+		victims = append(victims, areaVictim{token: token, target: target})
+	}
+
+	damageRoll, err := s.diceService.ParseAndRoll(req.DamageDice, fmt.Sprintf("%s damage", req.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if caster != nil {
+		if err := s.spend(ctx, caster, EconomyCost{Action: true}, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	effect := &models.AreaEffect{
+		Name:        req.Name,
+		Template:    req.Template,
+		Cells:       cells,
+		SaveAbility: req.SaveAbility,
+		SaveDC:      req.SaveDC,
+		DamageRoll:  damageRoll,
+		DamageType:  req.DamageType,
+		OnSave:      req.OnSave,
+		Targets:     []models.AreaTarget{},
+	}
+	if effect.OnSave == "" {
+		effect.OnSave = "half"
+	}
+
+	saveName := strings.ToUpper(req.SaveAbility[:1]) + req.SaveAbility[1:]
+	var applyErr error
+	for _, victim := range victims {
+		target := victim.target
+		save := s.diceService.RollSavingThrow(target.saveModifier(req.SaveAbility), saveName)
+		result := models.AreaTarget{
+			TokenID:     victim.token.ID,
+			Name:        target.Name,
+			CharacterID: target.CharacterID,
+			CombatantID: target.CombatantID,
+			Save:        save,
+			Saved:       save.Total >= req.SaveDC,
+			HPBefore:    target.CurrentHP,
+		}
+
+		damage := damageRoll.Total
+		if result.Saved {
+			damage = 0
+			if effect.OnSave == "half" {
+				damage = damageRoll.Total / 2
+			}
+		}
+		result.Damage, result.DamageNote = target.adjustDamage(damage, req.DamageType)
+
+		result.HPAfter, err = s.applyDamage(ctx, target, result.Damage)
+		if err != nil {
+			applyErr = fmt.Errorf("failed to damage %s: %w", target.Name, err)
+			break
+		}
+		effect.Targets = append(effect.Targets, result)
+	}
+
+	// Logged even when damaging a target failed, so the damage already dealt is on record
+	turn := caster
+	if turn == nil {
+		turn = &CombatTurn{Session: session, Entry: &models.TurnEntry{Name: req.Name}}
+	}
+	summary := areaSummary(effect)
+	data := map[string]interface{}{
+		"kind":   "area_effect",
+		"effect": effect,
+	}
+	if applyErr != nil {
+		summary += fmt.Sprintf(" (stopped early: %v)", applyErr)
+		data["error"] = applyErr.Error()
+	}
+	if err := s.logCombatEvent(ctx, turn, summary, data); err != nil {
+		return nil, nil, err
+	}
+	if applyErr != nil {
+		return nil, nil, applyErr
+	}
+
+	return effect, caster, nil
+}
+
+// areaSummary describes an area effect's outcome in one line for the event log
+func areaSummary(effect *models.AreaEffect) string {
+	parts := make([]string, 0, len(effect.Targets))
+	for _, target := range effect.Targets {
+		outcome := "fails"
+		if target.Saved {
+			outcome = "saves"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s and takes %d", target.Name, outcome, target.Damage))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%s hits no one", effect.Name)
+	}
+	return fmt.Sprintf("%s (%d %s): %s", effect.Name, effect.DamageRoll.Total, effect.DamageType, strings.Join(parts, "; "))
+}
+
+// tokensInCells returns the tokens standing on any of the given cells
+func tokensInCells(battleMap *models.BattleMap, cells []models.GridPoint) []models.MapToken {
+	covered := make(map[models.GridPoint]bool, len(cells))
+	for _, cell := range cells {
+		covered[cell] = true
+	}
+
+	tokens := []models.MapToken{}
+	for _, token := range battleMap.Tokens {
+		if covered[token.Position()] {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+var damageDicePattern = regexp.MustCompile(`^(\d+)d(\d+)([+-]\d+)?$`)
+
+// attackProfile is the to-hit bonus and damage of a weapon or monster action
+type attackProfile struct {
+	Name            string
+	AttackBonus     int
+	DamageDice      string
+	DamageModifier  int
+	DamageType      string
+	ExtraDamageDice string
+	ExtraDamageType string
+}
+
+// weaponProfile builds a character's attack with a weapon. Finesse weapons use the better
+// of Strength and Dexterity, ranged weapons use Dexterity and everything else Strength.
+// Characters are assumed proficient with the weapons they carry.
+func weaponProfile(character *models.Character, name string) (*attackProfile, error) {
+	if len(character.Weapons) == 0 {
+		return nil, fmt.Errorf("%s has no weapons", character.Name)
+	}
+
+	weapon := &character.Weapons[0]
+	if name != "" {
+		weapon = nil
+		for i := range character.Weapons {
+			if strings.EqualFold(character.Weapons[i].Name, name) {
+				weapon = &character.Weapons[i]
+				break
+			}
+		}
+		if weapon == nil {
+			return nil, fmt.Errorf("%s does not carry a %s", character.Name, name)
+		}
+	}
+
+	strength := abilityModifier(character.Abilities.Strength)
+	dexterity := abilityModifier(character.Abilities.Dexterity)
+	modifier := strength
+	switch {
+	case containsFold(weapon.Properties, "finesse"):
+		if dexterity > strength {
+			modifier = dexterity
+		}
+	case containsFold(weapon.Properties, "ammunition"),
+		weapon.Range != "" && !containsFold(weapon.Properties, "thrown"):
+		modifier = dexterity
+	}
+
+	return &attackProfile{
+		Name:           weapon.Name,
+		AttackBonus:    modifier + character.ProficiencyBonus,
+		DamageDice:     weapon.Damage,
+		DamageModifier: modifier,
+		DamageType:     weapon.DamageType,
+	}, nil
+}
+
+// monsterProfile builds a monster's attack from one of its stat block actions, defaulting
+// to the first action with an attack roll
+func monsterProfile(monster *models.Monster, name string) (*attackProfile, error) {
+	for _, action := range monster.Actions {
+		if action.DamageDice == "" || (action.AttackBonus == 0 && action.SaveDC > 0) {
+			continue // Not an attack roll
+		}
+		if name != "" && !strings.EqualFold(action.Name, name) {
+			continue
+		}
+		return &attackProfile{
+			Name:            action.Name,
+			AttackBonus:     action.AttackBonus,
+			DamageDice:      action.DamageDice,
+			DamageType:      action.DamageType,
+			ExtraDamageDice: action.ExtraDamageDice,
+			ExtraDamageType: action.ExtraDamageType,
+		}, nil
+	}
+
+	if name != "" {
+		return nil, fmt.Errorf("%s has no attack named %s", monster.Name, name)
+	}
+	return nil, fmt.Errorf("%s has no attacks", monster.Name)
+}
+
+// damageDice adds a modifier to a dice string and doubles the number of dice on a critical hit
+func damageDice(dice string, modifier int, critical bool) (string, error) {
+	matches := damageDicePattern.FindStringSubmatch(strings.TrimSpace(strings.ToLower(dice)))
+	if matches == nil {
+		return "", fmt.Errorf("invalid damage dice: %s", dice)
+	}
+
+	count, _ := strconv.Atoi(matches[1])
+	if matches[3] != "" {
+		flat, _ := strconv.Atoi(matches[3])
+		modifier += flat
+	}
+	if critical {
+		count *= 2
+	}
+
+	if modifier == 0 {
+		return fmt.Sprintf("%dd%s", count, matches[2]), nil
+	}
+	return fmt.Sprintf("%dd%s%+d", count, matches[2], modifier), nil
+}
+
+// ResolveAttack makes a full attack: it rolls to hit against the target's armor class,
+// rolls damage on a hit (doubling the dice on a critical), applies resistances and
+// subtracts the damage from the target's hit points. The attacker's action (or bonus
+// action) is spent, so the user must control the attacker; the AI DM acts as the DM.
+func (s *CombatService) ResolveAttack(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.ResolveAttackRequest) (*models.AttackResult, *CombatTurn, error) {
+	if req.Target.CharacterID.IsZero() && req.Target.CombatantID.IsZero() {
+		return nil, nil, errors.New("an attack needs a target character or combatant")
+	}
+
+	turn, err := s.GetTurn(ctx, sessionID, userID, req.Attacker)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attacker, err := s.loadTarget(ctx, turn.Session, models.CombatActor{
+		CharacterID: turn.Entry.CharacterID,
+		CombatantID: turn.Entry.CombatantID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := s.loadTarget(ctx, turn.Session, req.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var profile *attackProfile
+	switch {
+	case attacker.Character != nil:
+		profile, err = weaponProfile(attacker.Character, req.Weapon)
+	case attacker.Monster != nil:
+		profile, err = monsterProfile(attacker.Monster, req.Action)
+	default:
+		err = fmt.Errorf("%s has no stat block to attack with", attacker.Name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roll := s.diceService.RollAttackWithAdvantage(profile.AttackBonus, req.Advantage, profile.Name)
+	natural := roll.Total - roll.Modifier
+	result := &models.AttackResult{
+		AttackerName: attacker.Name,
+		AttackerID:   targetID(attacker),
+		TargetName:   target.Name,
+		TargetID:     targetID(target),
+		Attack:       profile.Name,
+		Advantage:    req.Advantage,
+		AttackRoll:   roll,
+		Natural:      natural,
+		TargetAC:     target.ArmorClass,
+		Critical:     natural == 20,
+		Hit:          natural == 20 || (natural != 1 && roll.Total >= target.ArmorClass),
+		DamageType:   profile.DamageType,
+		HPBefore:     target.CurrentHP,
+		HPAfter:      target.CurrentHP,
+	}
+
+	if result.Hit {
+		dice, err := damageDice(profile.DamageDice, profile.DamageModifier, result.Critical)
+		if err != nil {
+			return nil, nil, err
+		}
+		damageRoll, err := s.diceService.ParseAndRoll(dice, fmt.Sprintf("%s damage", profile.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+		result.DamageRolls = append(result.DamageRolls, damageRoll)
+		result.Damage, result.DamageNote = target.adjustDamage(max(damageRoll.Total, 0), profile.DamageType)
+
+		if profile.ExtraDamageDice != "" {
+			dice, err := damageDice(profile.ExtraDamageDice, 0, result.Critical)
+			if err != nil {
+				return nil, nil, err
+			}
+			extraRoll, err := s.diceService.ParseAndRoll(dice, fmt.Sprintf("%s %s damage", profile.Name, profile.ExtraDamageType))
+			if err != nil {
+				return nil, nil, err
+			}
+			result.DamageRolls = append(result.DamageRolls, extraRoll)
+			extra, _ := target.adjustDamage(extraRoll.Total, profile.ExtraDamageType)
+			result.Damage += extra
+		}
+	}
+
+	// Spent before the damage, so two requests can't both attack with one action; if the
+	// damage can't be applied, the action is given back
+	cost := EconomyCost{Action: !req.BonusAction, BonusAction: req.BonusAction}
+	if err := s.spend(ctx, turn, cost, nil); err != nil {
+		return nil, nil, err
+	}
+
+	result.HPAfter, err = s.applyDamage(ctx, target, result.Damage)
+	if err != nil {
+		if refundErr := s.refund(ctx, turn, cost); refundErr != nil {
+			log.Printf("Failed to give back %s's attack after it failed: %v", attacker.Name, refundErr)
+		}
+		return nil, nil, err
+	}
+	result.Narrative = attackNarrative(result)
+
+	err = s.logCombatEvent(ctx, turn, result.Narrative, map[string]interface{}{
+		"kind":   "attack",
+		"result": result,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, turn, nil
+}
+
+// attackNarrative describes an attack's outcome in one line
+func attackNarrative(result *models.AttackResult) string {
+	roll := fmt.Sprintf("%d vs AC %d", result.AttackRoll.Total, result.TargetAC)
+	if result.Advantage != "" {
+		roll = fmt.Sprintf("%s, with %s", roll, result.Advantage)
+	}
+
+	switch {
+	case !result.Hit && result.Natural == 1:
+		return fmt.Sprintf("%s attacks %s with %s and rolls a natural 1 — a miss (%s).", result.AttackerName, result.TargetName, result.Attack, roll)
+	case !result.Hit:
+		return fmt.Sprintf("%s attacks %s with %s and misses (%s).", result.AttackerName, result.TargetName, result.Attack, roll)
+	}
+
+	hit := "hits"
+	if result.Critical {
+		hit = "lands a critical hit on"
+	}
+	damage := fmt.Sprintf("%d %s damage", result.Damage, result.DamageType)
+	if result.DamageNote != "" {
+		damage = fmt.Sprintf("%s (%s)", damage, result.DamageNote)
+	}
+
+	narrative := fmt.Sprintf("%s %s %s with %s for %s (%s).", result.AttackerName, hit, result.TargetName, result.Attack, damage, roll)
+	if result.HPAfter == 0 && result.HPBefore > 0 {
+		narrative += fmt.Sprintf(" %s drops to 0 hit points!", result.TargetName)
+	}
+	return narrative
+}
+
+// targetID returns a target's character or combatant ID
+func targetID(target *combatTarget) primitive.ObjectID {
+	if !target.CharacterID.IsZero() {
+		return target.CharacterID
+	}
+	return target.CombatantID
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/database"
+	"dnd-simulator/internal/models"
+)
+
+const (
+	DefaultChatPageSize = 50
+	MaxChatPageSize     = 200
+)
+
+// ChatService stores session chat in the chat_messages collection
+type ChatService struct {
+	db             *database.DB
+	sessionService *SessionService
+}
+
+// NewChatService creates a new chat service instance
+func NewChatService(db *database.DB, sessionService *SessionService) *ChatService {
+	return &ChatService{
+		db:             db,
+		sessionService: sessionService,
+	}
+}
+
+// EnsureIndexes creates the indexes chat pagination and search rely on
+func (s *ChatService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.db.GetCollection("chat_messages").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "message", Value: "text"}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create chat indexes: %w", err)
+	}
+	return nil
+}
+
+// AddMessage stores a chat message
+func (s *ChatService) AddMessage(ctx context.Context, msg *models.SessionChatMessage) error {
+	if msg.ID.IsZero() {
+		msg.ID = primitive.NewObjectID()
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	_, err := s.db.GetCollection("chat_messages").InsertOne(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to add chat message: %w", err)
+	}
+	return nil
+}
+
+// SendMessage checks a message's visibility and recipients against the session, stores it
+// and returns the users it should be delivered to, or nil for the whole session
+func (s *ChatService) SendMessage(ctx context.Context, msg *models.SessionChatMessage) ([]primitive.ObjectID, error) {
+	session, err := s.sessionService.GetSession(ctx, msg.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg.Visibility {
+	case "", models.ChatVisibilityPublic:
+		msg.Visibility = models.ChatVisibilityPublic
+		msg.Recipients = nil
+	case models.ChatVisibilityDM:
+		msg.Recipients = nil
+	case models.ChatVisibilityWhisper:
+		recipients, err := whisperRecipients(session, msg.UserID, msg.Recipients)
+		if err != nil {
+			return nil, err
+		}
+		msg.Recipients = recipients
+	default:
+		return nil, fmt.Errorf("unknown chat visibility: %s", msg.Visibility)
+	}
+
+	if err := s.AddMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg.Audience(session.DMUserID), nil
+}
+
+// whisperRecipients checks that every recipient is the DM or a player in the session,
+// dropping duplicates and the sender
+func whisperRecipients(session *models.GameSession, senderID primitive.ObjectID, recipients []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	members := map[primitive.ObjectID]bool{session.DMUserID: true}
+	for _, player := range session.Players {
+		members[player.UserID] = true
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	valid := []primitive.ObjectID{}
+	for _, id := range recipients {
+		if !members[id] {
+			return nil, fmt.Errorf("whisper recipient %s is not in this session", id.Hex())
+		}
+		if id == senderID || seen[id] {
+			continue
+		}
+		seen[id] = true
+		valid = append(valid, id)
+	}
+	if len(valid) == 0 {
+		return nil, errors.New("a whisper needs at least one other recipient")
+	}
+	return valid, nil
+}
+
+// visibleTo matches the messages a user may read: public ones, their own, whispers to them,
+// and for the DM, messages sent to the DM. A zero user ID, as for spectators, only reads
+// public messages.
+func visibleTo(session *models.GameSession, userID primitive.ObjectID) bson.M {
+	if userID.IsZero() {
+		return bson.M{"visibility": bson.M{"$in": bson.A{nil, models.ChatVisibilityPublic}}}
+	}
+	or := bson.A{
+		bson.M{"visibility": bson.M{"$in": bson.A{nil, models.ChatVisibilityPublic}}},
+		bson.M{"user_id": userID},
+		bson.M{"recipients": userID},
+	}
+	if session.DMUserID == userID {
+		or = append(or, bson.M{"visibility": models.ChatVisibilityDM})
+	}
+	return bson.M{"$or": or}
+}
+
+// GetMessages returns a page of the chat a user can see, newest first, starting just
+// before the given message ID (or at the latest message when it is zero)
+func (s *ChatService) GetMessages(ctx context.Context, sessionID, userID, before primitive.ObjectID, limit int) (*models.ChatPage, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	limit = chatPageSize(limit)
+
+	filter := visibleTo(session, userID)
+	filter["session_id"] = sessionID
+	if !before.IsZero() {
+		filter["_id"] = bson.M{"$lt": before}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := s.db.GetCollection("chat_messages").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat messages: %w", err)
+	}
+
+	page := &models.ChatPage{Messages: []models.SessionChatMessage{}}
+	if err := cursor.All(ctx, &page.Messages); err != nil {
+		return nil, fmt.Errorf("failed to decode chat messages: %w", err)
+	}
+	if len(page.Messages) == limit {
+		oldest := page.Messages[len(page.Messages)-1].ID
+		page.Before = &oldest
+	}
+	return page, nil
+}
+
+// SearchMessages runs a full-text search over the chat a user can see, best matches first
+func (s *ChatService) SearchMessages(ctx context.Context, sessionID, userID primitive.ObjectID, query string, limit int) ([]models.SessionChatMessage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("search query is required")
+	}
+
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	filter := visibleTo(session, userID)
+	filter["session_id"] = sessionID
+	filter["$text"] = bson.M{"$search": query}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(chatPageSize(limit)))
+	cursor, err := s.db.GetCollection("chat_messages").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search chat messages: %w", err)
+	}
+
+	messages := []models.SessionChatMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode chat messages: %w", err)
+	}
+	return messages, nil
+}
+
+// EditMessage changes a message's text; only its author or the session's DM may edit it.
+// It returns the users the edit should be delivered to, or nil for the whole session.
+func (s *ChatService) EditMessage(ctx context.Context, sessionID, messageID, userID primitive.ObjectID, text string) (*models.SessionChatMessage, []primitive.ObjectID, error) {
+	session, filter, err := s.moderationFilter(ctx, sessionID, messageID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var msg models.SessionChatMessage
+	err = s.db.GetCollection("chat_messages").FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"message": text, "edited_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&msg)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, errors.New("message not found or you cannot edit it")
+		}
+		return nil, nil, fmt.Errorf("failed to edit chat message: %w", err)
+	}
+	return &msg, msg.Audience(session.DMUserID), nil
+}
+
+// DeleteMessage removes a message; only its author or the session's DM may delete it.
+// It returns the users the deletion should be delivered to, or nil for the whole session.
+func (s *ChatService) DeleteMessage(ctx context.Context, sessionID, messageID, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	session, filter, err := s.moderationFilter(ctx, sessionID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg models.SessionChatMessage
+	err = s.db.GetCollection("chat_messages").FindOneAndDelete(ctx, filter).Decode(&msg)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("message not found or you cannot delete it")
+		}
+		return nil, fmt.Errorf("failed to delete chat message: %w", err)
+	}
+	return msg.Audience(session.DMUserID), nil
+}
+
+// moderationFilter matches a session message the user may change: for the DM, any message
+// they can see, otherwise only the user's own
+func (s *ChatService) moderationFilter(ctx context.Context, sessionID, messageID, userID primitive.ObjectID) (*models.GameSession, bson.M, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filter := bson.M{"_id": messageID, "session_id": sessionID, "user_id": userID}
+	if session.DMUserID == userID {
+		filter = visibleTo(session, userID)
+		filter["_id"] = messageID
+		filter["session_id"] = sessionID
+	}
+	return session, filter, nil
+}
+
+// legacyChatMsg is a chat message as it was embedded in session documents
+type legacyChatMsg struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Username  string             `bson:"username"`
+	Message   string             `bson:"message"`
+	Type      string             `bson:"type"`
+	Timestamp time.Time          `bson:"timestamp"`
+}
+
+// MigrateEmbeddedChat moves chat histories embedded in session documents into the
+// chat_messages collection and removes them from the sessions. It is safe to run on
+// every start: messages keep their IDs, so a re-run after a partial failure skips the
+// ones already copied.
+func (s *ChatService) MigrateEmbeddedChat(ctx context.Context) (int, error) {
+	sessions := s.db.GetCollection("sessions")
+	cursor, err := sessions.Find(ctx,
+		bson.M{"chat_history": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"chat_history": 1}),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find sessions with chat history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	moved := 0
+	for cursor.Next(ctx) {
+		var session struct {
+			ID          primitive.ObjectID `bson:"_id"`
+			ChatHistory []legacyChatMsg    `bson:"chat_history"`
+		}
+		if err := cursor.Decode(&session); err != nil {
+			return moved, fmt.Errorf("failed to decode session chat history: %w", err)
+		}
+
+		if len(session.ChatHistory) > 0 {
+			documents := make([]interface{}, 0, len(session.ChatHistory))
+			for i, legacy := range session.ChatHistory {
+				id := legacy.ID
+				if id.IsZero() {
+					id = legacyMessageID(session.ID, legacy.Timestamp, i)
+				}
+				documents = append(documents, models.SessionChatMessage{
+					ID:        id,
+					SessionID: session.ID,
+					UserID:    legacy.UserID,
+					Username:  legacy.Username,
+					Message:   legacy.Message,
+					Type:      legacy.Type,
+					Timestamp: legacy.Timestamp,
+				})
+			}
+
+			_, err := s.db.GetCollection("chat_messages").InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+			if err != nil && !onlyDuplicateKeyErrors(err) {
+				return moved, fmt.Errorf("failed to copy chat history: %w", err)
+			}
+			moved += len(documents)
+		}
+
+		_, err = sessions.UpdateOne(ctx, bson.M{"_id": session.ID}, bson.M{"$unset": bson.M{"chat_history": ""}})
+		if err != nil {
+			return moved, fmt.Errorf("failed to remove embedded chat history: %w", err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return moved, fmt.Errorf("failed to read sessions: %w", err)
+	}
+
+	return moved, nil
+}
+
+// legacyMessageID builds a stable ID for an embedded message that never had one. It starts
+// with the message's timestamp so pagination keeps the original order, and is derived from
+// the session and the message's position so a re-run produces the same ID.
+func legacyMessageID(sessionID primitive.ObjectID, timestamp time.Time, index int) primitive.ObjectID {
+	id := primitive.NewObjectIDFromTimestamp(timestamp)
+	copy(id[4:9], sessionID[7:12])
+	id[9], id[10], id[11] = byte(index>>16), byte(index>>8), byte(index)
+	return id
+}
+
+// onlyDuplicateKeyErrors reports whether a bulk write failed only on documents that already exist
+func onlyDuplicateKeyErrors(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
+
+// chatPageSize clamps a requested page size, defaulting when unset
+func chatPageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultChatPageSize
+	}
+	if limit > MaxChatPageSize {
+		return MaxChatPageSize
+	}
+	return limit
+}
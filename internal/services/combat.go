@@ -0,0 +1,581 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/database"
+	"dnd-simulator/internal/models"
+)
+
+// CombatService enforces turn-based combat rules on the session turn order
+type CombatService struct {
+	db             *database.DB
+	sessionService *SessionService
+	diceService    *DiceService
+	eventService   *EventService
+}
+
+// NewCombatService creates a new combat service instance
+func NewCombatService(db *database.DB, sessionService *SessionService, diceService *DiceService, eventService *EventService) *CombatService {
+	return &CombatService{
+		db:             db,
+		sessionService: sessionService,
+		diceService:    diceService,
+		eventService:   eventService,
+	}
+}
+
+// EconomyCost is what a single combat action spends from the actor's turn
+type EconomyCost struct {
+	Action            bool
+	BonusAction       bool
+	Reaction          bool
+	ObjectInteraction bool
+	Movement          int // Feet of movement spent
+	ExtraMovement     int // Feet added to this turn's movement (Dash)
+	LegendaryActions  int // Legendary actions spent between other creatures' turns
+}
+
+// reactionOnly reports whether the cost may be paid outside the actor's own turn
+func (c EconomyCost) reactionOnly() bool {
+	return c.Reaction && !c.Action && !c.BonusAction && !c.ObjectInteraction && c.Movement == 0 && c.ExtraMovement == 0
+}
+
+// ErrMoveOnMap means movement was spent directly in a session with a battle map, where it
+// has to be spent by moving the token
+var ErrMoveOnMap = errors.New("this session has a battle map: move the token with POST /api/sessions/:id/map/tokens/:tokenId/move instead")
+
+// CombatTurn is a turn order entry resolved for a combat action
+type CombatTurn struct {
+	Session *models.GameSession
+	Index   int
+	Entry   *models.TurnEntry
+
+	// InitiativeFinished is set when resolving the turn closed an expired initiative
+	// roll window, so the turn order hasn't been announced yet
+	InitiativeFinished bool
+}
+
+// Recipient returns the user controlling this turn entry (the DM for NPCs)
+func (t *CombatTurn) Recipient() primitive.ObjectID {
+	if t.Entry.UserID.IsZero() {
+		return t.Session.DMUserID
+	}
+	return t.Entry.UserID
+}
+
+// GetTurn resolves the acting turn entry and checks the user may act for it
+func (s *CombatService) GetTurn(ctx context.Context, sessionID, userID primitive.ObjectID, actor models.CombatActor) (*CombatTurn, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, finished, err := s.sessionService.finishExpiredInitiative(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if session.PendingInitiative != nil {
+		return nil, errors.New("initiative is still being rolled")
+	}
+	if len(session.TurnOrder) == 0 {
+		return nil, errors.New("no turn order established")
+	}
+
+	index := -1
+	switch {
+	case !actor.CharacterID.IsZero():
+		for i, entry := range session.TurnOrder {
+			if entry.CharacterID == actor.CharacterID {
+				index = i
+				break
+			}
+		}
+	case !actor.CombatantID.IsZero():
+		for i, entry := range session.TurnOrder {
+			if entry.CombatantID == actor.CombatantID {
+				index = i
+				break
+			}
+		}
+	default:
+		index = session.CurrentTurn
+	}
+	if index < 0 || index >= len(session.TurnOrder) {
+		return nil, errors.New("combatant not found in turn order")
+	}
+
+	entry := &session.TurnOrder[index]
+	if session.DMUserID != userID && (entry.UserID.IsZero() || entry.UserID != userID) {
+		return nil, errors.New("you do not control this combatant")
+	}
+
+	return &CombatTurn{Session: session, Index: index, Entry: entry, InitiativeFinished: finished}, nil
+}
+
+// SpendEconomy deducts a cost from the actor's action economy
+func (s *CombatService) SpendEconomy(ctx context.Context, sessionID, userID primitive.ObjectID, actor models.CombatActor, cost EconomyCost) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, userID, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.spend(ctx, turn, cost, nil); err != nil {
+		return nil, err
+	}
+	return turn, nil
+}
+
+// spend deducts a cost from a resolved turn entry, setting any extra entry fields in the
+// same write. Only reactions may be spent outside the actor's own turn, and legendary
+// actions only outside it. The update is conditional on the economy not having changed
+// since it was read, so concurrent requests cannot double-spend.
+func (s *CombatService) spend(ctx context.Context, turn *CombatTurn, cost EconomyCost, extra bson.M) error {
+	return s.spendWith(ctx, turn, cost, extra, nil, nil)
+}
+
+// spendWith is spend, also making another change to the session in the same conditional
+// write: where matches the state it depends on and set is applied with the spend
+func (s *CombatService) spendWith(ctx context.Context, turn *CombatTurn, cost EconomyCost, extra, where, also bson.M) error {
+	isCurrent := turn.Index == turn.Session.CurrentTurn
+	economy := &turn.Entry.Economy
+
+	switch {
+	case cost.LegendaryActions > 0:
+		if isCurrent {
+			return errors.New("legendary actions can only be taken at the end of another creature's turn")
+		}
+		if remaining := turn.Entry.LegendaryActions - economy.LegendaryActionsUsed; cost.LegendaryActions > remaining {
+			return fmt.Errorf("not enough legendary actions remaining (%d left)", remaining)
+		}
+	case !isCurrent && !cost.reactionOnly():
+		return fmt.Errorf("it is not %s's turn", turn.Entry.Name)
+	}
+
+	if cost.Action && economy.ActionUsed {
+		return errors.New("action already used this turn")
+	}
+	if cost.BonusAction && economy.BonusActionUsed {
+		return errors.New("bonus action already used this turn")
+	}
+	if cost.Reaction && economy.ReactionUsed {
+		return errors.New("reaction already used this round")
+	}
+	if cost.ObjectInteraction && economy.ObjectInteractionUsed {
+		return errors.New("free object interaction already used this turn")
+	}
+	if cost.Movement > economy.MovementRemaining()+cost.ExtraMovement {
+		return fmt.Errorf("not enough movement remaining (%d ft left)", economy.MovementRemaining())
+	}
+
+	prefix := fmt.Sprintf("turn_order.%d.", turn.Index)
+	filter := bson.M{
+		"_id":                            turn.Session.ID,
+		"current_turn":                   turn.Session.CurrentTurn,
+		"round":                          turn.Session.Round,
+		prefix + "name":                  turn.Entry.Name,
+		prefix + "economy.movement_used": economy.MovementUsed,
+		prefix + "economy.movement_max":  economy.MovementMax,
+		prefix + "economy.legendary_actions_used": economy.LegendaryActionsUsed,
+	}
+	set := bson.M{"updated_at": time.Now()}
+	inc := bson.M{}
+
+	if cost.Action {
+		filter[prefix+"economy.action_used"] = false
+		set[prefix+"economy.action_used"] = true
+		economy.ActionUsed = true
+	}
+	if cost.BonusAction {
+		filter[prefix+"economy.bonus_action_used"] = false
+		set[prefix+"economy.bonus_action_used"] = true
+		economy.BonusActionUsed = true
+	}
+	if cost.Reaction {
+		filter[prefix+"economy.reaction_used"] = false
+		set[prefix+"economy.reaction_used"] = true
+		economy.ReactionUsed = true
+	}
+	if cost.ObjectInteraction {
+		filter[prefix+"economy.object_interaction_used"] = false
+		set[prefix+"economy.object_interaction_used"] = true
+		economy.ObjectInteractionUsed = true
+	}
+	if cost.ExtraMovement > 0 {
+		inc[prefix+"economy.movement_max"] = cost.ExtraMovement
+		economy.MovementMax += cost.ExtraMovement
+	}
+	if cost.Movement > 0 {
+		inc[prefix+"economy.movement_used"] = cost.Movement
+		economy.MovementUsed += cost.Movement
+	}
+	if cost.LegendaryActions > 0 {
+		inc[prefix+"economy.legendary_actions_used"] = cost.LegendaryActions
+		economy.LegendaryActionsUsed += cost.LegendaryActions
+	}
+	for field, value := range extra {
+		set[prefix+field] = value
+	}
+	for field, value := range where {
+		filter[field] = value
+	}
+	for field, value := range also {
+		set[field] = value
+	}
+
+	update := bson.M{"$set": set}
+	if len(inc) > 0 {
+		update["$inc"] = inc
+	}
+
+	result, err := s.sessionService.updateSession(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to update action economy: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return s.sessionService.conflict(ctx, turn.Session.ID)
+	}
+
+	return nil
+}
+
+// refund gives back the action or bonus action spend took for something that then failed.
+// It only applies while the turn is still the one the action was spent in.
+func (s *CombatService) refund(ctx context.Context, turn *CombatTurn, cost EconomyCost) error {
+	prefix := fmt.Sprintf("turn_order.%d.", turn.Index)
+	filter := bson.M{
+		"_id":           turn.Session.ID,
+		"current_turn":  turn.Session.CurrentTurn,
+		"round":         turn.Session.Round,
+		prefix + "name": turn.Entry.Name,
+	}
+	set := bson.M{"updated_at": time.Now()}
+	if cost.Action {
+		filter[prefix+"economy.action_used"] = true
+		set[prefix+"economy.action_used"] = false
+		turn.Entry.Economy.ActionUsed = false
+	}
+	if cost.BonusAction {
+		filter[prefix+"economy.bonus_action_used"] = true
+		set[prefix+"economy.bonus_action_used"] = false
+		turn.Entry.Economy.BonusActionUsed = false
+	}
+
+	if _, err := s.sessionService.updateSession(ctx, filter, bson.M{"$set": set}); err != nil {
+		return fmt.Errorf("failed to refund action economy: %w", err)
+	}
+	return nil
+}
+
+// Attack spends the action (or bonus action) for a weapon attack
+func (s *CombatService) Attack(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.AttackActionRequest) (*CombatTurn, error) {
+	return s.SpendEconomy(ctx, sessionID, userID, req.CombatActor, EconomyCost{
+		Action:      !req.BonusAction,
+		BonusAction: req.BonusAction,
+	})
+}
+
+// CastSpell spends the economy slot matching the spell's casting time
+func (s *CombatService) CastSpell(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.CastSpellRequest) (*CombatTurn, error) {
+	var cost EconomyCost
+	switch req.CastingTime {
+	case "bonus_action":
+		cost.BonusAction = true
+	case "reaction":
+		cost.Reaction = true
+	default:
+		cost.Action = true
+	}
+	return s.SpendEconomy(ctx, sessionID, userID, req.CombatActor, cost)
+}
+
+// Dash spends the action (or bonus action) to add the actor's speed to this turn's movement
+func (s *CombatService) Dash(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.DashRequest) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, userID, req.CombatActor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.spend(ctx, turn, EconomyCost{
+		Action:        !req.BonusAction,
+		BonusAction:   req.BonusAction,
+		ExtraMovement: turn.Entry.Speed,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return turn, nil
+}
+
+// Move spends feet of movement in a session without a battle map. With a map, movement is
+// spent by moving the token, so its path is checked against walls and other tokens.
+func (s *CombatService) Move(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.MoveRequest) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, userID, req.CombatActor)
+	if err != nil {
+		return nil, err
+	}
+	if turn.Session.Map != nil {
+		return nil, ErrMoveOnMap
+	}
+
+	// Only while there is still no map, in case one is set up meanwhile
+	if err := s.spendWith(ctx, turn, EconomyCost{Movement: req.Feet}, nil, bson.M{"map": nil}, nil); err != nil {
+		return nil, err
+	}
+	return turn, nil
+}
+
+// Interact spends the free object interaction for this turn
+func (s *CombatService) Interact(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.InteractRequest) (*CombatTurn, error) {
+	return s.SpendEconomy(ctx, sessionID, userID, req.CombatActor, EconomyCost{ObjectInteraction: true})
+}
+
+// Ready spends the action to hold another action until a trigger occurs. The readied
+// action is taken with the reaction, and lapses at the start of the combatant's next turn.
+func (s *CombatService) Ready(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.ReadyActionRequest) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, userID, req.CombatActor)
+	if err != nil {
+		return nil, err
+	}
+
+	readied := &models.ReadiedAction{
+		Trigger:   req.Trigger,
+		Action:    req.Action,
+		Round:     turn.Session.Round,
+		ReadiedAt: time.Now(),
+	}
+	if err := s.spend(ctx, turn, EconomyCost{Action: true}, bson.M{"readied": readied}); err != nil {
+		return nil, err
+	}
+	turn.Entry.Readied = readied
+
+	err = s.logCombatEvent(ctx, turn, fmt.Sprintf("%s readies an action: %s, %s", turn.Entry.Name, req.Action, req.Trigger), map[string]interface{}{
+		"kind":    "ready",
+		"trigger": req.Trigger,
+		"action":  req.Action,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return turn, nil
+}
+
+// TriggerReadied spends the reaction to take a combatant's readied action
+func (s *CombatService) TriggerReadied(ctx context.Context, sessionID, userID primitive.ObjectID, actor models.CombatActor) (*CombatTurn, *models.ReadiedAction, error) {
+	turn, err := s.GetTurn(ctx, sessionID, userID, actor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readied := turn.Entry.Readied
+	if readied == nil {
+		return nil, nil, fmt.Errorf("%s has no readied action", turn.Entry.Name)
+	}
+
+	if err := s.spend(ctx, turn, EconomyCost{Reaction: true}, bson.M{"readied": nil}); err != nil {
+		return nil, nil, err
+	}
+	turn.Entry.Readied = nil
+
+	err = s.logCombatEvent(ctx, turn, fmt.Sprintf("%s takes their readied action: %s", turn.Entry.Name, readied.Action), map[string]interface{}{
+		"kind":    "readied_trigger",
+		"trigger": readied.Trigger,
+		"action":  readied.Action,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return turn, readied, nil
+}
+
+// Reaction spends any combatant's reaction out of turn, e.g. an opportunity attack (DM only)
+func (s *CombatService) Reaction(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.ReactionRequest) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, dmUserID, req.CombatActor)
+	if err != nil {
+		return nil, err
+	}
+
+	if turn.Session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can trigger reactions")
+	}
+
+	if err := s.spend(ctx, turn, EconomyCost{Reaction: true}, nil); err != nil {
+		return nil, err
+	}
+
+	err = s.logCombatEvent(ctx, turn, fmt.Sprintf("%s reacts: %s", turn.Entry.Name, req.Reaction), map[string]interface{}{
+		"kind":     "reaction",
+		"reaction": req.Reaction,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return turn, nil
+}
+
+// DelayTurn moves the current combatant later in the turn order before they act. Delaying
+// spends the reaction, which stays spent through the delayed turn. The combatant now in
+// the current slot starts their turn.
+func (s *CombatService) DelayTurn(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.DelayTurnRequest) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, userID, req.CombatActor)
+	if err != nil {
+		return nil, err
+	}
+
+	session := turn.Session
+	if turn.Index != session.CurrentTurn {
+		return nil, fmt.Errorf("it is not %s's turn", turn.Entry.Name)
+	}
+
+	economy := turn.Entry.Economy
+	if economy.ActionUsed || economy.BonusActionUsed || economy.MovementUsed > 0 || economy.ObjectInteractionUsed {
+		return nil, errors.New("cannot delay after acting this turn")
+	}
+	if economy.ReactionUsed {
+		return nil, errors.New("delaying requires a reaction, which is already used this round")
+	}
+	if req.Initiative >= turn.Entry.Initiative {
+		return nil, fmt.Errorf("delayed initiative must be lower than %d", turn.Entry.Initiative)
+	}
+
+	delayed := *turn.Entry
+	delayed.Initiative = req.Initiative
+	delayed.Delayed = true
+	delayed.Economy.ReactionUsed = true
+
+	rest := make([]models.TurnEntry, 0, len(session.TurnOrder))
+	rest = append(rest, session.TurnOrder[:turn.Index]...)
+	rest = append(rest, session.TurnOrder[turn.Index+1:]...)
+
+	// Combatants before the current one have already acted, so only look after it
+	position := len(rest)
+	for i := turn.Index; i < len(rest); i++ {
+		if rest[i].Initiative < req.Initiative {
+			position = i
+			break
+		}
+	}
+	if position == turn.Index {
+		return nil, fmt.Errorf("no one acts before initiative %d; end your turn instead", req.Initiative)
+	}
+
+	turnOrder := make([]models.TurnEntry, 0, len(session.TurnOrder))
+	turnOrder = append(turnOrder, rest[:position]...)
+	turnOrder = append(turnOrder, delayed)
+	turnOrder = append(turnOrder, rest[position:]...)
+	startTurn(&turnOrder[session.CurrentTurn])
+
+	// The whole turn order is rewritten, so only write it over the exact state read above
+	applied, err := s.sessionService.compareAndSwap(ctx, session, bson.M{
+		"$set": bson.M{
+			"turn_order": turnOrder,
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delay turn: %w", err)
+	}
+	if !applied {
+		return nil, s.sessionService.conflict(ctx, sessionID)
+	}
+
+	session.TurnOrder = turnOrder
+	turn.Index = position
+	turn.Entry = &turnOrder[position]
+
+	err = s.logCombatEvent(ctx, turn, fmt.Sprintf("%s delays to initiative %d", turn.Entry.Name, req.Initiative), map[string]interface{}{
+		"kind":       "delay",
+		"initiative": req.Initiative,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return turn, nil
+}
+
+// LairAction takes the lair action on the lair's turn (DM only)
+func (s *CombatService) LairAction(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.LairActionRequest) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, dmUserID, models.CombatActor{})
+	if err != nil {
+		return nil, err
+	}
+
+	if turn.Entry.Type != models.TurnTypeLair {
+		return nil, errors.New("lair actions can only be taken on the lair's turn")
+	}
+
+	if err := s.spend(ctx, turn, EconomyCost{Action: true}, nil); err != nil {
+		return nil, err
+	}
+
+	err = s.logCombatEvent(ctx, turn, fmt.Sprintf("%s: %s", turn.Entry.Name, req.Action), map[string]interface{}{
+		"kind":   "lair_action",
+		"action": req.Action,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return turn, nil
+}
+
+// LegendaryAction spends a monster's legendary actions between other creatures' turns (DM only).
+// Legendary actions refresh at the start of the monster's own turn.
+func (s *CombatService) LegendaryAction(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.LegendaryActionRequest) (*CombatTurn, error) {
+	turn, err := s.GetTurn(ctx, sessionID, dmUserID, models.CombatActor{CombatantID: req.CombatantID})
+	if err != nil {
+		return nil, err
+	}
+
+	if turn.Entry.LegendaryActions == 0 {
+		return nil, fmt.Errorf("%s has no legendary actions", turn.Entry.Name)
+	}
+
+	cost := req.Cost
+	if cost == 0 {
+		cost = 1
+	}
+	if err := s.spend(ctx, turn, EconomyCost{LegendaryActions: cost}, nil); err != nil {
+		return nil, err
+	}
+
+	err = s.logCombatEvent(ctx, turn, fmt.Sprintf("%s uses a legendary action: %s", turn.Entry.Name, req.Action), map[string]interface{}{
+		"kind":      "legendary_action",
+		"action":    req.Action,
+		"cost":      cost,
+		"remaining": turn.Entry.LegendaryActions - turn.Entry.Economy.LegendaryActionsUsed,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return turn, nil
+}
+
+// logCombatEvent records an out-of-the-ordinary combat action in the session's event log
+func (s *CombatService) logCombatEvent(ctx context.Context, turn *CombatTurn, description string, data map[string]interface{}) error {
+	actorID := turn.Entry.CharacterID
+	if actorID.IsZero() {
+		actorID = turn.Entry.CombatantID
+	}
+
+	data["name"] = turn.Entry.Name
+	data["round"] = turn.Session.Round
+	return s.eventService.StoreEvent(ctx, &models.GameEvent{
+		SessionID:   turn.Session.ID,
+		Type:        "combat",
+		Description: description,
+		ActorID:     actorID,
+		Data:        data,
+	})
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+// combatFixture is a session in combat: a player's character up first, then two NPCs
+type combatFixture struct {
+	sessions  *SessionService
+	combat    *CombatService
+	sessionID primitive.ObjectID
+	dmID      primitive.ObjectID
+	playerID  primitive.ObjectID
+	character primitive.ObjectID
+}
+
+func newCombatFixture(t *testing.T) *combatFixture {
+	t.Helper()
+
+	db := newTestDB(t)
+	dice := NewDiceService()
+	sessions := NewSessionService(db, dice)
+	f := &combatFixture{
+		sessions:  sessions,
+		combat:    NewCombatService(db, sessions, dice, NewEventService(db)),
+		sessionID: primitive.NewObjectID(),
+		dmID:      primitive.NewObjectID(),
+		playerID:  primitive.NewObjectID(),
+		character: primitive.NewObjectID(),
+	}
+
+	now := time.Now()
+	session := &models.GameSession{
+		ID:       f.sessionID,
+		Name:     "Concurrency",
+		Status:   models.SessionStatusActive,
+		DMUserID: f.dmID,
+		Players: []models.SessionPlayer{
+			{UserID: f.playerID, CharacterID: f.character, Username: "player", CharName: "Hero", JoinedAt: now},
+		},
+		TurnOrder: []models.TurnEntry{
+			{Type: models.TurnTypePlayer, UserID: f.playerID, CharacterID: f.character, Initiative: 20, Name: "Hero", Speed: 30, Economy: models.NewActionEconomy(30)},
+			{Type: models.TurnTypeNPC, CombatantID: primitive.NewObjectID(), Initiative: 15, Name: "Goblin", Speed: 30},
+			{Type: models.TurnTypeNPC, CombatantID: primitive.NewObjectID(), Initiative: 10, Name: "Wolf", Speed: 40},
+		},
+		Round:     1,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := db.GetCollection("sessions").InsertOne(context.Background(), session); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	return f
+}
+
+func (f *combatFixture) session(t *testing.T) *models.GameSession {
+	t.Helper()
+	session, err := f.sessions.GetSession(context.Background(), f.sessionID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	return session
+}
+
+func isConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
+
+// Two DMs advancing from the same version: one wins and the other gets a conflict
+func TestAdvanceTurnExpectedVersionConflict(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+
+	for round := 0; round < 10; round++ {
+		before := f.session(t)
+
+		errs := make([]error, 2)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = f.sessions.AdvanceTurn(ctx, f.sessionID, f.dmID, false, before.Version)
+			}(i)
+		}
+		wg.Wait()
+
+		succeeded, conflicts := 0, 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				succeeded++
+			case isConflict(err):
+				conflicts++
+			default:
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if succeeded != 1 || conflicts != 1 {
+			t.Fatalf("got %d successes and %d conflicts, want 1 and 1", succeeded, conflicts)
+		}
+
+		after := f.session(t)
+		if after.Version != before.Version+1 {
+			t.Fatalf("version went from %d to %d, want one write", before.Version, after.Version)
+		}
+		if want := (before.CurrentTurn + 1) % len(before.TurnOrder); after.CurrentTurn != want {
+			t.Fatalf("current turn is %d, want %d", after.CurrentTurn, want)
+		}
+	}
+}
+
+// Advances without an expected version retry on conflict, so none of them are lost
+func TestAdvanceTurnConcurrentNoLostUpdate(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	before := f.session(t)
+
+	// Each loser retries after someone else's write lands, so this many always fit
+	// within the retry budget
+	const racers = maxSessionUpdateAttempts
+
+	errs := make([]error, racers)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = f.sessions.AdvanceTurn(ctx, f.sessionID, f.dmID, false, 0)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("advance failed: %v", err)
+		}
+	}
+
+	after := f.session(t)
+	turns := before.CurrentTurn + racers
+	if want := turns % len(before.TurnOrder); after.CurrentTurn != want {
+		t.Errorf("current turn is %d, want %d", after.CurrentTurn, want)
+	}
+	if want := before.Round + turns/len(before.TurnOrder); after.Round != want {
+		t.Errorf("round is %d, want %d", after.Round, want)
+	}
+	if want := before.Version + racers; after.Version != want {
+		t.Errorf("version is %d, want %d", after.Version, want)
+	}
+}
+
+// A player delaying while the DM advances: exactly one of them lands
+func TestDelayTurnRacesAdvanceTurn(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	before := f.session(t)
+
+	var advanceErr, delayErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, advanceErr = f.sessions.AdvanceTurn(ctx, f.sessionID, f.dmID, false, before.Version)
+	}()
+	go func() {
+		defer wg.Done()
+		_, delayErr = f.combat.DelayTurn(ctx, f.sessionID, f.playerID, &models.DelayTurnRequest{
+			CombatActor: models.CombatActor{CharacterID: f.character},
+			Initiative:  12,
+		})
+	}()
+	wg.Wait()
+
+	after := f.session(t)
+	if after.Version != before.Version+1 {
+		t.Fatalf("version went from %d to %d, want exactly one write", before.Version, after.Version)
+	}
+
+	switch {
+	case advanceErr == nil && delayErr == nil:
+		t.Fatal("both the advance and the delay succeeded")
+	case advanceErr == nil:
+		if after.CurrentTurn != 1 || after.TurnOrder[0].Name != "Hero" {
+			t.Errorf("advance won but the turn order is %+v at turn %d", after.TurnOrder, after.CurrentTurn)
+		}
+	case delayErr == nil:
+		if !isConflict(advanceErr) {
+			t.Errorf("delay won but the advance failed with %v, want a conflict", advanceErr)
+		}
+		if after.TurnOrder[1].Name != "Hero" || !after.TurnOrder[1].Delayed {
+			t.Errorf("delay won but the turn order is %+v", after.TurnOrder)
+		}
+	default:
+		t.Fatalf("both failed: advance %v, delay %v", advanceErr, delayErr)
+	}
+}
+
+// Racing attacks spend the action once; the rest are turned away with a conflict or
+// because the action is already used
+func TestSpendConcurrentAttacks(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	before := f.session(t)
+
+	const racers = 8
+	errs := make([]error, racers)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = f.combat.Attack(ctx, f.sessionID, f.playerID, &models.AttackActionRequest{
+				CombatActor: models.CombatActor{CharacterID: f.character},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case isConflict(err), err.Error() == "action already used this turn":
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d attacks spent the action, want 1", succeeded)
+	}
+
+	after := f.session(t)
+	if !after.TurnOrder[0].Economy.ActionUsed {
+		t.Error("action is not marked used")
+	}
+	if after.Version != before.Version+1 {
+		t.Errorf("version went from %d to %d, want one write", before.Version, after.Version)
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+// Movement is only spent directly without a map; with one, the token's path is checked
+func TestMoveRefusedWithMap(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	move := &models.MoveRequest{Feet: 10}
+
+	turn, err := f.combat.Move(ctx, f.sessionID, f.playerID, move)
+	if err != nil {
+		t.Fatalf("move without a map: %v", err)
+	}
+	if turn.Entry.Economy.MovementUsed != 10 {
+		t.Errorf("spent %d ft, want 10", turn.Entry.Economy.MovementUsed)
+	}
+
+	battleMap := models.BattleMap{Width: 10, Height: 10, CellFeet: 5, UpdatedAt: time.Now()}
+	_, err = f.sessions.db.GetCollection("sessions").UpdateOne(ctx, bson.M{"_id": f.sessionID}, bson.M{"$set": bson.M{"map": battleMap}})
+	if err != nil {
+		t.Fatalf("set map: %v", err)
+	}
+
+	if _, err := f.combat.Move(ctx, f.sessionID, f.playerID, move); !errors.Is(err, ErrMoveOnMap) {
+		t.Fatalf("move with a map: got %v, want ErrMoveOnMap", err)
+	}
+	if used := f.session(t).TurnOrder[0].Economy.MovementUsed; used != 10 {
+		t.Errorf("movement used is %d ft after the refused move, want 10", used)
+	}
+}
+
+// An area effect with a target that can't be loaded fails before the caster's action is spent
+func TestResolveAreaChecksTargetsFirst(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+
+	battleMap := models.BattleMap{
+		Width:    10,
+		Height:   10,
+		CellFeet: 5,
+		// The fixture's character has no character document to load
+		Tokens:    []models.MapToken{{ID: primitive.NewObjectID(), Name: "Hero", CharacterID: f.character, X: 2, Y: 2}},
+		UpdatedAt: time.Now(),
+	}
+	_, err := f.sessions.db.GetCollection("sessions").UpdateOne(ctx, bson.M{"_id": f.sessionID}, bson.M{"$set": bson.M{"map": battleMap}})
+	if err != nil {
+		t.Fatalf("set map: %v", err)
+	}
+
+	_, _, err = f.combat.ResolveArea(ctx, f.sessionID, f.playerID, &models.ResolveAreaRequest{
+		Caster:      models.CombatActor{CharacterID: f.character},
+		Name:        "Fireball",
+		Template:    models.AreaTemplate{Shape: models.AreaSphere, Origin: models.GridPoint{X: 2, Y: 2}, Size: 20},
+		SaveAbility: "dexterity",
+		SaveDC:      15,
+		DamageDice:  "8d6",
+	})
+	if err == nil {
+		t.Fatal("resolved an area effect on a target that doesn't exist")
+	}
+	if f.session(t).TurnOrder[0].Economy.ActionUsed {
+		t.Error("the caster's action was spent on an effect that failed")
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/data"
+	"dnd-simulator/internal/models"
+)
+
+// combatTarget is a session character or encounter combatant that can be attacked or
+// forced to make a saving throw
+type combatTarget struct {
+	Name            string
+	CharacterID     primitive.ObjectID
+	CombatantID     primitive.ObjectID
+	EncounterID     primitive.ObjectID
+	ArmorClass      int
+	CurrentHP       int
+	Speed           int
+	Abilities       models.AbilityScores
+	SavingThrows    map[string]int
+	Resistances     []string
+	Immunities      []string
+	Vulnerabilities []string
+	Character       *models.Character // Set for characters
+	Monster         *models.Monster   // Set for combatants spawned from a known monster
+}
+
+// saveModifier returns the target's saving throw modifier for an ability
+func (t *combatTarget) saveModifier(ability string) int {
+	if modifier, ok := t.SavingThrows[ability]; ok {
+		return modifier
+	}
+	return abilityModifier(abilityScore(t.Abilities, ability))
+}
+
+// adjustDamage applies immunity, resistance and vulnerability to an amount of damage
+func (t *combatTarget) adjustDamage(amount int, damageType string) (int, string) {
+	damageType = strings.ToLower(damageType)
+	if damageType == "" {
+		return amount, ""
+	}
+	if containsFold(t.Immunities, damageType) {
+		return 0, "immune"
+	}
+	if containsFold(t.Resistances, damageType) {
+		return amount / 2, "resistant"
+	}
+	if containsFold(t.Vulnerabilities, damageType) {
+		return amount * 2, "vulnerable"
+	}
+	return amount, ""
+}
+
+// loadTarget resolves a character in the session or a combatant in one of its encounters
+func (s *CombatService) loadTarget(ctx context.Context, session *models.GameSession, actor models.CombatActor) (*combatTarget, error) {
+	switch {
+	case !actor.CharacterID.IsZero():
+		inSession := false
+		for _, player := range session.Players {
+			if player.CharacterID == actor.CharacterID {
+				inSession = true
+				break
+			}
+		}
+		if !inSession {
+			return nil, errors.New("character is not in this session")
+		}
+
+		var character models.Character
+		err := s.db.GetCollection("characters").FindOne(ctx, bson.M{"_id": actor.CharacterID}).Decode(&character)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get character: %w", err)
+		}
+
+		target := &combatTarget{
+			Name:         character.Name,
+			CharacterID:  character.ID,
+			ArmorClass:   character.ArmorClass,
+			CurrentHP:    character.CurrentHP,
+			Speed:        character.Speed,
+			Abilities:    character.Abilities,
+			SavingThrows: character.SavingThrows,
+			Character:    &character,
+		}
+		for _, trait := range data.Races[character.Race].Traits {
+			target.Resistances = append(target.Resistances, data.TraitResistances[trait]...)
+		}
+		return target, nil
+
+	case !actor.CombatantID.IsZero():
+		var encounter models.Encounter
+		err := s.db.GetCollection("encounters").FindOne(ctx, bson.M{
+			"session_id":     session.ID,
+			"combatants._id": actor.CombatantID,
+		}).Decode(&encounter)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, errors.New("combatant not found in this session's encounters")
+			}
+			return nil, fmt.Errorf("failed to get encounter: %w", err)
+		}
+
+		for _, combatant := range encounter.Combatants {
+			if combatant.ID != actor.CombatantID {
+				continue
+			}
+			target := &combatTarget{
+				Name:        combatant.Name,
+				CombatantID: combatant.ID,
+				EncounterID: encounter.ID,
+				ArmorClass:  combatant.ArmorClass,
+				CurrentHP:   combatant.CurrentHP,
+				Speed:       combatant.Speed,
+				Abilities:   combatant.Abilities,
+			}
+			if monster, ok := data.Monsters[combatant.MonsterSlug]; ok {
+				target.SavingThrows = monster.SavingThrows
+				target.Resistances = monster.DamageResistances
+				target.Immunities = monster.DamageImmunities
+				target.Vulnerabilities = monster.DamageVulnerabilities
+				target.Monster = &monster
+			}
+			return target, nil
+		}
+	}
+
+	return nil, errors.New("target must be a character or combatant")
+}
+
+// applyDamage subtracts damage from a target's hit points, stopping at 0, and returns the new total
+func (s *CombatService) applyDamage(ctx context.Context, target *combatTarget, damage int) (int, error) {
+	if damage <= 0 {
+		return target.CurrentHP, nil
+	}
+
+	if !target.CharacterID.IsZero() {
+		collection := s.db.GetCollection("characters")
+		filter := bson.M{"_id": target.CharacterID}
+		if _, err := collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"current_hp": -damage}}); err != nil {
+			return 0, fmt.Errorf("failed to apply damage: %w", err)
+		}
+
+		var character models.Character
+		err := collection.FindOneAndUpdate(ctx, filter,
+			bson.M{"$max": bson.M{"current_hp": 0}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&character)
+		if err != nil {
+			return 0, fmt.Errorf("failed to apply damage: %w", err)
+		}
+		target.CurrentHP = character.CurrentHP
+		return character.CurrentHP, nil
+	}
+
+	collection := s.db.GetCollection("encounters")
+	filter := bson.M{"_id": target.EncounterID}
+	arrayFilters := options.ArrayFilters{Filters: []interface{}{bson.M{"c._id": target.CombatantID}}}
+	_, err := collection.UpdateOne(ctx, filter,
+		bson.M{"$inc": bson.M{"combatants.$[c].current_hp": -damage}},
+		options.Update().SetArrayFilters(arrayFilters),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply damage: %w", err)
+	}
+
+	var encounter models.Encounter
+	err = collection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$max": bson.M{"combatants.$[c].current_hp": 0}},
+		options.FindOneAndUpdate().SetArrayFilters(arrayFilters).SetReturnDocument(options.After),
+	).Decode(&encounter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply damage: %w", err)
+	}
+	for _, combatant := range encounter.Combatants {
+		if combatant.ID == target.CombatantID {
+			target.CurrentHP = combatant.CurrentHP
+		}
+	}
+	return target.CurrentHP, nil
+}
+
+// abilityScore returns an ability score by its lowercase name
+func abilityScore(abilities models.AbilityScores, ability string) int {
+	switch ability {
+	case "strength":
+		return abilities.Strength
+	case "dexterity":
+		return abilities.Dexterity
+	case "constitution":
+		return abilities.Constitution
+	case "intelligence":
+		return abilities.Intelligence
+	case "wisdom":
+		return abilities.Wisdom
+	case "charisma":
+		return abilities.Charisma
+	}
+	return 10
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"regexp"
 	"strconv"
@@ -202,6 +203,48 @@ func (ds *DiceService) RollInitiative(dexModifier int) *models.DiceRoll {
 	}
 }
 
+// RollHitPoints rolls a creature's hit dice (e.g., "2d6", "5d10+10"), with a minimum of 1 HP
+func (ds *DiceService) RollHitPoints(hitDice string) (*models.DiceRoll, error) {
+	roll, err := ds.ParseAndRoll(hitDice, "Hit Points")
+	if err != nil {
+		return nil, err
+	}
+	
+	if roll.Total < 1 {
+		roll.Total = 1
+	}
+	
+	return roll, nil
+}
+
+// RollInitiativeWithAdvantage rolls initiative, taking the higher of two d20s when advantaged
+func (ds *DiceService) RollInitiativeWithAdvantage(dexModifier int, advantage bool) *models.DiceRoll {
+	if !advantage {
+		return ds.RollInitiative(dexModifier)
+	}
+	
+	roll1 := ds.rng.Intn(20) + 1
+	roll2 := ds.rng.Intn(20) + 1
+	
+	higher := roll1
+	if roll2 > roll1 {
+		higher = roll2
+	}
+	
+	return &models.DiceRoll{
+		Dice:     "2d20 (advantage)",
+		Result:   []int{roll1, roll2},
+		Total:    higher + dexModifier,
+		Modifier: dexModifier,
+		Purpose:  "Initiative (advantage)",
+	}
+}
+
+// RollD20 rolls a single d20, e.g. for tie-breaking roll-offs
+func (ds *DiceService) RollD20() int {
+	return ds.rng.Intn(20) + 1
+}
+
 // RollAttack rolls an attack with modifiers
 func (ds *DiceService) RollAttack(attackBonus int, purpose string) *models.DiceRoll {
 	roll := ds.rng.Intn(20) + 1
@@ -216,6 +259,30 @@ func (ds *DiceService) RollAttack(attackBonus int, purpose string) *models.DiceR
 	}
 }
 
+// RollAttackWithAdvantage rolls an attack, taking the higher (advantage) or lower
+// (disadvantage) of two d20s when given
+func (ds *DiceService) RollAttackWithAdvantage(attackBonus int, advantage string, purpose string) *models.DiceRoll {
+	if advantage != "advantage" && advantage != "disadvantage" {
+		return ds.RollAttack(attackBonus, purpose)
+	}
+	
+	roll1 := ds.rng.Intn(20) + 1
+	roll2 := ds.rng.Intn(20) + 1
+	
+	kept := roll1
+	if (advantage == "advantage" && roll2 > roll1) || (advantage == "disadvantage" && roll2 < roll1) {
+		kept = roll2
+	}
+	
+	return &models.DiceRoll{
+		Dice:     fmt.Sprintf("2d20 (%s)", advantage),
+		Result:   []int{roll1, roll2},
+		Total:    kept + attackBonus,
+		Modifier: attackBonus,
+		Purpose:  fmt.Sprintf("Attack: %s (%s)", purpose, advantage),
+	}
+}
+
 // RollSavingThrow rolls a saving throw
 func (ds *DiceService) RollSavingThrow(saveModifier int, saveName string) *models.DiceRoll {
 	roll := ds.rng.Intn(20) + 1
@@ -244,6 +311,11 @@ func (ds *DiceService) RollSkillCheck(skillModifier int, skillName string) *mode
 	}
 }
 
+// abilityModifier converts an ability score to its modifier
+func abilityModifier(score int) int {
+	return int(math.Floor(float64(score-10) / 2))
+}
+
 // GetCriticalHitMessage returns a fun message for natural 20s
 func (ds *DiceService) GetCriticalHitMessage() string {
 	messages := []string{
@@ -0,0 +1,521 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/data"
+	"dnd-simulator/internal/database"
+	"dnd-simulator/internal/models"
+)
+
+// EncounterService manages combat encounters and their monster combatants
+type EncounterService struct {
+	db           *database.DB
+	diceService  *DiceService
+	eventService *EventService
+}
+
+// NewEncounterService creates a new encounter service instance
+func NewEncounterService(db *database.DB, diceService *DiceService, eventService *EventService) *EncounterService {
+	return &EncounterService{
+		db:           db,
+		diceService:  diceService,
+		eventService: eventService,
+	}
+}
+
+// CreateEncounter creates a planned encounter in a session, rolling HP for any initial monsters
+func (s *EncounterService) CreateEncounter(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.CreateEncounterRequest) (*models.Encounter, error) {
+	if err := s.verifySessionDM(ctx, sessionID, dmUserID); err != nil {
+		return nil, err
+	}
+
+	combatants, err := s.buildCombatants(nil, req.Monsters)
+	if err != nil {
+		return nil, err
+	}
+
+	encounter := &models.Encounter{
+		ID:         primitive.NewObjectID(),
+		SessionID:  sessionID,
+		Name:       req.Name,
+		Status:     models.EncounterStatusPlanned,
+		Combatants: combatants,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	_, err = s.db.GetCollection("encounters").InsertOne(ctx, encounter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encounter: %w", err)
+	}
+
+	return encounter, nil
+}
+
+// GetEncounter retrieves an encounter belonging to a session
+func (s *EncounterService) GetEncounter(ctx context.Context, sessionID, encounterID primitive.ObjectID) (*models.Encounter, error) {
+	var encounter models.Encounter
+	err := s.db.GetCollection("encounters").FindOne(ctx, bson.M{
+		"_id":        encounterID,
+		"session_id": sessionID,
+	}).Decode(&encounter)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("encounter not found")
+		}
+		return nil, fmt.Errorf("failed to get encounter: %w", err)
+	}
+	return &encounter, nil
+}
+
+// GetSessionEncounters retrieves all encounters for a session, newest first
+func (s *EncounterService) GetSessionEncounters(ctx context.Context, sessionID primitive.ObjectID) ([]models.Encounter, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := s.db.GetCollection("encounters").Find(ctx, bson.M{"session_id": sessionID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find encounters: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	encounters := []models.Encounter{}
+	if err := cursor.All(ctx, &encounters); err != nil {
+		return nil, fmt.Errorf("failed to decode encounters: %w", err)
+	}
+
+	return encounters, nil
+}
+
+// AddMonsters adds monster combatants to an encounter that hasn't finished yet
+func (s *EncounterService) AddMonsters(ctx context.Context, sessionID, encounterID, dmUserID primitive.ObjectID, groups []models.MonsterGroup) (*models.Encounter, error) {
+	if err := s.verifySessionDM(ctx, sessionID, dmUserID); err != nil {
+		return nil, err
+	}
+
+	encounter, err := s.GetEncounter(ctx, sessionID, encounterID)
+	if err != nil {
+		return nil, err
+	}
+
+	if encounter.Status == models.EncounterStatusCompleted {
+		return nil, errors.New("encounter has already ended")
+	}
+
+	combatants, err := s.buildCombatants(encounter.Combatants, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.GetCollection("encounters").UpdateOne(ctx,
+		bson.M{"_id": encounterID},
+		bson.M{
+			"$push": bson.M{"combatants": bson.M{"$each": combatants}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add monsters: %w", err)
+	}
+
+	encounter.Combatants = append(encounter.Combatants, combatants...)
+	return encounter, nil
+}
+
+// PreviewDifficulty rates a prospective set of monsters against the session's party
+func (s *EncounterService) PreviewDifficulty(ctx context.Context, sessionID primitive.ObjectID, groups []models.MonsterGroup) (*models.EncounterDifficulty, error) {
+	var monsterXP []int
+	for _, group := range groups {
+		monster, exists := data.Monsters[group.Slug]
+		if !exists {
+			return nil, fmt.Errorf("unknown monster: %s", group.Slug)
+		}
+
+		count := group.Count
+		if count <= 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			monsterXP = append(monsterXP, monster.XP)
+		}
+	}
+
+	partyLevels, err := s.getPartyLevels(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return CalculateDifficulty(partyLevels, monsterXP), nil
+}
+
+// GetEncounterDifficulty rates an existing encounter's living monsters against the session's party
+func (s *EncounterService) GetEncounterDifficulty(ctx context.Context, sessionID, encounterID primitive.ObjectID) (*models.EncounterDifficulty, error) {
+	encounter, err := s.GetEncounter(ctx, sessionID, encounterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var monsterXP []int
+	for _, combatant := range encounter.Combatants {
+		if combatant.CurrentHP > 0 {
+			monsterXP = append(monsterXP, combatant.XP)
+		}
+	}
+
+	partyLevels, err := s.getPartyLevels(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return CalculateDifficulty(partyLevels, monsterXP), nil
+}
+
+// EndEncounter completes an encounter. On victory the monsters' XP is split evenly among
+// the surviving player characters and the award is recorded as a game event. If the award
+// fails part way, ending the encounter again as a victory finishes it.
+func (s *EncounterService) EndEncounter(ctx context.Context, sessionID, encounterID, dmUserID primitive.ObjectID, outcome models.EncounterOutcome) (*models.Encounter, *models.XPAward, error) {
+	if err := s.verifySessionDM(ctx, sessionID, dmUserID); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	var encounter models.Encounter
+	err := s.db.GetCollection("encounters").FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":        encounterID,
+			"session_id": sessionID,
+			"status":     bson.M{"$ne": models.EncounterStatusCompleted},
+		},
+		bson.M{
+			"$set": bson.M{
+				"status":     models.EncounterStatusCompleted,
+				"outcome":    outcome,
+				"ended_at":   &now,
+				"updated_at": now,
+			},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&encounter)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			return nil, nil, fmt.Errorf("failed to end encounter: %w", err)
+		}
+
+		// A victory whose award didn't finish can be ended again to retry it
+		ended, getErr := s.GetEncounter(ctx, sessionID, encounterID)
+		if getErr != nil || outcome != models.EncounterOutcomeVictory ||
+			ended.Outcome != models.EncounterOutcomeVictory || ended.XPAwarded {
+			return nil, nil, errors.New("encounter not found or already ended")
+		}
+		encounter = *ended
+	}
+
+	if outcome != models.EncounterOutcomeVictory {
+		return &encounter, nil, nil
+	}
+
+	award, err := s.awardExperience(ctx, &encounter)
+	if err != nil {
+		return &encounter, nil, err
+	}
+
+	return &encounter, award, nil
+}
+
+// awardExperience credits each surviving party member with an equal share of the encounter
+// XP. Each step can be repeated safely: the split is fixed on the encounter first, each
+// character is credited once, and the event is keyed by the encounter.
+func (s *EncounterService) awardExperience(ctx context.Context, encounter *models.Encounter) (*models.XPAward, error) {
+	award := encounter.XPAward
+	if award == nil {
+		var err error
+		award, err = s.splitExperience(ctx, encounter)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := s.db.GetCollection("encounters").UpdateOne(ctx,
+			bson.M{"_id": encounter.ID, "xp_award": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"xp_award": award}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record XP award: %w", err)
+		}
+		if result.ModifiedCount == 0 {
+			// A retry running alongside fixed the split first; credit that one
+			current, err := s.GetEncounter(ctx, encounter.SessionID, encounter.ID)
+			if err != nil {
+				return nil, err
+			}
+			if current.XPAward == nil {
+				return nil, errors.New("failed to record XP award")
+			}
+			award = current.XPAward
+		}
+	}
+	encounter.XPAward = award
+
+	if len(award.CharacterIDs) > 0 && award.XPPerCharacter > 0 {
+		_, err := s.db.GetCollection("characters").UpdateMany(ctx,
+			bson.M{
+				"_id":           bson.M{"$in": award.CharacterIDs},
+				"xp_encounters": bson.M{"$ne": encounter.ID},
+			},
+			bson.M{
+				"$inc":      bson.M{"experience_points": award.XPPerCharacter},
+				"$addToSet": bson.M{"xp_encounters": encounter.ID},
+				"$set":      bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to award experience: %w", err)
+		}
+
+		event := &models.GameEvent{
+			ID:        encounter.ID, // One award event per encounter, however often it's retried
+			SessionID: encounter.SessionID,
+			Type:      "xp_award",
+			Description: fmt.Sprintf("%s: %d XP awarded, %d XP each to %s",
+				encounter.Name, award.TotalXP, award.XPPerCharacter, strings.Join(award.CharacterNames, ", ")),
+			Data: award,
+		}
+		if err := s.eventService.StoreEvent(ctx, event); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+	}
+
+	_, err := s.db.GetCollection("encounters").UpdateOne(ctx,
+		bson.M{"_id": encounter.ID},
+		bson.M{"$set": bson.M{"xp_awarded": true}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record XP award: %w", err)
+	}
+	encounter.XPAwarded = true
+
+	return award, nil
+}
+
+// splitExperience works out the encounter's XP share for each surviving party member
+func (s *EncounterService) splitExperience(ctx context.Context, encounter *models.Encounter) (*models.XPAward, error) {
+	totalXP := 0
+	for _, combatant := range encounter.Combatants {
+		totalXP += combatant.XP
+	}
+
+	characters, err := s.getPartyCharacters(ctx, encounter.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	award := &models.XPAward{
+		EncounterID:    encounter.ID,
+		TotalXP:        totalXP,
+		CharacterIDs:   []primitive.ObjectID{},
+		CharacterNames: []string{},
+	}
+	for _, character := range characters {
+		if character.CurrentHP > 0 {
+			award.CharacterIDs = append(award.CharacterIDs, character.ID)
+			award.CharacterNames = append(award.CharacterNames, character.Name)
+		}
+	}
+
+	if len(award.CharacterIDs) > 0 {
+		award.XPPerCharacter = totalXP / len(award.CharacterIDs)
+	}
+	return award, nil
+}
+
+// CalculateDifficulty applies the DMG encounter-building rules: monster XP is scaled by
+// a group-size multiplier (adjusted for very small or large parties) and compared with
+// the party's summed XP thresholds.
+func CalculateDifficulty(partyLevels []int, monsterXP []int) *models.EncounterDifficulty {
+	result := &models.EncounterDifficulty{
+		PartyLevels:  partyLevels,
+		MonsterCount: len(monsterXP),
+	}
+
+	for _, level := range partyLevels {
+		if level < 1 {
+			level = 1
+		} else if level > 20 {
+			level = 20
+		}
+		threshold := data.XPThresholds[level]
+		result.PartyThresholds.Easy += threshold.Easy
+		result.PartyThresholds.Medium += threshold.Medium
+		result.PartyThresholds.Hard += threshold.Hard
+		result.PartyThresholds.Deadly += threshold.Deadly
+	}
+
+	for _, xp := range monsterXP {
+		result.TotalXP += xp
+	}
+
+	result.Multiplier = encounterMultiplier(len(monsterXP), len(partyLevels))
+	result.AdjustedXP = int(float64(result.TotalXP) * result.Multiplier)
+
+	switch {
+	case len(monsterXP) == 0 || result.AdjustedXP < result.PartyThresholds.Easy:
+		result.Difficulty = "trivial"
+	case result.AdjustedXP < result.PartyThresholds.Medium:
+		result.Difficulty = "easy"
+	case result.AdjustedXP < result.PartyThresholds.Hard:
+		result.Difficulty = "medium"
+	case result.AdjustedXP < result.PartyThresholds.Deadly:
+		result.Difficulty = "hard"
+	default:
+		result.Difficulty = "deadly"
+	}
+
+	if len(partyLevels) > 0 {
+		result.XPPerCharacter = result.TotalXP / len(partyLevels)
+	}
+
+	return result
+}
+
+// encounterMultiplier looks up the group-size multiplier, stepping up for parties of
+// fewer than three characters and down for parties of six or more
+func encounterMultiplier(monsterCount, partySize int) float64 {
+	if monsterCount == 0 {
+		return 1
+	}
+
+	index := 1
+	for i := 1; i < len(data.EncounterMultipliers)-1; i++ {
+		if monsterCount >= data.EncounterMultipliers[i].MinMonsters {
+			index = i
+		}
+	}
+
+	if partySize < 3 {
+		index++
+	} else if partySize >= 6 {
+		index--
+	}
+
+	return data.EncounterMultipliers[index].Multiplier
+}
+
+// getPartyCharacters loads the characters of the players who joined the session
+func (s *EncounterService) getPartyCharacters(ctx context.Context, sessionID primitive.ObjectID) ([]models.Character, error) {
+	var session models.GameSession
+	err := s.db.GetCollection("sessions").FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	characterIDs := make([]primitive.ObjectID, 0, len(session.Players))
+	for _, player := range session.Players {
+		characterIDs = append(characterIDs, player.CharacterID)
+	}
+	if len(characterIDs) == 0 {
+		return []models.Character{}, nil
+	}
+
+	cursor, err := s.db.GetCollection("characters").Find(ctx, bson.M{"_id": bson.M{"$in": characterIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find characters: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var characters []models.Character
+	if err := cursor.All(ctx, &characters); err != nil {
+		return nil, fmt.Errorf("failed to decode characters: %w", err)
+	}
+
+	return characters, nil
+}
+
+// getPartyLevels returns the level of each party member in the session
+func (s *EncounterService) getPartyLevels(ctx context.Context, sessionID primitive.ObjectID) ([]int, error) {
+	characters, err := s.getPartyCharacters(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := make([]int, 0, len(characters))
+	for _, character := range characters {
+		levels = append(levels, character.Level)
+	}
+	return levels, nil
+}
+
+// buildCombatants instantiates monster groups, rolling each monster's HP from its hit dice.
+// Existing combatants are used to continue numbering (e.g., "Goblin 3").
+func (s *EncounterService) buildCombatants(existing []models.Combatant, groups []models.MonsterGroup) ([]models.Combatant, error) {
+	counts := make(map[string]int)
+	for _, combatant := range existing {
+		counts[combatant.MonsterSlug]++
+	}
+
+	combatants := []models.Combatant{}
+	for _, group := range groups {
+		monster, exists := data.Monsters[group.Slug]
+		if !exists {
+			return nil, fmt.Errorf("unknown monster: %s", group.Slug)
+		}
+
+		count := group.Count
+		if count <= 0 {
+			count = 1
+		}
+
+		baseName := monster.Name
+		if group.Name != "" {
+			baseName = group.Name
+		}
+
+		for i := 0; i < count; i++ {
+			hpRoll, err := s.diceService.RollHitPoints(monster.HitDice)
+			if err != nil {
+				return nil, fmt.Errorf("failed to roll hit points for %s: %w", monster.Name, err)
+			}
+
+			counts[monster.Slug]++
+			combatants = append(combatants, models.Combatant{
+				ID:           primitive.NewObjectID(),
+				MonsterSlug:  monster.Slug,
+				Name:         fmt.Sprintf("%s %d", baseName, counts[monster.Slug]),
+				ArmorClass:   monster.ArmorClass,
+				MaxHP:        hpRoll.Total,
+				CurrentHP:    hpRoll.Total,
+				HitPointRoll: hpRoll,
+				Abilities:    monster.Abilities,
+				Speed:        monster.Speed["walk"],
+				XP:           monster.XP,
+			})
+		}
+	}
+
+	return combatants, nil
+}
+
+// verifySessionDM checks that the user is the DM of the session
+func (s *EncounterService) verifySessionDM(ctx context.Context, sessionID, dmUserID primitive.ObjectID) error {
+	count, err := s.db.GetCollection("sessions").CountDocuments(ctx, bson.M{
+		"_id":        sessionID,
+		"dm_user_id": dmUserID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify session: %w", err)
+	}
+	if count == 0 {
+		return errors.New("session not found or you are not the DM")
+	}
+	return nil
+}
@@ -13,6 +13,9 @@ import (
 	"dnd-simulator/internal/models"
 )
 
+// EventTypeDiceRoll is the event type of public dice rolls, kept for spectator overlays
+const EventTypeDiceRoll = "dice_roll"
+
 // EventService handles game event tracking and retrieval
 type EventService struct {
 	db *database.DB
@@ -89,6 +92,48 @@ func (s *EventService) StoreAIResponse(ctx context.Context, sessionID primitive.
 	return event, nil
 }
 
+// StoreRoll stores a public dice roll as a game event
+func (s *EventService) StoreRoll(ctx context.Context, sessionID primitive.ObjectID, roll *models.OverlayRoll) error {
+	return s.StoreEvent(ctx, &models.GameEvent{
+		SessionID:   sessionID,
+		Type:        EventTypeDiceRoll,
+		Description: roll.Username + " rolled " + roll.Dice,
+		Timestamp:   roll.Timestamp,
+		ActorID:     roll.CharacterID,
+		Data:        roll,
+	})
+}
+
+// RecentRolls returns a session's latest public dice rolls made before a time, newest first
+func (s *EventService) RecentRolls(ctx context.Context, sessionID primitive.ObjectID, before time.Time, limit int) ([]models.OverlayRoll, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := s.db.GetCollection("game_events").Find(ctx, bson.M{
+		"session_id": sessionID,
+		"type":       EventTypeDiceRoll,
+		"timestamp":  bson.M{"$lt": before},
+	}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rolls: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var events []struct {
+		Roll models.OverlayRoll `bson:"data"`
+	}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, fmt.Errorf("failed to decode rolls: %w", err)
+	}
+
+	rolls := make([]models.OverlayRoll, 0, len(events))
+	for _, event := range events {
+		rolls = append(rolls, event.Roll)
+	}
+	return rolls, nil
+}
+
 // GetRecentEvents retrieves recent events for a session
 func (s *EventService) GetRecentEvents(ctx context.Context, sessionID primitive.ObjectID, limit int) ([]models.GameEvent, error) {
 	if limit <= 0 {
@@ -99,7 +144,11 @@ func (s *EventService) GetRecentEvents(ctx context.Context, sessionID primitive.
 		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
 		SetLimit(int64(limit))
 
-	cursor, err := s.db.GetCollection("game_events").Find(ctx, bson.M{"session_id": sessionID}, opts)
+	// Dice rolls are only kept for spectators and would crowd out the story
+	cursor, err := s.db.GetCollection("game_events").Find(ctx, bson.M{
+		"session_id": sessionID,
+		"type":       bson.M{"$ne": EventTypeDiceRoll},
+	}, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find events: %w", err)
 	}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+// HubStore gives the WebSocket hub access to session and chat persistence
+type HubStore struct {
+	sessionService *SessionService
+	chatService    *ChatService
+	eventService   *EventService
+}
+
+// NewHubStore creates a new hub store instance
+func NewHubStore(sessionService *SessionService, chatService *ChatService, eventService *EventService) *HubStore {
+	return &HubStore{
+		sessionService: sessionService,
+		chatService:    chatService,
+		eventService:   eventService,
+	}
+}
+
+// GetSession returns the session's current game state
+func (s *HubStore) GetSession(ctx context.Context, sessionID primitive.ObjectID) (*models.GameSession, error) {
+	return s.sessionService.GetSession(ctx, sessionID)
+}
+
+// UpdatePlayerConnection records whether a player has a live connection to the session
+func (s *HubStore) UpdatePlayerConnection(ctx context.Context, sessionID, userID primitive.ObjectID, isConnected bool) error {
+	return s.sessionService.UpdatePlayerConnection(ctx, sessionID, userID, isConnected)
+}
+
+// SaveChatMessage stores a chat message sent over the WebSocket and returns the users it
+// should be delivered to, or nil for the whole session
+func (s *HubStore) SaveChatMessage(ctx context.Context, msg *models.SessionChatMessage) ([]primitive.ObjectID, error) {
+	return s.chatService.SendMessage(ctx, msg)
+}
+
+// RecordRoll stores a public dice roll for spectator overlays
+func (s *HubStore) RecordRoll(ctx context.Context, sessionID primitive.ObjectID, roll *models.OverlayRoll) error {
+	return s.eventService.StoreRoll(ctx, sessionID, roll)
+}
+
+// RecentChatMessages returns the latest chat messages a user can see, oldest first
+func (s *HubStore) RecentChatMessages(ctx context.Context, sessionID, userID primitive.ObjectID, limit int) ([]models.SessionChatMessage, error) {
+	page, err := s.chatService.GetMessages(ctx, sessionID, userID, primitive.NilObjectID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := page.Messages
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// StartReadyCheck starts a ready check for every player in the session
+func (s *HubStore) StartReadyCheck(ctx context.Context, sessionID, dmUserID primitive.ObjectID, prompt string, timeout time.Duration) (*models.ReadyCheck, error) {
+	return s.sessionService.StartReadyCheck(ctx, sessionID, dmUserID, prompt, timeout)
+}
+
+// RespondReadyCheck records a player's answer to the open ready check
+func (s *HubStore) RespondReadyCheck(ctx context.Context, sessionID, userID primitive.ObjectID, ready bool) (*models.ReadyCheck, error) {
+	return s.sessionService.RespondReadyCheck(ctx, sessionID, userID, ready)
+}
+
+// FinishReadyCheck closes a ready check whose deadline has passed
+func (s *HubStore) FinishReadyCheck(ctx context.Context, sessionID primitive.ObjectID, startedAt time.Time) (*models.ReadyCheck, error) {
+	return s.sessionService.FinishReadyCheck(ctx, sessionID, startedAt)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// validateJSON checks a decoded JSON value (decoded with UseNumber) against the subset of
+// JSON Schema that LLM structured output uses: type, properties, required, items and enum.
+// It returns a problem for each mismatch, located by a JSONPath-like path.
+func validateJSON(value interface{}, schema map[string]interface{}, path string) []string {
+	var problems []string
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object", path)}
+		}
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: is required", path, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok || object[name] == nil {
+				continue // Unknown fields and nulls are ignored
+			}
+			problems = append(problems, validateJSON(object[name], property, path+"."+name)...)
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array", path)}
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				problems = append(problems, validateJSON(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a string", path)}
+		}
+		if enum := stringList(schema["enum"]); len(enum) > 0 && !containsString(enum, text) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %v", path, text, enum))
+		}
+
+	case "integer":
+		number, ok := value.(json.Number)
+		if _, err := number.Int64(); !ok || err != nil {
+			return []string{fmt.Sprintf("%s: expected an integer", path)}
+		}
+
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return []string{fmt.Sprintf("%s: expected a number", path)}
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected a boolean", path)}
+		}
+	}
+	return problems
+}
+
+// stringList reads a schema keyword holding strings, e.g. required or enum
+func stringList(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// containsString reports whether a list holds a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
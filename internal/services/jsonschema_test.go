@@ -0,0 +1,62 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeForSchema(t *testing.T, text string) interface{} {
+	t.Helper()
+
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("decode %s: %v", text, err)
+	}
+	return value
+}
+
+func TestValidateJSON(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"kind":  map[string]interface{}{"type": "string", "enum": []string{"goblin", "wolf"}},
+			"hp":    map[string]interface{}{"type": "integer"},
+			"speed": map[string]interface{}{"type": "number"},
+			"alive": map[string]interface{}{"type": "boolean"},
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+		"required": []string{"name", "hp"},
+	}
+
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{name: "valid", json: `{"name": "Snik", "kind": "goblin", "hp": 7, "speed": 30.5, "alive": true, "tags": ["sneaky"]}`},
+		{name: "unknown fields and nulls are ignored", json: `{"name": "Snik", "hp": 7, "kind": null, "extra": 1}`},
+		{name: "missing required", json: `{"kind": "wolf"}`, want: []string{"$.name: is required", "$.hp: is required"}},
+		{name: "not an object", json: `["Snik"]`, want: []string{"$: expected an object"}},
+		{name: "wrong types", json: `{"name": 3, "hp": "7", "alive": "yes"}`, want: []string{
+			"$.alive: expected a boolean", "$.hp: expected an integer", "$.name: expected a string",
+		}},
+		{name: "fractional integer", json: `{"name": "Snik", "hp": 7.5}`, want: []string{"$.hp: expected an integer"}},
+		{name: "not in enum", json: `{"name": "Snik", "hp": 7, "kind": "dragon"}`, want: []string{`$.kind: "dragon" is not one of [goblin wolf]`}},
+		{name: "array items", json: `{"name": "Snik", "hp": 7, "tags": ["ok", 2]}`, want: []string{"$.tags[1]: expected a string"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateJSON(decodeForSchema(t, tt.json), schema, "$")
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got problems %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"dnd-simulator/internal/config"
+	"dnd-simulator/internal/models"
+)
+
+// LLMProvider generates text with a large language model
+type LLMProvider interface {
+	// Name identifies the provider in campaign settings, e.g. "gemini"
+	Name() string
+
+	// DefaultModel is the model used when a request doesn't name one
+	DefaultModel() string
+
+	// Generate returns the model's complete reply
+	Generate(ctx context.Context, req *LLMRequest) (*LLMResponse, error)
+
+	// Stream calls onDelta with each piece of the reply as it arrives, then returns the
+	// complete reply
+	Stream(ctx context.Context, req *LLMRequest, onDelta func(delta string)) (*LLMResponse, error)
+}
+
+// LLMRequest is a prompt and how to sample the reply. Setting JSONSchema asks for
+// structured output: a JSON reply matching the schema.
+type LLMRequest struct {
+	Model       string // Empty for the provider's default
+	System      string
+	Prompt      string
+	Temperature float64
+	MaxTokens   int
+	Stop        []string
+	JSONSchema  map[string]interface{}
+}
+
+// LLMResponse is a model's reply and what it cost
+type LLMResponse struct {
+	Text         string
+	Model        string
+	FinishReason string
+	Usage        TokenUsage
+}
+
+// TokenUsage counts the tokens a request used
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// GenerateJSON asks a provider for structured output and decodes the reply into out
+func GenerateJSON(ctx context.Context, provider LLMProvider, req *LLMRequest, schema map[string]interface{}, out interface{}) (*LLMResponse, error) {
+	structured := *req
+	structured.JSONSchema = schema
+	resp, err := provider.Generate(ctx, &structured)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(resp.Text), out); err != nil {
+		return resp, fmt.Errorf("failed to decode structured reply: %w", err)
+	}
+	return resp, nil
+}
+
+// LLMRegistry holds the providers this server is configured for, and picks one for each
+// campaign
+type LLMRegistry struct {
+	providers       map[string]LLMProvider
+	defaultProvider string
+	defaultModel    string
+}
+
+// NewLLMRegistry registers every provider the configuration enables
+func NewLLMRegistry(cfg *config.Config) *LLMRegistry {
+	r := &LLMRegistry{
+		providers:       make(map[string]LLMProvider),
+		defaultProvider: cfg.AIProvider,
+		defaultModel:    cfg.AIModel,
+	}
+	if cfg.GeminiAPIKey != "" {
+		r.Register(NewGeminiProvider(cfg.GeminiAPIKey, cfg.GeminiModel))
+	}
+	if cfg.OpenAIBaseURL != "" {
+		r.Register(NewOpenAIProvider(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel))
+	}
+	if cfg.AIMockEnabled {
+		r.Register(NewMockProvider())
+	}
+	return r
+}
+
+// Register adds a provider, replacing any with the same name
+func (r *LLMRegistry) Register(provider LLMProvider) {
+	r.providers[provider.Name()] = provider
+}
+
+// Providers lists the registered providers' names with their default models
+func (r *LLMRegistry) Providers() []models.AIProviderInfo {
+	infos := make([]models.AIProviderInfo, 0, len(r.providers))
+	for name, provider := range r.providers {
+		infos = append(infos, models.AIProviderInfo{
+			Name:         name,
+			DefaultModel: provider.DefaultModel(),
+			Default:      name == r.defaultProvider,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Validate checks that campaign AI settings name a registered provider
+func (r *LLMRegistry) Validate(settings models.AISettings) error {
+	if settings.Provider == "" {
+		return nil
+	}
+	if _, ok := r.providers[settings.Provider]; !ok {
+		return fmt.Errorf("AI provider %q is not configured on this server", settings.Provider)
+	}
+	return nil
+}
+
+// ForCampaign returns the provider and model a campaign uses: its own settings, else the
+// server's defaults. An empty model means the provider's default.
+func (r *LLMRegistry) ForCampaign(campaign *models.Campaign) (LLMProvider, string, error) {
+	name, model := r.defaultProvider, r.defaultModel
+	if campaign != nil {
+		if campaign.Settings.AI.Provider != "" {
+			name, model = campaign.Settings.AI.Provider, "" // The server's default model is for its default provider
+		}
+		if campaign.Settings.AI.Model != "" {
+			model = campaign.Settings.AI.Model
+		}
+	}
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, "", fmt.Errorf("AI provider %q is not configured", name)
+	}
+	return provider, model, nil
+}
+
+// readSSE calls onData with the payload of each "data:" line of a server-sent event stream,
+// stopping at "[DONE]"
+func readSSE(body io.Reader, onData func(data []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if len(data) == 0 {
+			continue
+		}
+		if string(data) == "[DONE]" {
+			return nil
+		}
+		if err := onData(data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil
+}
+
+// errEmptyReply is returned when a model replies with nothing
+var errEmptyReply = errors.New("empty response from AI")
+
+// finishReply trims a streamed reply and checks it isn't empty
+func finishReply(text *strings.Builder, resp *LLMResponse) (*LLMResponse, error) {
+	resp.Text = strings.TrimSpace(text.String())
+	if resp.Text == "" {
+		return nil, errEmptyReply
+	}
+	return resp, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GeminiProvider generates with Google's Gemini API
+type GeminiProvider struct {
+	apiKey      string
+	apiEndpoint string
+	model       string
+	httpClient  *http.Client
+}
+
+// NewGeminiProvider creates a Gemini provider using the given default model
+func NewGeminiProvider(apiKey, model string) *GeminiProvider {
+	return &GeminiProvider{
+		apiKey:      apiKey,
+		apiEndpoint: "https://generativelanguage.googleapis.com/v1beta/models",
+		model:       model,
+		httpClient: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
+	}
+}
+
+func (p *GeminiProvider) Name() string         { return "gemini" }
+func (p *GeminiProvider) DefaultModel() string { return p.model }
+
+// Generate returns Gemini's complete reply
+func (p *GeminiProvider) Generate(ctx context.Context, req *LLMRequest) (*LLMResponse, error) {
+	return p.Stream(ctx, req, nil)
+}
+
+// Stream sends the request to streamGenerateContent and passes each chunk's text on as it
+// arrives
+func (p *GeminiProvider) Stream(ctx context.Context, req *LLMRequest, onDelta func(string)) (*LLMResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	generationConfig := map[string]interface{}{
+		"temperature":      req.Temperature,
+		"responseMimeType": "text/plain",
+		"topK":             40,
+		"topP":             0.95,
+	}
+	if req.MaxTokens > 0 {
+		generationConfig["maxOutputTokens"] = req.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		generationConfig["stopSequences"] = req.Stop
+	}
+	if req.JSONSchema != nil {
+		generationConfig["responseMimeType"] = "application/json"
+		generationConfig["responseSchema"] = req.JSONSchema
+	}
+	requestBody := map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{
+				"role":  "user",
+				"parts": []map[string]interface{}{{"text": req.Prompt}},
+			},
+		},
+		"generationConfig": generationConfig,
+	}
+	if req.System != "" {
+		requestBody["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{{"text": req.System}},
+		}
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/%s:streamGenerateContent?alt=sse", p.apiEndpoint, model)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.apiKey)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errorBody map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&errorBody)
+		return nil, fmt.Errorf("API returned status code %d: %v", resp.StatusCode, errorBody)
+	}
+
+	var text strings.Builder
+	result := &LLMResponse{Model: model}
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk GeminiStreamResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil // Skip chunks we can't read
+		}
+
+		if len(chunk.Candidates) > 0 {
+			candidate := chunk.Candidates[0]
+			for _, part := range candidate.Content.Parts {
+				text.WriteString(part.Text)
+				if onDelta != nil && part.Text != "" {
+					onDelta(part.Text)
+				}
+			}
+			if candidate.FinishReason != "" {
+				result.FinishReason = candidate.FinishReason
+			}
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			result.Usage = TokenUsage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+				TotalTokens:      chunk.UsageMetadata.TotalTokenCount,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishReply(&text, result)
+}
+
+// GeminiStreamResponse represents a single chunk in the streaming response
+type GeminiStreamResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+			Role string `json:"role"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason,omitempty"`
+		Index        int    `json:"index"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// mockDefaultReply is what the mock provider says when it has no script
+const mockDefaultReply = "The dungeon is quiet. Nothing happens... yet."
+
+// MockProvider replays scripted replies instead of calling a model, for tests and offline
+// development. Replies are given in order and the last one repeats.
+type MockProvider struct {
+	mu       sync.Mutex
+	replies  []string
+	next     int
+	requests []LLMRequest
+}
+
+// NewMockProvider creates a mock provider with the replies to give
+func NewMockProvider(replies ...string) *MockProvider {
+	return &MockProvider{replies: replies}
+}
+
+func (p *MockProvider) Name() string         { return "mock" }
+func (p *MockProvider) DefaultModel() string { return "mock" }
+
+// Script replaces the replies still to come
+func (p *MockProvider) Script(replies ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.replies = replies
+	p.next = 0
+}
+
+// Requests returns the requests the provider has received
+func (p *MockProvider) Requests() []LLMRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]LLMRequest(nil), p.requests...)
+}
+
+// Generate returns the next scripted reply
+func (p *MockProvider) Generate(ctx context.Context, req *LLMRequest) (*LLMResponse, error) {
+	return p.Stream(ctx, req, nil)
+}
+
+// Stream returns the next scripted reply, passing it on a word at a time
+func (p *MockProvider) Stream(ctx context.Context, req *LLMRequest, onDelta func(string)) (*LLMResponse, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, *req)
+	reply := mockDefaultReply
+	if len(p.replies) > 0 {
+		reply = p.replies[p.next]
+		if p.next < len(p.replies)-1 {
+			p.next++
+		}
+	}
+	p.mu.Unlock()
+
+	if onDelta != nil {
+		for _, word := range strings.SplitAfter(reply, " ") {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			onDelta(word)
+		}
+	}
+
+	model := req.Model
+	if model == "" {
+		model = p.DefaultModel()
+	}
+	prompt := len(strings.Fields(req.System + " " + req.Prompt))
+	completion := len(strings.Fields(reply))
+	return &LLMResponse{
+		Text:         reply,
+		Model:        model,
+		FinishReason: "stop",
+		Usage: TokenUsage{
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+			TotalTokens:      prompt + completion,
+		},
+	}, nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// openAIMaxStop is the most stop sequences the chat completions API accepts
+const openAIMaxStop = 4
+
+// OpenAIProvider generates with an OpenAI-compatible chat completions API. Besides OpenAI
+// itself this covers local servers such as Ollama (http://localhost:11434/v1) and
+// llama.cpp (http://localhost:8080/v1), which don't need an API key.
+type OpenAIProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAIProvider creates a provider for the API at baseURL, using the given default model
+func NewOpenAIProvider(baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Minute, // Local models can be slow
+		},
+	}
+}
+
+func (p *OpenAIProvider) Name() string         { return "openai" }
+func (p *OpenAIProvider) DefaultModel() string { return p.model }
+
+// Generate returns the complete reply
+func (p *OpenAIProvider) Generate(ctx context.Context, req *LLMRequest) (*LLMResponse, error) {
+	return p.Stream(ctx, req, nil)
+}
+
+// Stream requests a streamed chat completion and passes each content delta on as it
+// arrives
+func (p *OpenAIProvider) Stream(ctx context.Context, req *LLMRequest, onDelta func(string)) (*LLMResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	messages := []map[string]string{}
+	if req.System != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": req.System})
+	}
+	messages = append(messages, map[string]string{"role": "user", "content": req.Prompt})
+
+	requestBody := map[string]interface{}{
+		"model":          model,
+		"messages":       messages,
+		"temperature":    req.Temperature,
+		"stream":         true,
+		"stream_options": map[string]interface{}{"include_usage": true},
+	}
+	if req.MaxTokens > 0 {
+		requestBody["max_tokens"] = req.MaxTokens
+	}
+	if stop := req.Stop; len(stop) > 0 {
+		if len(stop) > openAIMaxStop {
+			stop = stop[:openAIMaxStop]
+		}
+		requestBody["stop"] = stop
+	}
+	if req.JSONSchema != nil {
+		requestBody["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "response",
+				"schema": req.JSONSchema,
+			},
+		}
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errorBody map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&errorBody)
+		return nil, fmt.Errorf("API returned status code %d: %v", resp.StatusCode, errorBody)
+	}
+
+	var text strings.Builder
+	result := &LLMResponse{Model: model}
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil // Skip chunks we can't read
+		}
+
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		for _, choice := range chunk.Choices {
+			text.WriteString(choice.Delta.Content)
+			if onDelta != nil && choice.Delta.Content != "" {
+				onDelta(choice.Delta.Content)
+			}
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishReply(&text, result)
+}
+
+// openAIStreamChunk is a single chunk of a streamed chat completion
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *TokenUsage `json:"usage"`
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dnd-simulator/internal/config"
+	"dnd-simulator/internal/models"
+)
+
+func TestLLMRegistryForCampaign(t *testing.T) {
+	registry := NewLLMRegistry(&config.Config{AIProvider: "mock", AIModel: "server-model"})
+	registry.Register(NewMockProvider())
+	registry.Register(namedProvider{MockProvider: NewMockProvider(), name: "other"})
+
+	campaign := func(provider, model string) *models.Campaign {
+		return &models.Campaign{Settings: models.CampaignSettings{AI: models.AISettings{Provider: provider, Model: model}}}
+	}
+
+	tests := []struct {
+		name         string
+		campaign     *models.Campaign
+		wantProvider string
+		wantModel    string
+		wantErr      bool
+	}{
+		{name: "no campaign", campaign: nil, wantProvider: "mock", wantModel: "server-model"},
+		{name: "no settings", campaign: campaign("", ""), wantProvider: "mock", wantModel: "server-model"},
+		{name: "model only", campaign: campaign("", "campaign-model"), wantProvider: "mock", wantModel: "campaign-model"},
+		// The server's default model belongs to its default provider, not this one
+		{name: "provider only", campaign: campaign("other", ""), wantProvider: "other", wantModel: ""},
+		{name: "provider and model", campaign: campaign("other", "campaign-model"), wantProvider: "other", wantModel: "campaign-model"},
+		{name: "unknown provider", campaign: campaign("missing", ""), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, model, err := registry.ForCampaign(tt.campaign)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got provider %v, want an error", provider)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ForCampaign: %v", err)
+			}
+			if provider.Name() != tt.wantProvider || model != tt.wantModel {
+				t.Errorf("got %s/%q, want %s/%q", provider.Name(), model, tt.wantProvider, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestLLMRegistryDefaultNotConfigured(t *testing.T) {
+	registry := NewLLMRegistry(&config.Config{AIProvider: "gemini"}) // No API key, so not registered
+	if _, _, err := registry.ForCampaign(nil); err == nil {
+		t.Error("got a provider for an unconfigured default")
+	}
+	if err := registry.Validate(models.AISettings{Provider: "gemini"}); err == nil {
+		t.Error("validated an unconfigured provider")
+	}
+	if err := registry.Validate(models.AISettings{}); err != nil {
+		t.Errorf("empty settings fall back to the default: %v", err)
+	}
+}
+
+// namedProvider is a mock registered under another name
+type namedProvider struct {
+	*MockProvider
+	name string
+}
+
+func (p namedProvider) Name() string { return p.name }
+
+func TestMockProviderScript(t *testing.T) {
+	mock := NewMockProvider("first reply", "second reply")
+	ctx := context.Background()
+
+	var replies []string
+	for i := 0; i < 3; i++ {
+		resp, err := mock.Generate(ctx, &LLMRequest{Prompt: "go on"})
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		replies = append(replies, resp.Text)
+	}
+	if want := []string{"first reply", "second reply", "second reply"}; strings.Join(replies, "|") != strings.Join(want, "|") {
+		t.Errorf("got replies %q, want %q with the last repeating", replies, want)
+	}
+	if got := len(mock.Requests()); got != 3 {
+		t.Errorf("recorded %d requests, want 3", got)
+	}
+
+	mock.Script("rescripted")
+	resp, err := mock.Generate(ctx, &LLMRequest{Model: "chosen"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if resp.Text != "rescripted" || resp.Model != "chosen" {
+		t.Errorf("got %q from %q, want the new script from the requested model", resp.Text, resp.Model)
+	}
+}
+
+func TestMockProviderStream(t *testing.T) {
+	mock := NewMockProvider("the goblin flees")
+
+	var deltas []string
+	resp, err := mock.Stream(context.Background(), &LLMRequest{}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if strings.Join(deltas, "") != resp.Text || len(deltas) != 3 {
+		t.Errorf("got deltas %q for %q, want it a word at a time", deltas, resp.Text)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mock.Stream(ctx, &LLMRequest{}, func(string) {}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v streaming with a cancelled context, want context.Canceled", err)
+	}
+}
+
+func TestGenerateJSON(t *testing.T) {
+	mock := NewMockProvider(`{"name": "Goblin", "hp": 7}`, `not json`)
+	schema := map[string]interface{}{"type": "object"}
+
+	var out struct {
+		Name string `json:"name"`
+		HP   int    `json:"hp"`
+	}
+	if _, err := GenerateJSON(context.Background(), mock, &LLMRequest{}, schema, &out); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if out.Name != "Goblin" || out.HP != 7 {
+		t.Errorf("decoded %+v", out)
+	}
+	if got := mock.Requests()[0].JSONSchema; got == nil {
+		t.Error("schema wasn't sent with the request")
+	}
+
+	if _, err := GenerateJSON(context.Background(), mock, &LLMRequest{}, schema, &out); err == nil {
+		t.Error("decoded a reply that isn't JSON")
+	}
+}
+
+// Gemini gets maxOutputTokens only when a limit is set, since 0 would ask for nothing
+func TestGeminiMaxOutputTokens(t *testing.T) {
+	var sent struct {
+		GenerationConfig map[string]interface{} `json:"generationConfig"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.GenerationConfig = nil
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hi\"}]},\"finishReason\":\"STOP\"}]}\n\n"))
+	}))
+	defer server.Close()
+
+	gemini := NewGeminiProvider("key", "gemini-test")
+	gemini.apiEndpoint = server.URL
+
+	if _, err := gemini.Generate(context.Background(), &LLMRequest{Prompt: "Hello"}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if _, ok := sent.GenerationConfig["maxOutputTokens"]; ok {
+		t.Errorf("sent maxOutputTokens without a limit: %v", sent.GenerationConfig)
+	}
+
+	if _, err := gemini.Generate(context.Background(), &LLMRequest{Prompt: "Hello", MaxTokens: 500}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if got := sent.GenerationConfig["maxOutputTokens"]; got != float64(500) {
+		t.Errorf("got maxOutputTokens %v, want 500", got)
+	}
+}
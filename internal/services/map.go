@@ -0,0 +1,580 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"dnd-simulator/internal/database"
+	"dnd-simulator/internal/models"
+)
+
+// DefaultCellFeet is the standard 5e grid square
+const DefaultCellFeet = 5
+
+// MapService manages a session's battle map and the tokens on it
+type MapService struct {
+	db             *database.DB
+	sessionService *SessionService
+	combatService  *CombatService
+}
+
+// NewMapService creates a new map service instance
+func NewMapService(db *database.DB, sessionService *SessionService, combatService *CombatService) *MapService {
+	return &MapService{
+		db:             db,
+		sessionService: sessionService,
+		combatService:  combatService,
+	}
+}
+
+// GetMap retrieves a session's battle map
+func (s *MapService) GetMap(ctx context.Context, sessionID primitive.ObjectID) (*models.BattleMap, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Map == nil {
+		return nil, errors.New("session has no battle map")
+	}
+	return session.Map, nil
+}
+
+// SetMap creates or resizes the battle map and replaces its terrain, keeping existing tokens (DM only)
+func (s *MapService) SetMap(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.SetMapRequest) (*models.BattleMap, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can edit the battle map")
+	}
+
+	battleMap := &models.BattleMap{
+		Width:     req.Width,
+		Height:    req.Height,
+		CellFeet:  req.CellFeet,
+		Terrain:   []models.TerrainCell{},
+		Tokens:    []models.MapToken{},
+		Lighting:  req.Lighting,
+		UpdatedAt: time.Now(),
+	}
+	if battleMap.CellFeet == 0 {
+		battleMap.CellFeet = DefaultCellFeet
+	}
+
+	// Later entries for the same cell win
+	seen := make(map[models.GridPoint]int)
+	for _, cell := range req.Terrain {
+		point := models.GridPoint{X: cell.X, Y: cell.Y}
+		if !inBounds(battleMap, point) {
+			return nil, fmt.Errorf("terrain cell (%d,%d) is off the map", cell.X, cell.Y)
+		}
+		if i, ok := seen[point]; ok {
+			battleMap.Terrain[i] = cell
+			continue
+		}
+		seen[point] = len(battleMap.Terrain)
+		battleMap.Terrain = append(battleMap.Terrain, cell)
+	}
+
+	if session.Map != nil {
+		terrain := terrainIndex(battleMap)
+		for _, token := range session.Map.Tokens {
+			if !inBounds(battleMap, token.Position()) {
+				return nil, fmt.Errorf("token %s would be off the map", token.Name)
+			}
+			if terrain[token.Position()] == models.TerrainWall {
+				return nil, fmt.Errorf("token %s would be inside a wall", token.Name)
+			}
+		}
+		battleMap.Tokens = session.Map.Tokens
+
+		// Lights and revealed or hidden cells that no longer fit are dropped
+		for _, light := range session.Map.Lights {
+			if inBounds(battleMap, models.GridPoint{X: light.X, Y: light.Y}) {
+				battleMap.Lights = append(battleMap.Lights, light)
+			}
+		}
+		battleMap.Revealed = cellsInBounds(battleMap, session.Map.Revealed)
+		battleMap.Hidden = cellsInBounds(battleMap, session.Map.Hidden)
+	}
+
+	_, err = s.sessionService.updateSession(ctx,
+		bson.M{"_id": sessionID},
+		bson.M{
+			"$set": bson.M{
+				"map":        battleMap,
+				"updated_at": time.Now(),
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save battle map: %w", err)
+	}
+
+	return battleMap, nil
+}
+
+// PlaceToken puts a token on the map, bound to a session character or encounter combatant (DM only)
+func (s *MapService) PlaceToken(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.PlaceTokenRequest) (*models.MapToken, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can place tokens")
+	}
+
+	if session.Map == nil {
+		return nil, errors.New("session has no battle map")
+	}
+
+	token := models.MapToken{
+		ID:          primitive.NewObjectID(),
+		Name:        req.Name,
+		CharacterID: req.CharacterID,
+		CombatantID: req.CombatantID,
+		X:           req.X,
+		Y:           req.Y,
+	}
+
+	switch {
+	case !req.CharacterID.IsZero():
+		found := false
+		for _, player := range session.Players {
+			if player.CharacterID == req.CharacterID {
+				found = true
+				if token.Name == "" {
+					token.Name = player.CharName
+				}
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("character is not in this session")
+		}
+	case !req.CombatantID.IsZero():
+		var encounter models.Encounter
+		err := s.db.GetCollection("encounters").FindOne(ctx, bson.M{
+			"session_id":     sessionID,
+			"combatants._id": req.CombatantID,
+		}).Decode(&encounter)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, errors.New("combatant not found in this session's encounters")
+			}
+			return nil, fmt.Errorf("failed to get encounter: %w", err)
+		}
+		for _, combatant := range encounter.Combatants {
+			if combatant.ID == req.CombatantID && token.Name == "" {
+				token.Name = combatant.Name
+			}
+		}
+	}
+	if token.Name == "" {
+		return nil, errors.New("unbound tokens need a name")
+	}
+
+	for _, existing := range session.Map.Tokens {
+		if !token.CharacterID.IsZero() && existing.CharacterID == token.CharacterID ||
+			!token.CombatantID.IsZero() && existing.CombatantID == token.CombatantID {
+			return nil, fmt.Errorf("%s already has a token on the map", token.Name)
+		}
+	}
+
+	if err := validateDestination(session.Map, token.Position(), tokenCells(session.Map, primitive.NilObjectID)); err != nil {
+		return nil, err
+	}
+
+	result, err := s.sessionService.updateSession(ctx,
+		bson.M{"_id": sessionID, "map": bson.M{"$ne": nil}},
+		bson.M{
+			"$push": bson.M{"map.tokens": token},
+			"$set":  bson.M{"map.updated_at": time.Now(), "updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to place token: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, errors.New("session has no battle map")
+	}
+
+	return &token, nil
+}
+
+// RemoveToken takes a token off the map (DM only)
+func (s *MapService) RemoveToken(ctx context.Context, sessionID, dmUserID, tokenID primitive.ObjectID) error {
+	result, err := s.sessionService.updateSession(ctx,
+		bson.M{
+			"_id":            sessionID,
+			"dm_user_id":     dmUserID,
+			"map.tokens._id": tokenID,
+		},
+		bson.M{
+			"$pull": bson.M{"map.tokens": bson.M{"_id": tokenID}},
+			"$set":  bson.M{"map.updated_at": time.Now(), "updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove token: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("token not found or you are not the DM")
+	}
+	return nil
+}
+
+// AddLight places a light source on the map (DM only)
+func (s *MapService) AddLight(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.AddLightRequest) (*models.LightSource, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can place lights")
+	}
+
+	if session.Map == nil {
+		return nil, errors.New("session has no battle map")
+	}
+
+	light := models.LightSource{
+		ID:     primitive.NewObjectID(),
+		Name:   req.Name,
+		X:      req.X,
+		Y:      req.Y,
+		Bright: req.Bright,
+		Dim:    req.Dim,
+	}
+	if !inBounds(session.Map, models.GridPoint{X: light.X, Y: light.Y}) {
+		return nil, fmt.Errorf("(%d,%d) is off the map", light.X, light.Y)
+	}
+
+	_, err = s.sessionService.updateSession(ctx,
+		bson.M{"_id": sessionID, "map": bson.M{"$ne": nil}},
+		bson.M{
+			"$push": bson.M{"map.lights": light},
+			"$set":  bson.M{"map.updated_at": time.Now(), "updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add light: %w", err)
+	}
+
+	return &light, nil
+}
+
+// RemoveLight removes a light source from the map (DM only)
+func (s *MapService) RemoveLight(ctx context.Context, sessionID, dmUserID, lightID primitive.ObjectID) error {
+	result, err := s.sessionService.updateSession(ctx,
+		bson.M{
+			"_id":            sessionID,
+			"dm_user_id":     dmUserID,
+			"map.lights._id": lightID,
+		},
+		bson.M{
+			"$pull": bson.M{"map.lights": bson.M{"_id": lightID}},
+			"$set":  bson.M{"map.updated_at": time.Now(), "updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove light: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("light not found or you are not the DM")
+	}
+	return nil
+}
+
+// RevealRegion shows a rectangle of cells to every player, even out of their sight (DM only)
+func (s *MapService) RevealRegion(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.MapRegionRequest) (*models.BattleMap, error) {
+	return s.updateRegion(ctx, sessionID, dmUserID, req, true)
+}
+
+// HideRegion hides a rectangle of cells from every player, even in their sight (DM only)
+func (s *MapService) HideRegion(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.MapRegionRequest) (*models.BattleMap, error) {
+	return s.updateRegion(ctx, sessionID, dmUserID, req, false)
+}
+
+// updateRegion adds a rectangle to the revealed or hidden cells, taking it out of the other
+func (s *MapService) updateRegion(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.MapRegionRequest, reveal bool) (*models.BattleMap, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can reveal or hide the map")
+	}
+
+	battleMap := session.Map
+	if battleMap == nil {
+		return nil, errors.New("session has no battle map")
+	}
+
+	region := make(map[models.GridPoint]bool)
+	for x := req.X; x < req.X+req.Width && x < battleMap.Width; x++ {
+		for y := req.Y; y < req.Y+req.Height && y < battleMap.Height; y++ {
+			region[models.GridPoint{X: x, Y: y}] = true
+		}
+	}
+	if len(region) == 0 {
+		return nil, errors.New("region is off the map")
+	}
+
+	add, remove := &battleMap.Hidden, &battleMap.Revealed
+	if reveal {
+		add, remove = &battleMap.Revealed, &battleMap.Hidden
+	}
+
+	kept := []models.GridPoint{}
+	for _, cell := range *remove {
+		if !region[cell] {
+			kept = append(kept, cell)
+		}
+	}
+	*remove = kept
+
+	for _, cell := range *add {
+		delete(region, cell)
+	}
+	for x := req.X; x < req.X+req.Width && x < battleMap.Width; x++ {
+		for y := req.Y; y < req.Y+req.Height && y < battleMap.Height; y++ {
+			if cell := (models.GridPoint{X: x, Y: y}); region[cell] {
+				*add = append(*add, cell)
+			}
+		}
+	}
+
+	battleMap.UpdatedAt = time.Now()
+	_, err = s.sessionService.updateSession(ctx,
+		bson.M{"_id": sessionID, "map": bson.M{"$ne": nil}},
+		bson.M{
+			"$set": bson.M{
+				"map.revealed":   battleMap.Revealed,
+				"map.hidden":     battleMap.Hidden,
+				"map.updated_at": battleMap.UpdatedAt,
+				"updated_at":     time.Now(),
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update map visibility: %w", err)
+	}
+
+	return battleMap, nil
+}
+
+// MoveToken moves a token along a path. During combat, a token bound to a combatant in
+// the turn order spends that combatant's movement; the DM may move any token for free.
+// The returned turn is nil when no movement was spent.
+func (s *MapService) MoveToken(ctx context.Context, sessionID, userID, tokenID primitive.ObjectID, req *models.MoveTokenRequest) (*models.TokenMove, *CombatTurn, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if session.Map == nil {
+		return nil, nil, errors.New("session has no battle map")
+	}
+
+	var token *models.MapToken
+	tokenIndex := -1
+	for i := range session.Map.Tokens {
+		if session.Map.Tokens[i].ID == tokenID {
+			token = &session.Map.Tokens[i]
+			tokenIndex = i
+			break
+		}
+	}
+	if token == nil {
+		return nil, nil, errors.New("token not found")
+	}
+
+	isDM := session.DMUserID == userID
+	if req.Free && !isDM {
+		return nil, nil, errors.New("only the DM can move tokens for free")
+	}
+
+	from := token.Position()
+	cost, err := pathCost(session.Map, from, req.Path, tokenCells(session.Map, tokenID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The move only lands if the token is still where it was read, and movement is spent
+	// in the same write, so a lost race neither moves the token nor costs any feet
+	to := req.Path[len(req.Path)-1]
+	prefix := fmt.Sprintf("map.tokens.%d.", tokenIndex)
+	where := bson.M{
+		prefix + "_id": tokenID,
+		prefix + "x":   from.X,
+		prefix + "y":   from.Y,
+	}
+	move := bson.M{
+		prefix + "x":     to.X,
+		prefix + "y":     to.Y,
+		"map.updated_at": time.Now(),
+	}
+
+	actor := models.CombatActor{CharacterID: token.CharacterID, CombatantID: token.CombatantID}
+	var turn *CombatTurn
+	if !req.Free && inTurnOrder(session, actor) {
+		turn, err = s.combatService.GetTurn(ctx, sessionID, userID, actor)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.combatService.spendWith(ctx, turn, EconomyCost{Movement: cost}, nil, where, move); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if !isDM && !controlsToken(session, userID, token) {
+			return nil, nil, errors.New("you do not control this token")
+		}
+
+		where["_id"] = sessionID
+		move["updated_at"] = time.Now()
+		result, err := s.sessionService.updateSession(ctx, where, bson.M{"$set": move})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to move token: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			return nil, nil, s.sessionService.conflict(ctx, sessionID)
+		}
+	}
+
+	token.X, token.Y = to.X, to.Y
+	if turn == nil {
+		cost = 0
+	}
+	return &models.TokenMove{
+		Token: *token,
+		From:  from,
+		Path:  req.Path,
+		Cost:  cost,
+	}, turn, nil
+}
+
+// pathCost validates a path step by step and returns its cost in feet. Each step moves to
+// one of the eight neighbouring cells, with diagonals costing the same as orthogonal steps.
+// Walls block, difficult terrain and water cost double, and the path may pass through
+// other tokens but not end on one.
+func pathCost(battleMap *models.BattleMap, from models.GridPoint, path []models.GridPoint, occupied map[models.GridPoint]bool) (int, error) {
+	terrain := terrainIndex(battleMap)
+	cost := 0
+	previous := from
+	for _, step := range path {
+		if abs(step.X-previous.X) > 1 || abs(step.Y-previous.Y) > 1 || step == previous {
+			return 0, fmt.Errorf("(%d,%d) is not adjacent to (%d,%d)", step.X, step.Y, previous.X, previous.Y)
+		}
+		if !inBounds(battleMap, step) {
+			return 0, fmt.Errorf("(%d,%d) is off the map", step.X, step.Y)
+		}
+
+		switch terrain[step] {
+		case models.TerrainWall:
+			return 0, fmt.Errorf("(%d,%d) is a wall", step.X, step.Y)
+		case models.TerrainDifficult, models.TerrainWater:
+			cost += 2 * battleMap.CellFeet
+		default:
+			cost += battleMap.CellFeet
+		}
+		previous = step
+	}
+
+	if occupied[previous] {
+		return 0, fmt.Errorf("(%d,%d) is occupied", previous.X, previous.Y)
+	}
+	return cost, nil
+}
+
+// validateDestination checks a token can stand on a cell
+func validateDestination(battleMap *models.BattleMap, point models.GridPoint, occupied map[models.GridPoint]bool) error {
+	if !inBounds(battleMap, point) {
+		return fmt.Errorf("(%d,%d) is off the map", point.X, point.Y)
+	}
+	if terrainIndex(battleMap)[point] == models.TerrainWall {
+		return fmt.Errorf("(%d,%d) is a wall", point.X, point.Y)
+	}
+	if occupied[point] {
+		return fmt.Errorf("(%d,%d) is occupied", point.X, point.Y)
+	}
+	return nil
+}
+
+// terrainIndex maps cells to their terrain; cells not present are open ground
+func terrainIndex(battleMap *models.BattleMap) map[models.GridPoint]models.TerrainType {
+	index := make(map[models.GridPoint]models.TerrainType, len(battleMap.Terrain))
+	for _, cell := range battleMap.Terrain {
+		index[models.GridPoint{X: cell.X, Y: cell.Y}] = cell.Type
+	}
+	return index
+}
+
+// tokenCells returns the cells occupied by every token except the given one
+func tokenCells(battleMap *models.BattleMap, except primitive.ObjectID) map[models.GridPoint]bool {
+	cells := make(map[models.GridPoint]bool, len(battleMap.Tokens))
+	for _, token := range battleMap.Tokens {
+		if token.ID != except {
+			cells[token.Position()] = true
+		}
+	}
+	return cells
+}
+
+// cellsInBounds returns the cells that lie on the map
+func cellsInBounds(battleMap *models.BattleMap, cells []models.GridPoint) []models.GridPoint {
+	kept := []models.GridPoint{}
+	for _, cell := range cells {
+		if inBounds(battleMap, cell) {
+			kept = append(kept, cell)
+		}
+	}
+	return kept
+}
+
+func inBounds(battleMap *models.BattleMap, point models.GridPoint) bool {
+	return point.X >= 0 && point.Y >= 0 && point.X < battleMap.Width && point.Y < battleMap.Height
+}
+
+// inTurnOrder reports whether the actor has an entry in the session's turn order
+func inTurnOrder(session *models.GameSession, actor models.CombatActor) bool {
+	for _, entry := range session.TurnOrder {
+		if !actor.CharacterID.IsZero() && entry.CharacterID == actor.CharacterID ||
+			!actor.CombatantID.IsZero() && entry.CombatantID == actor.CombatantID {
+			return true
+		}
+	}
+	return false
+}
+
+// controlsToken reports whether a player owns the character a token is bound to
+func controlsToken(session *models.GameSession, userID primitive.ObjectID, token *models.MapToken) bool {
+	if token.CharacterID.IsZero() {
+		return false
+	}
+	for _, player := range session.Players {
+		if player.UserID == userID && player.CharacterID == token.CharacterID {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
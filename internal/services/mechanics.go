@@ -0,0 +1,761 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/database"
+	"dnd-simulator/internal/models"
+)
+
+// mechanicApplyTimeout is how long a change can stay applying before it's taken to be
+// stuck, e.g. because the server died mid-apply, and can be reviewed again
+const mechanicApplyTimeout = 2 * time.Minute
+
+// MechanicsService turns the mechanics in a structured AI DM response into changes to game
+// state, queues them for the DM to review, and applies the ones the DM approves
+type MechanicsService struct {
+	db             *database.DB
+	sessionService *SessionService
+	combatService  *CombatService
+	diceService    *DiceService
+}
+
+// NewMechanicsService creates a new mechanics service instance
+func NewMechanicsService(db *database.DB, sessionService *SessionService, combatService *CombatService, diceService *DiceService) *MechanicsService {
+	return &MechanicsService{
+		db:             db,
+		sessionService: sessionService,
+		combatService:  combatService,
+		diceService:    diceService,
+	}
+}
+
+// EnsureIndexes creates the index the review queue is listed by
+func (s *MechanicsService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.db.GetCollection("mechanic_changes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create mechanic change indexes: %w", err)
+	}
+	return nil
+}
+
+// Propose queues the changes a structured AI response calls for. Mechanics guessed from
+// plain narrative are too unreliable to act on and propose nothing. When the campaign
+// applies AI mechanics automatically, each change is applied straight away.
+func (s *MechanicsService) Propose(ctx context.Context, session *models.GameSession, campaign *models.Campaign, response *models.AIResponse) ([]models.MechanicChange, error) {
+	if !response.Structured {
+		return nil, nil
+	}
+	if response.ID.IsZero() {
+		response.ID = primitive.NewObjectID()
+	}
+
+	targets, err := s.loadTargets(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []models.MechanicChange
+	add := func(kind models.MechanicChangeKind, description string, spec models.MechanicChangeSpec) {
+		changes = append(changes, models.MechanicChange{
+			ID:           primitive.NewObjectID(),
+			SessionID:    session.ID,
+			AIResponseID: response.ID,
+			Kind:         kind,
+			Status:       models.MechanicPending,
+			Description:  description,
+			Proposed:     spec,
+			CreatedAt:    time.Now(),
+		})
+	}
+
+	for _, mechanic := range response.GameMechanics {
+		target := targets.resolve(mechanic.Target, session.DMUserID)
+
+		// On an NPC's turn its attacks are rolled by the server, against the target's AC
+		if mechanic.Type == "attack_roll" {
+			if attacker := currentNPC(session); attacker != nil && (!target.CharacterID.IsZero() || !target.CombatantID.IsZero()) {
+				add(models.MechanicNPCAttack, mechanic.Description, models.MechanicChangeSpec{
+					Target:   target,
+					Attacker: attacker,
+					Roll:     mechanic.Requirements,
+				})
+				continue
+			}
+		}
+
+		switch mechanic.Type {
+		case "skill_check", "saving_throw", "attack_roll", "initiative":
+			roll := mechanic.Requirements
+			if roll == nil {
+				roll = &models.RollRequirements{RollType: "d20"}
+			}
+			add(models.MechanicRollRequest, mechanic.Description, models.MechanicChangeSpec{
+				Target:  target,
+				Roll:    roll,
+				Effects: mechanic.Effects,
+			})
+
+		case "damage":
+			if mechanic.Requirements == nil || mechanic.Requirements.DamageDice == "" {
+				continue
+			}
+			damage := &models.DamageChange{
+				Dice: mechanic.Requirements.DamageDice,
+				Type: mechanic.Requirements.DamageType,
+			}
+			roll, err := s.diceService.ParseAndRoll(damage.Dice, mechanic.Description)
+			if err != nil {
+				continue
+			}
+			damage.Amount = roll.Total
+			add(models.MechanicDamage, mechanic.Description, models.MechanicChangeSpec{Target: target, Damage: damage})
+
+			if mechanic.Effects != nil && len(mechanic.Effects.Conditions) > 0 {
+				add(models.MechanicCondition, mechanic.Description, models.MechanicChangeSpec{
+					Target:     target,
+					Conditions: mechanic.Effects.Conditions,
+					Duration:   mechanic.Effects.Duration,
+				})
+			}
+
+		case "condition":
+			if mechanic.Effects == nil || len(mechanic.Effects.Conditions) == 0 {
+				continue
+			}
+			add(models.MechanicCondition, mechanic.Description, models.MechanicChangeSpec{
+				Target:     target,
+				Conditions: mechanic.Effects.Conditions,
+				Duration:   mechanic.Effects.Duration,
+			})
+		}
+	}
+
+	// NPCs only join the turn order once there is one
+	if len(session.TurnOrder) > 0 {
+		for _, action := range response.NPCActions {
+			if action.Initiative == 0 {
+				continue
+			}
+			initiative := action.Initiative
+			target := targets.resolve(action.NPCName, session.DMUserID)
+			if !target.CharacterID.IsZero() || npcHasTurn(session, target) {
+				continue
+			}
+			add(models.MechanicNPCInitiative, fmt.Sprintf("%s joins the fight", action.NPCName), models.MechanicChangeSpec{
+				Target:     target,
+				Initiative: &initiative,
+			})
+		}
+	}
+
+	if response.SceneChanges != nil {
+		add(models.MechanicScene, "The scene changes", models.MechanicChangeSpec{Scene: response.SceneChanges})
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	documents := make([]interface{}, len(changes))
+	for i := range changes {
+		documents[i] = changes[i]
+	}
+	if _, err := s.db.GetCollection("mechanic_changes").InsertMany(ctx, documents); err != nil {
+		return nil, fmt.Errorf("failed to queue mechanic changes: %w", err)
+	}
+
+	if campaign != nil && campaign.Settings.AI.AutoApply {
+		for i := range changes {
+			applied, err := s.approve(ctx, session, changes[i].ID, session.DMUserID, true)
+			if err != nil {
+				log.Printf("Failed to auto-apply mechanic change %s: %v", changes[i].ID.Hex(), err)
+			}
+			if applied != nil {
+				changes[i] = *applied
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// List returns a session's changes, oldest first, optionally only those with a status
+func (s *MechanicsService) List(ctx context.Context, sessionID primitive.ObjectID, status models.MechanicChangeStatus) ([]models.MechanicChange, error) {
+	filter := bson.M{"session_id": sessionID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	cursor, err := s.db.GetCollection("mechanic_changes").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mechanic changes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	changes := []models.MechanicChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, fmt.Errorf("failed to decode mechanic changes: %w", err)
+	}
+	return changes, nil
+}
+
+// Get retrieves a change belonging to a session
+func (s *MechanicsService) Get(ctx context.Context, sessionID, changeID primitive.ObjectID) (*models.MechanicChange, error) {
+	var change models.MechanicChange
+	err := s.db.GetCollection("mechanic_changes").FindOne(ctx, bson.M{
+		"_id":        changeID,
+		"session_id": sessionID,
+	}).Decode(&change)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("mechanic change not found")
+		}
+		return nil, fmt.Errorf("failed to get mechanic change: %w", err)
+	}
+	return &change, nil
+}
+
+// Edit replaces parts of a change the DM hasn't approved yet. The AI's proposal is kept
+// alongside the edit, so the two can be compared when tuning prompts.
+func (s *MechanicsService) Edit(ctx context.Context, session *models.GameSession, changeID, dmUserID primitive.ObjectID, req *models.EditMechanicChangeRequest) (*models.MechanicChange, error) {
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can edit AI mechanics")
+	}
+
+	change, err := s.Get(ctx, session.ID, changeID)
+	if err != nil {
+		return nil, err
+	}
+	if !changeReviewable(change, time.Now()) {
+		return nil, fmt.Errorf("mechanic change is already %s", change.Status)
+	}
+
+	spec := change.Spec()
+	if req.Target != nil {
+		target, err := s.checkTarget(ctx, session, *req.Target)
+		if err != nil {
+			return nil, err
+		}
+		spec.Target = target
+	}
+	if req.Roll != nil {
+		spec.Roll = req.Roll
+	}
+	if req.Effects != nil {
+		spec.Effects = req.Effects
+	}
+	if req.Damage != nil {
+		damage, reroll := editDamage(spec.Damage, req.Damage)
+		if reroll {
+			roll, err := s.diceService.ParseAndRoll(damage.Dice, change.Description)
+			if err != nil {
+				return nil, fmt.Errorf("invalid damage dice: %w", err)
+			}
+			damage.Amount = roll.Total
+		}
+		spec.Damage = damage
+	}
+	if req.Conditions != nil {
+		spec.Conditions = req.Conditions
+	}
+	if req.Duration != nil {
+		spec.Duration = *req.Duration
+	}
+	if req.Initiative != nil {
+		spec.Initiative = req.Initiative
+	}
+	if req.Scene != nil {
+		spec.Scene = req.Scene
+	}
+	if req.Attack != nil {
+		spec.Attack = *req.Attack
+	}
+
+	var updated models.MechanicChange
+	err = s.db.GetCollection("mechanic_changes").FindOneAndUpdate(ctx,
+		reviewableFilter(bson.M{"_id": change.ID}, time.Now()),
+		bson.M{"$set": bson.M{"edited": spec}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("mechanic change was reviewed in the meantime")
+		}
+		return nil, fmt.Errorf("failed to edit mechanic change: %w", err)
+	}
+	return &updated, nil
+}
+
+// Approve applies a pending change, or retries one that failed. A change that can't be
+// applied is marked failed with the reason and returned along with the error.
+func (s *MechanicsService) Approve(ctx context.Context, session *models.GameSession, changeID, dmUserID primitive.ObjectID) (*models.MechanicChange, error) {
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can approve AI mechanics")
+	}
+	return s.approve(ctx, session, changeID, dmUserID, false)
+}
+
+// Reject discards a pending change. It is kept with the DM's reason and logged, so
+// prompts can be tuned against what the AI gets wrong.
+func (s *MechanicsService) Reject(ctx context.Context, session *models.GameSession, changeID, dmUserID primitive.ObjectID, reason string) (*models.MechanicChange, error) {
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can reject AI mechanics")
+	}
+
+	now := time.Now()
+	var change models.MechanicChange
+	err := s.db.GetCollection("mechanic_changes").FindOneAndUpdate(ctx,
+		reviewableFilter(bson.M{"_id": changeID, "session_id": session.ID}, now),
+		bson.M{"$set": bson.M{
+			"status":      models.MechanicRejected,
+			"reason":      reason,
+			"reviewed_by": dmUserID,
+			"reviewed_at": now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&change)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("mechanic change not found or already reviewed")
+		}
+		return nil, fmt.Errorf("failed to reject mechanic change: %w", err)
+	}
+
+	log.Printf("Rejected AI mechanic %s (%s) in session %s: %q, reason: %q",
+		change.ID.Hex(), change.Kind, session.ID.Hex(), change.Description, reason)
+	return &change, nil
+}
+
+// reviewableFilter narrows filter to changes the DM can still edit, approve or reject:
+// pending ones, failed ones, and ones stuck applying
+func reviewableFilter(filter bson.M, now time.Time) bson.M {
+	filter["$or"] = []bson.M{
+		{"status": bson.M{"$in": []models.MechanicChangeStatus{models.MechanicPending, models.MechanicFailed}}},
+		{"status": models.MechanicApplying, "applying_at": bson.M{"$lt": now.Add(-mechanicApplyTimeout)}},
+	}
+	return filter
+}
+
+// changeReviewable is reviewableFilter for a change already loaded
+func changeReviewable(change *models.MechanicChange, now time.Time) bool {
+	switch change.Status {
+	case models.MechanicPending, models.MechanicFailed:
+		return true
+	case models.MechanicApplying:
+		return change.ApplyingAt != nil && change.ApplyingAt.Before(now.Add(-mechanicApplyTimeout))
+	}
+	return false
+}
+
+// approve claims a change so it can't be applied twice, applies it and records the outcome.
+// A change whose outcome never gets recorded stays claimed until mechanicApplyTimeout passes.
+func (s *MechanicsService) approve(ctx context.Context, session *models.GameSession, changeID, dmUserID primitive.ObjectID, auto bool) (*models.MechanicChange, error) {
+	collection := s.db.GetCollection("mechanic_changes")
+
+	claimedAt := time.Now().Truncate(time.Millisecond) // As stored, so the outcome can match the claim
+	var change models.MechanicChange
+	err := collection.FindOneAndUpdate(ctx,
+		reviewableFilter(bson.M{"_id": changeID, "session_id": session.ID}, claimedAt),
+		bson.M{"$set": bson.M{"status": models.MechanicApplying, "applying_at": claimedAt}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&change)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("mechanic change not found or already reviewed")
+		}
+		return nil, fmt.Errorf("failed to approve mechanic change: %w", err)
+	}
+
+	result, applyErr := s.apply(ctx, session, &change)
+
+	now := time.Now()
+	change.ReviewedBy = dmUserID
+	change.ReviewedAt = &now
+	change.AutoApplied = auto
+	change.Result = result
+	change.Status = models.MechanicApplied
+	change.Error = ""
+	if applyErr != nil {
+		change.Status = models.MechanicFailed
+		change.Error = applyErr.Error()
+	}
+
+	// Recorded even if the request was cancelled while applying, so the change isn't left
+	// claimed; matching the claim keeps a late write from overwriting a later review
+	recorded, err := collection.UpdateOne(context.WithoutCancel(ctx), bson.M{
+		"_id":         change.ID,
+		"status":      models.MechanicApplying,
+		"applying_at": claimedAt,
+	}, bson.M{"$set": bson.M{
+		"status":       change.Status,
+		"result":       change.Result,
+		"error":        change.Error,
+		"auto_applied": change.AutoApplied,
+		"reviewed_by":  change.ReviewedBy,
+		"reviewed_at":  change.ReviewedAt,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record mechanic change: %w", err)
+	}
+	if recorded.MatchedCount == 0 {
+		return nil, errors.New("mechanic change was reviewed again while it was being applied")
+	}
+
+	if applyErr != nil {
+		return &change, applyErr
+	}
+	return &change, nil
+}
+
+// apply makes a change to the game and describes what it did
+func (s *MechanicsService) apply(ctx context.Context, session *models.GameSession, change *models.MechanicChange) (map[string]interface{}, error) {
+	spec := change.Spec()
+
+	switch change.Kind {
+	case models.MechanicRollRequest:
+		// The roll itself happens on the player's side; approving sends the request
+		if spec.Roll == nil {
+			return nil, errors.New("roll request has no roll")
+		}
+		userID := spec.Target.UserID
+		if userID.IsZero() {
+			userID = session.DMUserID
+		}
+		return map[string]interface{}{"requested_from": userID}, nil
+
+	case models.MechanicDamage:
+		if spec.Damage == nil {
+			return nil, errors.New("damage change has no damage")
+		}
+		target, err := s.combatService.loadTarget(ctx, session, models.CombatActor{
+			CharacterID: spec.Target.CharacterID,
+			CombatantID: spec.Target.CombatantID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		damage, adjustment := target.adjustDamage(spec.Damage.Amount, spec.Damage.Type)
+		hp, err := s.combatService.applyDamage(ctx, target, damage)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{"target": target.Name, "damage": damage, "current_hp": hp}
+		if adjustment != "" {
+			result["adjustment"] = adjustment
+		}
+		return result, nil
+
+	case models.MechanicCondition:
+		if len(spec.Conditions) == 0 {
+			return nil, errors.New("condition change has no conditions")
+		}
+		if err := s.addConditions(ctx, session, spec.Target, spec.Conditions); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"target": spec.Target.Name, "conditions": spec.Conditions}, nil
+
+	case models.MechanicNPCInitiative:
+		if spec.Initiative == nil {
+			return nil, errors.New("initiative change has no initiative")
+		}
+		entry := models.TurnEntry{
+			Type:        models.TurnTypeNPC,
+			CombatantID: spec.Target.CombatantID,
+			Initiative:  *spec.Initiative,
+			Name:        spec.Target.Name,
+		}
+		if !spec.Target.CombatantID.IsZero() {
+			target, err := s.combatService.loadTarget(ctx, session, models.CombatActor{CombatantID: spec.Target.CombatantID})
+			if err != nil {
+				return nil, err
+			}
+			entry.Name = target.Name
+			entry.Dexterity = target.Abilities.Dexterity
+			entry.Speed = target.Speed
+			if target.Monster != nil {
+				entry.LegendaryActions = target.Monster.LegendaryActionsPerRound
+			}
+		}
+		if entry.Name == "" {
+			return nil, errors.New("initiative change has no NPC name")
+		}
+		updated, err := s.sessionService.AddTurnEntry(ctx, session.ID, session.DMUserID, entry)
+		if err != nil {
+			return nil, err
+		}
+		*session = *updated
+		return map[string]interface{}{"name": entry.Name, "initiative": entry.Initiative}, nil
+
+	case models.MechanicNPCAttack:
+		if spec.Attacker == nil || spec.Attacker.CombatantID.IsZero() {
+			return nil, errors.New("NPC attack has no attacker")
+		}
+		advantage := ""
+		if spec.Roll != nil && spec.Roll.Advantage != spec.Roll.Disadvantage {
+			advantage = "disadvantage"
+			if spec.Roll.Advantage {
+				advantage = "advantage"
+			}
+		}
+		result, turn, err := s.combatService.ResolveAttack(ctx, session.ID, session.DMUserID, &models.ResolveAttackRequest{
+			Attacker:  models.CombatActor{CombatantID: spec.Attacker.CombatantID},
+			Target:    models.CombatActor{CharacterID: spec.Target.CharacterID, CombatantID: spec.Target.CombatantID},
+			Action:    spec.Attack,
+			Advantage: advantage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		*session = *turn.Session
+		return map[string]interface{}{"attack": result}, nil
+
+	case models.MechanicScene:
+		if spec.Scene == nil {
+			return nil, errors.New("scene change has no scene")
+		}
+		scene := describeScene(session.Scene, spec.Scene)
+		if err := s.sessionService.UpdateScene(ctx, session.ID, session.DMUserID, scene, session.Notes); err != nil {
+			return nil, err
+		}
+		session.Scene = scene
+		return map[string]interface{}{"scene": scene}, nil
+	}
+
+	return nil, fmt.Errorf("unknown mechanic change kind %q", change.Kind)
+}
+
+// addConditions adds conditions to a character or encounter combatant, skipping ones it has
+func (s *MechanicsService) addConditions(ctx context.Context, session *models.GameSession, target models.MechanicTarget, conditions []string) error {
+	normalized := make([]string, len(conditions))
+	for i, condition := range conditions {
+		normalized[i] = strings.ToLower(strings.TrimSpace(condition))
+	}
+	add := bson.M{"$each": normalized}
+
+	switch {
+	case !target.CharacterID.IsZero():
+		inSession := false
+		for _, player := range session.Players {
+			if player.CharacterID == target.CharacterID {
+				inSession = true
+				break
+			}
+		}
+		if !inSession {
+			return errors.New("character is not in this session")
+		}
+		_, err := s.db.GetCollection("characters").UpdateOne(ctx,
+			bson.M{"_id": target.CharacterID},
+			bson.M{
+				"$addToSet": bson.M{"conditions": add},
+				"$set":      bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add conditions: %w", err)
+		}
+		return nil
+
+	case !target.CombatantID.IsZero():
+		result, err := s.db.GetCollection("encounters").UpdateOne(ctx,
+			bson.M{"session_id": session.ID, "combatants._id": target.CombatantID},
+			bson.M{
+				"$addToSet": bson.M{"combatants.$[c].conditions": add},
+				"$set":      bson.M{"updated_at": time.Now()},
+			},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"c._id": target.CombatantID}}}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add conditions: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("combatant not found in this session's encounters")
+		}
+		return nil
+	}
+
+	return errors.New("target must be a character or combatant")
+}
+
+// checkTarget fills in who rolls for a target the DM picked, making sure it is in the session
+func (s *MechanicsService) checkTarget(ctx context.Context, session *models.GameSession, target models.MechanicTarget) (models.MechanicTarget, error) {
+	if target.CharacterID.IsZero() && target.CombatantID.IsZero() {
+		target.UserID = session.DMUserID
+		return target, nil
+	}
+
+	resolved, err := s.combatService.loadTarget(ctx, session, models.CombatActor{
+		CharacterID: target.CharacterID,
+		CombatantID: target.CombatantID,
+	})
+	if err != nil {
+		return target, err
+	}
+	target.Name = resolved.Name
+	target.UserID = session.DMUserID
+	if resolved.Character != nil {
+		target.UserID = resolved.Character.UserID
+	}
+	return target, nil
+}
+
+// sessionTargets are the characters and encounter combatants an AI response can name
+type sessionTargets struct {
+	characters []models.Character
+	combatants []models.Combatant
+}
+
+// loadTargets gathers the session's characters and the combatants of unfinished encounters
+func (s *MechanicsService) loadTargets(ctx context.Context, session *models.GameSession) (*sessionTargets, error) {
+	targets := &sessionTargets{}
+
+	characterIDs := make([]primitive.ObjectID, 0, len(session.Players))
+	for _, player := range session.Players {
+		characterIDs = append(characterIDs, player.CharacterID)
+	}
+	if len(characterIDs) > 0 {
+		cursor, err := s.db.GetCollection("characters").Find(ctx, bson.M{"_id": bson.M{"$in": characterIDs}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get characters: %w", err)
+		}
+		defer cursor.Close(ctx)
+		if err := cursor.All(ctx, &targets.characters); err != nil {
+			return nil, fmt.Errorf("failed to decode characters: %w", err)
+		}
+	}
+
+	cursor, err := s.db.GetCollection("encounters").Find(ctx, bson.M{
+		"session_id": session.ID,
+		"status":     bson.M{"$ne": models.EncounterStatusCompleted},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encounters: %w", err)
+	}
+	defer cursor.Close(ctx)
+	var encounters []models.Encounter
+	if err := cursor.All(ctx, &encounters); err != nil {
+		return nil, fmt.Errorf("failed to decode encounters: %w", err)
+	}
+	for _, encounter := range encounters {
+		targets.combatants = append(targets.combatants, encounter.Combatants...)
+	}
+
+	return targets, nil
+}
+
+// resolve matches a name from the AI to a character or combatant. Names it can't match
+// are kept, with the DM rolling, so the DM can pick the right target when reviewing.
+func (t *sessionTargets) resolve(name string, dmUserID primitive.ObjectID) models.MechanicTarget {
+	name = strings.TrimSpace(name)
+	target := models.MechanicTarget{Name: name, UserID: dmUserID}
+	if name == "" {
+		return target
+	}
+
+	for _, character := range t.characters {
+		if strings.EqualFold(character.Name, name) {
+			target.Name = character.Name
+			target.CharacterID = character.ID
+			target.UserID = character.UserID
+			return target
+		}
+	}
+	for _, combatant := range t.combatants {
+		if strings.EqualFold(combatant.Name, name) {
+			target.Name = combatant.Name
+			target.CombatantID = combatant.ID
+			return target
+		}
+	}
+	return target
+}
+
+// currentNPC returns the NPC whose turn it is, if combat is underway and it's an NPC's turn
+func currentNPC(session *models.GameSession) *models.MechanicTarget {
+	if session.PendingInitiative != nil || session.CurrentTurn < 0 || session.CurrentTurn >= len(session.TurnOrder) {
+		return nil
+	}
+	entry := session.TurnOrder[session.CurrentTurn]
+	if entry.Type != models.TurnTypeNPC || entry.CombatantID.IsZero() {
+		return nil
+	}
+	return &models.MechanicTarget{Name: entry.Name, CombatantID: entry.CombatantID, UserID: session.DMUserID}
+}
+
+// editDamage merges a DM's damage edit into the proposed damage: fields left empty keep
+// their proposed values. It reports whether the amount must be rolled again from the dice,
+// which is when the dice changed or there is no amount, unless the DM gave one.
+func editDamage(current, edit *models.DamageChange) (*models.DamageChange, bool) {
+	merged := models.DamageChange{}
+	if current != nil {
+		merged = *current
+	}
+	diceChanged := edit.Dice != "" && edit.Dice != merged.Dice
+	if edit.Dice != "" {
+		merged.Dice = edit.Dice
+	}
+	if edit.Type != "" {
+		merged.Type = edit.Type
+	}
+	if edit.Amount > 0 {
+		merged.Amount = edit.Amount
+		return &merged, false
+	}
+	return &merged, merged.Dice != "" && (diceChanged || merged.Amount == 0)
+}
+
+// npcHasTurn reports whether an NPC already has a turn, by combatant or by name
+func npcHasTurn(session *models.GameSession, target models.MechanicTarget) bool {
+	for _, entry := range session.TurnOrder {
+		if !target.CombatantID.IsZero() && entry.CombatantID == target.CombatantID {
+			return true
+		}
+		if strings.EqualFold(entry.Name, target.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeScene folds a scene change into the current scene description
+func describeScene(current string, change *models.SceneChange) string {
+	scene := current
+	if change.NewLocation != "" {
+		scene = change.NewLocation
+	}
+
+	var details []string
+	if change.TimeChange != "" {
+		details = append(details, "Time: "+change.TimeChange)
+	}
+	if change.Weather != "" {
+		details = append(details, "Weather: "+change.Weather)
+	}
+	if change.Visibility != "" {
+		details = append(details, "Visibility: "+change.Visibility)
+	}
+	if len(change.Hazards) > 0 {
+		details = append(details, "Hazards: "+strings.Join(change.Hazards, ", "))
+	}
+	if len(details) == 0 {
+		return scene
+	}
+	if scene != "" {
+		scene += "\n"
+	}
+	return scene + strings.Join(details, ". ")
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+func TestChangeReviewable(t *testing.T) {
+	now := time.Now()
+	fresh := now.Add(-time.Second)
+	stale := now.Add(-mechanicApplyTimeout - time.Second)
+
+	tests := []struct {
+		name   string
+		change models.MechanicChange
+		want   bool
+	}{
+		{name: "pending", change: models.MechanicChange{Status: models.MechanicPending}, want: true},
+		{name: "failed", change: models.MechanicChange{Status: models.MechanicFailed}, want: true},
+		{name: "being applied", change: models.MechanicChange{Status: models.MechanicApplying, ApplyingAt: &fresh}},
+		{name: "stuck applying", change: models.MechanicChange{Status: models.MechanicApplying, ApplyingAt: &stale}, want: true},
+		{name: "applied", change: models.MechanicChange{Status: models.MechanicApplied}},
+		{name: "rejected", change: models.MechanicChange{Status: models.MechanicRejected}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changeReviewable(&tt.change, now); got != tt.want {
+				t.Errorf("changeReviewable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A change left applying, e.g. by a server that died mid-apply, can be approved again once
+// the claim is stale, but not while another approval may still be applying it
+func TestApproveStuckApplyingChange(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	mechanics := NewMechanicsService(f.sessions.db, f.sessions, f.combat, NewDiceService())
+	session := f.session(t)
+
+	claimedAt := time.Now()
+	change := models.MechanicChange{
+		ID:          primitive.NewObjectID(),
+		SessionID:   f.sessionID,
+		Kind:        models.MechanicRollRequest,
+		Status:      models.MechanicApplying,
+		Description: "Hero rolls Athletics",
+		Proposed: models.MechanicChangeSpec{
+			Target: models.MechanicTarget{Name: "Hero", CharacterID: f.character, UserID: f.playerID},
+			Roll:   &models.RollRequirements{},
+		},
+		ApplyingAt: &claimedAt,
+		CreatedAt:  claimedAt,
+	}
+	collection := f.sessions.db.GetCollection("mechanic_changes")
+	if _, err := collection.InsertOne(ctx, change); err != nil {
+		t.Fatalf("insert change: %v", err)
+	}
+
+	if _, err := mechanics.Approve(ctx, session, change.ID, f.dmID); err == nil {
+		t.Fatal("approved a change that is still being applied")
+	}
+
+	stale := claimedAt.Add(-mechanicApplyTimeout - time.Second)
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": change.ID}, bson.M{"$set": bson.M{"applying_at": stale}}); err != nil {
+		t.Fatalf("age claim: %v", err)
+	}
+
+	approved, err := mechanics.Approve(ctx, session, change.ID, f.dmID)
+	if err != nil {
+		t.Fatalf("approve stuck change: %v", err)
+	}
+	if approved.Status != models.MechanicApplied {
+		t.Errorf("got status %s, want %s", approved.Status, models.MechanicApplied)
+	}
+
+	stored, err := mechanics.Get(ctx, f.sessionID, change.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Status != models.MechanicApplied {
+		t.Errorf("stored status %s, want %s", stored.Status, models.MechanicApplied)
+	}
+}
+
+func TestCurrentNPC(t *testing.T) {
+	dmID := primitive.NewObjectID()
+	goblin := primitive.NewObjectID()
+	session := &models.GameSession{
+		DMUserID: dmID,
+		TurnOrder: []models.TurnEntry{
+			{Type: models.TurnTypePlayer, CharacterID: primitive.NewObjectID(), Name: "Hero"},
+			{Type: models.TurnTypeNPC, CombatantID: goblin, Name: "Goblin"},
+			{Type: models.TurnTypeNPC, Name: "Unbound"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		turn    int
+		pending bool
+		want    primitive.ObjectID
+	}{
+		{name: "player's turn", turn: 0},
+		{name: "npc's turn", turn: 1, want: goblin},
+		{name: "npc without a combatant", turn: 2},
+		{name: "initiative still being rolled", turn: 1, pending: true},
+		{name: "out of range", turn: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session.CurrentTurn = tt.turn
+			session.PendingInitiative = nil
+			if tt.pending {
+				session.PendingInitiative = &models.PendingInitiative{}
+			}
+			got := currentNPC(session)
+			if tt.want.IsZero() {
+				if got != nil {
+					t.Errorf("currentNPC = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.CombatantID != tt.want || got.UserID != dmID {
+				t.Errorf("currentNPC = %+v, want combatant %s acting as the DM", got, tt.want.Hex())
+			}
+		})
+	}
+}
+
+// An attack the AI makes on an NPC's turn is resolved as a real attack: approving it
+// rolls against the target's armor class and spends the NPC's action
+func TestApproveNPCAttackResolvesAttack(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	db := f.sessions.db
+	mechanics := NewMechanicsService(db, f.sessions, f.combat, NewDiceService())
+
+	goblin := f.session(t).TurnOrder[1].CombatantID
+	if _, err := db.GetCollection("characters").InsertOne(ctx, models.Character{
+		ID: f.character, UserID: f.playerID, Name: "Hero", ArmorClass: 12, MaxHP: 40, CurrentHP: 40,
+	}); err != nil {
+		t.Fatalf("insert character: %v", err)
+	}
+	if _, err := db.GetCollection("encounters").InsertOne(ctx, models.Encounter{
+		ID: primitive.NewObjectID(), SessionID: f.sessionID, Name: "Ambush", Status: models.EncounterStatusActive,
+		Combatants: []models.Combatant{{ID: goblin, MonsterSlug: "goblin", Name: "Goblin", ArmorClass: 15, MaxHP: 7, CurrentHP: 7}},
+	}); err != nil {
+		t.Fatalf("insert encounter: %v", err)
+	}
+	if _, err := db.GetCollection("sessions").UpdateOne(ctx, bson.M{"_id": f.sessionID},
+		bson.M{"$set": bson.M{"current_turn": 1}}); err != nil {
+		t.Fatalf("set turn: %v", err)
+	}
+
+	session := f.session(t)
+	changes, err := mechanics.Propose(ctx, session, nil, &models.AIResponse{
+		SessionID:  f.sessionID,
+		Structured: true,
+		GameMechanics: []models.GameMechanic{
+			{Type: "attack_roll", Target: "Hero", Description: "The goblin slashes at Hero"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("propose: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Kind != models.MechanicNPCAttack {
+		t.Fatalf("got changes %+v, want one %s", changes, models.MechanicNPCAttack)
+	}
+	if attacker := changes[0].Proposed.Attacker; attacker == nil || attacker.CombatantID != goblin {
+		t.Fatalf("got attacker %+v, want the goblin", attacker)
+	}
+
+	approved, err := mechanics.Approve(ctx, session, changes[0].ID, f.dmID)
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if approved.Status != models.MechanicApplied {
+		t.Errorf("got status %s, want %s", approved.Status, models.MechanicApplied)
+	}
+	if _, ok := approved.Result["attack"].(*models.AttackResult); !ok {
+		t.Errorf("got result %+v, want an attack result", approved.Result)
+	}
+	if !f.session(t).TurnOrder[1].Economy.ActionUsed {
+		t.Error("the goblin's action was not spent")
+	}
+}
+
+func TestEditDamage(t *testing.T) {
+	proposed := &models.DamageChange{Dice: "1d6+2", Amount: 5, Type: "slashing"}
+
+	tests := []struct {
+		name       string
+		current    *models.DamageChange
+		edit       models.DamageChange
+		want       models.DamageChange
+		wantReroll bool
+	}{
+		{
+			name: "type only",
+			edit: models.DamageChange{Type: "fire"},
+			want: models.DamageChange{Dice: "1d6+2", Amount: 5, Type: "fire"},
+		},
+		{
+			name:       "new dice",
+			edit:       models.DamageChange{Dice: "2d8"},
+			want:       models.DamageChange{Dice: "2d8", Amount: 5, Type: "slashing"},
+			wantReroll: true,
+		},
+		{
+			name: "new dice and amount",
+			edit: models.DamageChange{Dice: "2d8", Amount: 11},
+			want: models.DamageChange{Dice: "2d8", Amount: 11, Type: "slashing"},
+		},
+		{
+			name: "amount only",
+			edit: models.DamageChange{Amount: 3},
+			want: models.DamageChange{Dice: "1d6+2", Amount: 3, Type: "slashing"},
+		},
+		{
+			name:       "no proposed amount",
+			current:    &models.DamageChange{Dice: "1d4"},
+			edit:       models.DamageChange{Type: "cold"},
+			want:       models.DamageChange{Dice: "1d4", Type: "cold"},
+			wantReroll: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := tt.current
+			if current == nil {
+				current = proposed
+			}
+			got, reroll := editDamage(current, &tt.edit)
+			if *got != tt.want || reroll != tt.wantReroll {
+				t.Errorf("editDamage = %+v, %v; want %+v, %v", *got, reroll, tt.want, tt.wantReroll)
+			}
+		})
+	}
+	if proposed.Type != "slashing" {
+		t.Error("editDamage changed the proposed damage")
+	}
+}
+
+// Editing only the dice of proposed damage rolls a new amount from them and keeps the type
+func TestEditDamageDiceRerollsAmount(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	mechanics := NewMechanicsService(f.sessions.db, f.sessions, f.combat, NewDiceService())
+	session := f.session(t)
+
+	change := models.MechanicChange{
+		ID:          primitive.NewObjectID(),
+		SessionID:   f.sessionID,
+		Kind:        models.MechanicDamage,
+		Status:      models.MechanicPending,
+		Description: "The trap fires",
+		Proposed: models.MechanicChangeSpec{
+			Target: models.MechanicTarget{Name: "Hero", CharacterID: f.character, UserID: f.playerID},
+			Damage: &models.DamageChange{Dice: "1d4", Amount: 2, Type: "piercing"},
+		},
+		CreatedAt: time.Now(),
+	}
+	if _, err := f.sessions.db.GetCollection("mechanic_changes").InsertOne(ctx, change); err != nil {
+		t.Fatalf("insert change: %v", err)
+	}
+
+	edited, err := mechanics.Edit(ctx, session, change.ID, f.dmID, &models.EditMechanicChangeRequest{
+		Damage: &models.DamageChange{Dice: "10d6+100"},
+	})
+	if err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	damage := edited.Edited.Damage
+	if damage.Dice != "10d6+100" || damage.Type != "piercing" {
+		t.Errorf("got damage %+v, want the new dice with the proposed type", damage)
+	}
+	if damage.Amount < 110 || damage.Amount > 160 {
+		t.Errorf("got amount %d, want one rolled from 10d6+100", damage.Amount)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dnd-simulator/internal/data"
+	"dnd-simulator/internal/models"
+)
+
+// MonsterService provides search and lookup over the SRD monster catalog
+type MonsterService struct{}
+
+// NewMonsterService creates a new monster service instance
+func NewMonsterService() *MonsterService {
+	return &MonsterService{}
+}
+
+// MonsterFilter narrows a monster search; zero values are ignored
+type MonsterFilter struct {
+	Query string   // Case-insensitive match on name or slug
+	Type  string   // e.g., "undead", "humanoid"
+	Size  string   // e.g., "Large"
+	MinCR *float64 // Inclusive lower bound on challenge rating
+	MaxCR *float64 // Inclusive upper bound on challenge rating
+}
+
+// SearchMonsters returns the monsters matching the filter, ordered by CR then name
+func (s *MonsterService) SearchMonsters(filter MonsterFilter) []models.Monster {
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+	monsterType := strings.ToLower(strings.TrimSpace(filter.Type))
+
+	monsters := make([]models.Monster, 0, len(data.Monsters))
+	for _, monster := range data.Monsters {
+		if query != "" && !strings.Contains(strings.ToLower(monster.Name), query) && !strings.Contains(monster.Slug, query) {
+			continue
+		}
+		if monsterType != "" && !strings.HasPrefix(strings.ToLower(monster.Type), monsterType) {
+			continue
+		}
+		if filter.Size != "" && !strings.EqualFold(monster.Size, filter.Size) {
+			continue
+		}
+		if filter.MinCR != nil && monster.ChallengeRating < *filter.MinCR {
+			continue
+		}
+		if filter.MaxCR != nil && monster.ChallengeRating > *filter.MaxCR {
+			continue
+		}
+		monsters = append(monsters, monster)
+	}
+
+	sort.Slice(monsters, func(i, j int) bool {
+		if monsters[i].ChallengeRating != monsters[j].ChallengeRating {
+			return monsters[i].ChallengeRating < monsters[j].ChallengeRating
+		}
+		return monsters[i].Name < monsters[j].Name
+	})
+
+	return monsters
+}
+
+// GetMonster looks up a monster by slug
+func (s *MonsterService) GetMonster(slug string) (*models.Monster, error) {
+	monster, exists := data.Monsters[strings.ToLower(slug)]
+	if !exists {
+		return nil, errors.New("monster not found")
+	}
+	return &monster, nil
+}
+
+// ParseChallengeRating parses a CR such as "5", "0.25" or "1/4"
+func ParseChallengeRating(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if num, den, found := strings.Cut(value, "/"); found {
+		n, err1 := strconv.Atoi(num)
+		d, err2 := strconv.Atoi(den)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, fmt.Errorf("invalid challenge rating: %s", value)
+		}
+		return float64(n) / float64(d), nil
+	}
+
+	cr, err := strconv.ParseFloat(value, 64)
+	if err != nil || cr < 0 {
+		return 0, fmt.Errorf("invalid challenge rating: %s", value)
+	}
+	return cr, nil
+}
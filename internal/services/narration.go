@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/database"
+)
+
+const (
+	// responseClaimTTL is how long a session's claim on the AI DM outlives the last sign
+	// that the server writing the response is alive
+	responseClaimTTL = time.Minute
+
+	// responseClaimPollInterval is how often the server writing a response renews its
+	// claim and checks whether the DM interrupted it from another server
+	responseClaimPollInterval = 500 * time.Millisecond
+)
+
+var (
+	// ErrAIResponseInProgress means the AI DM is still answering an earlier action in the session
+	ErrAIResponseInProgress = errors.New("the AI DM is still responding to another action")
+
+	// ErrAIResponseInterrupted means the DM stopped the AI DM mid-response
+	ErrAIResponseInterrupted = errors.New("the DM interrupted the AI response")
+)
+
+// narrativeKeyPattern finds where the narrative's value starts in a structured reply
+var narrativeKeyPattern = regexp.MustCompile(`"narrative"\s*:\s*"`)
+
+// activeNarration is an AI response being written for a session
+type activeNarration struct {
+	responseID primitive.ObjectID
+	cancel     context.CancelCauseFunc
+}
+
+// narrations tracks the AI responses being written on this server, so an interrupt can
+// cancel one without waiting for the next claim poll
+type narrations struct {
+	mu     sync.Mutex
+	active map[primitive.ObjectID]*activeNarration
+}
+
+// begin registers a response for a session, returning a context the DM's interrupt cancels
+// and a func to call once it is finished
+func (n *narrations) begin(ctx context.Context, sessionID, responseID primitive.ObjectID) (context.Context, func(), error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.active[sessionID]; ok {
+		return nil, nil, ErrAIResponseInProgress
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	narration := &activeNarration{responseID: responseID, cancel: cancel}
+	n.active[sessionID] = narration
+
+	return ctx, func() {
+		n.mu.Lock()
+		if n.active[sessionID] == narration {
+			delete(n.active, sessionID)
+		}
+		n.mu.Unlock()
+		cancel(nil)
+	}, nil
+}
+
+// interrupt cancels the session's response, if there is one, and returns its ID
+func (n *narrations) interrupt(sessionID primitive.ObjectID) (primitive.ObjectID, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	narration, ok := n.active[sessionID]
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+	narration.cancel(ErrAIResponseInterrupted)
+	delete(n.active, sessionID)
+	return narration.responseID, true
+}
+
+// responseClaim is a session's claim on the AI DM, shared by every server so players
+// can't pile up responses and the DM can interrupt one written elsewhere
+type responseClaim struct {
+	SessionID   primitive.ObjectID `bson:"_id"`
+	ResponseID  primitive.ObjectID `bson:"response_id"`
+	Narrating   bool               `bson:"narrating"`   // Still being written, so it can be interrupted
+	Interrupted bool               `bson:"interrupted"` // The DM interrupted it
+	ExpiresAt   time.Time          `bson:"expires_at"`
+}
+
+// responseClaims claims sessions' AI responses in MongoDB, and cancels the ones written
+// on this server when the DM interrupts them from any server
+type responseClaims struct {
+	db    *database.DB
+	local *narrations
+}
+
+func newResponseClaims(db *database.DB) *responseClaims {
+	return &responseClaims{
+		db:    db,
+		local: &narrations{active: make(map[primitive.ObjectID]*activeNarration)},
+	}
+}
+
+// ensureIndexes expires claims left by servers that died mid-response. Expiry is also
+// checked when claiming, since the TTL monitor only runs every minute or so.
+func (r *responseClaims) ensureIndexes(ctx context.Context) error {
+	_, err := r.db.GetCollection("ai_response_claims").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create AI response claim indexes: %w", err)
+	}
+	return nil
+}
+
+// begin claims a session for a response. The claim is renewed while the response is
+// written, and its context cancelled with ErrAIResponseInterrupted if the DM interrupts.
+func (r *responseClaims) begin(ctx context.Context, sessionID, responseID primitive.ObjectID) (context.Context, *AIResponseSlot, error) {
+	now := time.Now()
+	collection := r.db.GetCollection("ai_response_claims")
+
+	// Upserting onto an expired claim takes it over; a live one makes the insert collide
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": sessionID, "expires_at": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{
+			"response_id": responseID,
+			"narrating":   true,
+			"interrupted": false,
+			"expires_at":  now.Add(responseClaimTTL),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, nil, ErrAIResponseInProgress
+		}
+		return nil, nil, fmt.Errorf("failed to claim AI response: %w", err)
+	}
+
+	slot := &AIResponseSlot{claims: r, sessionID: sessionID, responseID: responseID}
+	ctx, slot.done, err = r.local.begin(ctx, sessionID, responseID)
+	if err != nil {
+		slot.release()
+		return nil, nil, err
+	}
+	go slot.watch(ctx)
+	return ctx, slot, nil
+}
+
+// interrupt stops the session's response wherever it is being written, returning its ID.
+// A response that has finished being written can't be interrupted.
+func (r *responseClaims) interrupt(ctx context.Context, sessionID primitive.ObjectID) (primitive.ObjectID, bool, error) {
+	var claim responseClaim
+	err := r.db.GetCollection("ai_response_claims").FindOneAndUpdate(ctx,
+		bson.M{"_id": sessionID, "narrating": true, "interrupted": false, "expires_at": bson.M{"$gte": time.Now()}},
+		bson.M{"$set": bson.M{"interrupted": true}},
+	).Decode(&claim)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return primitive.NilObjectID, false, nil
+		}
+		return primitive.NilObjectID, false, fmt.Errorf("failed to interrupt AI response: %w", err)
+	}
+
+	// Cancel straight away if it's being written here; other servers notice on their next poll
+	r.local.interrupt(sessionID)
+	return claim.ResponseID, true, nil
+}
+
+// AIResponseSlot is a session's claim on the AI DM for one response
+type AIResponseSlot struct {
+	claims     *responseClaims
+	sessionID  primitive.ObjectID
+	responseID primitive.ObjectID
+	done       func()
+}
+
+// watch renews the claim until the response is done, cancelling it if the DM interrupts
+// from another server or the claim is lost
+func (s *AIResponseSlot) watch(ctx context.Context) {
+	ticker := time.NewTicker(responseClaimPollInterval)
+	defer ticker.Stop()
+
+	collection := s.claims.db.GetCollection("ai_response_claims")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var claim responseClaim
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{"_id": s.sessionID, "response_id": s.responseID},
+			bson.M{"$set": bson.M{"expires_at": time.Now().Add(responseClaimTTL)}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&claim)
+		switch {
+		case err == mongo.ErrNoDocuments, err == nil && claim.Interrupted:
+			s.claims.local.interrupt(s.sessionID)
+			return
+		case err != nil && ctx.Err() == nil:
+			log.Printf("Failed to renew AI response claim for session %s: %v", s.sessionID.Hex(), err)
+		}
+	}
+}
+
+// Finish marks the response as written, so a later interrupt has nothing to stop. It
+// returns ErrAIResponseInterrupted if the DM interrupted first.
+func (s *AIResponseSlot) Finish(ctx context.Context) error {
+	result, err := s.claims.db.GetCollection("ai_response_claims").UpdateOne(ctx,
+		bson.M{"_id": s.sessionID, "response_id": s.responseID, "narrating": true, "interrupted": false},
+		bson.M{"$set": bson.M{"narrating": false}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish AI response: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrAIResponseInterrupted
+	}
+	return nil
+}
+
+// Release gives up the claim, letting the session's next response begin
+func (s *AIResponseSlot) Release() {
+	s.done()
+	s.release()
+}
+
+func (s *AIResponseSlot) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.claims.db.GetCollection("ai_response_claims").DeleteOne(ctx, bson.M{
+		"_id":         s.sessionID,
+		"response_id": s.responseID,
+	})
+	if err != nil {
+		// The claim expires on its own
+		log.Printf("Failed to release AI response claim for session %s: %v", s.sessionID.Hex(), err)
+	}
+}
+
+// narrator passes narration on as it is written. When a reply is rejected and the model
+// asked again, the first piece of the next attempt is marked as a restart, so listeners
+// drop what they have shown.
+type narrator struct {
+	onDelta func(delta string, restart bool)
+	sent    bool
+	restart bool
+}
+
+// begin starts an attempt
+func (n *narrator) begin() {
+	if n.sent {
+		n.restart = true
+	}
+}
+
+// send passes on a piece of narration
+func (n *narrator) send(delta string) {
+	if delta == "" {
+		return
+	}
+	n.onDelta(delta, n.restart)
+	n.restart = false
+	n.sent = true
+}
+
+// narrativeExtractor picks the narrative out of a structured reply as it streams in,
+// decoding its JSON string escapes, and passes it on a piece at a time
+type narrativeExtractor struct {
+	raw   strings.Builder
+	start bool // Found the narrative's opening quote
+	pos   int  // How far into raw the narrative has been passed on
+	done  bool
+	emit  func(string)
+}
+
+func newNarrativeExtractor(emit func(string)) *narrativeExtractor {
+	return &narrativeExtractor{emit: emit}
+}
+
+// write takes the next chunk of the reply
+func (e *narrativeExtractor) write(chunk string) {
+	e.raw.WriteString(chunk)
+	if e.done {
+		return
+	}
+
+	text := e.raw.String()
+	if !e.start {
+		loc := narrativeKeyPattern.FindStringIndex(text)
+		if loc == nil {
+			return
+		}
+		e.start = true
+		e.pos = loc[1]
+	}
+
+	var out strings.Builder
+	for e.pos < len(text) {
+		ch := text[e.pos]
+		if ch == '"' {
+			e.done = true
+			break
+		}
+		if ch != '\\' {
+			// Wait for the rest of a character split across chunks
+			if !utf8.FullRuneInString(text[e.pos:]) {
+				break
+			}
+			_, size := utf8.DecodeRuneInString(text[e.pos:])
+			out.WriteString(text[e.pos : e.pos+size])
+			e.pos += size
+			continue
+		}
+
+		length := escapeLength(text[e.pos:])
+		if length == 0 {
+			break // The escape isn't complete yet
+		}
+		var decoded string
+		if err := json.Unmarshal([]byte(`"`+text[e.pos:e.pos+length]+`"`), &decoded); err == nil {
+			out.WriteString(decoded)
+		}
+		e.pos += length
+	}
+
+	if out.Len() > 0 {
+		e.emit(out.String())
+	}
+}
+
+// escapeLength returns how long the JSON escape at the start of s is, or 0 if s ends
+// before it does. A \u escape for half of a surrogate pair takes the other half with it.
+func escapeLength(s string) int {
+	if len(s) < 2 {
+		return 0
+	}
+	if s[1] != 'u' {
+		return 2
+	}
+	if len(s) < 6 {
+		return 0
+	}
+	if high := strings.ToLower(s[2:4]); high >= "d8" && high <= "db" {
+		if len(s) < 12 {
+			return 0
+		}
+		return 12
+	}
+	return 6
+}
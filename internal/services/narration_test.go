@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// narrativeReply has escapes, a surrogate pair and multibyte characters to split
+const narrativeReply = `{"narrative": "Snik hisses \"mine!\"\n\tThe café's éclair 😀 glows 🐉 \ud83d\ude00 \u00e9 \\ gone.", "game_mechanics": []}`
+
+// extract feeds the reply to an extractor in the given chunks and returns what it emitted
+func extract(chunks ...string) string {
+	var out strings.Builder
+	extractor := newNarrativeExtractor(func(delta string) { out.WriteString(delta) })
+	for _, chunk := range chunks {
+		extractor.write(chunk)
+	}
+	return out.String()
+}
+
+func TestNarrativeExtractorSplits(t *testing.T) {
+	var reply struct {
+		Narrative string `json:"narrative"`
+	}
+	if err := json.Unmarshal([]byte(narrativeReply), &reply); err != nil {
+		t.Fatalf("decode reply: %v", err)
+	}
+
+	if got := extract(narrativeReply); got != reply.Narrative {
+		t.Fatalf("whole reply: got %q, want %q", got, reply.Narrative)
+	}
+	for i := 1; i < len(narrativeReply); i++ {
+		if got := extract(narrativeReply[:i], narrativeReply[i:]); got != reply.Narrative {
+			t.Errorf("split at byte %d (%q|%q): got %q", i, narrativeReply[:i], narrativeReply[i:], got)
+		}
+	}
+
+	bytes := make([]string, len(narrativeReply))
+	for i := 0; i < len(narrativeReply); i++ {
+		bytes[i] = narrativeReply[i : i+1]
+	}
+	if got := extract(bytes...); got != reply.Narrative {
+		t.Errorf("a byte at a time: got %q, want %q", got, reply.Narrative)
+	}
+}
+
+// Nothing after the narrative's closing quote is passed on
+func TestNarrativeExtractorStopsAtEnd(t *testing.T) {
+	if got := extract(`{"game_mechanics": [], "narrative": "Quiet.", "npc_actions": [{"action": "x"}]}`); got != "Quiet." {
+		t.Errorf("got %q, want %q", got, "Quiet.")
+	}
+	if got := extract(`{"game_mechanics": []}`); got != "" {
+		t.Errorf("got %q from a reply without a narrative", got)
+	}
+}
+
+func TestNarrationsBeginInterrupt(t *testing.T) {
+	n := &narrations{active: make(map[primitive.ObjectID]*activeNarration)}
+	sessionID, responseID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	if _, ok := n.interrupt(sessionID); ok {
+		t.Error("interrupted a session with no response")
+	}
+
+	ctx, done, err := n.begin(context.Background(), sessionID, responseID)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, _, err := n.begin(context.Background(), sessionID, primitive.NewObjectID()); !errors.Is(err, ErrAIResponseInProgress) {
+		t.Errorf("second begin: got %v, want ErrAIResponseInProgress", err)
+	}
+	if _, _, err := n.begin(context.Background(), primitive.NewObjectID(), primitive.NewObjectID()); err != nil {
+		t.Errorf("begin in another session: %v", err)
+	}
+
+	interrupted, ok := n.interrupt(sessionID)
+	if !ok || interrupted != responseID {
+		t.Fatalf("interrupt: got %s, %v, want %s", interrupted.Hex(), ok, responseID.Hex())
+	}
+	if !errors.Is(context.Cause(ctx), ErrAIResponseInterrupted) {
+		t.Errorf("context cause is %v, want ErrAIResponseInterrupted", context.Cause(ctx))
+	}
+	done()
+
+	// done frees the slot, and the interrupted response's done doesn't free the next one
+	_, next, err := n.begin(context.Background(), sessionID, primitive.NewObjectID())
+	if err != nil {
+		t.Fatalf("begin after interrupt: %v", err)
+	}
+	done()
+	if _, _, err := n.begin(context.Background(), sessionID, primitive.NewObjectID()); !errors.Is(err, ErrAIResponseInProgress) {
+		t.Errorf("an old response's done freed the next one's slot: %v", err)
+	}
+	next()
+	if _, _, err := n.begin(context.Background(), sessionID, primitive.NewObjectID()); err != nil {
+		t.Errorf("begin after done: %v", err)
+	}
+}
+
+// A retry's narration starts with a restart, so listeners drop the rejected attempt's
+func TestNarratorRestartsOnRetry(t *testing.T) {
+	service, _ := newScriptedAIService(`{"narrative": "Hm, let me", "game_mechanics": [{"type": "dance"}]}`, validStructuredReply)
+
+	type delta struct {
+		text    string
+		restart bool
+	}
+	var deltas []delta
+	narration := &narrator{onDelta: func(text string, restart bool) {
+		deltas = append(deltas, delta{text, restart})
+	}}
+	if _, err := service.generateDMResponse(context.Background(), testAIContext(primitive.NilObjectID), narration); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	var first, second strings.Builder
+	restarts := 0
+	for _, d := range deltas {
+		if d.restart {
+			restarts++
+		}
+		if restarts == 0 {
+			first.WriteString(d.text)
+		} else {
+			second.WriteString(d.text)
+		}
+	}
+	if restarts != 1 || deltas[0].restart {
+		t.Fatalf("got %d restarts in %+v, want one starting the retry", restarts, deltas)
+	}
+	if first.String() != "Hm, let me" || second.String() != "The goblin snarls." {
+		t.Errorf("narrated %q then %q", first.String(), second.String())
+	}
+}
+
+// The DM can interrupt a response written on another server, but not one that finished
+func TestResponseClaimsAcrossServers(t *testing.T) {
+	db := newTestDB(t)
+	writer, other := newResponseClaims(db), newResponseClaims(db)
+	sessionID, responseID := primitive.NewObjectID(), primitive.NewObjectID()
+	bg := context.Background()
+
+	ctx, slot, err := writer.begin(bg, sessionID, responseID)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, _, err := other.begin(bg, sessionID, primitive.NewObjectID()); !errors.Is(err, ErrAIResponseInProgress) {
+		t.Fatalf("begin on another server: got %v, want ErrAIResponseInProgress", err)
+	}
+
+	interrupted, ok, err := other.interrupt(bg, sessionID)
+	if err != nil || !ok || interrupted != responseID {
+		t.Fatalf("interrupt: got %s, %v, %v", interrupted.Hex(), ok, err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * responseClaimPollInterval):
+		t.Fatal("interrupt from another server didn't cancel the response")
+	}
+	if !errors.Is(context.Cause(ctx), ErrAIResponseInterrupted) {
+		t.Errorf("context cause is %v, want ErrAIResponseInterrupted", context.Cause(ctx))
+	}
+	if err := slot.Finish(bg); !errors.Is(err, ErrAIResponseInterrupted) {
+		t.Errorf("finish after interrupt: got %v, want ErrAIResponseInterrupted", err)
+	}
+	slot.Release()
+
+	_, slot, err = other.begin(bg, sessionID, primitive.NewObjectID())
+	if err != nil {
+		t.Fatalf("begin after release: %v", err)
+	}
+	defer slot.Release()
+	if err := slot.Finish(bg); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if _, ok, err := writer.interrupt(bg, sessionID); err != nil || ok {
+		t.Errorf("interrupted a finished response: %v, %v", ok, err)
+	}
+}
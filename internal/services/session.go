@@ -4,23 +4,27 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 
+	"dnd-simulator/internal/data"
 	"dnd-simulator/internal/database"
 	"dnd-simulator/internal/models"
 )
 
 type SessionService struct {
-	db *database.DB
+	db          *database.DB
+	diceService *DiceService
 }
 
-func NewSessionService(db *database.DB) *SessionService {
+func NewSessionService(db *database.DB, diceService *DiceService) *SessionService {
 	return &SessionService{
-		db: db,
+		db:          db,
+		diceService: diceService,
 	}
 }
 
@@ -51,7 +55,6 @@ func (s *SessionService) CreateSession(ctx context.Context, req *models.CreateSe
 		Round:       0,
 		Players:     []models.SessionPlayer{},
 		DMUserID:    dmUserID,
-		ChatHistory: []models.SessionChatMsg{},
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -77,6 +80,92 @@ func (s *SessionService) GetSession(ctx context.Context, sessionID primitive.Obj
 	return &session, nil
 }
 
+// ErrInitiativeWindowClosed means a player rolled initiative after the roll window's deadline
+var ErrInitiativeWindowClosed = errors.New("the initiative roll window has closed")
+
+// maxSessionUpdateAttempts bounds how often a compare-and-swap session update is retried
+const maxSessionUpdateAttempts = 5
+
+// ConflictError is returned when a session changed underneath a compare-and-swap update.
+// Session holds the current state so the caller can show it.
+type ConflictError struct {
+	Session *models.GameSession
+}
+
+func (e *ConflictError) Error() string {
+	return "session was changed by someone else, please retry"
+}
+
+// updateSession applies an update to a session and bumps its version, so compare-and-swap
+// writers notice every change made since they read the session
+func (s *SessionService) updateSession(ctx context.Context, filter, update bson.M) (*mongo.UpdateResult, error) {
+	inc, ok := update["$inc"].(bson.M)
+	if !ok {
+		inc = bson.M{}
+		update["$inc"] = inc
+	}
+	inc["version"] = 1
+	return s.db.GetCollection("sessions").UpdateOne(ctx, filter, update)
+}
+
+// compareAndSwap applies an update only if the session is still at the version it was
+// read at, and reports whether it was applied
+func (s *SessionService) compareAndSwap(ctx context.Context, session *models.GameSession, update bson.M) (bool, error) {
+	filter := bson.M{"_id": session.ID, "version": session.Version}
+	if session.Version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}} // Sessions created before versioning
+	}
+
+	result, err := s.updateSession(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 0 {
+		return false, nil
+	}
+	session.Version++
+	return true, nil
+}
+
+// conflict builds a ConflictError carrying the session's current state
+func (s *SessionService) conflict(ctx context.Context, sessionID primitive.ObjectID) error {
+	session, err := s.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return &ConflictError{Session: session}
+}
+
+// modifySession reads a session, lets modify change it and build the matching update, and
+// writes it with compare-and-swap, re-reading and retrying when someone else wrote first.
+// A non-zero expected version fails with a ConflictError as soon as the session has moved
+// past it, since the caller's decision was based on the older state.
+func (s *SessionService) modifySession(ctx context.Context, sessionID primitive.ObjectID, expectedVersion int64, modify func(session *models.GameSession) (bson.M, error)) (*models.GameSession, error) {
+	for attempt := 0; attempt < maxSessionUpdateAttempts; attempt++ {
+		session, err := s.GetSession(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if expectedVersion != 0 && session.Version != expectedVersion {
+			return nil, &ConflictError{Session: session}
+		}
+
+		update, err := modify(session)
+		if err != nil {
+			return nil, err
+		}
+
+		applied, err := s.compareAndSwap(ctx, session, update)
+		if err != nil {
+			return nil, err
+		}
+		if applied {
+			return session, nil
+		}
+	}
+	return nil, s.conflict(ctx, sessionID)
+}
+
 // GetSessionsForCampaign retrieves all sessions for a campaign
 func (s *SessionService) GetSessionsForCampaign(ctx context.Context, campaignID primitive.ObjectID) ([]*models.GameSession, error) {
 	cursor, err := s.db.GetCollection("sessions").Find(ctx, bson.M{"campaign_id": campaignID})
@@ -146,8 +235,13 @@ func (s *SessionService) JoinSession(ctx context.Context, sessionID, userID, cha
 		JoinedAt:    time.Now(),
 	}
 
-	_, err = s.db.GetCollection("sessions").UpdateOne(ctx,
-		bson.M{"_id": sessionID},
+	// The duplicate check above is repeated in the filter so concurrent joins can't both push
+	result, err := s.updateSession(ctx,
+		bson.M{
+			"_id":             sessionID,
+			"status":          bson.M{"$in": []models.SessionStatus{models.SessionStatusPending, models.SessionStatusActive}},
+			"players.user_id": bson.M{"$ne": userID},
+		},
 		bson.M{
 			"$push": bson.M{"players": newPlayer},
 			"$set":  bson.M{"updated_at": time.Now()},
@@ -156,13 +250,16 @@ func (s *SessionService) JoinSession(ctx context.Context, sessionID, userID, cha
 	if err != nil {
 		return fmt.Errorf("failed to join session: %w", err)
 	}
+	if result.MatchedCount == 0 {
+		return errors.New("you are already in this session, or it is no longer accepting players")
+	}
 
 	return nil
 }
 
 // LeaveSession removes a player from a session
 func (s *SessionService) LeaveSession(ctx context.Context, sessionID, userID primitive.ObjectID) error {
-	_, err := s.db.GetCollection("sessions").UpdateOne(ctx,
+	_, err := s.updateSession(ctx,
 		bson.M{"_id": sessionID},
 		bson.M{
 			"$pull": bson.M{"players": bson.M{"user_id": userID}},
@@ -178,7 +275,7 @@ func (s *SessionService) LeaveSession(ctx context.Context, sessionID, userID pri
 // StartSession transitions a session from pending to active
 func (s *SessionService) StartSession(ctx context.Context, sessionID, dmUserID primitive.ObjectID) error {
 	now := time.Now()
-	result, err := s.db.GetCollection("sessions").UpdateOne(ctx,
+	result, err := s.updateSession(ctx,
 		bson.M{
 			"_id":       sessionID,
 			"dm_user_id": dmUserID,
@@ -203,121 +300,611 @@ func (s *SessionService) StartSession(ctx context.Context, sessionID, dmUserID p
 
 // SetInitiative sets or updates a character's initiative in the turn order
 func (s *SessionService) SetInitiative(ctx context.Context, sessionID, characterID primitive.ObjectID, initiative int) error {
-	session, err := s.GetSession(ctx, sessionID)
-	if err != nil {
-		return err
-	}
-
 	// Get character info
 	var character models.Character
-	err = s.db.GetCollection("characters").FindOne(ctx, bson.M{"_id": characterID}).Decode(&character)
+	err := s.db.GetCollection("characters").FindOne(ctx, bson.M{"_id": characterID}).Decode(&character)
 	if err != nil {
 		return fmt.Errorf("failed to get character: %w", err)
 	}
 
-	// Update or add turn entry
-	turnOrder := session.TurnOrder
-	found := false
-	for i, entry := range turnOrder {
-		if entry.CharacterID == characterID {
-			turnOrder[i].Initiative = initiative
-			turnOrder[i].HasActed = false
-			found = true
-			break
+	_, err = s.modifySession(ctx, sessionID, 0, func(session *models.GameSession) (bson.M, error) {
+		// Update or add turn entry
+		turnOrder := session.TurnOrder
+		found := false
+		for i, entry := range turnOrder {
+			if entry.CharacterID == characterID {
+				turnOrder[i].Initiative = initiative
+				turnOrder[i].HasActed = false
+				found = true
+				break
+			}
 		}
-	}
 
-	if !found {
-		newEntry := models.TurnEntry{
-			Type:        models.TurnTypePlayer,
-			UserID:      character.UserID,
-			CharacterID: characterID,
-			Initiative:  initiative,
-			Name:        character.Name,
-			HasActed:    false,
+		if !found {
+			newEntry := models.TurnEntry{
+				Type:        models.TurnTypePlayer,
+				UserID:      character.UserID,
+				CharacterID: characterID,
+				Initiative:  initiative,
+				Dexterity:   character.Abilities.Dexterity,
+				Name:        character.Name,
+				Speed:       character.Speed,
+				HasActed:    false,
+				Economy:     models.NewActionEconomy(character.Speed),
+			}
+			turnOrder = append(turnOrder, newEntry)
 		}
-		turnOrder = append(turnOrder, newEntry)
+
+		s.sortTurnOrder(turnOrder)
+		session.TurnOrder = turnOrder
+
+		return bson.M{
+			"$set": bson.M{
+				"turn_order": turnOrder,
+				"updated_at": time.Now(),
+			},
+		}, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update initiative: %w", err)
+	}
+
+	return nil
+}
+
+// RollInitiative rolls initiative for every player character in the session and every
+// living monster in the given encounter. With a player roll window, NPCs are rolled
+// immediately and players get until the deadline to roll their own; otherwise the
+// whole table is rolled and the turn order is set right away.
+func (s *SessionService) RollInitiative(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.RollInitiativeRequest) (*models.GameSession, error) {
+	session, err := s.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can roll initiative")
+	}
+
+	// A roll window whose deadline passed without being closed (e.g. the server
+	// restarted) is finished first, so it doesn't block rolling again. Its turn order
+	// isn't announced, as the roll below replaces it and is announced instead.
+	session, _, err = s.finishExpiredInitiative(ctx, session)
+	if err != nil {
+		return nil, err
 	}
+	if session.PendingInitiative != nil {
+		return nil, errors.New("initiative is already being rolled")
+	}
+
+	advantage := make(map[primitive.ObjectID]bool)
+	for _, id := range req.Advantage {
+		advantage[id] = true
+	}
+
+	// NPCs always roll immediately
+	rolled := []models.TurnEntry{}
+	if !req.EncounterID.IsZero() {
+		var encounter models.Encounter
+		err := s.db.GetCollection("encounters").FindOne(ctx, bson.M{
+			"_id":        req.EncounterID,
+			"session_id": sessionID,
+			"status":     bson.M{"$ne": models.EncounterStatusCompleted},
+		}).Decode(&encounter)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, errors.New("encounter not found or already ended")
+			}
+			return nil, fmt.Errorf("failed to get encounter: %w", err)
+		}
 
-	// Sort turn order by initiative (descending)
-	for i := 0; i < len(turnOrder)-1; i++ {
-		for j := 0; j < len(turnOrder)-i-1; j++ {
-			if turnOrder[j].Initiative < turnOrder[j+1].Initiative {
-				turnOrder[j], turnOrder[j+1] = turnOrder[j+1], turnOrder[j]
+		for _, combatant := range encounter.Combatants {
+			if combatant.CurrentHP <= 0 {
+				continue
+			}
+			roll := s.diceService.RollInitiativeWithAdvantage(abilityModifier(combatant.Abilities.Dexterity), advantage[combatant.ID])
+			entry := models.TurnEntry{
+				Type:        models.TurnTypeNPC,
+				CombatantID: combatant.ID,
+				Initiative:  roll.Total,
+				Dexterity:   combatant.Abilities.Dexterity,
+				Name:        combatant.Name,
+				Speed:       combatant.Speed,
+				Economy:     models.NewActionEconomy(combatant.Speed),
+			}
+			if monster, ok := data.Monsters[combatant.MonsterSlug]; ok {
+				entry.LegendaryActions = monster.LegendaryActionsPerRound
 			}
+			rolled = append(rolled, entry)
 		}
 	}
 
-	// Update session
-	_, err = s.db.GetCollection("sessions").UpdateOne(ctx,
-		bson.M{"_id": sessionID},
+	awaiting := make([]primitive.ObjectID, 0, len(session.Players))
+	for _, player := range session.Players {
+		awaiting = append(awaiting, player.CharacterID)
+	}
+
+	now := time.Now()
+	pending := &models.PendingInitiative{
+		StartedAt:   now,
+		Deadline:    now.Add(time.Duration(req.PlayerRollWindow) * time.Second),
+		EncounterID: req.EncounterID,
+		Advantage:   req.Advantage,
+		Rolled:      rolled,
+		Awaiting:    awaiting,
+	}
+	if pending.Advantage == nil {
+		pending.Advantage = []primitive.ObjectID{}
+	}
+
+	if req.PlayerRollWindow == 0 || len(awaiting) == 0 {
+		completed, err := s.completeInitiative(ctx, session, pending)
+		if err != nil {
+			return nil, err
+		}
+		if completed == nil {
+			return nil, errors.New("initiative is already being rolled")
+		}
+		if err := s.activateEncounter(ctx, req.EncounterID); err != nil {
+			return nil, err
+		}
+		return completed, nil
+	}
+
+	result, err := s.updateSession(ctx,
+		bson.M{"_id": sessionID, "pending_initiative": nil},
 		bson.M{
 			"$set": bson.M{
-				"turn_order": turnOrder,
-				"updated_at": time.Now(),
+				"pending_initiative": pending,
+				"updated_at":         now,
 			},
 		},
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update initiative: %w", err)
+		return nil, fmt.Errorf("failed to start initiative: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, errors.New("initiative is already being rolled")
+	}
+
+	// Only the caller that opened the roll window starts the fight
+	if err := s.activateEncounter(ctx, req.EncounterID); err != nil {
+		return nil, err
 	}
 
+	session.PendingInitiative = pending
+	return session, nil
+}
+
+// activateEncounter marks the encounter initiative was rolled for as in progress
+func (s *SessionService) activateEncounter(ctx context.Context, encounterID primitive.ObjectID) error {
+	if encounterID.IsZero() {
+		return nil
+	}
+	_, err := s.db.GetCollection("encounters").UpdateOne(ctx,
+		bson.M{"_id": encounterID, "status": bson.M{"$ne": models.EncounterStatusCompleted}},
+		bson.M{"$set": bson.M{"status": models.EncounterStatusActive, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to activate encounter: %w", err)
+	}
 	return nil
 }
 
-// AdvanceTurn moves to the next turn in the order
-func (s *SessionService) AdvanceTurn(ctx context.Context, sessionID, dmUserID primitive.ObjectID, force bool) error {
+// finishExpiredInitiative closes a roll window whose deadline has passed, in case the
+// timer meant to close it never ran: the server restarted, or another replica opened it.
+// It returns the session as it stands afterwards, and whether this call closed the
+// window, in which case the caller should announce the new turn order.
+func (s *SessionService) finishExpiredInitiative(ctx context.Context, session *models.GameSession) (*models.GameSession, bool, error) {
+	if session.PendingInitiative == nil || time.Now().Before(session.PendingInitiative.Deadline) {
+		return session, false, nil
+	}
+
+	finished, err := s.completeInitiative(ctx, session, session.PendingInitiative)
+	if err != nil {
+		return nil, false, err
+	}
+	if finished == nil {
+		// Someone else closed it first, and announces it
+		session, err = s.GetSession(ctx, session.ID)
+		return session, false, err
+	}
+	return finished, true, nil
+}
+
+// RollOwnInitiative lets a player roll initiative for their own character while the
+// roll window is open. The turn order is finalized once the last player has rolled,
+// in which case the returned session has no pending initiative. Rolling after the
+// deadline returns ErrInitiativeWindowClosed, along with the finalized session if this
+// call was what closed the window.
+func (s *SessionService) RollOwnInitiative(ctx context.Context, sessionID, userID primitive.ObjectID, req *models.RollOwnInitiativeRequest) (*models.DiceRoll, *models.GameSession, error) {
 	session, err := s.GetSession(ctx, sessionID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	// Verify DM permission
-	if session.DMUserID != dmUserID {
-		return errors.New("only the DM can advance turns")
+	pending := session.PendingInitiative
+	if pending == nil {
+		return nil, nil, errors.New("initiative is not being rolled")
+	}
+	if time.Now().After(pending.Deadline) {
+		finished, closed, err := s.finishExpiredInitiative(ctx, session)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !closed {
+			finished = nil
+		}
+		return nil, finished, ErrInitiativeWindowClosed
 	}
 
-	if len(session.TurnOrder) == 0 {
-		return errors.New("no turn order established")
+	var player *models.SessionPlayer
+	for i := range session.Players {
+		if session.Players[i].UserID == userID && session.Players[i].CharacterID == req.CharacterID {
+			player = &session.Players[i]
+			break
+		}
+	}
+	if player == nil {
+		return nil, nil, errors.New("character is not yours or not in this session")
 	}
 
-	// Mark current player as having acted (if not forced)
-	if !force && session.CurrentTurn < len(session.TurnOrder) {
-		session.TurnOrder[session.CurrentTurn].HasActed = true
+	var character models.Character
+	err = s.db.GetCollection("characters").FindOne(ctx, bson.M{"_id": req.CharacterID}).Decode(&character)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get character: %w", err)
 	}
 
-	// Advance to next turn
-	session.CurrentTurn++
-	if session.CurrentTurn >= len(session.TurnOrder) {
-		session.CurrentTurn = 0
-		session.Round++
-		// Reset HasActed for new round
-		for i := range session.TurnOrder {
-			session.TurnOrder[i].HasActed = false
+	advantage := req.Advantage
+	for _, id := range pending.Advantage {
+		if id == req.CharacterID {
+			advantage = true
 		}
 	}
 
-	// Update session
-	_, err = s.db.GetCollection("sessions").UpdateOne(ctx,
-		bson.M{"_id": sessionID},
+	roll := s.diceService.RollInitiativeWithAdvantage(abilityModifier(character.Abilities.Dexterity), advantage)
+	roll.CharacterID = req.CharacterID
+	entry := s.playerTurnEntry(*player, &character, roll.Total)
+
+	// Only succeeds while this character is still awaited, so a double submit can't roll twice
+	result, err := s.updateSession(ctx,
+		bson.M{
+			"_id":                           sessionID,
+			"pending_initiative.started_at": pending.StartedAt,
+			"pending_initiative.awaiting":   req.CharacterID,
+		},
+		bson.M{
+			"$pull": bson.M{"pending_initiative.awaiting": req.CharacterID},
+			"$push": bson.M{"pending_initiative.rolled": entry},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to record initiative: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, nil, errors.New("you have already rolled initiative")
+	}
+
+	session, err = s.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if session.PendingInitiative != nil && len(session.PendingInitiative.Awaiting) == 0 {
+		finalized, err := s.completeInitiative(ctx, session, session.PendingInitiative)
+		if err != nil {
+			return nil, nil, err
+		}
+		if finalized != nil {
+			session = finalized
+		}
+	}
+
+	return roll, session, nil
+}
+
+// FinalizeInitiative closes an open roll window, rolling for any players who didn't
+// roll their own. It returns nil if there was nothing left to finalize.
+func (s *SessionService) FinalizeInitiative(ctx context.Context, sessionID primitive.ObjectID) (*models.GameSession, error) {
+	session, err := s.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.PendingInitiative == nil {
+		return nil, nil
+	}
+
+	return s.completeInitiative(ctx, session, session.PendingInitiative)
+}
+
+// completeInitiative rolls for every awaited player, resolves ties and writes the final
+// turn order. It returns nil if another caller already completed the same roll.
+func (s *SessionService) completeInitiative(ctx context.Context, session *models.GameSession, pending *models.PendingInitiative) (*models.GameSession, error) {
+	turnOrder := append([]models.TurnEntry{}, pending.Rolled...)
+
+	if len(pending.Awaiting) > 0 {
+		var characters []models.Character
+		cursor, err := s.db.GetCollection("characters").Find(ctx, bson.M{"_id": bson.M{"$in": pending.Awaiting}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find characters: %w", err)
+		}
+		if err := cursor.All(ctx, &characters); err != nil {
+			return nil, fmt.Errorf("failed to decode characters: %w", err)
+		}
+
+		advantage := make(map[primitive.ObjectID]bool)
+		for _, id := range pending.Advantage {
+			advantage[id] = true
+		}
+
+		for i := range characters {
+			character := &characters[i]
+			for _, player := range session.Players {
+				if player.CharacterID != character.ID {
+					continue
+				}
+				roll := s.diceService.RollInitiativeWithAdvantage(abilityModifier(character.Abilities.Dexterity), advantage[character.ID])
+				turnOrder = append(turnOrder, s.playerTurnEntry(player, character, roll.Total))
+			}
+		}
+	}
+
+	s.breakInitiativeTies(turnOrder)
+	s.sortTurnOrder(turnOrder)
+
+	filter := bson.M{"_id": session.ID, "pending_initiative": nil}
+	if session.PendingInitiative != nil {
+		filter = bson.M{"_id": session.ID, "pending_initiative.started_at": session.PendingInitiative.StartedAt}
+	}
+
+	result, err := s.updateSession(ctx,
+		filter,
 		bson.M{
 			"$set": bson.M{
-				"turn_order":   session.TurnOrder,
-				"current_turn": session.CurrentTurn,
-				"round":        session.Round,
+				"turn_order":   turnOrder,
+				"current_turn": 0,
+				"round":        1,
 				"updated_at":   time.Now(),
 			},
+			"$unset": bson.M{"pending_initiative": ""},
 		},
 	)
 	if err != nil {
-		return fmt.Errorf("failed to advance turn: %w", err)
+		return nil, fmt.Errorf("failed to set turn order: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, nil
 	}
 
-	return nil
+	session.TurnOrder = turnOrder
+	session.CurrentTurn = 0
+	session.Round = 1
+	session.PendingInitiative = nil
+	return session, nil
+}
+
+// playerTurnEntry builds the turn entry for a player's character
+func (s *SessionService) playerTurnEntry(player models.SessionPlayer, character *models.Character, initiative int) models.TurnEntry {
+	return models.TurnEntry{
+		Type:        models.TurnTypePlayer,
+		UserID:      player.UserID,
+		CharacterID: character.ID,
+		Initiative:  initiative,
+		Dexterity:   character.Abilities.Dexterity,
+		Name:        character.Name,
+		Speed:       character.Speed,
+		Economy:     models.NewActionEconomy(character.Speed),
+	}
+}
+
+// breakInitiativeTies assigns roll-off values to entries tied on both initiative and
+// Dexterity, re-rolling until every tied entry has a distinct result
+func (s *SessionService) breakInitiativeTies(turnOrder []models.TurnEntry) {
+	type tieKey struct{ initiative, dexterity int }
+	groups := make(map[tieKey][]int)
+	for i, entry := range turnOrder {
+		key := tieKey{entry.Initiative, entry.Dexterity}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, indexes := range groups {
+		if len(indexes) < 2 {
+			continue
+		}
+		for {
+			seen := make(map[int]bool)
+			distinct := true
+			for _, i := range indexes {
+				turnOrder[i].TieBreaker = s.diceService.RollD20()
+				if seen[turnOrder[i].TieBreaker] {
+					distinct = false
+				}
+				seen[turnOrder[i].TieBreaker] = true
+			}
+			if distinct || len(indexes) > 20 {
+				break
+			}
+		}
+	}
+}
+
+// sortTurnOrder orders entries by initiative, then Dexterity score, then roll-off (all descending)
+func (s *SessionService) sortTurnOrder(turnOrder []models.TurnEntry) {
+	sort.SliceStable(turnOrder, func(i, j int) bool {
+		if turnOrder[i].Initiative != turnOrder[j].Initiative {
+			return turnOrder[i].Initiative > turnOrder[j].Initiative
+		}
+		if turnOrder[i].Dexterity != turnOrder[j].Dexterity {
+			return turnOrder[i].Dexterity > turnOrder[j].Dexterity
+		}
+		return turnOrder[i].TieBreaker > turnOrder[j].TieBreaker
+	})
+}
+
+// AdvanceTurn moves to the next turn in the order. With an expected version, the turn
+// only advances if nobody changed the session since the DM saw it, so a double click
+// can't skip a combatant.
+func (s *SessionService) AdvanceTurn(ctx context.Context, sessionID, dmUserID primitive.ObjectID, force bool, expectedVersion int64) (*models.GameSession, error) {
+	return s.modifySession(ctx, sessionID, expectedVersion, func(session *models.GameSession) (bson.M, error) {
+		// Verify DM permission
+		if session.DMUserID != dmUserID {
+			return nil, errors.New("only the DM can advance turns")
+		}
+
+		if len(session.TurnOrder) == 0 {
+			return nil, errors.New("no turn order established")
+		}
+
+		// Mark current player as having acted (if not forced)
+		if !force && session.CurrentTurn < len(session.TurnOrder) {
+			session.TurnOrder[session.CurrentTurn].HasActed = true
+		}
+
+		// Advance to next turn
+		session.CurrentTurn++
+		if session.CurrentTurn >= len(session.TurnOrder) {
+			session.CurrentTurn = 0
+			session.Round++
+			// Reset HasActed for new round
+			for i := range session.TurnOrder {
+				session.TurnOrder[i].HasActed = false
+			}
+		}
+
+		startTurn(&session.TurnOrder[session.CurrentTurn])
+
+		return bson.M{
+			"$set": bson.M{
+				"turn_order":   session.TurnOrder,
+				"current_turn": session.CurrentTurn,
+				"round":        session.Round,
+				"updated_at":   time.Now(),
+			},
+		}, nil
+	})
+}
+
+// startTurn refreshes the incoming combatant's action, bonus action, movement, reaction and
+// legendary actions. A readied action lapses, and a combatant who delayed into this slot
+// has already spent this round's reaction on delaying.
+func startTurn(entry *models.TurnEntry) {
+	reactionUsed := entry.Delayed
+	entry.Economy = models.NewActionEconomy(entry.Speed)
+	entry.Economy.ReactionUsed = reactionUsed
+	entry.Delayed = false
+	entry.Readied = nil
+}
+
+// AddLairActions inserts a lair action entry at initiative 20, after every combatant
+// tied with it, so the DM gets a lair turn each round
+func (s *SessionService) AddLairActions(ctx context.Context, sessionID, dmUserID primitive.ObjectID, name string) (*models.GameSession, error) {
+	if name == "" {
+		name = "Lair Actions"
+	}
+
+	return s.modifySession(ctx, sessionID, 0, func(session *models.GameSession) (bson.M, error) {
+		if session.DMUserID != dmUserID {
+			return nil, errors.New("only the DM can add lair actions")
+		}
+
+		if len(session.TurnOrder) == 0 {
+			return nil, errors.New("no turn order established")
+		}
+
+		for _, entry := range session.TurnOrder {
+			if entry.Type == models.TurnTypeLair {
+				return nil, errors.New("turn order already has lair actions")
+			}
+		}
+
+		position := len(session.TurnOrder)
+		for i, entry := range session.TurnOrder {
+			if entry.Initiative < 20 {
+				position = i
+				break
+			}
+		}
+
+		lair := models.TurnEntry{
+			Type:       models.TurnTypeLair,
+			Initiative: 20,
+			Name:       name,
+		}
+		turnOrder := make([]models.TurnEntry, 0, len(session.TurnOrder)+1)
+		turnOrder = append(turnOrder, session.TurnOrder[:position]...)
+		turnOrder = append(turnOrder, lair)
+		turnOrder = append(turnOrder, session.TurnOrder[position:]...)
+
+		// Keep pointing at the same combatant
+		if position <= session.CurrentTurn {
+			session.CurrentTurn++
+		}
+		session.TurnOrder = turnOrder
+
+		return bson.M{
+			"$set": bson.M{
+				"turn_order":   turnOrder,
+				"current_turn": session.CurrentTurn,
+				"updated_at":   time.Now(),
+			},
+		}, nil
+	})
+}
+
+// AddTurnEntry inserts an entry into a running turn order after every entry with the same
+// or higher initiative, so a combatant joining mid-fight doesn't jump the queue
+func (s *SessionService) AddTurnEntry(ctx context.Context, sessionID, dmUserID primitive.ObjectID, entry models.TurnEntry) (*models.GameSession, error) {
+	return s.modifySession(ctx, sessionID, 0, func(session *models.GameSession) (bson.M, error) {
+		if session.DMUserID != dmUserID {
+			return nil, errors.New("only the DM can add to the turn order")
+		}
+
+		if len(session.TurnOrder) == 0 {
+			return nil, errors.New("no turn order established")
+		}
+
+		for _, existing := range session.TurnOrder {
+			if !entry.CombatantID.IsZero() && existing.CombatantID == entry.CombatantID {
+				return nil, errors.New("combatant is already in the turn order")
+			}
+		}
+
+		position := len(session.TurnOrder)
+		for i, existing := range session.TurnOrder {
+			if existing.Initiative < entry.Initiative {
+				position = i
+				break
+			}
+		}
+
+		entry.Economy = models.NewActionEconomy(entry.Speed)
+		turnOrder := make([]models.TurnEntry, 0, len(session.TurnOrder)+1)
+		turnOrder = append(turnOrder, session.TurnOrder[:position]...)
+		turnOrder = append(turnOrder, entry)
+		turnOrder = append(turnOrder, session.TurnOrder[position:]...)
+
+		// Keep pointing at the same combatant
+		if position <= session.CurrentTurn {
+			session.CurrentTurn++
+		}
+		session.TurnOrder = turnOrder
+
+		return bson.M{
+			"$set": bson.M{
+				"turn_order":   turnOrder,
+				"current_turn": session.CurrentTurn,
+				"updated_at":   time.Now(),
+			},
+		}, nil
+	})
 }
 
 // UpdatePlayerConnection updates a player's connection status
 func (s *SessionService) UpdatePlayerConnection(ctx context.Context, sessionID, userID primitive.ObjectID, isConnected bool) error {
+	// Presence isn't game state, so it doesn't bump the version: players coming and going
+	// mustn't invalidate the DM's pending compare-and-swap updates
 	_, err := s.db.GetCollection("sessions").UpdateOne(ctx,
 		bson.M{
 			"_id":              sessionID,
@@ -336,24 +923,163 @@ func (s *SessionService) UpdatePlayerConnection(ctx context.Context, sessionID,
 	return nil
 }
 
-// AddChatMessage adds a chat message to the session history
-func (s *SessionService) AddChatMessage(ctx context.Context, sessionID primitive.ObjectID, msg models.SessionChatMsg) error {
-	_, err := s.db.GetCollection("sessions").UpdateOne(ctx,
-		bson.M{"_id": sessionID},
+// StartReadyCheck asks every player in the session whether they are ready, until they
+// have all answered or the timeout passes. Only the DM may start one, and not while
+// another is still open.
+func (s *SessionService) StartReadyCheck(ctx context.Context, sessionID, dmUserID primitive.ObjectID, prompt string, timeout time.Duration) (*models.ReadyCheck, error) {
+	session, err := s.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.DMUserID != dmUserID {
+		return nil, errors.New("only the DM can start a ready check")
+	}
+
+	awaiting := make([]primitive.ObjectID, 0, len(session.Players))
+	seen := make(map[primitive.ObjectID]bool)
+	for _, player := range session.Players {
+		if !seen[player.UserID] {
+			seen[player.UserID] = true
+			awaiting = append(awaiting, player.UserID)
+		}
+	}
+	if len(awaiting) == 0 {
+		return nil, errors.New("there are no players to check")
+	}
+
+	// MongoDB keeps milliseconds, so truncate for started_at to match when filtering on it
+	now := time.Now().Truncate(time.Millisecond)
+	check := &models.ReadyCheck{
+		StartedAt: now,
+		Deadline:  now.Add(timeout),
+		Prompt:    prompt,
+		Ready:     []primitive.ObjectID{},
+		NotReady:  []primitive.ObjectID{},
+		Awaiting:  awaiting,
+	}
+
+	// A check whose deadline passed without being finished (e.g. the server restarted)
+	// doesn't block a new one
+	result, err := s.updateSession(ctx,
+		bson.M{
+			"_id":        sessionID,
+			"dm_user_id": dmUserID,
+			"$or": bson.A{
+				bson.M{"ready_check": nil},
+				bson.M{"ready_check.completed_at": bson.M{"$exists": true}},
+				bson.M{"ready_check.deadline": bson.M{"$lt": now}},
+			},
+		},
 		bson.M{
-			"$push": bson.M{"chat_history": msg},
+			"$set": bson.M{
+				"ready_check": check,
+				"updated_at":  now,
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ready check: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, errors.New("a ready check is already open")
+	}
+	return check, nil
+}
+
+// RespondReadyCheck records a player's answer to the open ready check. The check finishes
+// once every player has answered.
+func (s *SessionService) RespondReadyCheck(ctx context.Context, sessionID, userID primitive.ObjectID, ready bool) (*models.ReadyCheck, error) {
+	answers := "ready_check.not_ready"
+	if ready {
+		answers = "ready_check.ready"
+	}
+
+	// Only succeeds while this user is still awaited, so nobody can answer twice
+	result, err := s.updateSession(ctx,
+		bson.M{
+			"_id":                      sessionID,
+			"ready_check.awaiting":     userID,
+			"ready_check.completed_at": bson.M{"$exists": false},
+			"ready_check.deadline":     bson.M{"$gt": time.Now()},
+		},
+		bson.M{
+			"$pull": bson.M{"ready_check.awaiting": userID},
+			"$push": bson.M{answers: userID},
 			"$set":  bson.M{"updated_at": time.Now()},
 		},
 	)
 	if err != nil {
-		return fmt.Errorf("failed to add chat message: %w", err)
+		return nil, fmt.Errorf("failed to record ready check answer: %w", err)
 	}
-	return nil
+	if result.MatchedCount == 0 {
+		return nil, errors.New("no open ready check is waiting for your answer")
+	}
+
+	session, err := s.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	check := session.ReadyCheck
+	if check != nil && check.IsOpen() && len(check.Awaiting) == 0 {
+		finished, err := s.completeReadyCheck(ctx, sessionID, check)
+		if err != nil {
+			return nil, err
+		}
+		if finished != nil {
+			check = finished
+		}
+	}
+	return check, nil
+}
+
+// FinishReadyCheck closes the ready check started at startedAt once its deadline passes.
+// Players who haven't answered stay in Awaiting. It returns nil if that check already
+// finished.
+func (s *SessionService) FinishReadyCheck(ctx context.Context, sessionID primitive.ObjectID, startedAt time.Time) (*models.ReadyCheck, error) {
+	session, err := s.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	check := session.ReadyCheck
+	if check == nil || !check.IsOpen() || !check.StartedAt.Equal(startedAt) {
+		return nil, nil
+	}
+	return s.completeReadyCheck(ctx, sessionID, check)
+}
+
+// completeReadyCheck marks a ready check finished. It returns nil if another caller
+// already finished it.
+func (s *SessionService) completeReadyCheck(ctx context.Context, sessionID primitive.ObjectID, check *models.ReadyCheck) (*models.ReadyCheck, error) {
+	now := time.Now()
+	result, err := s.updateSession(ctx,
+		bson.M{
+			"_id":                      sessionID,
+			"ready_check.started_at":   check.StartedAt,
+			"ready_check.completed_at": bson.M{"$exists": false},
+		},
+		bson.M{
+			"$set": bson.M{
+				"ready_check.completed_at": now,
+				"updated_at":               now,
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish ready check: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, nil
+	}
+
+	finished := *check
+	finished.CompletedAt = &now
+	return &finished, nil
 }
 
 // UpdateScene updates the current scene description and optional notes
 func (s *SessionService) UpdateScene(ctx context.Context, sessionID, dmUserID primitive.ObjectID, scene, notes string) error {
-	result, err := s.db.GetCollection("sessions").UpdateOne(ctx,
+	result, err := s.updateSession(ctx,
 		bson.M{
 			"_id":        sessionID,
 			"dm_user_id": dmUserID,
@@ -382,7 +1108,7 @@ func (s *SessionService) EndSession(ctx context.Context, sessionID, dmUserID pri
 	}
 
 	now := time.Now()
-	result, err := s.db.GetCollection("sessions").UpdateOne(ctx,
+	result, err := s.updateSession(ctx,
 		bson.M{
 			"_id":        sessionID,
 			"dm_user_id": dmUserID,
@@ -413,7 +1139,7 @@ func (s *SessionService) EndSession(ctx context.Context, sessionID, dmUserID pri
 
 // PauseSession pauses an active session
 func (s *SessionService) PauseSession(ctx context.Context, sessionID, dmUserID primitive.ObjectID) error {
-	result, err := s.db.GetCollection("sessions").UpdateOne(ctx,
+	result, err := s.updateSession(ctx,
 		bson.M{
 			"_id":        sessionID,
 			"dm_user_id": dmUserID,
@@ -437,7 +1163,7 @@ func (s *SessionService) PauseSession(ctx context.Context, sessionID, dmUserID p
 
 // ResumeSession resumes a paused session
 func (s *SessionService) ResumeSession(ctx context.Context, sessionID, dmUserID primitive.ObjectID) error {
-	result, err := s.db.GetCollection("sessions").UpdateOne(ctx,
+	result, err := s.updateSession(ctx,
 		bson.M{
 			"_id":        sessionID,
 			"dm_user_id": dmUserID,
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"dnd-simulator/internal/models"
+)
+
+// Players connecting and disconnecting don't invalidate the DM's expected version
+func TestUpdatePlayerConnectionKeepsVersion(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	before := f.session(t)
+
+	for _, connected := range []bool{true, false, true} {
+		if err := f.sessions.UpdatePlayerConnection(ctx, f.sessionID, f.playerID, connected); err != nil {
+			t.Fatalf("update connection: %v", err)
+		}
+	}
+
+	after := f.session(t)
+	if after.Version != before.Version {
+		t.Errorf("version went from %d to %d on presence changes", before.Version, after.Version)
+	}
+	if !after.Players[0].IsConnected {
+		t.Error("player is not marked connected")
+	}
+
+	if _, err := f.sessions.AdvanceTurn(ctx, f.sessionID, f.dmID, false, before.Version); err != nil {
+		t.Errorf("advance with the version read before the presence changes: %v", err)
+	}
+}
+
+// Acting after an initiative window's deadline closes it, and reports that only once so
+// the new turn order is announced once
+func TestGetTurnFinishesExpiredInitiative(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	session := f.session(t)
+
+	pending := models.PendingInitiative{
+		StartedAt: time.Now().Add(-time.Minute),
+		Deadline:  time.Now().Add(-time.Second),
+		Rolled:    session.TurnOrder,
+	}
+	_, err := f.sessions.db.GetCollection("sessions").UpdateOne(ctx,
+		bson.M{"_id": f.sessionID},
+		bson.M{"$set": bson.M{"pending_initiative": pending, "turn_order": []models.TurnEntry{}}},
+	)
+	if err != nil {
+		t.Fatalf("open initiative window: %v", err)
+	}
+
+	turn, err := f.combat.GetTurn(ctx, f.sessionID, f.dmID, models.CombatActor{})
+	if err != nil {
+		t.Fatalf("get turn: %v", err)
+	}
+	if !turn.InitiativeFinished || turn.Session.PendingInitiative != nil || len(turn.Session.TurnOrder) != 3 {
+		t.Errorf("got finished %v with %d entries, want the expired window closed", turn.InitiativeFinished, len(turn.Session.TurnOrder))
+	}
+
+	turn, err = f.combat.GetTurn(ctx, f.sessionID, f.dmID, models.CombatActor{})
+	if err != nil {
+		t.Fatalf("get turn again: %v", err)
+	}
+	if turn.InitiativeFinished {
+		t.Error("reported the window closed a second time")
+	}
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/database"
+	"dnd-simulator/internal/models"
+)
+
+const (
+	// spectatorSnapshotInterval is how often the state spectators see is recorded while
+	// someone is watching. What they are shown lags by their delay plus up to this much.
+	spectatorSnapshotInterval = 5 * time.Second
+
+	// spectatorSnapshotTTL keeps snapshots for longer than the longest spectator delay
+	spectatorSnapshotTTL = 15 * time.Minute
+)
+
+// ErrSpectatorViewNotReady means nothing was recorded long enough ago to show a delayed
+// spectator yet
+var ErrSpectatorViewNotReady = errors.New("the spectator view isn't available yet, as it runs behind the game")
+
+// SpectatorService issues the links spectators use to watch a session and builds the
+// stream overlay and map they can read
+type SpectatorService struct {
+	db             *database.DB
+	sessionService *SessionService
+	mapService     *MapService
+	eventService   *EventService
+}
+
+// NewSpectatorService creates a new spectator service instance
+func NewSpectatorService(db *database.DB, sessionService *SessionService, mapService *MapService, eventService *EventService) *SpectatorService {
+	return &SpectatorService{
+		db:             db,
+		sessionService: sessionService,
+		mapService:     mapService,
+		eventService:   eventService,
+	}
+}
+
+// spectatorSnapshot is a session's overlay and public map as they were at one moment
+type spectatorSnapshot struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty"`
+	SessionID primitive.ObjectID     `bson:"session_id"`
+	TakenAt   time.Time              `bson:"taken_at"`
+	Overlay   *models.SessionOverlay `bson:"overlay"`
+	Map       *models.MapView        `bson:"map,omitempty"` // Nil when the session has no battle map
+}
+
+// EnsureIndexes creates the token lookup index and expires links once they run out
+func (s *SpectatorService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.db.GetCollection("spectator_links").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "session_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create spectator link indexes: %w", err)
+	}
+
+	_, err = s.db.GetCollection("spectator_snapshots").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "taken_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "taken_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(spectatorSnapshotTTL.Seconds())),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create spectator snapshot indexes: %w", err)
+	}
+	return nil
+}
+
+// hashSpectatorToken is how tokens are stored, so a leaked database doesn't leak links
+func hashSpectatorToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateLink issues a spectator link for a session (DM only). The token is only returned
+// here; afterwards just its hash is kept.
+func (s *SpectatorService) CreateLink(ctx context.Context, sessionID, dmUserID primitive.ObjectID, req *models.CreateSpectatorLinkRequest) (*models.SpectatorLink, string, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if session.DMUserID != dmUserID {
+		return nil, "", errors.New("only the DM can create spectator links")
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("failed to generate spectator token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	now := time.Now()
+	link := &models.SpectatorLink{
+		ID:           primitive.NewObjectID(),
+		SessionID:    sessionID,
+		TokenHash:    hashSpectatorToken(token),
+		Label:        req.Label,
+		DelaySeconds: req.DelaySeconds,
+		CreatedBy:    dmUserID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(time.Duration(req.ExpiresIn) * time.Second),
+	}
+	if _, err := s.db.GetCollection("spectator_links").InsertOne(ctx, link); err != nil {
+		return nil, "", fmt.Errorf("failed to create spectator link: %w", err)
+	}
+	return link, token, nil
+}
+
+// ListLinks returns a session's spectator links that are still usable, newest first
+func (s *SpectatorService) ListLinks(ctx context.Context, sessionID primitive.ObjectID) ([]models.SpectatorLink, error) {
+	cursor, err := s.db.GetCollection("spectator_links").Find(ctx, bson.M{
+		"session_id": sessionID,
+		"expires_at": bson.M{"$gt": time.Now()},
+		"revoked_at": bson.M{"$exists": false},
+	}, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spectator links: %w", err)
+	}
+
+	links := []models.SpectatorLink{}
+	if err := cursor.All(ctx, &links); err != nil {
+		return nil, fmt.Errorf("failed to decode spectator links: %w", err)
+	}
+	return links, nil
+}
+
+// RevokeLink stops a spectator link from working (DM only)
+func (s *SpectatorService) RevokeLink(ctx context.Context, sessionID, dmUserID, linkID primitive.ObjectID) error {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.DMUserID != dmUserID {
+		return errors.New("only the DM can revoke spectator links")
+	}
+
+	result, err := s.db.GetCollection("spectator_links").UpdateOne(ctx,
+		bson.M{
+			"_id":        linkID,
+			"session_id": sessionID,
+			"revoked_at": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke spectator link: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("spectator link not found")
+	}
+	return nil
+}
+
+// Authenticate returns the link a spectator token belongs to, if it is for the session and
+// hasn't expired or been revoked
+func (s *SpectatorService) Authenticate(ctx context.Context, sessionID primitive.ObjectID, token string) (*models.SpectatorLink, error) {
+	var link models.SpectatorLink
+	err := s.db.GetCollection("spectator_links").FindOne(ctx, bson.M{
+		"token_hash": hashSpectatorToken(token),
+		"session_id": sessionID,
+		"expires_at": bson.M{"$gt": time.Now()},
+		"revoked_at": bson.M{"$exists": false},
+	}).Decode(&link)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("invalid or expired spectator link")
+		}
+		return nil, fmt.Errorf("failed to check spectator link: %w", err)
+	}
+	return &link, nil
+}
+
+// GetOverlay returns a session's overlay state as it was at least the link's delay ago:
+// the turn order, the party's health and the latest public rolls
+func (s *SpectatorService) GetOverlay(ctx context.Context, link *models.SpectatorLink, rollLimit int) (*models.SessionOverlay, error) {
+	snapshot, err := s.delayedSnapshot(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	rolls, err := s.eventService.RecentRolls(ctx, link.SessionID, time.Now().Add(-link.Delay()), rollLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay := snapshot.Overlay
+	overlay.RecentRolls = rolls
+	overlay.DelaySeconds = link.DelaySeconds
+	return overlay, nil
+}
+
+// GetMap returns the parts of the battle map the DM had revealed at least the link's
+// delay ago
+func (s *SpectatorService) GetMap(ctx context.Context, link *models.SpectatorLink) (*models.MapView, error) {
+	snapshot, err := s.delayedSnapshot(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Map == nil {
+		return nil, errors.New("session has no battle map")
+	}
+	return snapshot.Map, nil
+}
+
+// delayedSnapshot returns the latest snapshot taken at least the link's delay ago, first
+// recording the current state if the last snapshot is getting old. Snapshots are only
+// taken while someone is watching, so a new link shows nothing until its delay has passed.
+func (s *SpectatorService) delayedSnapshot(ctx context.Context, link *models.SpectatorLink) (*spectatorSnapshot, error) {
+	now := time.Now()
+	if link.Delay() <= 0 {
+		return s.takeSnapshot(ctx, link.SessionID, now)
+	}
+
+	snapshots := s.db.GetCollection("spectator_snapshots")
+	recent, err := snapshots.CountDocuments(ctx, bson.M{
+		"session_id": link.SessionID,
+		"taken_at":   bson.M{"$gt": now.Add(-spectatorSnapshotInterval)},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check spectator snapshots: %w", err)
+	}
+	if recent == 0 {
+		snapshot, err := s.takeSnapshot(ctx, link.SessionID, now)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := snapshots.InsertOne(ctx, snapshot); err != nil {
+			return nil, fmt.Errorf("failed to store spectator snapshot: %w", err)
+		}
+	}
+
+	var snapshot spectatorSnapshot
+	err = snapshots.FindOne(ctx, bson.M{
+		"session_id": link.SessionID,
+		"taken_at":   bson.M{"$lte": now.Add(-link.Delay())},
+	}, options.FindOne().SetSort(bson.D{{Key: "taken_at", Value: -1}})).Decode(&snapshot)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSpectatorViewNotReady
+		}
+		return nil, fmt.Errorf("failed to get spectator snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// takeSnapshot captures a session's overlay and public map as they are now
+func (s *SpectatorService) takeSnapshot(ctx context.Context, sessionID primitive.ObjectID, now time.Time) (*spectatorSnapshot, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay, err := s.buildOverlay(ctx, session, now)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &spectatorSnapshot{
+		SessionID: sessionID,
+		TakenAt:   now,
+		Overlay:   overlay,
+	}
+	if session.Map != nil {
+		views, err := s.mapService.MapViews(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Map = views.For(primitive.NilObjectID)
+	}
+	return snapshot, nil
+}
+
+// buildOverlay builds a session's overlay state: turn order and the party's health. Recent
+// rolls are filled in by the caller.
+func (s *SpectatorService) buildOverlay(ctx context.Context, session *models.GameSession, now time.Time) (*models.SessionOverlay, error) {
+	overlay := &models.SessionOverlay{
+		SessionID:   session.ID,
+		Name:        session.Name,
+		Status:      session.Status,
+		Scene:       session.Scene,
+		Round:       session.Round,
+		TurnOrder:   []models.OverlayTurn{},
+		Party:       []models.OverlayCharacter{},
+		RecentRolls: []models.OverlayRoll{},
+		GeneratedAt: now,
+	}
+
+	for i, entry := range session.TurnOrder {
+		turn := models.OverlayTurn{
+			Name:       entry.Name,
+			Type:       entry.Type,
+			Initiative: entry.Initiative,
+			Current:    i == session.CurrentTurn,
+		}
+		overlay.TurnOrder = append(overlay.TurnOrder, turn)
+		if turn.Current {
+			overlay.CurrentTurn = &turn
+		}
+	}
+
+	characterIDs := make([]primitive.ObjectID, 0, len(session.Players))
+	for _, player := range session.Players {
+		characterIDs = append(characterIDs, player.CharacterID)
+	}
+	if len(characterIDs) == 0 {
+		return overlay, nil
+	}
+
+	cursor, err := s.db.GetCollection("characters").Find(ctx, bson.M{"_id": bson.M{"$in": characterIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get characters: %w", err)
+	}
+	var characters []models.Character
+	if err := cursor.All(ctx, &characters); err != nil {
+		return nil, fmt.Errorf("failed to decode characters: %w", err)
+	}
+	byID := make(map[primitive.ObjectID]*models.Character, len(characters))
+	for i := range characters {
+		byID[characters[i].ID] = &characters[i]
+	}
+
+	// In the order players joined, so overlays don't reshuffle
+	for _, player := range session.Players {
+		character, ok := byID[player.CharacterID]
+		if !ok {
+			continue
+		}
+		overlay.Party = append(overlay.Party, models.OverlayCharacter{
+			CharacterID: character.ID,
+			Name:        character.Name,
+			PlayerName:  player.Username,
+			Race:        character.Race,
+			Class:       character.Class,
+			Level:       character.Level,
+			CurrentHP:   character.CurrentHP,
+			MaxHP:       character.MaxHP,
+			ArmorClass:  character.ArmorClass,
+			Connected:   player.IsConnected,
+		})
+	}
+	return overlay, nil
+}
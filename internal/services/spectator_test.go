@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"dnd-simulator/internal/models"
+)
+
+// The overlay shows nothing newer than the link's delay, turn order and rolls alike
+func TestSpectatorOverlayRunsBehind(t *testing.T) {
+	f := newCombatFixture(t)
+	ctx := context.Background()
+	db := f.sessions.db
+	events := NewEventService(db)
+	spectators := NewSpectatorService(db, f.sessions, NewMapService(db, f.sessions, f.combat), events)
+	link := &models.SpectatorLink{SessionID: f.sessionID, DelaySeconds: 1}
+
+	if _, err := spectators.GetOverlay(ctx, link, 10); !errors.Is(err, ErrSpectatorViewNotReady) {
+		t.Fatalf("first overlay: got %v, want ErrSpectatorViewNotReady", err)
+	}
+
+	// The turn moves on and a roll is made just after the first snapshot
+	if _, err := f.sessions.AdvanceTurn(ctx, f.sessionID, f.dmID, false, 0); err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	if err := events.StoreRoll(ctx, f.sessionID, &models.OverlayRoll{Username: "player", Dice: "1d20", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("store roll: %v", err)
+	}
+	time.Sleep(link.Delay())
+
+	overlay, err := spectators.GetOverlay(ctx, link, 10)
+	if err != nil {
+		t.Fatalf("overlay: %v", err)
+	}
+	if overlay.CurrentTurn == nil || overlay.CurrentTurn.Name != "Hero" {
+		t.Errorf("current turn is %+v, want Hero's from before the advance", overlay.CurrentTurn)
+	}
+
+	if len(overlay.RecentRolls) != 1 {
+		t.Errorf("got %d rolls once the delay passed, want 1", len(overlay.RecentRolls))
+	}
+
+	// A roll made just now stays held back
+	if err := events.StoreRoll(ctx, f.sessionID, &models.OverlayRoll{Username: "player", Dice: "1d6", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("store roll: %v", err)
+	}
+	overlay, err = spectators.GetOverlay(ctx, link, 10)
+	if err != nil {
+		t.Fatalf("overlay: %v", err)
+	}
+	if len(overlay.RecentRolls) != 1 || overlay.RecentRolls[0].Dice != "1d20" {
+		t.Errorf("got rolls %+v, want only the 1d20", overlay.RecentRolls)
+	}
+}
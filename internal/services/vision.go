@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/data"
+	"dnd-simulator/internal/models"
+)
+
+// DarkvisionFeet is the darkvision range granted by the Darkvision racial trait
+const DarkvisionFeet = 60
+
+// darkvisionForRace returns a race's darkvision range in feet, 0 without the trait
+func darkvisionForRace(race string) int {
+	for _, trait := range data.Races[race].Traits {
+		if trait == "Darkvision" {
+			return DarkvisionFeet
+		}
+	}
+	return 0
+}
+
+// lineOfSight walks the grid line between two cell centres and reports whether any
+// cell strictly between them is a wall. Walls themselves can be seen, not seen past.
+func lineOfSight(walls map[models.GridPoint]bool, from, to models.GridPoint) bool {
+	dx := abs(to.X - from.X)
+	dy := -abs(to.Y - from.Y)
+	sx, sy := 1, 1
+	if from.X > to.X {
+		sx = -1
+	}
+	if from.Y > to.Y {
+		sy = -1
+	}
+
+	x, y := from.X, from.Y
+	e := dx + dy
+	for {
+		point := models.GridPoint{X: x, Y: y}
+		if point == to {
+			return true
+		}
+		if point != from && walls[point] {
+			return false
+		}
+
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y += sy
+		}
+	}
+}
+
+// gridDistance returns the distance between two cells in feet, counting diagonals as one cell
+func gridDistance(battleMap *models.BattleMap, a, b models.GridPoint) int {
+	cells := abs(a.X - b.X)
+	if dy := abs(a.Y - b.Y); dy > cells {
+		cells = dy
+	}
+	return cells * battleMap.CellFeet
+}
+
+// wallSet returns the cells of a map that block sight
+func wallSet(battleMap *models.BattleMap) map[models.GridPoint]bool {
+	walls := make(map[models.GridPoint]bool)
+	for _, cell := range battleMap.Terrain {
+		if cell.Type == models.TerrainWall {
+			walls[models.GridPoint{X: cell.X, Y: cell.Y}] = true
+		}
+	}
+	return walls
+}
+
+// lightAt returns how brightly a cell is lit by ambient light and any light source in sight of it
+func lightAt(battleMap *models.BattleMap, walls map[models.GridPoint]bool, cell models.GridPoint) models.LightLevel {
+	level := battleMap.Lighting
+	if level == "" || level == models.LightBright {
+		return models.LightBright
+	}
+
+	for _, light := range battleMap.Lights {
+		source := models.GridPoint{X: light.X, Y: light.Y}
+		distance := gridDistance(battleMap, source, cell)
+		if distance > light.Bright+light.Dim || !lineOfSight(walls, source, cell) {
+			continue
+		}
+		if distance <= light.Bright {
+			return models.LightBright
+		}
+		level = models.LightDim
+	}
+	return level
+}
+
+// visibleCells returns the cells a viewer at origin can see: those in line of sight
+// that are lit, or dark but within darkvision range
+func visibleCells(battleMap *models.BattleMap, walls map[models.GridPoint]bool, origin models.GridPoint, darkvision int, visible map[models.GridPoint]bool) {
+	for x := 0; x < battleMap.Width; x++ {
+		for y := 0; y < battleMap.Height; y++ {
+			cell := models.GridPoint{X: x, Y: y}
+			if visible[cell] || !lineOfSight(walls, origin, cell) {
+				continue
+			}
+			if lightAt(battleMap, walls, cell) == models.LightDark && gridDistance(battleMap, origin, cell) > darkvision {
+				continue
+			}
+			visible[cell] = true
+		}
+	}
+}
+
+// GetMapView returns the part of the session's battle map a user can see
+func (s *MapService) GetMapView(ctx context.Context, sessionID, userID primitive.ObjectID) (*models.MapView, error) {
+	views, err := s.GetMapViews(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return views.For(userID), nil
+}
+
+// GetMapViews computes every viewer's view of a session's battle map
+func (s *MapService) GetMapViews(ctx context.Context, sessionID primitive.ObjectID) (*MapViews, error) {
+	session, err := s.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.MapViews(ctx, session)
+}
+
+// MapViews holds the battle map as seen by the DM and by each player in a session
+type MapViews struct {
+	dmUserID primitive.ObjectID
+	full     *models.MapView
+	players  map[primitive.ObjectID]*models.MapView
+	public   *models.MapView
+	owners   map[primitive.ObjectID]primitive.ObjectID // Character ID to controlling user
+}
+
+// For returns what a user can see; users who are neither the DM nor a player only see revealed cells
+func (v *MapViews) For(userID primitive.ObjectID) *models.MapView {
+	if userID == v.dmUserID {
+		return v.full
+	}
+	if view, ok := v.players[userID]; ok {
+		return view
+	}
+	return v.public
+}
+
+// TokenVisible reports whether a user can see a token
+func (v *MapViews) TokenVisible(userID, tokenID primitive.ObjectID) bool {
+	for _, token := range v.For(userID).Tokens {
+		if token.ID == tokenID {
+			return true
+		}
+	}
+	return false
+}
+
+// CellVisible reports whether a user can see a cell
+func (v *MapViews) CellVisible(userID primitive.ObjectID, cell models.GridPoint) bool {
+	view := v.For(userID)
+	if view.FullVision {
+		return true
+	}
+	for _, visible := range view.Visible {
+		if visible == cell {
+			return true
+		}
+	}
+	return false
+}
+
+// Owner returns the user controlling a token, or a zero ID for DM-controlled tokens
+func (v *MapViews) Owner(token models.MapToken) primitive.ObjectID {
+	return v.owners[token.CharacterID]
+}
+
+// VisiblePath returns the cells of a path a user can see, in order
+func (v *MapViews) VisiblePath(userID primitive.ObjectID, path []models.GridPoint) []models.GridPoint {
+	view := v.For(userID)
+	if view.FullVision {
+		return path
+	}
+
+	visible := make(map[models.GridPoint]bool, len(view.Visible))
+	for _, cell := range view.Visible {
+		visible[cell] = true
+	}
+	cells := make([]models.GridPoint, 0, len(path))
+	for _, cell := range path {
+		if visible[cell] {
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// MapViews computes every viewer's view of the session's battle map. Each player sees
+// through their character's token, using the character's darkvision.
+func (s *MapService) MapViews(ctx context.Context, session *models.GameSession) (*MapViews, error) {
+	if session.Map == nil {
+		return nil, errors.New("session has no battle map")
+	}
+	battleMap := session.Map
+
+	characterIDs := make([]primitive.ObjectID, 0, len(session.Players))
+	for _, player := range session.Players {
+		characterIDs = append(characterIDs, player.CharacterID)
+	}
+
+	darkvision := make(map[primitive.ObjectID]int)
+	if len(characterIDs) > 0 {
+		cursor, err := s.db.GetCollection("characters").Find(ctx, bson.M{"_id": bson.M{"$in": characterIDs}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get characters: %w", err)
+		}
+		var characters []models.Character
+		if err := cursor.All(ctx, &characters); err != nil {
+			return nil, fmt.Errorf("failed to decode characters: %w", err)
+		}
+		for _, character := range characters {
+			darkvision[character.ID] = darkvisionForRace(character.Race)
+		}
+	}
+
+	walls := wallSet(battleMap)
+	views := &MapViews{
+		dmUserID: session.DMUserID,
+		full: &models.MapView{
+			Width:      battleMap.Width,
+			Height:     battleMap.Height,
+			CellFeet:   battleMap.CellFeet,
+			Lighting:   battleMap.Lighting,
+			Terrain:    battleMap.Terrain,
+			Tokens:     battleMap.Tokens,
+			Lights:     battleMap.Lights,
+			FullVision: true,
+			Revealed:   battleMap.Revealed,
+			Hidden:     battleMap.Hidden,
+		},
+		players: make(map[primitive.ObjectID]*models.MapView),
+		public:  filterMapView(battleMap, make(map[models.GridPoint]bool), nil),
+		owners:  make(map[primitive.ObjectID]primitive.ObjectID),
+	}
+
+	for _, player := range session.Players {
+		views.owners[player.CharacterID] = player.UserID
+		visible := make(map[models.GridPoint]bool)
+		own := make(map[primitive.ObjectID]bool)
+		for _, token := range battleMap.Tokens {
+			if token.CharacterID == player.CharacterID {
+				visibleCells(battleMap, walls, token.Position(), darkvision[player.CharacterID], visible)
+				own[token.ID] = true
+			}
+		}
+		views.players[player.UserID] = filterMapView(battleMap, visible, own)
+	}
+
+	return views, nil
+}
+
+// filterMapView builds a player's view from the cells they can see, adding cells the DM
+// revealed and removing cells the DM hid. A player always sees their own tokens.
+func filterMapView(battleMap *models.BattleMap, visible map[models.GridPoint]bool, own map[primitive.ObjectID]bool) *models.MapView {
+	for _, cell := range battleMap.Revealed {
+		visible[cell] = true
+	}
+	for _, cell := range battleMap.Hidden {
+		delete(visible, cell)
+	}
+
+	view := &models.MapView{
+		Width:    battleMap.Width,
+		Height:   battleMap.Height,
+		CellFeet: battleMap.CellFeet,
+		Lighting: battleMap.Lighting,
+		Terrain:  []models.TerrainCell{},
+		Tokens:   []models.MapToken{},
+		Lights:   []models.LightSource{},
+		Visible:  make([]models.GridPoint, 0, len(visible)),
+	}
+	for x := 0; x < battleMap.Width; x++ {
+		for y := 0; y < battleMap.Height; y++ {
+			if cell := (models.GridPoint{X: x, Y: y}); visible[cell] {
+				view.Visible = append(view.Visible, cell)
+			}
+		}
+	}
+	for _, cell := range battleMap.Terrain {
+		if visible[models.GridPoint{X: cell.X, Y: cell.Y}] {
+			view.Terrain = append(view.Terrain, cell)
+		}
+	}
+	for _, light := range battleMap.Lights {
+		if visible[models.GridPoint{X: light.X, Y: light.Y}] {
+			view.Lights = append(view.Lights, light)
+		}
+	}
+	for _, token := range battleMap.Tokens {
+		if own[token.ID] || visible[token.Position()] {
+			view.Tokens = append(view.Tokens, token)
+		}
+	}
+	return view
+}
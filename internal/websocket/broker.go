@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+// Envelope is a session message on its way through the broker to every hub
+type Envelope struct {
+	SessionID     primitive.ObjectID   `bson:"session_id"`
+	Payload       []byte               `bson:"payload"` // JSON-encoded models.WSMessage
+	UserIDs       []primitive.ObjectID `bson:"user_ids,omitempty"`
+	Roles         []ClientRole         `bson:"roles,omitempty"`
+	ExcludeClient string               `bson:"exclude_client,omitempty"` // Client.ID to skip
+	Transient     bool                 `bson:"transient,omitempty"`      // Delivered live only: no sequence number, never replayed
+	RevokedLink   primitive.ObjectID   `bson:"revoked_link,omitempty"`   // Disconnect spectators using this link instead of delivering
+	Seq           uint64               `bson:"-"`                        // Set by the broker on delivery
+}
+
+// Audience returns who the envelope is addressed to
+func (e *Envelope) Audience() Audience {
+	return Audience{UserIDs: e.UserIDs, Roles: e.Roles}
+}
+
+// Broker fans session messages out to every hub instance and tracks which users are
+// connected to which instance, so the API can run as several replicas
+type Broker interface {
+	// Publish sends an envelope to every subscribed hub, including the publisher's
+	Publish(ctx context.Context, env *Envelope) error
+
+	// Subscribe hands every published envelope to deliver, one at a time, until ctx is done.
+	// All hubs see envelopes in the same order, numbered with increasing Seq. Seqs are
+	// shared by every session, so a session's own are sparse.
+	Subscribe(ctx context.Context, deliver func(*Envelope)) error
+
+	// SetPresence records a user's presence in a session on a node, or that they have no
+	// connection to it there when presence is nil
+	SetPresence(ctx context.Context, nodeID string, sessionID, userID primitive.ObjectID, presence *models.UserPresence) error
+
+	// RefreshPresence marks a node's presence as still current
+	RefreshPresence(ctx context.Context, nodeID string) error
+
+	// ConnectedUsers returns the users connected to a session on any node
+	ConnectedUsers(ctx context.Context, sessionID primitive.ObjectID) ([]primitive.ObjectID, error)
+
+	// SessionPresence returns the presence of each user connected to a session, merged
+	// across nodes
+	SessionPresence(ctx context.Context, sessionID primitive.ObjectID) ([]models.UserPresence, error)
+}
+
+// MemoryBroker is a Broker for hubs in a single process
+type MemoryBroker struct {
+	mu          sync.Mutex
+	seq         uint64
+	subscribers map[*memorySubscriber]bool
+	presence    map[primitive.ObjectID]map[primitive.ObjectID]map[string]models.UserPresence // session -> user -> node
+}
+
+// NewMemoryBroker creates a new in-process broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		subscribers: make(map[*memorySubscriber]bool),
+		presence:    make(map[primitive.ObjectID]map[primitive.ObjectID]map[string]models.UserPresence),
+	}
+}
+
+// memorySubscriber queues envelopes for one hub so publishing never waits on delivery
+type memorySubscriber struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*Envelope
+	closed bool
+}
+
+func (s *memorySubscriber) push(env *Envelope) {
+	s.mu.Lock()
+	s.queue = append(s.queue, env)
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+func (s *memorySubscriber) pop() (*Envelope, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.queue) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return nil, false
+	}
+	env := s.queue[0]
+	s.queue = s.queue[1:]
+	return env, true
+}
+
+func (s *memorySubscriber) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// Publish numbers an envelope and queues it for every subscriber
+func (b *MemoryBroker) Publish(ctx context.Context, env *Envelope) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	delivered := *env
+	delivered.Seq = b.seq
+	for sub := range b.subscribers {
+		sub.push(&delivered)
+	}
+	return nil
+}
+
+// Subscribe delivers envelopes published from now on until ctx is done
+func (b *MemoryBroker) Subscribe(ctx context.Context, deliver func(*Envelope)) error {
+	sub := &memorySubscriber{}
+	sub.cond = sync.NewCond(&sub.mu)
+
+	b.mu.Lock()
+	b.subscribers[sub] = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.subscribers, sub)
+		b.mu.Unlock()
+	}()
+
+	go func() {
+		<-ctx.Done()
+		sub.close()
+	}()
+
+	for {
+		env, ok := sub.pop()
+		if !ok {
+			return ctx.Err()
+		}
+		deliver(env)
+	}
+}
+
+// SetPresence records a user's presence in a session on a node, or removes it when nil
+func (b *MemoryBroker) SetPresence(ctx context.Context, nodeID string, sessionID, userID primitive.ObjectID, presence *models.UserPresence) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	users := b.presence[sessionID]
+	if presence != nil {
+		if users == nil {
+			users = make(map[primitive.ObjectID]map[string]models.UserPresence)
+			b.presence[sessionID] = users
+		}
+		if users[userID] == nil {
+			users[userID] = make(map[string]models.UserPresence)
+		}
+		users[userID][nodeID] = *presence
+		return nil
+	}
+
+	delete(users[userID], nodeID)
+	if len(users[userID]) == 0 {
+		delete(users, userID)
+	}
+	if len(users) == 0 {
+		delete(b.presence, sessionID)
+	}
+	return nil
+}
+
+// RefreshPresence is a no-op; in-process presence can't go stale
+func (b *MemoryBroker) RefreshPresence(ctx context.Context, nodeID string) error {
+	return nil
+}
+
+// ConnectedUsers returns the users connected to a session on any hub
+func (b *MemoryBroker) ConnectedUsers(ctx context.Context, sessionID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	users := make([]primitive.ObjectID, 0, len(b.presence[sessionID]))
+	for userID := range b.presence[sessionID] {
+		users = append(users, userID)
+	}
+	return users, nil
+}
+
+// SessionPresence returns the presence of each user connected to a session on any hub
+func (b *MemoryBroker) SessionPresence(ctx context.Context, sessionID primitive.ObjectID) ([]models.UserPresence, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var presences []models.UserPresence
+	for _, nodes := range b.presence[sessionID] {
+		for _, presence := range nodes {
+			presences = append(presences, presence)
+		}
+	}
+	return mergePresence(presences), nil
+}
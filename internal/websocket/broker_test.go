@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/config"
+	"dnd-simulator/internal/models"
+)
+
+// presenceStore is a SessionStore that only records connection changes; the hubs under
+// test never load anything else
+type presenceStore struct {
+	SessionStore
+}
+
+func (presenceStore) UpdatePlayerConnection(ctx context.Context, sessionID, userID primitive.ObjectID, isConnected bool) error {
+	return nil
+}
+
+// startHub runs a hub on the broker until the test ends
+func startHub(t *testing.T, broker Broker) *Hub {
+	t.Helper()
+
+	hub := NewHub(presenceStore{}, broker, config.WSLimits{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		<-hub.done
+	})
+	return hub
+}
+
+// waitForSubscribers blocks until n hubs are subscribed to the broker
+func waitForSubscribers(t *testing.T, broker *MemoryBroker, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		broker.mu.Lock()
+		subscribed := len(broker.subscribers)
+		broker.mu.Unlock()
+		if subscribed >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d hubs subscribed, want %d", subscribed, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// nextOfType reads a client's messages until one of the given type arrives
+func nextOfType(t *testing.T, client *Client, messageType string) models.WSMessage {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case data, ok := <-client.Send:
+			if !ok {
+				t.Fatalf("client was disconnected waiting for %s", messageType)
+			}
+			var message models.WSMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("decode message: %v", err)
+			}
+			if message.Type == messageType {
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("no %s message arrived", messageType)
+		}
+	}
+}
+
+// A broadcast from one hub reaches a client connected to another hub on the same broker
+func TestBroadcastReachesOtherHub(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := startHub(t, broker)
+	hubB := startHub(t, broker)
+	waitForSubscribers(t, broker, 2)
+
+	sessionID := primitive.NewObjectID()
+	clientB := &Client{
+		ID:        "b",
+		Send:      make(chan []byte, 16),
+		UserID:    primitive.NewObjectID(),
+		Username:  "player",
+		SessionID: sessionID,
+		Role:      RolePlayer,
+	}
+	hubB.Register <- clientB
+	nextOfType(t, clientB, models.MessageTypeSuccess)
+
+	hubA.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeChat,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data:      map[string]string{"content": "from hub A"},
+	})
+
+	message := nextOfType(t, clientB, models.MessageTypeChat)
+	if message.Seq == 0 {
+		t.Error("message from the other hub has no sequence number")
+	}
+	if message.SessionID != sessionID {
+		t.Errorf("message is for session %s, want %s", message.SessionID.Hex(), sessionID.Hex())
+	}
+}
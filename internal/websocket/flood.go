@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/config"
+	"dnd-simulator/internal/models"
+)
+
+// violationWindow is how long a connection must stay within its limits for earlier
+// violations to be forgiven
+const violationWindow = 30 * time.Second
+
+// mutedMessageTypes are what a muted connection can't send: anything the table sees
+var mutedMessageTypes = map[string]bool{
+	models.MessageTypeChat:            true,
+	models.MessageTypeChatIC:          true,
+	models.MessageTypeChatOOC:         true,
+	models.MessageTypeWhisper:         true,
+	models.MessageTypeDiceRoll:        true,
+	models.MessageTypeCharacterUpdate: true,
+	models.MessageTypeTyping:          true,
+	models.MessageTypeReadyCheck:      true,
+}
+
+// tokenBucket allows a message for each token, refilling at the limit's rate up to its burst
+type tokenBucket struct {
+	limit  config.RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit config.RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// allow takes a token if there is one
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// floodGuard tracks a connection's rate limits, violations and mutes. Only readPump uses it.
+type floodGuard struct {
+	limits        config.WSLimits
+	messages      *tokenBucket
+	byType        map[string]*tokenBucket
+	violations    int
+	lastViolation time.Time
+	mutes         int
+	mutedUntil    time.Time
+}
+
+func newFloodGuard(limits config.WSLimits) *floodGuard {
+	now := time.Now()
+	guard := &floodGuard{
+		limits:   limits,
+		messages: newTokenBucket(limits.Messages, now),
+		byType:   make(map[string]*tokenBucket, len(limits.PerType)),
+	}
+	for key, limit := range limits.PerType {
+		guard.byType[key] = newTokenBucket(limit, now)
+	}
+	return guard
+}
+
+// rateKey groups message types that share a limit
+func rateKey(messageType string) string {
+	switch messageType {
+	case models.MessageTypeChatIC, models.MessageTypeChatOOC, models.MessageTypeWhisper:
+		return models.MessageTypeChat
+	}
+	return messageType
+}
+
+// allowMessage checks the connection's overall limit
+func (g *floodGuard) allowMessage(now time.Time) bool {
+	return g.messages.allow(now)
+}
+
+// allowType checks the limit for a message's type, if it has one
+func (g *floodGuard) allowType(messageType string, now time.Time) bool {
+	bucket, ok := g.byType[rateKey(messageType)]
+	return !ok || bucket.allow(now)
+}
+
+// muted reports whether the connection is muted for a message's type
+func (g *floodGuard) muted(messageType string, now time.Time) bool {
+	return mutedMessageTypes[messageType] && now.Before(g.mutedUntil)
+}
+
+// violate handles a message over its rate limit: the client is told to slow down, muted
+// if it keeps going, and disconnected if it has been muted too often. It reports whether
+// the connection should close.
+func (c *Client) violate(requestID string) bool {
+	guard := c.flood
+	now := time.Now()
+	if now.Sub(guard.lastViolation) > violationWindow {
+		guard.violations = 0
+	}
+	guard.violations++
+	guard.lastViolation = now
+
+	if guard.violations < guard.limits.MuteAfter {
+		c.sendError(requestID, models.ErrorCodeRateLimited, "You're sending messages too fast; slow down")
+		return false
+	}
+
+	guard.violations = 0
+	guard.mutes++
+	if guard.mutes >= guard.limits.MaxMutes {
+		log.Printf("Disconnecting %s from session %s for flooding", c.Username, c.SessionID.Hex())
+		c.Hub.reportFlood(c, nil)
+		c.Conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Too many messages"),
+			now.Add(time.Second))
+		return true
+	}
+
+	guard.mutedUntil = now.Add(guard.limits.MuteDuration)
+	log.Printf("Muted %s in session %s for flooding until %s", c.Username, c.SessionID.Hex(), guard.mutedUntil.Format(time.RFC3339))
+	c.Hub.reportFlood(c, &guard.mutedUntil)
+	return false
+}
+
+// reportFlood tells the DM and the user that the user's connection was muted until a time,
+// or disconnected if there is none
+func (h *Hub) reportFlood(client *Client, until *time.Time) {
+	data := models.UserMutedData{
+		UserID:       client.UserID,
+		Username:     client.Username,
+		Mutes:        client.flood.mutes,
+		Disconnected: until == nil,
+	}
+	if until != nil {
+		mutedUntil := *until
+		data.Until = &mutedUntil
+	}
+
+	audience := Audience{Roles: []ClientRole{RoleDM}}
+	if !client.UserID.IsZero() {
+		audience.UserIDs = []primitive.ObjectID{client.UserID}
+	}
+	h.SendToAudience(client.SessionID, audience, models.WSMessage{
+		Type:      models.MessageTypeUserMuted,
+		Timestamp: time.Now(),
+		UserID:    client.UserID,
+		Username:  client.Username,
+		SessionID: client.SessionID,
+		Data:      data,
+	})
+}
@@ -1,15 +1,21 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"dnd-simulator/internal/config"
 	"dnd-simulator/internal/models"
 )
 
@@ -22,6 +28,60 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// ChatHistoryOnConnect is how many recent chat messages a newly connected client receives
+const ChatHistoryOnConnect = 50
+
+// presenceRefreshInterval is how often a hub tells the broker its users are still connected
+const presenceRefreshInterval = 30 * time.Second
+
+// SessionStore is the persistence the hub relies on for connection status, chat and game state
+type SessionStore interface {
+	GetSession(ctx context.Context, sessionID primitive.ObjectID) (*models.GameSession, error)
+	UpdatePlayerConnection(ctx context.Context, sessionID, userID primitive.ObjectID, isConnected bool) error
+	SaveChatMessage(ctx context.Context, msg *models.SessionChatMessage) ([]primitive.ObjectID, error)
+	RecordRoll(ctx context.Context, sessionID primitive.ObjectID, roll *models.OverlayRoll) error
+	RecentChatMessages(ctx context.Context, sessionID, userID primitive.ObjectID, limit int) ([]models.SessionChatMessage, error)
+	StartReadyCheck(ctx context.Context, sessionID, dmUserID primitive.ObjectID, prompt string, timeout time.Duration) (*models.ReadyCheck, error)
+	RespondReadyCheck(ctx context.Context, sessionID, userID primitive.ObjectID, ready bool) (*models.ReadyCheck, error)
+	FinishReadyCheck(ctx context.Context, sessionID primitive.ObjectID, startedAt time.Time) (*models.ReadyCheck, error)
+}
+
+// ClientRole is a connected user's role in their session
+type ClientRole string
+
+const (
+	RoleDM        ClientRole = "dm"
+	RolePlayer    ClientRole = "player"
+	RoleSpectator ClientRole = "spectator" // Read-only, through a spectator link
+)
+
+// Audience selects which clients in a session receive a message: anyone whose user ID or
+// role is listed. An empty audience means the whole session.
+type Audience struct {
+	UserIDs []primitive.ObjectID
+	Roles   []ClientRole
+}
+
+// IsEmpty reports whether the audience targets the whole session
+func (a Audience) IsEmpty() bool {
+	return len(a.UserIDs) == 0 && len(a.Roles) == 0
+}
+
+// includes reports whether a client is part of the audience
+func (a Audience) includes(client *Client) bool {
+	for _, id := range a.UserIDs {
+		if client.UserID == id {
+			return true
+		}
+	}
+	for _, role := range a.Roles {
+		if client.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
 // Client represents a WebSocket client connection
 type Client struct {
 	ID       string
@@ -32,6 +92,24 @@ type Client struct {
 	Username string
 	SessionID primitive.ObjectID
 	CharacterID primitive.ObjectID
+	Role     ClientRole
+	
+	// Activity and ping, for presence
+	lastActive atomic.Int64 // Unix nanoseconds of the last message from the client
+	pingSent   atomic.Int64 // Unix nanoseconds of the last ping
+	latency    atomic.Int64 // Round trip of the last ping, in nanoseconds
+	away       atomic.Bool  // The client reported its user away
+	
+	// Typing indicator state, only used by readPump
+	typing     bool
+	typingIC   bool
+	typingSent time.Time
+	
+	// Rate limits and mutes, only used by readPump
+	flood *floodGuard
+	
+	// Held-back feed, for spectators only
+	spectator *spectatorFeed
 }
 
 // Hub maintains active clients and broadcasts messages
@@ -39,38 +117,70 @@ type Hub struct {
 	// Registered clients grouped by session
 	Sessions map[primitive.ObjectID]map[*Client]bool
 	
-	// Register requests from clients
+	// Register requests from clients; the sender starts the client's writePump, which
+	// registering counts in writers
 	Register chan *Client
 	
 	// Unregister requests from clients
 	Unregister chan *Client
 	
-	// Broadcast to all clients in a session
-	Broadcast chan *SessionMessage
+	// Sequence numbers and replay buffers by session
+	logs map[primitive.ObjectID]*sessionLog
+	
+	// Oldest sequence number this hub can replay from, once it is receiving
+	floor     uint64
+	receiving bool
+	
+	// Fan-out and presence shared with other nodes
+	broker Broker
+	nodeID string
+	
+	// Presence last shared with the broker for this node's users, by session and user
+	shared     map[primitive.ObjectID]map[primitive.ObjectID]models.UserPresence
+	presenceMu sync.Mutex
+	
+	// Session persistence
+	store SessionStore
+	
+	// Flood protection for each connection
+	limits config.WSLimits
+	
+	// Shutdown: set once draining starts, writers tracks running writePumps, done closes
+	// when Run returns
+	draining atomic.Bool
+	writers  sync.WaitGroup
+	done     chan struct{}
 	
 	// Mutex for thread safety
 	mu sync.RWMutex
 }
 
-// SessionMessage represents a message to broadcast to a session
-type SessionMessage struct {
-	SessionID primitive.ObjectID
-	Message   models.WSMessage
-	Exclude   *Client // Optional: exclude this client from broadcast
-}
-
 // NewHub creates a new WebSocket hub
-func NewHub() *Hub {
+func NewHub(store SessionStore, broker Broker, limits config.WSLimits) *Hub {
 	return &Hub{
 		Sessions:   make(map[primitive.ObjectID]map[*Client]bool),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
-		Broadcast:  make(chan *SessionMessage),
+		logs:       make(map[primitive.ObjectID]*sessionLog),
+		broker:     broker,
+		nodeID:     primitive.NewObjectID().Hex(),
+		shared:     make(map[primitive.ObjectID]map[primitive.ObjectID]models.UserPresence),
+		store:      store,
+		limits:     limits,
+		done:       make(chan struct{}),
 	}
 }
 
-// Run starts the hub's main loop
-func (h *Hub) Run() {
+// Run starts the hub's main loop, which stops when ctx is cancelled
+func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
+	go h.subscribe(ctx)
+	
+	refresh := time.NewTicker(presenceRefreshInterval)
+	defer refresh.Stop()
+	presence := time.NewTicker(presenceInterval)
+	defer presence.Stop()
+	
 	for {
 		select {
 		case client := <-h.Register:
@@ -79,22 +189,68 @@ func (h *Hub) Run() {
 		case client := <-h.Unregister:
 			h.unregisterClient(client)
 			
-		case sessionMsg := <-h.Broadcast:
-			h.broadcastToSession(sessionMsg)
+		case <-refresh.C:
+			go h.refreshPresence()
+			
+		case <-presence.C:
+			go h.updatePresence()
+			
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// subscribe receives session messages from the broker, reconnecting if the stream fails,
+// until ctx is cancelled
+func (h *Hub) subscribe(ctx context.Context) {
+	for {
+		err := h.broker.Subscribe(ctx, h.receive)
+		if ctx.Err() != nil {
+			return
 		}
+		log.Printf("Hub subscription ended, retrying: %v", err)
+		time.Sleep(time.Second)
 	}
 }
 
-func (h *Hub) registerClient(client *Client) {
+// refreshPresence keeps this node's connected users from expiring on other nodes
+func (h *Hub) refreshPresence() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	if err := h.broker.RefreshPresence(ctx, h.nodeID); err != nil {
+		log.Printf("Error refreshing presence: %v", err)
+	}
+}
+
+func (h *Hub) registerClient(client *Client) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	
+	return h.addClient(client)
+}
+
+// addClient puts a client in its session and announces it. Callers must hold h.mu. It
+// reports false, and adds nothing, once the hub is draining: checking under h.mu means
+// Shutdown either sees the client or the client sees the drain. On true the client's
+// writePump is counted in h.writers, so the caller must start it.
+func (h *Hub) addClient(client *Client) bool {
+	if h.Draining() {
+		return false
+	}
+	h.writers.Add(1)
+	
 	if h.Sessions[client.SessionID] == nil {
 		h.Sessions[client.SessionID] = make(map[*Client]bool)
 	}
 	
 	h.Sessions[client.SessionID][client] = true
 	log.Printf("Client %s joined session %s", client.Username, client.SessionID.Hex())
+	if client.Role == RoleSpectator {
+		return true // Spectators watch unannounced
+	}
+	go h.syncConnection(client.SessionID, client.UserID)
 	
 	// Notify other clients in the session
 	joinMessage := models.WSMessage{
@@ -103,127 +259,254 @@ func (h *Hub) registerClient(client *Client) {
 		UserID:    client.UserID,
 		Username:  client.Username,
 		SessionID: client.SessionID,
-		Data: map[string]interface{}{
-			"user_id":      client.UserID,
-			"username":     client.Username,
-			"character_id": client.CharacterID,
+		Data: models.PlayerJoinedData{
+			UserID:      client.UserID,
+			Username:    client.Username,
+			CharacterID: client.CharacterID,
 		},
 	}
 	
-	h.broadcastToSessionExclude(client.SessionID, joinMessage, client)
+	go h.publish(client.SessionID, joinMessage, Audience{}, client)
 	
 	// Send success message to the joining client
 	successMessage := models.WSMessage{
 		Type:      models.MessageTypeSuccess,
 		Timestamp: time.Now(),
-		Data: map[string]interface{}{
-			"message":    "Successfully joined session",
-			"session_id": client.SessionID,
+		Data: models.SuccessData{
+			Message:   "Successfully joined session",
+			SessionID: client.SessionID,
 		},
 	}
-	h.sendToClient(client, successMessage)
+	h.sendLocked(client, successMessage)
+	return true
+}
+
+// refuseClient closes a connection the hub wouldn't add because it started draining after
+// the upgrade
+func (h *Hub) refuseClient(client *Client) {
+	client.Conn.WriteControl(websocket.CloseMessage, h.closeMessage(), time.Now().Add(time.Second))
+	client.Conn.Close()
 }
 
 func (h *Hub) unregisterClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	
-	if clients, ok := h.Sessions[client.SessionID]; ok {
-		if _, ok := clients[client]; ok {
-			delete(clients, client)
-			close(client.Send)
-			
-			// Clean up empty sessions
-			if len(clients) == 0 {
-				delete(h.Sessions, client.SessionID)
-			}
-			
-			log.Printf("Client %s left session %s", client.Username, client.SessionID.Hex())
-			
-			// Notify other clients in the session
-			leaveMessage := models.WSMessage{
-				Type:      models.MessageTypePlayerLeft,
-				Timestamp: time.Now(),
-				UserID:    client.UserID,
-				Username:  client.Username,
-				SessionID: client.SessionID,
-				Data: map[string]interface{}{
-					"user_id":  client.UserID,
-					"username": client.Username,
-				},
-			}
-			
-			h.broadcastToSessionExclude(client.SessionID, leaveMessage, nil)
+	h.removeClient(client)
+}
+
+// removeClient takes a client out of its session and tells the others it left. Callers
+// must hold h.mu.
+func (h *Hub) removeClient(client *Client) {
+	clients, ok := h.Sessions[client.SessionID]
+	if !ok || !clients[client] {
+		return
+	}
+	delete(clients, client)
+	close(client.Send)
+	if client.spectator != nil {
+		close(client.spectator.done)
+	}
+	
+	// Clean up empty sessions
+	if len(clients) == 0 {
+		delete(h.Sessions, client.SessionID)
+		h.pruneLogs()
+	}
+	
+	log.Printf("Client %s left session %s", client.Username, client.SessionID.Hex())
+	if client.Role == RoleSpectator || h.Draining() {
+		return // Shutdown syncs presence itself, and nobody is left to tell
+	}
+	go h.syncConnection(client.SessionID, client.UserID)
+	
+	// Notify other clients in the session
+	leaveMessage := models.WSMessage{
+		Type:      models.MessageTypePlayerLeft,
+		Timestamp: time.Now(),
+		UserID:    client.UserID,
+		Username:  client.Username,
+		SessionID: client.SessionID,
+		Data: models.PlayerLeftData{
+			UserID:   client.UserID,
+			Username: client.Username,
+		},
+	}
+	
+	go h.publish(client.SessionID, leaveMessage, Audience{}, nil)
+}
+
+// syncConnection records whether a user still has a connection open to a session. It reads
+// the hub's current state instead of trusting the event that triggered it, so a quick
+// reconnect isn't overwritten by the disconnect just before it.
+func (h *Hub) syncConnection(sessionID, userID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	// Share this node's view, then record whether the user is connected to any node
+	connected := h.userConnected(sessionID, userID)
+	if h.sharePresence(ctx, sessionID, userID) {
+		if users, err := h.broker.ConnectedUsers(ctx, sessionID); err == nil {
+			connected = containsUser(users, userID)
 		}
 	}
+	
+	if err := h.store.UpdatePlayerConnection(ctx, sessionID, userID, connected); err != nil {
+		log.Printf("Error updating connection status: %v", err)
+	}
 }
 
-func (h *Hub) broadcastToSession(sessionMsg *SessionMessage) {
+// containsUser reports whether a user ID is in a list
+func containsUser(users []primitive.ObjectID, userID primitive.ObjectID) bool {
+	for _, id := range users {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// userConnected reports whether a user has any connection open to a session
+func (h *Hub) userConnected(sessionID, userID primitive.ObjectID) bool {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 	
-	if clients, ok := h.Sessions[sessionMsg.SessionID]; ok {
-		messageBytes, err := json.Marshal(sessionMsg.Message)
-		if err != nil {
-			log.Printf("Error marshaling message: %v", err)
-			return
-		}
-		
-		for client := range clients {
-			if sessionMsg.Exclude != nil && client == sessionMsg.Exclude {
-				continue
-			}
-			
-			select {
-			case client.Send <- messageBytes:
-			default:
-				// Client's send channel is full, remove them
-				delete(clients, client)
-				close(client.Send)
-			}
+	for client := range h.Sessions[sessionID] {
+		if client.UserID == userID {
+			return true
 		}
 	}
+	return false
 }
 
-func (h *Hub) broadcastToSessionExclude(sessionID primitive.ObjectID, message models.WSMessage, exclude *Client) {
-	sessionMsg := &SessionMessage{
-		SessionID: sessionID,
-		Message:   message,
-		Exclude:   exclude,
+// sendInitialState catches a new client up with the current game state and recent chat. It
+// returns the sequence number the snapshot is current as of; later messages still follow.
+func (h *Hub) sendInitialState(ctx context.Context, client *Client) uint64 {
+	seq := h.currentSeq(client.SessionID)
+	session, err := h.store.GetSession(ctx, client.SessionID)
+	if err != nil {
+		log.Printf("Error loading game state: %v", err)
+	} else {
+		state := *session.WithoutMap() // Map views differ per player; clients fetch theirs from the map endpoint
+		h.sendToClient(client, models.WSMessage{
+			Type:      models.MessageTypeGameState,
+			Timestamp: time.Now(),
+			SessionID: client.SessionID,
+			Data: models.GameStateData{
+				Session: state,
+				Seq:     seq,
+			},
+		})
+	}
+	
+	h.sendPresence(ctx, client)
+	
+	messages, err := h.store.RecentChatMessages(ctx, client.SessionID, client.UserID, ChatHistoryOnConnect)
+	if err != nil {
+		log.Printf("Error loading chat history: %v", err)
+		return seq
+	}
+	h.sendToClient(client, models.WSMessage{
+		Type:      models.MessageTypeChatHistory,
+		Timestamp: time.Now(),
+		SessionID: client.SessionID,
+		Data: models.ChatHistoryData{
+			Messages: messages,
+			Seq:      seq,
+		},
+	})
+	return seq
+}
+
+// deliver queues a message for a client and reports false if its buffer is full. Callers
+// must hold h.mu.
+func (h *Hub) deliver(client *Client, messageBytes []byte) bool {
+	if !h.Sessions[client.SessionID][client] {
+		return true // Already disconnected
 	}
 	
 	select {
-	case h.Broadcast <- sessionMsg:
+	case client.Send <- messageBytes:
+		return true
 	default:
-		log.Println("Broadcast channel full, dropping message")
+		return false
 	}
 }
 
-func (h *Hub) sendToClient(client *Client, message models.WSMessage) {
+// dropSlowClients disconnects clients that couldn't keep up instead of silently skipping
+// messages for them. They reconnect and resume from their last sequence number. Callers
+// must hold h.mu.
+func (h *Hub) dropSlowClients(clients []*Client) {
+	for _, client := range clients {
+		log.Printf("Client %s send channel full, disconnecting so it can resume", client.Username)
+		h.removeClient(client)
+	}
+}
+
+// sendLocked sends a message to one client outside the session's sequence, e.g. a reply
+// to something it sent. Callers must hold h.mu.
+func (h *Hub) sendLocked(client *Client, message models.WSMessage) {
+	message.Version = models.ProtocolVersion
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Error marshaling message: %v", err)
 		return
 	}
 	
-	select {
-	case client.Send <- messageBytes:
-	default:
-		log.Printf("Client %s send channel full", client.Username)
+	if !h.deliver(client, messageBytes) {
+		h.dropSlowClients([]*Client{client})
 	}
 }
 
+func (h *Hub) sendToClient(client *Client, message models.WSMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	
+	h.sendLocked(client, message)
+}
+
 // BroadcastToSession sends a message to all clients in a session
 func (h *Hub) BroadcastToSession(sessionID primitive.ObjectID, message models.WSMessage) {
-	sessionMsg := &SessionMessage{
-		SessionID: sessionID,
-		Message:   message,
-	}
+	h.publish(sessionID, message, Audience{}, nil)
+}
+
+// BroadcastTransient sends a message that only matters live to all clients in a session.
+// It gets no sequence number and isn't replayed to resuming clients.
+func (h *Hub) BroadcastTransient(sessionID primitive.ObjectID, message models.WSMessage) {
+	h.publishTransient(sessionID, message, Audience{}, nil)
+}
+
+// SendToUser sends a message to every connection a user has open in a session
+func (h *Hub) SendToUser(sessionID, userID primitive.ObjectID, message models.WSMessage) {
+	h.SendToAudience(sessionID, Audience{UserIDs: []primitive.ObjectID{userID}}, message)
+}
+
+// SendToAudience sends a message to the clients in a session selected by the audience, or
+// to the whole session if the audience is empty
+func (h *Hub) SendToAudience(sessionID primitive.ObjectID, audience Audience, message models.WSMessage) {
+	h.publish(sessionID, message, audience, nil)
+}
+
+// BroadcastPerUser sends each user connected to a session, on any node, their own version
+// of a message, e.g. one filtered to what they can see. build is called once per user;
+// returning false skips them. Spectators get the version built for a zero user ID, i.e.
+// someone with no place in the session.
+func (h *Hub) BroadcastPerUser(sessionID primitive.ObjectID, build func(userID primitive.ObjectID) (models.WSMessage, bool)) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	
-	select {
-	case h.Broadcast <- sessionMsg:
-	default:
-		log.Println("Broadcast channel full, dropping message")
+	users, err := h.broker.ConnectedUsers(ctx, sessionID)
+	if err != nil {
+		log.Printf("Error loading connected users: %v", err)
+		return
+	}
+	for _, userID := range users {
+		if message, ok := build(userID); ok {
+			h.publish(sessionID, message, Audience{UserIDs: []primitive.ObjectID{userID}}, nil)
+		}
+	}
+	if message, ok := build(primitive.NilObjectID); ok {
+		h.publishTransient(sessionID, message, Audience{Roles: []ClientRole{RoleSpectator}}, nil)
 	}
 }
 
@@ -240,6 +523,12 @@ func (h *Hub) GetSessionClients(sessionID primitive.ObjectID) int {
 
 // HandleWebSocket handles the WebSocket upgrade and client management
 func (h *Hub) HandleWebSocket(c *gin.Context) {
+	if h.Draining() {
+		c.Header("Retry-After", restartRetryAfter)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is restarting"})
+		return
+	}
+	
 	// Get user info from auth middleware
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -253,28 +542,38 @@ func (h *Hub) HandleWebSocket(c *gin.Context) {
 		return
 	}
 	
-	// Get session ID from query parameter
-	sessionIDStr := c.Query("session_id")
-	if sessionIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID required"})
+	// Session membership is checked by the middleware that loaded it
+	value, exists := c.Get("session")
+	if !exists {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Session membership not verified"})
 		return
 	}
+	session := value.(*models.GameSession)
+	sessionID := session.ID
 	
-	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
-		return
+	// The user's role decides which private messages they receive
+	role := RolePlayer
+	if session.DMUserID == userID.(primitive.ObjectID) {
+		role = RoleDM
 	}
 	
-	// Optional character ID
-	characterIDStr := c.Query("character_id")
+	// Players speak as the character they joined with; the DM has none
 	var characterID primitive.ObjectID
-	if characterIDStr != "" {
-		characterID, err = primitive.ObjectIDFromHex(characterIDStr)
+	for _, player := range session.Players {
+		if player.UserID == userID.(primitive.ObjectID) {
+			characterID = player.CharacterID
+		}
+	}
+	if characterIDStr := c.Query("character_id"); characterIDStr != "" {
+		requested, err := primitive.ObjectIDFromHex(characterIDStr)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid character ID"})
 			return
 		}
+		if characterID.IsZero() || requested != characterID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Character is not yours in this session"})
+			return
+		}
 	}
 	
 	// Upgrade HTTP connection to WebSocket
@@ -294,12 +593,28 @@ func (h *Hub) HandleWebSocket(c *gin.Context) {
 		Username:    username.(string),
 		SessionID:   sessionID,
 		CharacterID: characterID,
+		Role:        role,
 	}
+	client.touch()
 	
-	// Register client
-	h.Register <- client
+	// Register synchronously so nothing sent to the client can race its registration, then
+	// catch it up before its pumps start: a reconnecting client passes the last sequence
+	// number it saw and gets the gap, anyone else a fresh snapshot. A hub that began draining
+	// since the check above refuses the client.
+	if lastSeq, err := strconv.ParseUint(c.Query("last_seq"), 10, 64); err == nil {
+		if !h.registerAndResume(c.Request.Context(), client, lastSeq, "") {
+			h.refuseClient(client)
+			return
+		}
+	} else {
+		if !h.registerClient(client) {
+			h.refuseClient(client)
+			return
+		}
+		h.sendInitialState(c.Request.Context(), client)
+	}
 	
-	// Start goroutines for reading and writing
+	// Start goroutines for reading and writing; addClient counted the writer
 	go client.writePump()
 	go client.readPump()
 }
@@ -307,41 +622,77 @@ func (h *Hub) HandleWebSocket(c *gin.Context) {
 // Client methods
 func (c *Client) readPump() {
 	defer func() {
-		c.Hub.Unregister <- c
+		select {
+		case c.Hub.Unregister <- c:
+		case <-c.Hub.done:
+			c.Hub.unregisterClient(c)
+		}
 		c.Conn.Close()
 	}()
 	
+	// Larger messages close the connection
+	c.flood = newFloodGuard(c.Hub.limits)
+	if c.Hub.limits.MaxMessageBytes > 0 {
+		c.Conn.SetReadLimit(c.Hub.limits.MaxMessageBytes)
+	}
+	
 	// Set read deadline and pong handler
 	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.Conn.SetPongHandler(func(string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		if sent := c.pingSent.Load(); sent != 0 {
+			c.latency.Store(time.Now().UnixNano() - sent)
+		}
 		return nil
 	})
 	
 	for {
 		_, messageBytes, err := c.Conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("Closing WebSocket for %s: message over %d bytes", c.Username, c.Hub.limits.MaxMessageBytes)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
 		
-		// Parse message
-		var message models.WSMessage
-		if err := json.Unmarshal(messageBytes, &message); err != nil {
-			log.Printf("Error parsing message: %v", err)
+		now := time.Now()
+		if !c.flood.allowMessage(now) {
+			if c.violate("") {
+				break
+			}
 			continue
 		}
 		
-		// Set message metadata
-		message.Timestamp = time.Now()
-		message.UserID = c.UserID
-		message.Username = c.Username
-		message.SessionID = c.SessionID
+		req, rejected := decodeRequest(messageBytes)
+		if rejected != nil {
+			c.sendError(rejected.RequestID, rejected.Code, rejected.Message)
+			continue
+		}
+		if req.Type == models.MessageTypeLeaveSession {
+			break
+		}
+		if c.Role == RoleSpectator {
+			c.sendError(req.RequestID, models.ErrorCodeForbidden, "Spectators can't send messages")
+			continue
+		}
+		if !c.flood.allowType(req.Type, now) {
+			if c.violate(req.RequestID) {
+				break
+			}
+			continue
+		}
+		if c.flood.muted(req.Type, now) {
+			c.sendError(req.RequestID, models.ErrorCodeMuted, "You're muted for sending too many messages until "+c.flood.mutedUntil.Format(time.RFC3339))
+			continue
+		}
+		if req.Type != models.MessageTypePresence {
+			c.touch() // Presence reports may be automatic, e.g. when a tab is hidden
+		}
 		
 		// Handle the message based on type
-		c.handleMessage(message)
+		c.handleRequest(req)
 	}
 }
 
@@ -350,6 +701,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
+		c.Hub.writers.Done()
 	}()
 	
 	for {
@@ -357,7 +709,7 @@ func (c *Client) writePump() {
 		case message, ok := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Conn.WriteMessage(websocket.CloseMessage, c.Hub.closeMessage())
 				return
 			}
 			
@@ -368,6 +720,7 @@ func (c *Client) writePump() {
 			
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.pingSent.Store(time.Now().UnixNano())
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -375,31 +728,164 @@ func (c *Client) writePump() {
 	}
 }
 
-func (c *Client) handleMessage(message models.WSMessage) {
+func (c *Client) handleRequest(req *request) {
 	// Route message based on type
-	switch message.Type {
-	case models.MessageTypeChat, models.MessageTypeChatIC, models.MessageTypeChatOOC:
-		c.handleChatMessage(message)
-	case models.MessageTypeDiceRoll:
-		c.handleDiceRoll(message)
-	case models.MessageTypeCharacterUpdate:
-		c.handleCharacterUpdate(message)
+	switch data := req.Payload.(type) {
+	case *models.ChatMessage:
+		c.handleChatMessage(req, data)
+	case *models.DiceRollData:
+		c.handleDiceRoll(req, data)
+	case *models.CharacterUpdate:
+		c.handleCharacterUpdate(req, data)
+	case *models.TypingData:
+		c.handleTyping(data)
+	case *models.PresenceData:
+		c.handlePresence(req, data)
+	case *models.ReadyCheckStartData:
+		c.handleReadyCheck(req, data)
+	case *models.ReadyResponseData:
+		c.handleReadyResponse(req, data)
+	case *models.ResumeData:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		c.Hub.resume(ctx, c, *data.LastSeq, req.RequestID)
+	case *models.JoinSessionData:
+		c.sendError(req.RequestID, models.ErrorCodeUnsupportedType, "To join another session, connect to its WebSocket endpoint")
 	default:
-		log.Printf("Unknown message type: %s", message.Type)
+		c.sendError(req.RequestID, models.ErrorCodeUnsupportedType, "Unsupported message type: "+req.Type)
+	}
+}
+
+// ownsCharacter reports whether the client may act for a character: its own, or any
+// character for the DM
+func (c *Client) ownsCharacter(characterID primitive.ObjectID) bool {
+	return characterID.IsZero() || characterID == c.CharacterID || c.Role == RoleDM
+}
+
+func (c *Client) handleChatMessage(req *request, data *models.ChatMessage) {
+	if strings.TrimSpace(data.Content) == "" {
+		c.sendError(req.RequestID, models.ErrorCodeInvalidMessage, "Chat message content is required")
+		return
+	}
+	
+	isIC := req.Type == models.MessageTypeChatIC || (req.Type == models.MessageTypeChat && data.IsIC)
+	chatType := "ooc"
+	if isIC {
+		chatType = "ic"
+	}
+	
+	// Only the DM may speak as someone else, e.g. an NPC
+	if !c.ownsCharacter(data.CharacterID) {
+		c.sendError(req.RequestID, models.ErrorCodeForbidden, "You can only speak as your own character")
+		return
+	}
+	characterID := c.CharacterID
+	if !data.CharacterID.IsZero() {
+		characterID = data.CharacterID
+	}
+	
+	visibility := data.Visibility
+	if req.Type == models.MessageTypeWhisper {
+		visibility = models.ChatVisibilityWhisper
+	}
+	
+	// Store the message before broadcasting so history never misses what players saw
+	chatMsg := &models.SessionChatMessage{
+		SessionID:   c.SessionID,
+		UserID:      c.UserID,
+		Username:    c.Username,
+		CharacterID: characterID,
+		Message:     data.Content,
+		Type:        chatType,
+		Visibility:  visibility,
+		Recipients:  data.Recipients,
+		Timestamp:   time.Now(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	audience, err := c.Hub.store.SaveChatMessage(ctx, chatMsg)
+	if err != nil {
+		log.Printf("Error saving chat message: %v", err)
+		c.sendError(req.RequestID, models.ErrorCodeFailed, "Failed to send chat message: "+err.Error())
+		return
 	}
+	
+	// The message is sent, so the sender has stopped typing it
+	if c.typing {
+		c.setTyping(false, c.typingIC)
+	}
+	
+	// Private messages only go to their audience; public ones to the whole session
+	c.Hub.SendToAudience(c.SessionID, Audience{UserIDs: audience}, models.WSMessage{
+		Type:      req.Type,
+		Timestamp: chatMsg.Timestamp,
+		UserID:    c.UserID,
+		Username:  c.Username,
+		SessionID: c.SessionID,
+		Data: models.ChatMessageData{
+			ID:          chatMsg.ID,
+			Content:     chatMsg.Message,
+			CharacterID: characterID,
+			IsIC:        isIC,
+			Visibility:  chatMsg.Visibility,
+			Recipients:  chatMsg.Recipients,
+		},
+	})
 }
 
-func (c *Client) handleChatMessage(message models.WSMessage) {
-	// Broadcast chat message to all clients in the session
-	c.Hub.BroadcastToSession(c.SessionID, message)
+// sendError reports a problem with a message back to the client that sent it
+func (c *Client) sendError(requestID, code, text string) {
+	c.Hub.sendToClient(c, models.WSMessage{
+		Type:      models.MessageTypeError,
+		Timestamp: time.Now(),
+		SessionID: c.SessionID,
+		RequestID: requestID,
+		Data: models.ErrorData{
+			Message: text,
+			Code:    code,
+		},
+	})
 }
 
-func (c *Client) handleDiceRoll(message models.WSMessage) {
-	// Process dice roll and broadcast result
-	c.Hub.BroadcastToSession(c.SessionID, message)
+func (c *Client) handleDiceRoll(req *request, data *models.DiceRollData) {
+	if !c.ownsCharacter(data.CharacterID) {
+		c.sendError(req.RequestID, models.ErrorCodeForbidden, "You can only roll for your own character")
+		return
+	}
+	
+	message := models.WSMessage{
+		Type:      req.Type,
+		Timestamp: time.Now(),
+		UserID:    c.UserID,
+		Username:  c.Username,
+		SessionID: c.SessionID,
+		Data:      data,
+	}
+	
+	// Hidden rolls go to the roller and the DM, self rolls to the roller alone
+	switch data.Visibility {
+	case models.ChatVisibilityDM:
+		c.Hub.SendToAudience(c.SessionID, Audience{UserIDs: []primitive.ObjectID{c.UserID}, Roles: []ClientRole{RoleDM}}, message)
+	case models.RollVisibilitySelf:
+		c.Hub.SendToUser(c.SessionID, c.UserID, message)
+	default:
+		c.Hub.BroadcastToSession(c.SessionID, message)
+	}
 }
 
-func (c *Client) handleCharacterUpdate(message models.WSMessage) {
+func (c *Client) handleCharacterUpdate(req *request, data *models.CharacterUpdate) {
+	if !c.ownsCharacter(data.CharacterID) {
+		c.sendError(req.RequestID, models.ErrorCodeForbidden, "You can only update your own character")
+		return
+	}
+	
 	// Broadcast character update to all clients in the session
-	c.Hub.BroadcastToSession(c.SessionID, message)
-}
\ No newline at end of file
+	c.Hub.BroadcastToSession(c.SessionID, models.WSMessage{
+		Type:      req.Type,
+		Timestamp: time.Now(),
+		UserID:    c.UserID,
+		Username:  c.Username,
+		SessionID: c.SessionID,
+		Data:      data,
+	})
+}
@@ -0,0 +1,213 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"dnd-simulator/internal/database"
+	"dnd-simulator/internal/models"
+)
+
+const (
+	// brokerMessageTTL is how long published envelopes stay in MongoDB; subscribers only
+	// need them long enough for the change stream to pick them up
+	brokerMessageTTL = time.Hour
+
+	// presenceTTL is how long a node's presence lasts without a refresh, so users on a
+	// crashed node stop counting as connected
+	presenceTTL = 2 * time.Minute
+)
+
+// MongoBroker is a Broker built on MongoDB change streams, so hubs on several API replicas
+// share session traffic and presence. Change streams need a replica set (a single-node
+// one is enough).
+type MongoBroker struct {
+	messages    *mongo.Collection
+	presence    *mongo.Collection
+	resumeToken bson.Raw // Where the last subscription stopped, for resuming after errors
+}
+
+// storedEnvelope is an envelope as written to the hub_messages collection
+type storedEnvelope struct {
+	Envelope  `bson:",inline"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// NewMongoBroker creates a new MongoDB-backed broker
+func NewMongoBroker(db *database.DB) *MongoBroker {
+	return &MongoBroker{
+		messages: db.GetCollection("hub_messages"),
+		presence: db.GetCollection("hub_presence"),
+	}
+}
+
+// CheckReplicaSet fails unless MongoDB is running as a replica set, since change streams
+// don't work on a standalone server and hubs would never receive each other's messages
+func (b *MongoBroker) CheckReplicaSet(ctx context.Context) error {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := b.messages.Database().RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return fmt.Errorf("failed to check for a replica set: %w", err)
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		return errors.New("the mongo hub broker needs MongoDB running as a replica set (a single-node one is enough): " +
+			"start mongod with --replSet and run rs.initiate(), or set HUB_BROKER=memory")
+	}
+	return nil
+}
+
+// EnsureIndexes creates the TTL indexes that keep the broker's collections small
+func (b *MongoBroker) EnsureIndexes(ctx context.Context) error {
+	_, err := b.messages.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(brokerMessageTTL.Seconds())),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create hub message indexes: %w", err)
+	}
+
+	_, err = b.presence.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "session_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "updated_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(presenceTTL.Seconds())),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create hub presence indexes: %w", err)
+	}
+	return nil
+}
+
+// Publish stores an envelope for every hub's change stream to pick up
+func (b *MongoBroker) Publish(ctx context.Context, env *Envelope) error {
+	_, err := b.messages.InsertOne(ctx, storedEnvelope{Envelope: *env, CreatedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to publish hub message: %w", err)
+	}
+	return nil
+}
+
+// Subscribe watches for new envelopes until ctx is done or the stream fails. Each
+// envelope's Seq comes from its cluster time, which every hub sees in the same order.
+// Calling it again after an error resumes where the previous stream stopped.
+func (b *MongoBroker) Subscribe(ctx context.Context, deliver func(*Envelope)) error {
+	opts := options.ChangeStream()
+	if b.resumeToken != nil {
+		opts.SetResumeAfter(b.resumeToken)
+	}
+
+	stream, err := b.messages.Watch(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+	}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to watch hub messages: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event struct {
+			ClusterTime  primitive.Timestamp `bson:"clusterTime"`
+			FullDocument Envelope            `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			return fmt.Errorf("failed to decode hub message: %w", err)
+		}
+
+		env := event.FullDocument
+		env.Seq = uint64(event.ClusterTime.T)<<32 | uint64(event.ClusterTime.I)
+		deliver(&env)
+		b.resumeToken = stream.ResumeToken()
+	}
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("hub message stream failed: %w", err)
+	}
+	return ctx.Err()
+}
+
+// SetPresence records a user's presence in a session on a node, or removes it when nil
+func (b *MongoBroker) SetPresence(ctx context.Context, nodeID string, sessionID, userID primitive.ObjectID, presence *models.UserPresence) error {
+	id := nodeID + ":" + sessionID.Hex() + ":" + userID.Hex()
+
+	var err error
+	if presence != nil {
+		_, err = b.presence.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+			"$set": bson.M{
+				"node_id":    nodeID,
+				"session_id": sessionID,
+				"user_id":    userID,
+				"presence":   presence,
+				"updated_at": time.Now(),
+			},
+		}, options.Update().SetUpsert(true))
+	} else {
+		_, err = b.presence.DeleteOne(ctx, bson.M{"_id": id})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update presence: %w", err)
+	}
+	return nil
+}
+
+// RefreshPresence keeps a node's presence from expiring
+func (b *MongoBroker) RefreshPresence(ctx context.Context, nodeID string) error {
+	_, err := b.presence.UpdateMany(ctx, bson.M{"node_id": nodeID}, bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to refresh presence: %w", err)
+	}
+	return nil
+}
+
+// ConnectedUsers returns the users connected to a session on any node
+func (b *MongoBroker) ConnectedUsers(ctx context.Context, sessionID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	values, err := b.presence.Distinct(ctx, "user_id", bson.M{
+		"session_id": sessionID,
+		"updated_at": bson.M{"$gt": time.Now().Add(-presenceTTL)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connected users: %w", err)
+	}
+
+	users := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			users = append(users, id)
+		}
+	}
+	return users, nil
+}
+
+// SessionPresence returns the presence of each user connected to a session on any node
+func (b *MongoBroker) SessionPresence(ctx context.Context, sessionID primitive.ObjectID) ([]models.UserPresence, error) {
+	cursor, err := b.presence.Find(ctx, bson.M{
+		"session_id": sessionID,
+		"updated_at": bson.M{"$gt": time.Now().Add(-presenceTTL)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session presence: %w", err)
+	}
+
+	var docs []struct {
+		Presence models.UserPresence `bson:"presence"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode session presence: %w", err)
+	}
+
+	presences := make([]models.UserPresence, 0, len(docs))
+	for _, doc := range docs {
+		presences = append(presences, doc.Presence)
+	}
+	return mergePresence(presences), nil
+}
@@ -0,0 +1,339 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+const (
+	// presenceInterval is how often connected users' presence is recomputed
+	presenceInterval = 15 * time.Second
+
+	// PresenceIdleAfter and PresenceAwayAfter are how long a user may go without sending
+	// anything before they count as idle, then away
+	PresenceIdleAfter = 2 * time.Minute
+	PresenceAwayAfter = 10 * time.Minute
+
+	// typingExpiry is how long clients show a typing indicator unless it is repeated
+	typingExpiry = 6 * time.Second
+
+	// typingRepeatInterval is how often an unchanged typing indicator is passed on
+	typingRepeatInterval = 3 * time.Second
+
+	// readyCheckTimeout is how long players have to answer a ready check by default
+	readyCheckTimeout = 30 * time.Second
+)
+
+// presenceRank orders statuses from most to least present
+var presenceRank = map[models.PresenceStatus]int{
+	models.PresenceOnline: 0,
+	models.PresenceIdle:   1,
+	models.PresenceAway:   2,
+}
+
+// touch records activity from the client
+func (c *Client) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// presence works out this connection's presence from its last activity and ping
+func (c *Client) presence(now time.Time) models.UserPresence {
+	lastActive := time.Unix(0, c.lastActive.Load())
+
+	status := models.PresenceOnline
+	switch inactive := now.Sub(lastActive); {
+	case c.away.Load() || inactive >= PresenceAwayAfter:
+		status = models.PresenceAway
+	case inactive >= PresenceIdleAfter:
+		status = models.PresenceIdle
+	}
+
+	return models.UserPresence{
+		UserID:      c.UserID,
+		Username:    c.Username,
+		Role:        string(c.Role),
+		Status:      status,
+		LastActive:  lastActive,
+		LatencyMS:   time.Duration(c.latency.Load()).Milliseconds(),
+		Connections: 1,
+	}
+}
+
+// mergePresence combines presences reported for the same users, e.g. by several
+// connections or nodes: the most present status and latest activity win. The result is
+// sorted by username.
+func mergePresence(presences []models.UserPresence) []models.UserPresence {
+	byUser := make(map[primitive.ObjectID]*models.UserPresence)
+	var merged []*models.UserPresence
+	for _, presence := range presences {
+		existing, ok := byUser[presence.UserID]
+		if !ok {
+			p := presence
+			byUser[presence.UserID] = &p
+			merged = append(merged, &p)
+			continue
+		}
+
+		if presenceRank[presence.Status] < presenceRank[existing.Status] {
+			existing.Status = presence.Status
+		}
+		if presence.LastActive.After(existing.LastActive) {
+			existing.LastActive = presence.LastActive
+		}
+		if existing.LatencyMS == 0 || (presence.LatencyMS > 0 && presence.LatencyMS < existing.LatencyMS) {
+			existing.LatencyMS = presence.LatencyMS
+		}
+		existing.Connections += presence.Connections
+	}
+
+	result := make([]models.UserPresence, 0, len(merged))
+	for _, presence := range merged {
+		result = append(result, *presence)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Username < result[j].Username })
+	return result
+}
+
+// localPresence is a user's presence across their connections to a session on this node,
+// or nil if they have none. Callers must hold h.mu.
+func (h *Hub) localPresence(sessionID, userID primitive.ObjectID) *models.UserPresence {
+	now := time.Now()
+	var presences []models.UserPresence
+	for client := range h.Sessions[sessionID] {
+		if client.UserID == userID {
+			presences = append(presences, client.presence(now))
+		}
+	}
+	if len(presences) == 0 {
+		return nil
+	}
+	return &mergePresence(presences)[0]
+}
+
+// updatePresence recomputes the presence of every user connected to this node and shares
+// whatever changed
+func (h *Hub) updatePresence() {
+	type sessionUser struct{ sessionID, userID primitive.ObjectID }
+
+	h.mu.RLock()
+	var users []sessionUser
+	seen := make(map[sessionUser]bool)
+	for sessionID, clients := range h.Sessions {
+		for client := range clients {
+			key := sessionUser{sessionID, client.UserID}
+			if client.Role != RoleSpectator && !seen[key] {
+				seen[key] = true
+				users = append(users, key)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, user := range users {
+		h.sharePresence(ctx, user.sessionID, user.userID)
+	}
+}
+
+// sharePresence tells the broker about a user's presence on this node if it changed since
+// it was last shared, and announces status changes to the session. It returns false if
+// the broker couldn't be updated.
+func (h *Hub) sharePresence(ctx context.Context, sessionID, userID primitive.ObjectID) bool {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	h.mu.RLock()
+	presence := h.localPresence(sessionID, userID)
+	h.mu.RUnlock()
+
+	previous, shared := h.shared[sessionID][userID]
+	if presence != nil && shared && previous.Status == presence.Status &&
+		previous.Connections == presence.Connections && previous.LastActive.Equal(presence.LastActive) {
+		return true
+	}
+
+	if err := h.broker.SetPresence(ctx, h.nodeID, sessionID, userID, presence); err != nil {
+		log.Printf("Error updating presence: %v", err)
+		return false
+	}
+
+	if presence == nil {
+		delete(h.shared[sessionID], userID)
+		if len(h.shared[sessionID]) == 0 {
+			delete(h.shared, sessionID)
+		}
+		return true // Disconnects are announced with player_left
+	}
+	if h.shared[sessionID] == nil {
+		h.shared[sessionID] = make(map[primitive.ObjectID]models.UserPresence)
+	}
+	h.shared[sessionID][userID] = *presence
+
+	if shared && previous.Status == presence.Status {
+		return true
+	}
+
+	// Announce the status merged across nodes, in case the user is connected to others too
+	merged := *presence
+	if all, err := h.broker.SessionPresence(ctx, sessionID); err == nil {
+		for _, p := range all {
+			if p.UserID == userID {
+				merged = p
+			}
+		}
+	}
+	h.publishTransient(sessionID, models.WSMessage{
+		Type:      models.MessageTypePresence,
+		Timestamp: time.Now(),
+		UserID:    userID,
+		Username:  merged.Username,
+		SessionID: sessionID,
+		Data: models.PresenceUpdateData{
+			Users: []models.UserPresence{merged},
+		},
+	}, Audience{}, nil)
+	return true
+}
+
+// SessionPresence returns the presence of every user connected to a session, on any node
+func (h *Hub) SessionPresence(ctx context.Context, sessionID primitive.ObjectID) ([]models.UserPresence, error) {
+	return h.broker.SessionPresence(ctx, sessionID)
+}
+
+// sendPresence sends a client everyone's current presence. Presence changes aren't
+// replayed, so this follows every connect and resume.
+func (h *Hub) sendPresence(ctx context.Context, client *Client) {
+	presence, err := h.broker.SessionPresence(ctx, client.SessionID)
+	if err != nil {
+		log.Printf("Error loading presence: %v", err)
+		return
+	}
+	h.sendToClient(client, models.WSMessage{
+		Type:      models.MessageTypePresence,
+		Timestamp: time.Now(),
+		SessionID: client.SessionID,
+		Data: models.PresenceUpdateData{
+			Users:    presence,
+			Snapshot: true,
+		},
+	})
+}
+
+// handlePresence records whether the client says its user is away, e.g. because the tab
+// is hidden, and shares the change right away
+func (c *Client) handlePresence(req *request, data *models.PresenceData) {
+	c.away.Store(data.Status == models.PresenceAway)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !c.Hub.sharePresence(ctx, c.SessionID, c.UserID) {
+		c.sendError(req.RequestID, models.ErrorCodeFailed, "Failed to update presence")
+	}
+}
+
+// handleTyping passes a typing indicator on to the rest of the session. Repeats of the
+// same state are only passed on every typingRepeatInterval, which keeps the indicator
+// alive without flooding the session.
+func (c *Client) handleTyping(data *models.TypingData) {
+	now := time.Now()
+	if data.Typing == c.typing && data.IsIC == c.typingIC && now.Sub(c.typingSent) < typingRepeatInterval {
+		return
+	}
+	c.setTyping(data.Typing, data.IsIC)
+}
+
+// setTyping records and announces whether the client is typing
+func (c *Client) setTyping(typing, isIC bool) {
+	c.typing, c.typingIC, c.typingSent = typing, isIC, time.Now()
+
+	c.Hub.publishTransient(c.SessionID, models.WSMessage{
+		Type:      models.MessageTypeTyping,
+		Timestamp: c.typingSent,
+		UserID:    c.UserID,
+		Username:  c.Username,
+		SessionID: c.SessionID,
+		Data: models.TypingIndicatorData{
+			UserID:      c.UserID,
+			Username:    c.Username,
+			CharacterID: c.CharacterID,
+			IsIC:        isIC,
+			Typing:      typing,
+			ExpiresIn:   int(typingExpiry.Seconds()),
+		},
+	}, Audience{}, c)
+}
+
+// handleReadyCheck starts a ready check for every player in the session. Only the DM may
+// start one.
+func (c *Client) handleReadyCheck(req *request, data *models.ReadyCheckStartData) {
+	if c.Role != RoleDM {
+		c.sendError(req.RequestID, models.ErrorCodeForbidden, "Only the DM can start a ready check")
+		return
+	}
+
+	timeout := readyCheckTimeout
+	if data.Timeout > 0 {
+		timeout = time.Duration(data.Timeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	check, err := c.Hub.store.StartReadyCheck(ctx, c.SessionID, c.UserID, data.Prompt, timeout)
+	if err != nil {
+		c.sendError(req.RequestID, models.ErrorCodeFailed, "Failed to start ready check: "+err.Error())
+		return
+	}
+
+	c.Hub.broadcastReadyCheck(c.SessionID, check)
+	sessionID := c.SessionID
+	time.AfterFunc(time.Until(check.Deadline), func() {
+		c.Hub.finishReadyCheck(sessionID, check.StartedAt)
+	})
+}
+
+// handleReadyResponse records a player's answer to the open ready check
+func (c *Client) handleReadyResponse(req *request, data *models.ReadyResponseData) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	check, err := c.Hub.store.RespondReadyCheck(ctx, c.SessionID, c.UserID, *data.Ready)
+	if err != nil {
+		c.sendError(req.RequestID, models.ErrorCodeFailed, "Failed to answer ready check: "+err.Error())
+		return
+	}
+	c.Hub.broadcastReadyCheck(c.SessionID, check)
+}
+
+// finishReadyCheck closes a ready check once its deadline passes
+func (h *Hub) finishReadyCheck(sessionID primitive.ObjectID, startedAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	check, err := h.store.FinishReadyCheck(ctx, sessionID, startedAt)
+	if err != nil {
+		log.Printf("Failed to finish ready check for session %s: %v", sessionID.Hex(), err)
+		return
+	}
+	if check != nil {
+		h.broadcastReadyCheck(sessionID, check)
+	}
+}
+
+// broadcastReadyCheck sends a ready check's current state to the whole session
+func (h *Hub) broadcastReadyCheck(sessionID primitive.ObjectID, check *models.ReadyCheck) {
+	h.BroadcastToSession(sessionID, models.WSMessage{
+		Type:      models.MessageTypeReadyCheck,
+		Timestamp: time.Now(),
+		SessionID: sessionID,
+		Data: models.ReadyCheckData{
+			Check: check,
+		},
+	})
+}
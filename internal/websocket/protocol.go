@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gin-gonic/gin/binding"
+
+	"dnd-simulator/internal/models"
+)
+
+// request is a client message whose payload has been decoded and validated
+type request struct {
+	Type      string
+	RequestID string
+	Payload   interface{} // Pointer to the type's client payload struct
+}
+
+// requestError is why a client message was rejected, sent back as a MessageTypeError
+type requestError struct {
+	RequestID string
+	Code      string
+	Message   string
+}
+
+// decodeRequest parses a client message, checks its type and version, and decodes its
+// payload into the type's struct, rejecting unknown fields and failed validation
+func decodeRequest(raw []byte) (*request, *requestError) {
+	var msg models.ClientMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, &requestError{Code: models.ErrorCodeInvalidMessage, Message: "Message is not valid JSON"}
+	}
+	if len(msg.RequestID) > 64 {
+		return nil, &requestError{Code: models.ErrorCodeInvalidMessage, Message: "request_id is longer than 64 characters"}
+	}
+	reject := func(code, text string) (*request, *requestError) {
+		return nil, &requestError{RequestID: msg.RequestID, Code: code, Message: text}
+	}
+
+	if msg.Version > models.ProtocolVersion {
+		return reject(models.ErrorCodeUnsupportedVersion, fmt.Sprintf("Protocol version %d is not supported; the server speaks %d", msg.Version, models.ProtocolVersion))
+	}
+	spec, ok := models.Protocol[msg.Type]
+	if !ok || spec.Client == nil {
+		return reject(models.ErrorCodeUnsupportedType, fmt.Sprintf("Clients cannot send %q messages", msg.Type))
+	}
+
+	payload := reflect.New(reflect.TypeOf(spec.Client)).Interface()
+	if len(msg.Data) > 0 && !bytes.Equal(msg.Data, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(msg.Data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(payload); err != nil {
+			return reject(models.ErrorCodeInvalidMessage, fmt.Sprintf("Invalid %s payload: %v", msg.Type, err))
+		}
+	}
+	if err := binding.Validator.ValidateStruct(payload); err != nil {
+		return reject(models.ErrorCodeInvalidMessage, fmt.Sprintf("Invalid %s payload: %v", msg.Type, err))
+	}
+
+	return &request{Type: msg.Type, RequestID: msg.RequestID, Payload: payload}, nil
+}
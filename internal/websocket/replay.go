@@ -0,0 +1,279 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+// ReplayBufferSize is how many recent messages per session are kept for resuming clients
+const ReplayBufferSize = 500
+
+// replayRetention is how long a session's replay buffer outlives its last connection
+const replayRetention = 15 * time.Minute
+
+// replayEntry is one sequenced message, kept so it can be delivered again on resume
+type replayEntry struct {
+	seq      uint64
+	data     []byte   // Marshaled message
+	audience Audience // Empty for the whole session
+}
+
+// sessionLog keeps a session's most recent messages. Sequence numbers come from the broker
+// and increase, but aren't contiguous within a session.
+type sessionLog struct {
+	seq      uint64 // Newest message buffered
+	floor    uint64 // Every message after this is buffered
+	entries  []replayEntry
+	lastUsed time.Time
+}
+
+// add keeps an entry, dropping the oldest once the buffer is full
+func (l *sessionLog) add(entry replayEntry) {
+	l.seq = entry.seq
+	l.lastUsed = time.Now()
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > ReplayBufferSize {
+		dropped := len(l.entries) - ReplayBufferSize
+		l.floor = l.entries[dropped-1].seq
+		l.entries = l.entries[dropped:]
+	}
+}
+
+// since returns the entries after seq, or false if some of them are no longer buffered
+// (or seq comes from before a server restart)
+func (l *sessionLog) since(seq uint64) ([]replayEntry, bool) {
+	if seq > l.seq || seq < l.floor {
+		return nil, false
+	}
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].seq > seq })
+	return l.entries[i:], true
+}
+
+// sessionLogFor returns a session's log, creating it if needed. Callers must hold h.mu.
+func (h *Hub) sessionLogFor(sessionID primitive.ObjectID) *sessionLog {
+	sl, ok := h.logs[sessionID]
+	if !ok {
+		sl = &sessionLog{seq: h.floor, floor: h.floor, lastUsed: time.Now()}
+		h.logs[sessionID] = sl
+	}
+	return sl
+}
+
+// currentSeq returns the newest sequence number this hub has seen in a session
+func (h *Hub) currentSeq(sessionID primitive.ObjectID) uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if sl, ok := h.logs[sessionID]; ok {
+		return sl.seq
+	}
+	return h.floor
+}
+
+// publish sends a message through the broker to the clients in the audience (the whole
+// session when empty) on every hub, skipping exclude
+func (h *Hub) publish(sessionID primitive.ObjectID, message models.WSMessage, audience Audience, exclude *Client) {
+	h.publishEnvelope(sessionID, message, audience, exclude, false)
+}
+
+// publishTransient publishes a message that only matters live, like a typing indicator.
+// It gets no sequence number and isn't replayed to resuming clients.
+func (h *Hub) publishTransient(sessionID primitive.ObjectID, message models.WSMessage, audience Audience, exclude *Client) {
+	h.publishEnvelope(sessionID, message, audience, exclude, true)
+}
+
+func (h *Hub) publishEnvelope(sessionID primitive.ObjectID, message models.WSMessage, audience Audience, exclude *Client, transient bool) {
+	if !transient && audience.IsEmpty() && message.Type == models.MessageTypeDiceResult {
+		go h.recordRoll(sessionID, message) // Hidden and self rolls have an audience
+	}
+
+	message.Version = models.ProtocolVersion
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	env := &Envelope{
+		SessionID: sessionID,
+		Payload:   payload,
+		UserIDs:   audience.UserIDs,
+		Roles:     audience.Roles,
+		Transient: transient,
+	}
+	if exclude != nil {
+		env.ExcludeClient = exclude.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.broker.Publish(ctx, env); err != nil {
+		log.Printf("Error publishing message: %v", err)
+	}
+}
+
+// receive numbers a message from the broker, buffers it for replay unless it is transient,
+// and delivers it to this hub's clients in its audience
+func (h *Hub) receive(env *Envelope) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.receiving {
+		// Everything from here on reaches this hub; nothing before it is buffered
+		h.floor = env.Seq - 1
+		h.receiving = true
+	}
+	sl := h.sessionLogFor(env.SessionID)
+	if env.Seq <= sl.seq {
+		return // Redelivered after the broker resumed
+	}
+	if !env.RevokedLink.IsZero() {
+		h.dropSpectators(env.SessionID, env.RevokedLink)
+		return
+	}
+
+	messageBytes := env.Payload
+	audience := env.Audience()
+	if !env.Transient {
+		var message models.WSMessage
+		if err := json.Unmarshal(env.Payload, &message); err != nil {
+			log.Printf("Error parsing broker message: %v", err)
+			return
+		}
+		message.Seq = env.Seq
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Error marshaling message: %v", err)
+			return
+		}
+		messageBytes = data
+		sl.add(replayEntry{seq: env.Seq, data: messageBytes, audience: audience})
+	}
+
+	var slow []*Client
+	var spectators *spectatorView // Worked out once, if the session has spectators here
+	for client := range h.Sessions[env.SessionID] {
+		if client.ID == env.ExcludeClient || (!audience.IsEmpty() && !audience.includes(client)) {
+			continue
+		}
+		if client.spectator != nil {
+			if spectators == nil {
+				view := viewForSpectators(env.Payload)
+				spectators = &view
+			}
+			if spectators.visible && !h.queueForSpectator(client, env.Payload, *spectators) {
+				slow = append(slow, client)
+			}
+			continue
+		}
+		if !h.deliver(client, messageBytes) {
+			slow = append(slow, client)
+		}
+	}
+	h.dropSlowClients(slow)
+}
+
+// resume sends a client everything it missed after lastSeq. If that is no longer buffered,
+// it gets a fresh snapshot of the game state and chat instead.
+func (h *Hub) resume(ctx context.Context, client *Client, lastSeq uint64, requestID string) {
+	h.mu.Lock()
+	replayed := h.replay(client, lastSeq, requestID)
+	h.mu.Unlock()
+
+	if !replayed {
+		h.sendSnapshot(ctx, client, lastSeq, requestID)
+	} else {
+		h.sendPresence(ctx, client)
+	}
+}
+
+// registerAndResume registers a reconnecting client and replays its gap in one step, so no
+// live message can overtake the replay. It reports false if the draining hub refused the client.
+func (h *Hub) registerAndResume(ctx context.Context, client *Client, lastSeq uint64, requestID string) bool {
+	h.mu.Lock()
+	if !h.addClient(client) {
+		h.mu.Unlock()
+		return false
+	}
+	replayed := h.replay(client, lastSeq, requestID)
+	h.mu.Unlock()
+
+	if !replayed {
+		h.sendSnapshot(ctx, client, lastSeq, requestID)
+	} else {
+		h.sendPresence(ctx, client)
+	}
+	return true
+}
+
+// replay delivers the buffered messages after lastSeq that the client may see, and reports
+// false if they are no longer all buffered. Callers must hold h.mu.
+func (h *Hub) replay(client *Client, lastSeq uint64, requestID string) bool {
+	var missed []replayEntry
+	ok := h.receiving && lastSeq >= h.floor // Without a log, nothing was sent since the floor
+	if sl, exists := h.logs[client.SessionID]; exists {
+		missed, ok = sl.since(lastSeq)
+	}
+	if !ok {
+		return false
+	}
+
+	for _, entry := range missed {
+		if !entry.audience.IsEmpty() && !entry.audience.includes(client) {
+			continue
+		}
+		if !h.deliver(client, entry.data) {
+			h.dropSlowClients([]*Client{client})
+			return true
+		}
+	}
+
+	seq := lastSeq
+	if len(missed) > 0 {
+		seq = missed[len(missed)-1].seq
+	}
+	h.sendLocked(client, resumedMessage(client, requestID, models.ResumedData{
+		LastSeq:  lastSeq,
+		Seq:      seq,
+		Replayed: len(missed),
+	}))
+	return true
+}
+
+// sendSnapshot catches up a client whose gap is too old to replay
+func (h *Hub) sendSnapshot(ctx context.Context, client *Client, lastSeq uint64, requestID string) {
+	seq := h.sendInitialState(ctx, client)
+	h.sendToClient(client, resumedMessage(client, requestID, models.ResumedData{
+		LastSeq:  lastSeq,
+		Seq:      seq,
+		Snapshot: true,
+	}))
+}
+
+// resumedMessage tells a client where its stream now stands after a resume
+func resumedMessage(client *Client, requestID string, data models.ResumedData) models.WSMessage {
+	return models.WSMessage{
+		Type:      models.MessageTypeResumed,
+		Timestamp: time.Now(),
+		SessionID: client.SessionID,
+		RequestID: requestID,
+		Data:      data,
+	}
+}
+
+// pruneLogs forgets the replay buffers of sessions nobody has been connected to for a
+// while. Callers must hold h.mu.
+func (h *Hub) pruneLogs() {
+	for sessionID, sl := range h.logs {
+		if len(h.Sessions[sessionID]) == 0 && time.Since(sl.lastUsed) > replayRetention {
+			delete(h.logs, sessionID)
+		}
+	}
+}
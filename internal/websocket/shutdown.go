@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+const (
+	// reconnectDelay and reconnectJitter spread out reconnects after a restart, so clients
+	// don't all arrive at once
+	reconnectDelay  = 2 * time.Second
+	reconnectJitter = 5 * time.Second
+
+	// restartRetryAfter is the Retry-After sent to upgrades refused while draining
+	restartRetryAfter = "5"
+)
+
+// Draining reports whether the hub is shutting down and refusing new connections
+func (h *Hub) Draining() bool {
+	return h.draining.Load()
+}
+
+// closeMessage is the close frame sent when the server ends a connection
+func (h *Hub) closeMessage() []byte {
+	if h.Draining() {
+		return websocket.FormatCloseMessage(websocket.CloseServiceRestart, "Server restarting")
+	}
+	return []byte{}
+}
+
+// Shutdown drains the hub before the server stops. New connections are refused. Every
+// client is told the server is restarting and when to reconnect. Pending messages are
+// flushed, and sockets are closed with a service-restart code. This node's presence is
+// then withdrawn. Shutdown returns when that is done or ctx expires.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.draining.Store(true)
+
+	type member struct {
+		sessionID primitive.ObjectID
+		userID    primitive.ObjectID
+	}
+	members := make(map[member]bool)
+
+	h.mu.Lock()
+	var clients []*Client
+	for _, sessionClients := range h.Sessions {
+		for client := range sessionClients {
+			clients = append(clients, client)
+		}
+	}
+	for _, client := range clients {
+		if client.Role != RoleSpectator {
+			members[member{client.SessionID, client.UserID}] = true
+		}
+		h.sendLocked(client, models.WSMessage{
+			Type:      models.MessageTypeServerRestarting,
+			Timestamp: time.Now(),
+			SessionID: client.SessionID,
+			Data: models.ServerRestartingData{
+				Message:     "The server is restarting; reconnect to carry on",
+				ReconnectIn: int((reconnectDelay + time.Duration(rand.Int63n(int64(reconnectJitter)))).Seconds()),
+			},
+		})
+		h.removeClient(client) // Closes Send, so writePump flushes it and closes the socket
+	}
+	h.mu.Unlock()
+	log.Printf("Draining %d WebSocket connections", len(clients))
+
+	flushed := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush WebSocket clients: %w", ctx.Err())
+	}
+
+	// Announcements were skipped while draining; withdraw this node's presence and record
+	// who is still connected elsewhere
+	for m := range members {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("failed to withdraw presence: %w", err)
+		}
+		h.syncConnection(m.sessionID, m.userID)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package websocket
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/config"
+)
+
+// A client that arrives after draining began is refused rather than added behind
+// Shutdown's back, and its writer isn't counted
+func TestDrainingHubRefusesClients(t *testing.T) {
+	hub := NewHub(presenceStore{}, NewMemoryBroker(), config.WSLimits{})
+	sessionID := primitive.NewObjectID()
+
+	early := &Client{SessionID: sessionID, Role: RoleSpectator, Send: make(chan []byte, 1)}
+	if !hub.registerClient(early) {
+		t.Fatal("refused a client before draining")
+	}
+	hub.writers.Done() // Stands in for the writePump the handler would start
+
+	hub.draining.Store(true)
+	late := &Client{SessionID: sessionID, Role: RoleSpectator, Send: make(chan []byte, 1)}
+	if hub.registerClient(late) {
+		t.Fatal("added a client while draining")
+	}
+	if hub.Sessions[sessionID][late] {
+		t.Error("the refused client is in its session")
+	}
+	hub.writers.Wait() // Hangs if the refused client's writer was counted
+}
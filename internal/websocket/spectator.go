@@ -0,0 +1,311 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/models"
+)
+
+// spectatorQueueSize is how many held-back messages a spectator may have waiting
+const spectatorQueueSize = 512
+
+// spectatorMessageTypes are the messages spectators receive: public chat, public dice
+// rolls and the revealed parts of the map. Private messages never reach them, since their
+// audience doesn't include spectators.
+var spectatorMessageTypes = map[string]bool{
+	models.MessageTypeChat:        true,
+	models.MessageTypeChatIC:      true,
+	models.MessageTypeChatOOC:     true,
+	models.MessageTypeChatEdited:  true,
+	models.MessageTypeChatDeleted: true,
+	models.MessageTypeDiceRoll:    true,
+	models.MessageTypeDiceResult:  true,
+	models.MessageTypeMapUpdate:   true,
+	models.MessageTypeTokenMoved:  true,
+}
+
+// spectatorFeed holds a spectator's messages back for their link's delay, so a stream
+// can't be used to see the game live
+type spectatorFeed struct {
+	linkID    primitive.ObjectID
+	delay     time.Duration
+	queue     chan delayedMessage
+	done      chan struct{}                // Closed when the spectator disconnects
+	retracted map[primitive.ObjectID]bool // Chat deleted while still held back; guarded by h.mu
+}
+
+type delayedMessage struct {
+	at     time.Time
+	data   []byte
+	chatID primitive.ObjectID // The chat message it shows, if any
+}
+
+// spectatorView is what spectators may know about a message
+type spectatorView struct {
+	visible   bool
+	chatID    primitive.ObjectID // Chat message shown
+	deletedID primitive.ObjectID // Chat message deleted
+}
+
+// viewForSpectators decides whether spectators may see a message, and which chat message
+// it shows or deletes
+func viewForSpectators(payload []byte) spectatorView {
+	var header struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &header); err != nil || !spectatorMessageTypes[header.Type] {
+		return spectatorView{}
+	}
+
+	view := spectatorView{visible: true}
+	switch header.Type {
+	case models.MessageTypeChat, models.MessageTypeChatIC, models.MessageTypeChatOOC:
+		var data models.ChatMessageData
+		if json.Unmarshal(header.Data, &data) == nil {
+			view.chatID = data.ID
+		}
+	case models.MessageTypeChatDeleted:
+		var data models.ChatDeletedData
+		if json.Unmarshal(header.Data, &data) == nil {
+			view.deletedID = data.MessageID
+		}
+	}
+	return view
+}
+
+// queueForSpectator holds a message back for a spectator, and reports false if their queue
+// is full. A deletion takes effect at once, so chat deleted within the delay is never
+// shown. Callers must hold h.mu.
+func (h *Hub) queueForSpectator(client *Client, payload []byte, view spectatorView) bool {
+	feed := client.spectator
+	if !view.deletedID.IsZero() {
+		feed.retracted[view.deletedID] = true
+	}
+
+	select {
+	case feed.queue <- delayedMessage{at: time.Now().Add(feed.delay), data: payload, chatID: view.chatID}:
+		return true
+	default:
+		return false
+	}
+}
+
+// runSpectatorFeed delivers a spectator's messages once their delay has passed
+func (h *Hub) runSpectatorFeed(client *Client) {
+	feed := client.spectator
+	for {
+		select {
+		case <-feed.done:
+			return
+		case msg := <-feed.queue:
+			if wait := time.Until(msg.at); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-feed.done:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+
+			h.mu.Lock()
+			if msg.chatID.IsZero() || !feed.retracted[msg.chatID] {
+				if !h.deliver(client, msg.data) {
+					h.dropSlowClients([]*Client{client})
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// HandleSpectatorWebSocket connects a read-only spectator to the session of the link the
+// spectator middleware verified. The connection closes when the link expires or is revoked.
+func (h *Hub) HandleSpectatorWebSocket(c *gin.Context) {
+	value, exists := c.Get("spectator_link")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Spectator link not verified"})
+		return
+	}
+	link := value.(*models.SpectatorLink)
+	if h.Draining() {
+		c.Header("Retry-After", restartRetryAfter)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is restarting"})
+		return
+	}
+
+	// Load public chat before connecting; anything newer than the delay is held back
+	history, err := h.store.RecentChatMessages(c.Request.Context(), link.SessionID, primitive.NilObjectID, ChatHistoryOnConnect)
+	if err != nil {
+		log.Printf("Error loading chat history: %v", err)
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+
+	username := "Spectator"
+	if link.Label != "" {
+		username = link.Label
+	}
+	client := &Client{
+		ID:        primitive.NewObjectID().Hex(),
+		Conn:      conn,
+		Hub:       h,
+		Send:      make(chan []byte, 256),
+		Username:  username,
+		SessionID: link.SessionID,
+		Role:      RoleSpectator,
+		spectator: &spectatorFeed{
+			linkID:    link.ID,
+			delay:     link.Delay(),
+			queue:     make(chan delayedMessage, spectatorQueueSize),
+			done:      make(chan struct{}),
+			retracted: make(map[primitive.ObjectID]bool),
+		},
+	}
+
+	h.mu.Lock()
+	if !h.addClient(client) {
+		h.mu.Unlock()
+		h.refuseClient(client)
+		return
+	}
+	h.sendSpectatorHistory(client, history)
+	h.mu.Unlock()
+
+	go h.runSpectatorFeed(client)
+	time.AfterFunc(time.Until(link.ExpiresAt), func() {
+		h.disconnectClient(client)
+	})
+
+	go client.writePump() // addClient counted the writer
+	go client.readPump()
+}
+
+// sendSpectatorHistory sends a new spectator the chat old enough to show now and holds
+// the rest back until its delay passes. Callers must hold h.mu.
+func (h *Hub) sendSpectatorHistory(client *Client, history []models.SessionChatMessage) {
+	cutoff := time.Now().Add(-client.spectator.delay)
+
+	shown := []models.SessionChatMessage{}
+	for _, msg := range history {
+		if msg.Timestamp.Before(cutoff) {
+			shown = append(shown, msg)
+			continue
+		}
+
+		messageType := models.MessageTypeChatOOC
+		if msg.Type == "ic" {
+			messageType = models.MessageTypeChatIC
+		}
+		data, err := json.Marshal(models.WSMessage{
+			Type:      messageType,
+			Version:   models.ProtocolVersion,
+			Timestamp: msg.Timestamp,
+			UserID:    msg.UserID,
+			Username:  msg.Username,
+			SessionID: msg.SessionID,
+			Data: models.ChatMessageData{
+				ID:          msg.ID,
+				Content:     msg.Message,
+				CharacterID: msg.CharacterID,
+				IsIC:        msg.Type == "ic",
+				Visibility:  models.ChatVisibilityPublic,
+			},
+		})
+		if err != nil {
+			log.Printf("Error marshaling message: %v", err)
+			continue
+		}
+		select {
+		case client.spectator.queue <- delayedMessage{at: msg.Timestamp.Add(client.spectator.delay), data: data, chatID: msg.ID}:
+		default:
+		}
+	}
+
+	h.sendLocked(client, models.WSMessage{
+		Type:      models.MessageTypeChatHistory,
+		Timestamp: time.Now(),
+		SessionID: client.SessionID,
+		Data: models.ChatHistoryData{
+			Messages: shown,
+		},
+	})
+}
+
+// disconnectClient closes a client's connection from the server side
+func (h *Hub) disconnectClient(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeClient(client)
+}
+
+// DisconnectSpectators closes every spectator connection using a link, on every node, e.g.
+// after the DM revokes it
+func (h *Hub) DisconnectSpectators(sessionID, linkID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := h.broker.Publish(ctx, &Envelope{
+		SessionID:   sessionID,
+		RevokedLink: linkID,
+		Transient:   true,
+	})
+	if err != nil {
+		log.Printf("Error publishing spectator disconnect: %v", err)
+	}
+}
+
+// dropSpectators disconnects this node's spectators using a link. Callers must hold h.mu.
+func (h *Hub) dropSpectators(sessionID, linkID primitive.ObjectID) {
+	for client := range h.Sessions[sessionID] {
+		if client.spectator != nil && client.spectator.linkID == linkID {
+			h.removeClient(client)
+		}
+	}
+}
+
+// recordRoll stores a public dice roll, so overlays on every node can show it once their
+// spectator delay has passed. Only the server's dice_result rolls are recorded: a client's
+// dice_roll is relayed as sent, so a player could put any number on the overlay.
+func (h *Hub) recordRoll(sessionID primitive.ObjectID, message models.WSMessage) {
+	data, err := json.Marshal(message.Data)
+	if err != nil {
+		log.Printf("Error marshaling dice roll: %v", err)
+		return
+	}
+	var result models.DiceResultData
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("Error parsing dice roll: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.store.RecordRoll(ctx, sessionID, &models.OverlayRoll{
+		Username:    message.Username,
+		CharacterID: result.CharacterID,
+		Dice:        result.Dice,
+		Result:      result.Result,
+		Total:       result.Total,
+		Modifier:    result.Modifier,
+		Purpose:     result.Purpose,
+		Timestamp:   message.Timestamp,
+	})
+	if err != nil {
+		log.Printf("Error recording dice roll: %v", err)
+	}
+}
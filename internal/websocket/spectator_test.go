@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"dnd-simulator/internal/config"
+	"dnd-simulator/internal/models"
+)
+
+// rollStore is a SessionStore that only records the rolls kept for overlays
+type rollStore struct {
+	SessionStore
+	rolls chan *models.OverlayRoll
+}
+
+func (s rollStore) RecordRoll(ctx context.Context, sessionID primitive.ObjectID, roll *models.OverlayRoll) error {
+	s.rolls <- roll
+	return nil
+}
+
+// A client's dice_roll is relayed as sent, so only the server's dice_result reaches overlays
+func TestOnlyServerRollsAreRecorded(t *testing.T) {
+	store := rollStore{rolls: make(chan *models.OverlayRoll, 2)}
+	hub := NewHub(store, NewMemoryBroker(), config.WSLimits{})
+	sessionID := primitive.NewObjectID()
+
+	hub.publish(sessionID, models.WSMessage{
+		Type:     models.MessageTypeDiceRoll,
+		Username: "cheater",
+		Data:     models.DiceRollData{Dice: "1d20", Result: []int{20}, Total: 20},
+	}, Audience{}, nil)
+	hub.publish(sessionID, models.WSMessage{
+		Type:     models.MessageTypeDiceResult,
+		Username: "player",
+		Data:     models.DiceResultData{Dice: "1d20", Result: []int{7}, Total: 7},
+	}, Audience{}, nil)
+
+	select {
+	case roll := <-store.rolls:
+		if roll.Username != "player" || roll.Total != 7 {
+			t.Errorf("recorded %+v, want the server's roll", roll)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the server's roll was not recorded")
+	}
+	select {
+	case roll := <-store.rolls:
+		t.Errorf("recorded a client roll: %+v", roll)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
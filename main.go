@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"dnd-simulator/internal/auth"
@@ -23,29 +29,80 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.Close()
 
 	// Initialize services
 	jwtService := auth.NewJWTService(cfg.JWTSecret)
 	userService := services.NewUserService(db)
 	campaignService := services.NewCampaignService(db)
 	characterService := services.NewCharacterService(db)
-	sessionService := services.NewSessionService(db)
 	diceService := services.NewDiceService()
-	aiService := services.NewAIService(cfg)
+	sessionService := services.NewSessionService(db, diceService)
+	llms := services.NewLLMRegistry(cfg)
+	aiService := services.NewAIService(db, llms)
 	eventService := services.NewEventService(db)
+	monsterService := services.NewMonsterService()
+	encounterService := services.NewEncounterService(db, diceService, eventService)
+	combatService := services.NewCombatService(db, sessionService, diceService, eventService)
+	mapService := services.NewMapService(db, sessionService, combatService)
+	chatService := services.NewChatService(db, sessionService)
+	spectatorService := services.NewSpectatorService(db, sessionService, mapService, eventService)
+	mechanicsService := services.NewMechanicsService(db, sessionService, combatService, diceService)
 
-	// Initialize WebSocket hub and start it
-	hub := websocket.NewHub()
-	go hub.Run()
+	// Prepare the chat, spectator link, AI mechanics and AI response stores, and move any chat
+	// still embedded in sessions into the chat store
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), 2*time.Minute)
+	if err := chatService.EnsureIndexes(migrateCtx); err != nil {
+		log.Fatal("Failed to prepare chat store:", err)
+	}
+	if moved, err := chatService.MigrateEmbeddedChat(migrateCtx); err != nil {
+		log.Fatal("Failed to migrate chat history:", err)
+	} else if moved > 0 {
+		log.Printf("Moved %d embedded chat messages to chat_messages", moved)
+	}
+	if err := spectatorService.EnsureIndexes(migrateCtx); err != nil {
+		log.Fatal("Failed to prepare spectator links:", err)
+	}
+	if err := mechanicsService.EnsureIndexes(migrateCtx); err != nil {
+		log.Fatal("Failed to prepare AI mechanics queue:", err)
+	}
+	if err := aiService.EnsureIndexes(migrateCtx); err != nil {
+		log.Fatal("Failed to prepare AI response claims:", err)
+	}
+	cancelMigrate()
+
+	// Initialize WebSocket hub and start it. With the mongo broker, hubs on every replica
+	// share session traffic and presence.
+	var broker websocket.Broker = websocket.NewMemoryBroker()
+	if cfg.HubBroker == "mongo" {
+		mongoBroker := websocket.NewMongoBroker(db)
+		indexCtx, cancelIndex := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := mongoBroker.CheckReplicaSet(indexCtx); err != nil {
+			log.Fatal("Failed to prepare hub broker:", err)
+		}
+		if err := mongoBroker.EnsureIndexes(indexCtx); err != nil {
+			log.Fatal("Failed to prepare hub broker:", err)
+		}
+		cancelIndex()
+		broker = mongoBroker
+	}
+	hub := websocket.NewHub(services.NewHubStore(sessionService, chatService, eventService), broker, cfg.WSLimits)
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	go hub.Run(hubCtx)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userService, jwtService)
-	campaignHandler := handlers.NewCampaignHandler(campaignService)
+	campaignHandler := handlers.NewCampaignHandler(campaignService, llms)
 	characterHandler := handlers.NewCharacterHandler(characterService)
-	sessionHandler := handlers.NewSessionHandler(sessionService, campaignService)
-	wsHandler := handlers.NewWebSocketHandler(hub, diceService)
-	aiHandler := handlers.NewAIHandler(aiService, sessionService, characterService, campaignService, eventService)
+	sessionHandler := handlers.NewSessionHandler(sessionService, campaignService, hub)
+	wsHandler := handlers.NewWebSocketHandler(hub, diceService, chatService)
+	aiHandler := handlers.NewAIHandler(aiService, llms, sessionService, characterService, campaignService, eventService, mechanicsService, hub)
+	mechanicsHandler := handlers.NewMechanicsHandler(mechanicsService, hub)
+	monsterHandler := handlers.NewMonsterHandler(monsterService)
+	encounterHandler := handlers.NewEncounterHandler(encounterService)
+	combatHandler := handlers.NewCombatHandler(combatService, hub)
+	mapHandler := handlers.NewMapHandler(mapService, hub)
+	chatHandler := handlers.NewChatHandler(chatService, hub)
+	spectatorHandler := handlers.NewSpectatorHandler(spectatorService, hub)
 
 	// Setup router
 	r := gin.Default()
@@ -85,7 +142,7 @@ func main() {
 			campaigns.DELETE("/:id", middleware.DMMiddleware(campaignService), campaignHandler.DeleteCampaign)              // Delete campaign (DM only)
 			campaigns.POST("/:id/join", campaignHandler.JoinCampaign)             // Join campaign
 			campaigns.POST("/:id/leave", campaignHandler.LeaveCampaign)           // Leave campaign
-			campaigns.GET("/:id/sessions", sessionHandler.GetCampaignSessions)    // Get campaign sessions
+			campaigns.GET("/:id/sessions", middleware.CampaignMemberMiddleware(campaignService), sessionHandler.GetCampaignSessions) // Get campaign sessions (members only)
 		}
 
 		// Character routes
@@ -105,13 +162,20 @@ func main() {
 			dnd.GET("/races", characterHandler.GetRaces)                          // Get available races
 			dnd.GET("/classes", characterHandler.GetClasses)                      // Get available classes
 			dnd.GET("/backgrounds", characterHandler.GetBackgrounds)              // Get available backgrounds
+			dnd.GET("/monsters", monsterHandler.GetMonsters)                      // Search SRD monsters
+			dnd.GET("/monsters/:slug", monsterHandler.GetMonster)                 // Get monster stat block
 		}
 
+		// AI DM
+		api.GET("/ai/providers", middleware.AuthMiddleware(jwtService), aiHandler.GetProviders) // LLM providers campaigns can pick
+
 		// Game Session routes
-		sessions := api.Group("/sessions", middleware.AuthMiddleware(jwtService))
+		api.POST("/sessions", middleware.AuthMiddleware(jwtService), sessionHandler.CreateSession) // Create session
+
+		// Every /sessions/:id route is limited to the session's DM, players and campaign members
+		sessions := api.Group("/sessions", middleware.AuthMiddleware(jwtService), middleware.SessionMemberMiddleware(sessionService, campaignService))
 		{
 			// Session management
-			sessions.POST("", sessionHandler.CreateSession)                       // Create session
 			sessions.GET("/:id", sessionHandler.GetSession)                       // Get session details
 			sessions.POST("/:id/join", sessionHandler.JoinSession)                // Join session
 			sessions.POST("/:id/leave", sessionHandler.LeaveSession)              // Leave session
@@ -119,9 +183,49 @@ func main() {
 			
 			// Turn management
 			sessions.POST("/:id/initiative", sessionHandler.SetInitiative)        // Set initiative
+			sessions.POST("/:id/initiative/roll", sessionHandler.RollInitiative)  // Roll initiative for the table (DM only)
+			sessions.POST("/:id/initiative/self", sessionHandler.RollOwnInitiative) // Roll own initiative during the roll window
 			sessions.POST("/:id/turn/advance", sessionHandler.AdvanceTurn)        // Advance turn (DM only)
+			sessions.POST("/:id/turn/delay", combatHandler.DelayTurn)             // Delay to a later initiative
+			sessions.POST("/:id/turn/lair", sessionHandler.AddLairActions)        // Add lair actions at initiative 20 (DM only)
 			sessions.PUT("/:id/scene", sessionHandler.UpdateScene)                // Update scene (DM only)
 			
+			// Encounters
+			sessions.POST("/:id/encounters", encounterHandler.CreateEncounter)                           // Create encounter (DM only)
+			sessions.GET("/:id/encounters", encounterHandler.GetEncounters)                              // List session encounters
+			sessions.POST("/:id/encounters/preview", encounterHandler.PreviewEncounter)                  // Preview encounter difficulty
+			sessions.GET("/:id/encounters/:encounterId", encounterHandler.GetEncounter)                  // Get encounter details
+			sessions.POST("/:id/encounters/:encounterId/monsters", encounterHandler.AddMonsters)         // Add monsters (DM only)
+			sessions.GET("/:id/encounters/:encounterId/difficulty", encounterHandler.GetEncounterDifficulty) // Get encounter difficulty
+			sessions.POST("/:id/encounters/:encounterId/end", encounterHandler.EndEncounter)             // End encounter, award XP (DM only)
+			
+			// Combat actions
+			sessions.POST("/:id/combat/attack", combatHandler.Attack)            // Attack (action or bonus action)
+			sessions.POST("/:id/combat/cast", combatHandler.CastSpell)           // Cast a spell
+			sessions.POST("/:id/combat/dash", combatHandler.Dash)                // Dash
+			sessions.POST("/:id/combat/move", combatHandler.Move)                // Spend movement (sessions without a map)
+			sessions.POST("/:id/combat/interact", combatHandler.Interact)        // Free object interaction
+			sessions.POST("/:id/combat/ready", combatHandler.Ready)              // Ready an action with a trigger
+			sessions.POST("/:id/combat/ready/trigger", combatHandler.TriggerReadied) // Take a readied action
+			sessions.POST("/:id/combat/reaction", combatHandler.Reaction)        // Reaction interrupt (DM only)
+			sessions.POST("/:id/combat/lair", combatHandler.LairAction)          // Lair action (DM only)
+			sessions.POST("/:id/combat/legendary", combatHandler.LegendaryAction) // Legendary action (DM only)
+			sessions.POST("/:id/combat/attack/resolve", combatHandler.ResolveAttack) // Roll to hit and damage against a target
+			sessions.POST("/:id/combat/area/preview", combatHandler.PreviewArea) // Preview an area-of-effect template
+			sessions.POST("/:id/combat/area/resolve", combatHandler.ResolveArea) // Resolve saves and damage for an area effect
+			sessions.GET("/:id/combat/economy", combatHandler.GetEconomy)        // Remaining action economy
+			
+			// Battle map
+			sessions.GET("/:id/map", mapHandler.GetMap)                          // Get the visible part of the battle map
+			sessions.PUT("/:id/map", mapHandler.SetMap)                          // Create or resize map, set terrain (DM only)
+			sessions.POST("/:id/map/tokens", mapHandler.PlaceToken)              // Place a token (DM only)
+			sessions.DELETE("/:id/map/tokens/:tokenId", mapHandler.RemoveToken)  // Remove a token (DM only)
+			sessions.POST("/:id/map/tokens/:tokenId/move", mapHandler.MoveToken) // Move a token along a path
+			sessions.POST("/:id/map/lights", mapHandler.AddLight)                // Place a light source (DM only)
+			sessions.DELETE("/:id/map/lights/:lightId", mapHandler.RemoveLight)  // Remove a light source (DM only)
+			sessions.POST("/:id/map/reveal", mapHandler.RevealRegion)            // Reveal cells to players (DM only)
+			sessions.POST("/:id/map/hide", mapHandler.HideRegion)                // Hide cells from players (DM only)
+			
 			// Session state management
 			sessions.POST("/:id/end", sessionHandler.EndSession)                  // End session (DM only)
 			sessions.POST("/:id/pause", sessionHandler.PauseSession)              // Pause session (DM only)
@@ -130,21 +234,74 @@ func main() {
 			
 			// AI DM features
 			sessions.POST("/:id/action", aiHandler.ProcessPlayerAction)           // Process player action with AI
+			sessions.POST("/:id/action/stream", aiHandler.StreamPlayerAction)    // Same, streaming the narration as server-sent events
+			sessions.POST("/:id/action/interrupt", aiHandler.InterruptResponse)  // Stop the AI DM mid-response (DM only)
 			sessions.GET("/:id/narrative", aiHandler.GetNarrativeHistory)        // Get narrative history
 			sessions.GET("/:id/events/:type", aiHandler.GetEventsByType)         // Get events by type
+			sessions.GET("/:id/mechanics", mechanicsHandler.ListChanges)         // AI-proposed changes to review (?status=, DM only)
+			sessions.PUT("/:id/mechanics/:changeId", mechanicsHandler.EditChange) // Edit a proposed change (DM only)
+			sessions.POST("/:id/mechanics/:changeId/approve", mechanicsHandler.ApproveChange) // Apply a proposed change (DM only)
+			sessions.POST("/:id/mechanics/:changeId/reject", mechanicsHandler.RejectChange)   // Reject a proposed change with a reason (DM only)
 			
 			// REST API for real-time features
 			sessions.POST("/:id/chat", wsHandler.SendChatMessage)                 // Send chat message
+			sessions.GET("/:id/chat", chatHandler.GetMessages)                    // Chat history (?before=&limit=)
+			sessions.GET("/:id/chat/search", chatHandler.SearchMessages)          // Full-text chat search (?q=)
+			sessions.PUT("/:id/chat/:messageId", chatHandler.EditMessage)         // Edit a message (author or DM)
+			sessions.DELETE("/:id/chat/:messageId", chatHandler.DeleteMessage)    // Delete a message (author or DM)
 			sessions.POST("/:id/dice", wsHandler.RollDice)                        // Roll custom dice
 			sessions.POST("/:id/dice/:dice", wsHandler.RollQuickDice)             // Quick dice roll (d20, d6, etc.)
 			sessions.POST("/:id/character-update", wsHandler.UpdateCharacter)     // Broadcast character update
-			sessions.GET("/:id/ws/status", wsHandler.GetSessionStatus)            // Get WebSocket connection status
+			sessions.GET("/:id/ws/status", wsHandler.GetSessionStatus)            // Get connections, presence and ready check
+			
+			// Spectator links for streams
+			sessions.POST("/:id/spectators", spectatorHandler.CreateLink)         // Issue an expiring spectator link (DM only)
+			sessions.GET("/:id/spectators", spectatorHandler.ListLinks)           // Active spectator links (DM only)
+			sessions.DELETE("/:id/spectators/:linkId", spectatorHandler.RevokeLink) // Revoke a link and disconnect its spectators (DM only)
+		}
+		
+		// Read-only spectator access, authenticated by spectator link token (?token=)
+		spectate := api.Group("/spectate/:id", middleware.SpectatorAuthMiddleware(spectatorService))
+		{
+			spectate.GET("/ws", spectatorHandler.HandleWebSocket)                // Delayed feed of public chat, dice and map
+			spectate.GET("/overlay", spectatorHandler.GetOverlay)                // Current turn, party HP and recent rolls for OBS
+			spectate.GET("/map", spectatorHandler.GetMap)                        // Revealed parts of the battle map
 		}
 		
 		// WebSocket endpoint with custom auth (outside the auth group)
-		api.GET("/sessions/:id/ws", middleware.WebSocketAuthMiddleware(jwtService), wsHandler.HandleWebSocket)
+		api.GET("/sessions/:id/ws", middleware.WebSocketAuthMiddleware(jwtService), middleware.SessionMemberMiddleware(sessionService, campaignService), wsHandler.HandleWebSocket)
+	}
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
 	}
+	go func() {
+		log.Printf("Starting D&D Simulator server on :%s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	// Wait for a deploy or Ctrl-C, then shut down within the timeout: drain WebSocket
+	// clients first so they hear about the restart, then let in-flight requests such as AI
+	// calls finish, then stop the hub and disconnect from MongoDB
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-signals.Done()
+	stopSignals()
+	log.Println("Shutting down...")
 
-	log.Printf("Starting D&D Simulator server on :%s", cfg.Port)
-	r.Run(":" + cfg.Port)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error draining WebSocket hub: %v", err)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down HTTP server: %v", err)
+	}
+	stopHub()
+	if err := db.Close(shutdownCtx); err != nil {
+		log.Printf("Error disconnecting from MongoDB: %v", err)
+	}
+	log.Println("Server stopped")
 }
\ No newline at end of file
@@ -6,6 +6,7 @@ db.createCollection('users');
 db.createCollection('characters');
 db.createCollection('campaigns');
 db.createCollection('game_sessions');
+db.createCollection('chat_messages');
 
 // Create indexes for better performance
 db.users.createIndex({ "username": 1 }, { unique: true });
@@ -20,4 +21,7 @@ db.campaigns.createIndex({ "player_ids": 1 });
 db.game_sessions.createIndex({ "campaign_id": 1 });
 db.game_sessions.createIndex({ "player_ids": 1 });
 
+db.chat_messages.createIndex({ "session_id": 1, "_id": -1 });
+db.chat_messages.createIndex({ "session_id": 1, "message": "text" });
+
 print('Database initialized with collections and indexes');
\ No newline at end of file